@@ -1,22 +1,41 @@
 package config
 
 import (
+	"backend/models"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"text/template"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Kafka    KafkaConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Kafka      KafkaConfig
+	Validation ValidationConfig
+	Anomaly    AnomalyConfig
+	WebSocket  WebSocketConfig
+	Health     HealthConfig
+	Units      UnitsConfig
+	Output     OutputConfig
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Port         string
-	AllowOrigins []string
+	Port            string
+	AllowOrigins    []string      // Origins allowed to make cross-origin API requests
+	MachineRefresh  time.Duration // How often cached machine metadata is reloaded
+	MaxLookback     time.Duration // Longest "since" range accepted by statistics endpoints; 0 disables
+	ShutdownTimeout time.Duration // Deadline shared by all graceful shutdown steps
+	AdminToken      string        // Bearer token required by admin API endpoints; empty disables them
+	MaxBodyBytes    int64         // Largest accepted request body; 0 disables the limit
+	MaxPageSize     int           // Largest page a listing endpoint returns; larger limits are clamped
 }
 
 // DatabaseConfig holds database connection configuration
@@ -27,14 +46,164 @@ type DatabaseConfig struct {
 	User     string
 	Password string
 	SSLMode  string
+
+	ConnectMaxBackoff time.Duration // Longest wait between attempts to reach the database at startup
+
+	AlertBatchSize     int           // Alerts stored per write; 1 stores each alert as it is raised
+	AlertBatchInterval time.Duration // Longest an alert waits for its batch to fill before it is stored
+
+	ReplicaHost string // Read replica serving heavy read-only queries; empty sends them to the primary
+	ReplicaPort int
 }
 
 // KafkaConfig holds Kafka connection configuration
 type KafkaConfig struct {
-	Brokers     string
-	GroupID     string
-	Topics      []string
-	AutoOffset  string
+	Brokers    string
+	GroupID    string
+	Topics     []string
+	AutoOffset string
+	TopicLines map[string]string // Maps a topic to the production line its events belong to
+
+	MessageFormat     string            // Encoding of message values: json or avro
+	SchemaRegistryURL string            // Schema registry used to resolve Avro writer schemas
+	TopicFormats      map[string]string // Per-topic message encodings overriding MessageFormat
+
+	Workers         int // Workers processing consumed events in parallel across machines
+	WorkerQueueSize int // Events buffered per worker before consumption is paused
+
+	ConnectMaxBackoff time.Duration // Longest wait between attempts to connect to unreachable brokers
+	ErrorWindow       time.Duration // Identical consumer errors are reported once per window with a count; 0 reports each
+
+	RebalanceStrategy string        // How partitions are assigned across the group: RebalanceRange, RebalanceRoundRobin or RebalanceSticky
+	SessionTimeout    time.Duration // A member missing heartbeats this long is removed from the group
+	HeartbeatInterval time.Duration // How often members heartbeat; less than a third of the session timeout
+
+	PartitionCheck string // What a machine's events arriving on two partitions does: PartitionCheckOff, PartitionCheckWarn or PartitionCheckFail
+
+	StatusTopic string // Compacted topic receiving machine status changes, keyed by machine ID; empty disables
+}
+
+// Consumer group rebalance strategies
+const (
+	RebalanceRange      = "range"      // Contiguous partition ranges per topic
+	RebalanceRoundRobin = "roundrobin" // Partitions of all topics dealt out in turn
+	RebalanceSticky     = "sticky"     // Balanced like round robin, keeping existing assignments where possible
+)
+
+// Partition routing check modes. Detection state is per machine, so all of a machine's
+// events must be keyed by its ID onto one partition.
+const (
+	PartitionCheckOff  = "off"  // Not checked
+	PartitionCheckWarn = "warn" // Violations are reported as consumer errors
+	PartitionCheckFail = "fail" // The server exits on the first violation
+)
+
+// ValidationConfig holds rules applied to incoming events from any source
+type ValidationConfig struct {
+	MaxClockSkew time.Duration // How far ahead of server time an event timestamp may be
+
+	MachineIDCase    string         // Case folding applied to machine IDs: lower, upper or preserve
+	MachineIDPattern *regexp.Regexp // Machine IDs must match this after normalization
+
+	Statuses     []string // Accepted event statuses
+	IdleStatuses []string // Statuses of machines that are not producing: excluded from uptime and from threshold and trend detection
+
+	// Physically possible readings; events outside them are rejected as bad data. Unlike the
+	// anomaly thresholds, these do not raise alerts.
+	ConveyorSpeedBounds MetricBounds
+	TemperatureBounds   MetricBounds // In Celsius
+	RobotArmAngleBounds MetricBounds
+}
+
+// MetricBounds is an inclusive range of accepted metric values
+type MetricBounds struct {
+	Min float64
+	Max float64
+}
+
+// Contains reports whether value lies within the bounds
+func (b MetricBounds) Contains(value float64) bool {
+	return value >= b.Min && value <= b.Max
+}
+
+// WebSocketConfig holds WebSocket endpoint configuration
+type WebSocketConfig struct {
+	AllowedOrigins []string     // Origins permitted to open WebSocket connections
+	TrustedProxies []*net.IPNet // Proxies whose X-Forwarded-* headers are honoured
+	BackfillLimit  int          // Most stored events replayed to a client resuming from a cursor
+	AdminToken     string       // Token that grants clients the admin role; empty disables admin commands
+
+	CoalesceInterval time.Duration // Send at most one sensor event per machine per interval; 0 disables
+	AlertMinSeverity string        // Default lowest alert severity sent to clients; empty sends all
+	BroadcastPolicy  string        // BroadcastDrop or BroadcastBlock, when the hub is busy
+	MaxClients       int           // Most concurrent connections; further upgrades get 503. 0 is unlimited
+}
+
+// Broadcast policies for when the hub is not ready to take a broadcast
+const (
+	BroadcastDrop  = "drop"  // Drop the message and log it
+	BroadcastBlock = "block" // Wait for the hub, until it shuts down
+)
+
+// HealthConfig holds the uptime percentages that define system health status
+type HealthConfig struct {
+	DegradedUptime  float64       // Below this uptime percentage the system is degraded
+	UnhealthyUptime float64       // Below this uptime percentage the system is unhealthy
+	KafkaStaleAfter time.Duration // Readiness fails once the Kafka consumer has been without a session this long
+
+	UptimeCountsWarnings bool // Warning events count against uptime, like faults
+}
+
+// UnitsConfig holds the units used at the system boundaries. Storage is always Celsius.
+type UnitsConfig struct {
+	DisplayTemperature models.TemperatureUnit // Unit used in alert messages, thresholds and stats
+	IngestTemperature  models.TemperatureUnit // Unit in which incoming events report temperature
+	DisplayTimezone    *time.Location         // Zone of human-facing timestamps in responses; transport stays UTC
+}
+
+// OutputConfig holds how events are serialized to API and WebSocket clients
+type OutputConfig struct {
+	OmitMetrics   map[string][]string // Metric fields left out of events of each type; unlisted types emit all
+	RedactRawData []string            // raw_data keys left out of events in listings, exports and WebSocket messages
+}
+
+// AnomalyConfig holds anomaly detection configuration
+type AnomalyConfig struct {
+	OfflineTimeout   time.Duration          // Raise machine_offline after this long without events; 0 disables
+	WindowTTL        time.Duration          // Evict a machine's sliding window after this long without events; 0 disables
+	WindowSize       int                    // Number of recent events kept per machine
+	TrendMinEvents   int                    // Minimum events before trend detection runs
+	PatternMinEvents int                    // Minimum events before pattern detection runs
+	WarmupEvents     int                    // Events a machine's new window must see before trend and pattern alerts fire
+	WarmupDuration   time.Duration          // Time a machine's new window must exist before trend and pattern alerts fire
+	Pattern          PatternRule            // Repeated fault rule applied to every machine
+	PatternOverrides map[string]PatternRule // Repeated fault rules for specific machines
+	TrendMaxGap      time.Duration          // Skip trend detection when consecutive events are further apart; 0 disables
+	ResolveAfter     time.Duration          // Resolve threshold and status alerts once clear this long; 0 disables
+	TemperatureUnit  models.TemperatureUnit // Unit used when formatting temperatures in alert messages
+	IdleStatuses     []string               // Event statuses skipped by threshold and trend detection, see ValidationConfig
+	MessageTemplates map[string]string      // text/template alert messages by alert type, overriding the defaults
+	DedupWindow      time.Duration          // Suppress alerts with the same machine, type and message within this window; 0 disables
+	EventDedupWindow time.Duration          // Skip analyzing an event identical to one analyzed within this window; 0 disables
+	FaultTypes       map[string]FaultType   // Status alert handling by event type, overriding the built-in fault taxonomy
+	StatusDemotions  map[string]string      // Less severe status (warning or ok) analyzed in place of the reported one, by event type
+	RateInterval     time.Duration          // Interval over which each machine's events are counted for rate drop detection; 0 disables
+	RateBaseline     int                    // Completed intervals averaged into a machine's baseline rate
+	RateDropFraction float64                // Raise event_rate_drop when an interval's count falls below this fraction of the baseline
+}
+
+// FaultType describes how status alerts for an event type are raised. An empty field
+// keeps the default for the event's status.
+type FaultType struct {
+	Severity    string `json:"severity"`
+	Description string `json:"description"` // Used when the event reports no description of its own
+	Action      string `json:"action"`      // Recommended action for operators
+}
+
+// PatternRule raises repeated_faults when FaultLimit of a machine's last Lookback events are faults
+type PatternRule struct {
+	Lookback   int `json:"lookback"`
+	FaultLimit int `json:"fault_limit"`
 }
 
 // Load loads configuration from environment variables
@@ -44,13 +213,232 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid DB_PORT: %v", err)
 	}
 
+	dbConnectMaxBackoff, err := getDurationOrDefault("DB_CONNECT_MAX_BACKOFF", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	alertBatchSize, err := getIntOrDefault("ALERT_BATCH_SIZE", "50")
+	if err != nil {
+		return nil, err
+	}
+	// Each alert takes 8 of the 65535 parameters a Postgres statement allows
+	if alertBatchSize < 1 || alertBatchSize > 1000 {
+		return nil, fmt.Errorf("invalid ALERT_BATCH_SIZE: must be between 1 and 1000")
+	}
+	alertBatchInterval, err := getDurationOrDefault("ALERT_BATCH_INTERVAL", "500ms")
+	if err != nil {
+		return nil, err
+	}
+
+	dbReplicaPort, err := strconv.Atoi(getEnvOrDefault("DB_REPLICA_PORT", strconv.Itoa(dbPort)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_REPLICA_PORT: %v", err)
+	}
+
+	topicLines, err := parseKeyValueList(os.Getenv("KAFKA_TOPIC_LINES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KAFKA_TOPIC_LINES: %v", err)
+	}
+
+	topicFormats, err := parseKeyValueList(os.Getenv("KAFKA_TOPIC_FORMATS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KAFKA_TOPIC_FORMATS: %v", err)
+	}
+	for topic, format := range topicFormats {
+		topicFormats[topic] = strings.ToLower(format)
+	}
+
+	workers, err := getIntOrDefault("KAFKA_PROCESSING_WORKERS", "4")
+	if err != nil {
+		return nil, err
+	}
+	workerQueueSize, err := getIntOrDefault("KAFKA_WORKER_QUEUE_SIZE", "100")
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 || workerQueueSize < 1 {
+		return nil, fmt.Errorf("invalid KAFKA_PROCESSING_WORKERS/KAFKA_WORKER_QUEUE_SIZE: must be positive")
+	}
+
+	connectMaxBackoff, err := getDurationOrDefault("KAFKA_CONNECT_MAX_BACKOFF", "1m")
+	if err != nil {
+		return nil, err
+	}
+
+	errorWindow, err := getDurationOrDefault("KAFKA_ERROR_WINDOW", "10s")
+	if err != nil {
+		return nil, err
+	}
+
+	rebalanceStrategy := strings.ToLower(getEnvOrDefault("KAFKA_REBALANCE_STRATEGY", RebalanceRoundRobin))
+	if rebalanceStrategy != RebalanceRange && rebalanceStrategy != RebalanceRoundRobin && rebalanceStrategy != RebalanceSticky {
+		return nil, fmt.Errorf("invalid KAFKA_REBALANCE_STRATEGY: expected range, roundrobin or sticky")
+	}
+
+	partitionCheck := strings.ToLower(getEnvOrDefault("KAFKA_PARTITION_CHECK", PartitionCheckWarn))
+	if partitionCheck != PartitionCheckOff && partitionCheck != PartitionCheckWarn && partitionCheck != PartitionCheckFail {
+		return nil, fmt.Errorf("invalid KAFKA_PARTITION_CHECK: expected off, warn or fail")
+	}
+
+	sessionTimeout, err := getDurationOrDefault("KAFKA_SESSION_TIMEOUT", "20s")
+	if err != nil {
+		return nil, err
+	}
+	heartbeatInterval, err := getDurationOrDefault("KAFKA_HEARTBEAT_INTERVAL", "3s")
+	if err != nil {
+		return nil, err
+	}
+	// A member must be able to miss a couple of heartbeats before its session expires
+	if heartbeatInterval <= 0 || heartbeatInterval >= sessionTimeout/3 {
+		return nil, fmt.Errorf("invalid KAFKA_HEARTBEAT_INTERVAL: must be positive and less than a third of KAFKA_SESSION_TIMEOUT (%v)", sessionTimeout)
+	}
+
+	maxClockSkew, err := getDurationOrDefault("EVENT_MAX_CLOCK_SKEW", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	machineIDCase := strings.ToLower(getEnvOrDefault("MACHINE_ID_CASE", "preserve"))
+	if machineIDCase != "lower" && machineIDCase != "upper" && machineIDCase != "preserve" {
+		return nil, fmt.Errorf("invalid MACHINE_ID_CASE: expected lower, upper or preserve")
+	}
+
+	machineIDPattern, err := regexp.Compile(getEnvOrDefault("MACHINE_ID_PATTERN", `^[A-Za-z0-9][A-Za-z0-9_.-]{0,49}$`))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MACHINE_ID_PATTERN: %v", err)
+	}
+
+	statuses := splitList(getEnvOrDefault("EVENT_STATUSES", "ok,warning,fault"))
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("invalid EVENT_STATUSES: at least one status is required")
+	}
+
+	idleStatuses := splitList(getEnvOrDefault("EVENT_IDLE_STATUSES", "idle,setup"))
+	for _, status := range idleStatuses {
+		if status == "ok" || status == "warning" || status == "fault" {
+			return nil, fmt.Errorf("invalid EVENT_IDLE_STATUSES: %s cannot be an idle status", status)
+		}
+	}
+
+	speedBounds, err := loadMetricBounds("EVENT_CONVEYOR_SPEED", "0", "10")
+	if err != nil {
+		return nil, err
+	}
+
+	temperatureBounds, err := loadMetricBounds("EVENT_TEMPERATURE", "-50", "200")
+	if err != nil {
+		return nil, err
+	}
+
+	angleBounds, err := loadMetricBounds("EVENT_ROBOT_ARM_ANGLE", "0", "360")
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseNetworks(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	health, err := loadHealthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := loadUnitsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	anomaly, err := loadAnomalyConfig(machineIDCase)
+	if err != nil {
+		return nil, err
+	}
+	anomaly.TemperatureUnit = units.DisplayTemperature
+	anomaly.IdleStatuses = idleStatuses
+
+	omitMetrics, err := parseOmitMetrics(os.Getenv("EVENT_OMIT_METRICS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_OMIT_METRICS: %v", err)
+	}
+
+	backfillLimit, err := getIntOrDefault("WS_BACKFILL_LIMIT", "1000")
+	if err != nil {
+		return nil, err
+	}
+	if backfillLimit < 0 {
+		return nil, fmt.Errorf("invalid WS_BACKFILL_LIMIT: must not be negative")
+	}
+
+	coalesceInterval, err := getDurationOrDefault("WS_EVENT_COALESCE_INTERVAL", "0")
+	if err != nil {
+		return nil, err
+	}
+
+	alertMinSeverity := strings.ToLower(os.Getenv("WS_ALERT_MIN_SEVERITY"))
+	if _, ok := models.SeverityLevels[alertMinSeverity]; alertMinSeverity != "" && !ok {
+		return nil, fmt.Errorf("invalid WS_ALERT_MIN_SEVERITY: expected low, medium, high or critical")
+	}
+
+	broadcastPolicy := strings.ToLower(getEnvOrDefault("WS_BROADCAST_POLICY", BroadcastDrop))
+	if broadcastPolicy != BroadcastDrop && broadcastPolicy != BroadcastBlock {
+		return nil, fmt.Errorf("invalid WS_BROADCAST_POLICY: expected drop or block")
+	}
+
+	maxClients, err := getIntOrDefault("WS_MAX_CLIENTS", "1000")
+	if err != nil {
+		return nil, err
+	}
+	if maxClients < 0 {
+		return nil, fmt.Errorf("invalid WS_MAX_CLIENTS: must not be negative")
+	}
+
+	machineRefresh, err := getDurationOrDefault("MACHINE_CACHE_REFRESH", "5m")
+	if err != nil {
+		return nil, err
+	}
+
+	maxLookback, err := getDurationOrDefault("QUERY_MAX_LOOKBACK", "2160h")
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := getDurationOrDefault("SHUTDOWN_TIMEOUT", "30s")
+	if err != nil {
+		return nil, err
+	}
+
+	maxBodyBytes, err := getIntOrDefault("SERVER_MAX_BODY_BYTES", "1048576")
+	if err != nil {
+		return nil, err
+	}
+	if maxBodyBytes < 0 {
+		return nil, fmt.Errorf("invalid SERVER_MAX_BODY_BYTES: must not be negative")
+	}
+
+	maxPageSize, err := getIntOrDefault("API_MAX_PAGE_SIZE", "1000")
+	if err != nil {
+		return nil, err
+	}
+	if maxPageSize < 1 {
+		return nil, fmt.Errorf("invalid API_MAX_PAGE_SIZE: must be at least 1")
+	}
+
 	return &Config{
 		Server: ServerConfig{
 			Port: getEnvOrDefault("SERVER_PORT", "8080"),
 			AllowOrigins: []string{
 				getEnvOrDefault("FRONTEND_URL", "http://localhost:3000"),
 				"http://localhost:3000",
+				"https://8jmxm2bjvs.us-east-1.awsapprunner.com",
 			},
+			MachineRefresh:  machineRefresh,
+			MaxLookback:     maxLookback,
+			ShutdownTimeout: shutdownTimeout,
+			AdminToken:      os.Getenv("ADMIN_API_TOKEN"),
+			MaxBodyBytes:    int64(maxBodyBytes),
+			MaxPageSize:     maxPageSize,
 		},
 		Database: DatabaseConfig{
 			Host:     getEnvOrDefault("DB_HOST", "localhost"),
@@ -59,20 +447,252 @@ func Load() (*Config, error) {
 			User:     getEnvOrDefault("DB_USER", "factoryuser"),
 			Password: getEnvOrDefault("DB_PASSWORD", "factorypass"),
 			SSLMode:  getEnvOrDefault("DB_SSLMODE", "disable"),
+
+			ConnectMaxBackoff: dbConnectMaxBackoff,
+
+			AlertBatchSize:     alertBatchSize,
+			AlertBatchInterval: alertBatchInterval,
+
+			ReplicaHost: os.Getenv("DB_REPLICA_HOST"),
+			ReplicaPort: dbReplicaPort,
 		},
 		Kafka: KafkaConfig{
 			Brokers:    getEnvOrDefault("KAFKA_BROKERS", "localhost:9092"),
 			GroupID:    getEnvOrDefault("KAFKA_GROUP_ID", "factoryflow-backend"),
-			Topics:     []string{getEnvOrDefault("KAFKA_TOPIC", "line1.sensor")},
+			Topics:     splitList(getEnvOrDefault("KAFKA_TOPIC", "line1.sensor")),
 			AutoOffset: getEnvOrDefault("KAFKA_AUTO_OFFSET", "latest"),
+			TopicLines: topicLines,
+
+			MessageFormat:     strings.ToLower(getEnvOrDefault("KAFKA_MESSAGE_FORMAT", "json")),
+			SchemaRegistryURL: os.Getenv("SCHEMA_REGISTRY_URL"),
+			TopicFormats:      topicFormats,
+
+			Workers:         workers,
+			WorkerQueueSize: workerQueueSize,
+
+			ConnectMaxBackoff: connectMaxBackoff,
+			ErrorWindow:       errorWindow,
+
+			RebalanceStrategy: rebalanceStrategy,
+			SessionTimeout:    sessionTimeout,
+			HeartbeatInterval: heartbeatInterval,
+
+			PartitionCheck: partitionCheck,
+
+			StatusTopic: os.Getenv("KAFKA_STATUS_TOPIC"),
+		},
+		Validation: ValidationConfig{
+			MaxClockSkew: maxClockSkew,
+
+			MachineIDCase:    machineIDCase,
+			MachineIDPattern: machineIDPattern,
+
+			Statuses:     statuses,
+			IdleStatuses: idleStatuses,
+
+			ConveyorSpeedBounds: speedBounds,
+			TemperatureBounds:   temperatureBounds,
+			RobotArmAngleBounds: angleBounds,
+		},
+		Anomaly: anomaly,
+		WebSocket: WebSocketConfig{
+			AllowedOrigins: splitList(getEnvOrDefault("WS_ALLOWED_ORIGINS", "https://8jmxm2bjvs.us-east-1.awsapprunner.com")),
+			TrustedProxies: trustedProxies,
+			BackfillLimit:  backfillLimit,
+			AdminToken:     os.Getenv("WS_ADMIN_TOKEN"),
+
+			CoalesceInterval: coalesceInterval,
+			AlertMinSeverity: alertMinSeverity,
+			BroadcastPolicy:  broadcastPolicy,
+			MaxClients:       maxClients,
+		},
+		Health: health,
+		Units:  units,
+		Output: OutputConfig{
+			OmitMetrics:   omitMetrics,
+			RedactRawData: splitList(os.Getenv("EVENT_REDACT_RAW_DATA")),
 		},
 	}, nil
 }
 
+// loadUnitsConfig loads the display and ingest units from environment variables
+func loadUnitsConfig() (UnitsConfig, error) {
+	var cfg UnitsConfig
+	var err error
+
+	if cfg.DisplayTemperature, err = models.ParseTemperatureUnit(getEnvOrDefault("TEMPERATURE_DISPLAY_UNIT", "C")); err != nil {
+		return cfg, fmt.Errorf("invalid TEMPERATURE_DISPLAY_UNIT: %v", err)
+	}
+	if cfg.IngestTemperature, err = models.ParseTemperatureUnit(getEnvOrDefault("TEMPERATURE_INGEST_UNIT", "C")); err != nil {
+		return cfg, fmt.Errorf("invalid TEMPERATURE_INGEST_UNIT: %v", err)
+	}
+	if cfg.DisplayTimezone, err = time.LoadLocation(getEnvOrDefault("DISPLAY_TIMEZONE", "UTC")); err != nil {
+		return cfg, fmt.Errorf("invalid DISPLAY_TIMEZONE: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// loadHealthConfig loads system health thresholds from environment variables
+func loadHealthConfig() (HealthConfig, error) {
+	var cfg HealthConfig
+	var err error
+
+	if cfg.DegradedUptime, err = getFloatOrDefault("HEALTH_DEGRADED_UPTIME", "95"); err != nil {
+		return cfg, err
+	}
+	if cfg.UnhealthyUptime, err = getFloatOrDefault("HEALTH_UNHEALTHY_UPTIME", "90"); err != nil {
+		return cfg, err
+	}
+	if cfg.KafkaStaleAfter, err = getDurationOrDefault("HEALTH_KAFKA_STALE_AFTER", "1m"); err != nil {
+		return cfg, err
+	}
+	if cfg.UptimeCountsWarnings, err = strconv.ParseBool(getEnvOrDefault("HEALTH_UPTIME_COUNTS_WARNINGS", "false")); err != nil {
+		return cfg, fmt.Errorf("invalid HEALTH_UPTIME_COUNTS_WARNINGS: %v", err)
+	}
+
+	if cfg.DegradedUptime > 100 || cfg.UnhealthyUptime < 0 || cfg.DegradedUptime <= cfg.UnhealthyUptime {
+		return cfg, fmt.Errorf("invalid health thresholds: require 0 <= HEALTH_UNHEALTHY_UPTIME < HEALTH_DEGRADED_UPTIME <= 100")
+	}
+
+	return cfg, nil
+}
+
+// loadAnomalyConfig loads anomaly detection settings from environment variables. Machine
+// IDs in per-machine settings are normalized by machineIDCase, as event machine IDs are.
+func loadAnomalyConfig(machineIDCase string) (AnomalyConfig, error) {
+	var cfg AnomalyConfig
+	var err error
+
+	if cfg.OfflineTimeout, err = getDurationOrDefault("ANOMALY_OFFLINE_TIMEOUT", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.WindowTTL, err = getDurationOrDefault("ANOMALY_WINDOW_TTL", "24h"); err != nil {
+		return cfg, err
+	}
+	if cfg.WindowSize, err = getIntOrDefault("ANOMALY_WINDOW_SIZE", "50"); err != nil {
+		return cfg, err
+	}
+	if cfg.TrendMinEvents, err = getIntOrDefault("ANOMALY_TREND_MIN_EVENTS", "5"); err != nil {
+		return cfg, err
+	}
+	if cfg.PatternMinEvents, err = getIntOrDefault("ANOMALY_PATTERN_MIN_EVENTS", "10"); err != nil {
+		return cfg, err
+	}
+	if cfg.WarmupEvents, err = getIntOrDefault("ANOMALY_WARMUP_EVENTS", "10"); err != nil {
+		return cfg, err
+	}
+	if cfg.WarmupDuration, err = getDurationOrDefault("ANOMALY_WARMUP_DURATION", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.Pattern.Lookback, err = getIntOrDefault("ANOMALY_PATTERN_LOOKBACK", "20"); err != nil {
+		return cfg, err
+	}
+	if cfg.Pattern.FaultLimit, err = getIntOrDefault("ANOMALY_REPEATED_FAULT_LIMIT", "3"); err != nil {
+		return cfg, err
+	}
+	if cfg.PatternOverrides, err = parsePatternOverrides(os.Getenv("ANOMALY_PATTERN_OVERRIDES"), machineIDCase); err != nil {
+		return cfg, fmt.Errorf("invalid ANOMALY_PATTERN_OVERRIDES: %v", err)
+	}
+	if cfg.TrendMaxGap, err = getDurationOrDefault("ANOMALY_TREND_MAX_GAP", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.ResolveAfter, err = getDurationOrDefault("ANOMALY_RESOLVE_AFTER", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.DedupWindow, err = getDurationOrDefault("ANOMALY_DEDUP_WINDOW", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.EventDedupWindow, err = getDurationOrDefault("ANOMALY_EVENT_DEDUP_WINDOW", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.RateInterval, err = getDurationOrDefault("ANOMALY_RATE_INTERVAL", "0"); err != nil {
+		return cfg, err
+	}
+	if cfg.RateBaseline, err = getIntOrDefault("ANOMALY_RATE_BASELINE_INTERVALS", "10"); err != nil {
+		return cfg, err
+	}
+	if cfg.RateDropFraction, err = getFloatOrDefault("ANOMALY_RATE_DROP_FRACTION", "0.5"); err != nil {
+		return cfg, err
+	}
+
+	if cfg.MessageTemplates, err = loadAlertTemplates(os.Getenv("ALERT_TEMPLATES_FILE")); err != nil {
+		return cfg, fmt.Errorf("invalid ALERT_TEMPLATES_FILE: %v", err)
+	}
+	if cfg.FaultTypes, err = loadFaultTypes(os.Getenv("FAULT_TYPES_FILE")); err != nil {
+		return cfg, fmt.Errorf("invalid FAULT_TYPES_FILE: %v", err)
+	}
+	if cfg.StatusDemotions, err = parseStatusDemotions(os.Getenv("ANOMALY_STATUS_DEMOTIONS")); err != nil {
+		return cfg, fmt.Errorf("invalid ANOMALY_STATUS_DEMOTIONS: %v", err)
+	}
+
+	if cfg.WindowSize < 1 {
+		return cfg, fmt.Errorf("invalid ANOMALY_WINDOW_SIZE: must be positive")
+	}
+	// Trend detection compares against the event 5 readings back, so it needs at least 5
+	if cfg.TrendMinEvents < 5 || cfg.TrendMinEvents > cfg.WindowSize {
+		return cfg, fmt.Errorf("invalid ANOMALY_TREND_MIN_EVENTS: must be between 5 and the window size (%d)", cfg.WindowSize)
+	}
+	if cfg.WarmupEvents < 0 || cfg.WarmupDuration < 0 {
+		return cfg, fmt.Errorf("invalid ANOMALY_WARMUP_EVENTS or ANOMALY_WARMUP_DURATION: must not be negative")
+	}
+	if cfg.RateBaseline < 1 {
+		return cfg, fmt.Errorf("invalid ANOMALY_RATE_BASELINE_INTERVALS: must be positive")
+	}
+	if cfg.RateDropFraction <= 0 || cfg.RateDropFraction >= 1 {
+		return cfg, fmt.Errorf("invalid ANOMALY_RATE_DROP_FRACTION: must be between 0 and 1 (exclusive)")
+	}
+	if cfg.PatternMinEvents < 1 || cfg.PatternMinEvents > cfg.WindowSize {
+		return cfg, fmt.Errorf("invalid ANOMALY_PATTERN_MIN_EVENTS: must be between 1 and the window size (%d)", cfg.WindowSize)
+	}
+	if err := cfg.Pattern.validate(cfg.WindowSize); err != nil {
+		return cfg, fmt.Errorf("invalid ANOMALY_PATTERN_LOOKBACK or ANOMALY_REPEATED_FAULT_LIMIT: %v", err)
+	}
+	for machineID, rule := range cfg.PatternOverrides {
+		if err := rule.validate(cfg.WindowSize); err != nil {
+			return cfg, fmt.Errorf("invalid ANOMALY_PATTERN_OVERRIDES for %s: %v", machineID, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadMetricBounds loads the accepted range of a metric from the <prefix>_MIN and
+// <prefix>_MAX environment variables
+func loadMetricBounds(prefix, defaultMin, defaultMax string) (MetricBounds, error) {
+	var bounds MetricBounds
+	var err error
+
+	if bounds.Min, err = getFloatOrDefault(prefix+"_MIN", defaultMin); err != nil {
+		return bounds, err
+	}
+	if bounds.Max, err = getFloatOrDefault(prefix+"_MAX", defaultMax); err != nil {
+		return bounds, err
+	}
+	if bounds.Min >= bounds.Max {
+		return bounds, fmt.Errorf("invalid %s_MIN/%s_MAX: minimum must be below maximum", prefix, prefix)
+	}
+	return bounds, nil
+}
+
 // GetDatabaseURL returns formatted database connection URL
 func (c *Config) GetDatabaseURL() string {
+	return c.databaseURL(c.Database.Host, c.Database.Port)
+}
+
+// GetReplicaURL returns the read replica connection URL, or "" when none is configured.
+// The replica shares the primary's database name, credentials and SSL mode.
+func (c *Config) GetReplicaURL() string {
+	if c.Database.ReplicaHost == "" {
+		return ""
+	}
+	return c.databaseURL(c.Database.ReplicaHost, c.Database.ReplicaPort)
+}
+
+// databaseURL formats a connection URL for the given server
+func (c *Config) databaseURL(host string, port int) string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Database.Host, c.Database.Port, c.Database.User,
+		host, port, c.Database.User,
 		c.Database.Password, c.Database.Name, c.Database.SSLMode)
 }
 
@@ -82,4 +702,223 @@ func getEnvOrDefault(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getIntOrDefault parses an integer from an environment variable
+func getIntOrDefault(key, defaultValue string) (int, error) {
+	value, err := strconv.Atoi(getEnvOrDefault(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// getFloatOrDefault parses a float from an environment variable
+func getFloatOrDefault(key, defaultValue string) (float64, error) {
+	value, err := strconv.ParseFloat(getEnvOrDefault(key, defaultValue), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return value, nil
+}
+
+// getDurationOrDefault parses a non-negative duration from an environment variable
+func getDurationOrDefault(key, defaultValue string) (time.Duration, error) {
+	duration, err := time.ParseDuration(getEnvOrDefault(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("invalid %s: must not be negative", key)
+	}
+	return duration, nil
+}
+
+// loadAlertTemplates reads a JSON object mapping alert types to message templates.
+// An empty path means no overrides.
+func loadAlertTemplates(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates map[string]string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, err
+	}
+	for alertType, text := range templates {
+		if _, err := template.New(alertType).Parse(text); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// loadFaultTypes reads a JSON object mapping event types to fault types. An empty path
+// means no overrides.
+func loadFaultTypes(path string) (map[string]FaultType, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var faultTypes map[string]FaultType
+	if err := json.Unmarshal(data, &faultTypes); err != nil {
+		return nil, err
+	}
+	for eventType, faultType := range faultTypes {
+		faultType.Severity = strings.ToLower(faultType.Severity)
+		if _, ok := models.SeverityLevels[faultType.Severity]; faultType.Severity != "" && !ok {
+			return nil, fmt.Errorf("invalid severity %q for %s", faultType.Severity, eventType)
+		}
+		faultTypes[eventType] = faultType
+	}
+	return faultTypes, nil
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseNetworks parses a comma-separated list of CIDR ranges or bare IP addresses
+func parseNetworks(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range splitList(value) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseKeyValueList parses a comma-separated list of key=value pairs
+func parseKeyValueList(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range splitList(value) {
+		key, val, ok := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		result[key] = val
+	}
+	return result, nil
+}
+
+// parseStatusDemotions parses a comma-separated list of event_type=status pairs, e.g.
+// maintenance_due=warning, where status is warning or ok
+func parseStatusDemotions(value string) (map[string]string, error) {
+	demotions, err := parseKeyValueList(value)
+	if err != nil {
+		return nil, err
+	}
+	for eventType, status := range demotions {
+		status = strings.ToLower(status)
+		if status != "warning" && status != "ok" {
+			return nil, fmt.Errorf("invalid status %q for %s (expected warning or ok)", status, eventType)
+		}
+		demotions[eventType] = status
+	}
+	return demotions, nil
+}
+
+// parsePatternOverrides parses a comma-separated list of machine_id=faults/lookback pairs,
+// e.g. conveyor_001=2/10 to alert on 2 faults within the last 10 events. Machine IDs are
+// normalized by machineIDCase.
+func parsePatternOverrides(value, machineIDCase string) (map[string]PatternRule, error) {
+	pairs, err := parseKeyValueList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]PatternRule, len(pairs))
+	for machineID, spec := range pairs {
+		faults, lookback, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("expected faults/lookback for %s, got %q", machineID, spec)
+		}
+
+		var rule PatternRule
+		if rule.FaultLimit, err = strconv.Atoi(strings.TrimSpace(faults)); err != nil {
+			return nil, fmt.Errorf("invalid fault count for %s: %v", machineID, err)
+		}
+		if rule.Lookback, err = strconv.Atoi(strings.TrimSpace(lookback)); err != nil {
+			return nil, fmt.Errorf("invalid lookback for %s: %v", machineID, err)
+		}
+
+		normalized := NormalizeMachineID(machineID, machineIDCase)
+		if _, exists := result[normalized]; exists {
+			return nil, fmt.Errorf("duplicate rule for machine %s", normalized)
+		}
+		result[normalized] = rule
+	}
+	return result, nil
+}
+
+// NormalizeMachineID returns the canonical form of a machine ID under a MACHINE_ID_CASE
+// setting: trimmed, then folded to lower or upper case unless preserved
+func NormalizeMachineID(machineID, machineIDCase string) string {
+	machineID = strings.TrimSpace(machineID)
+	switch machineIDCase {
+	case "lower":
+		return strings.ToLower(machineID)
+	case "upper":
+		return strings.ToUpper(machineID)
+	default:
+		return machineID
+	}
+}
+
+// validate checks that the rule looks back no further than the window holds and that
+// its fault limit can be reached within the lookback
+func (r PatternRule) validate(windowSize int) error {
+	if r.Lookback < 1 || r.Lookback > windowSize {
+		return fmt.Errorf("lookback must be between 1 and the window size (%d)", windowSize)
+	}
+	if r.FaultLimit < 1 || r.FaultLimit > r.Lookback {
+		return fmt.Errorf("fault limit must be between 1 and the lookback (%d)", r.Lookback)
+	}
+	return nil
+}
+
+// parseOmitMetrics parses a comma-separated list of event_type=field|field pairs
+func parseOmitMetrics(value string) (map[string][]string, error) {
+	pairs, err := parseKeyValueList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(pairs))
+	for eventType, fields := range pairs {
+		for _, field := range strings.Split(fields, "|") {
+			if field = strings.TrimSpace(field); field != "" {
+				result[eventType] = append(result[eventType], field)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePatternOverridesNormalizesMachineIDs(t *testing.T) {
+	overrides, err := parsePatternOverrides("Conveyor_001=2/10, ROBOT_002 = 1/5", "lower")
+	if err != nil {
+		t.Fatalf("parsePatternOverrides: %v", err)
+	}
+
+	want := map[string]PatternRule{
+		"conveyor_001": {Lookback: 10, FaultLimit: 2},
+		"robot_002":    {Lookback: 5, FaultLimit: 1},
+	}
+	if !reflect.DeepEqual(overrides, want) {
+		t.Errorf("overrides = %v, want %v", overrides, want)
+	}
+}
+
+func TestParsePatternOverridesPreservesCase(t *testing.T) {
+	overrides, err := parsePatternOverrides("Conveyor_001=2/10", "preserve")
+	if err != nil {
+		t.Fatalf("parsePatternOverrides: %v", err)
+	}
+	if _, ok := overrides["Conveyor_001"]; !ok {
+		t.Errorf("overrides = %v, want Conveyor_001 kept as given", overrides)
+	}
+}
+
+func TestParsePatternOverridesRejectsNormalizedDuplicates(t *testing.T) {
+	if _, err := parsePatternOverrides("conveyor_001=2/10,CONVEYOR_001=3/10", "lower"); err == nil {
+		t.Error("parsePatternOverrides accepted two rules for the same normalized machine")
+	}
+}
+
+func TestNormalizeMachineID(t *testing.T) {
+	for _, tc := range []struct{ id, idCase, want string }{
+		{" Sensor_Hub_001 ", "lower", "sensor_hub_001"},
+		{"sensor_hub_001", "upper", "SENSOR_HUB_001"},
+		{" Sensor_Hub_001", "preserve", "Sensor_Hub_001"},
+	} {
+		if got := NormalizeMachineID(tc.id, tc.idCase); got != tc.want {
+			t.Errorf("NormalizeMachineID(%q, %s) = %q, want %q", tc.id, tc.idCase, got, tc.want)
+		}
+	}
+}
+
+// loadDefaults loads the configuration with the given variables left empty, so their
+// defaults apply
+func loadDefaults(t *testing.T, names ...string) *Config {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestEventDedupOffByDefault(t *testing.T) {
+	cfg := loadDefaults(t, "ANOMALY_EVENT_DEDUP_WINDOW")
+	if cfg.Anomaly.EventDedupWindow != 0 {
+		t.Errorf("event dedup window = %s, want 0 (off)", cfg.Anomaly.EventDedupWindow)
+	}
+}
+
+func TestOfflineWatchdogAndCaseFoldingOffByDefault(t *testing.T) {
+	cfg := loadDefaults(t, "ANOMALY_OFFLINE_TIMEOUT", "MACHINE_ID_CASE")
+	if cfg.Anomaly.OfflineTimeout != 0 {
+		t.Errorf("offline timeout = %s, want 0 (off)", cfg.Anomaly.OfflineTimeout)
+	}
+	if cfg.Validation.MachineIDCase != "preserve" {
+		t.Errorf("machine ID case = %s, want preserve", cfg.Validation.MachineIDCase)
+	}
+}
+
+func TestAutoResolveAndTrendGapOffByDefault(t *testing.T) {
+	cfg := loadDefaults(t, "ANOMALY_RESOLVE_AFTER", "ANOMALY_TREND_MAX_GAP")
+	if cfg.Anomaly.ResolveAfter != 0 || cfg.Anomaly.TrendMaxGap != 0 {
+		t.Errorf("resolve after = %s, trend max gap = %s, want both 0 (off)", cfg.Anomaly.ResolveAfter, cfg.Anomaly.TrendMaxGap)
+	}
+}
+
+func TestRateDropDetectionOffByDefault(t *testing.T) {
+	cfg := loadDefaults(t, "ANOMALY_RATE_INTERVAL")
+	if cfg.Anomaly.RateInterval != 0 {
+		t.Errorf("rate interval = %s, want 0 (off)", cfg.Anomaly.RateInterval)
+	}
+}
+
+func TestAlertTemplatesLoadedFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	os.WriteFile(path, []byte(`{"temperature_high": "Hot: {{.Value}}"}`), 0o600)
+	t.Setenv("ALERT_TEMPLATES_FILE", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Anomaly.MessageTemplates["temperature_high"]; got != "Hot: {{.Value}}" {
+		t.Errorf("temperature_high template = %q, want the file's", got)
+	}
+
+	os.WriteFile(path, []byte(`{"temperature_high": "Hot: {{.Value"}`), 0o600)
+	if _, err := Load(); err == nil {
+		t.Error("unparseable template accepted")
+	}
+}
+
+func TestShutdownTimeoutDefaultsToThirtySeconds(t *testing.T) {
+	cfg := loadDefaults(t, "SHUTDOWN_TIMEOUT")
+	if cfg.Server.ShutdownTimeout != 30*time.Second {
+		t.Errorf("shutdown timeout = %s, want 30s", cfg.Server.ShutdownTimeout)
+	}
+}
+
+func TestDetectionMinimumsMustFitWindow(t *testing.T) {
+	for _, name := range []string{"ANOMALY_TREND_MIN_EVENTS", "ANOMALY_PATTERN_MIN_EVENTS"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("ANOMALY_WINDOW_SIZE", "20")
+			t.Setenv(name, "20")
+			if _, err := Load(); err != nil {
+				t.Fatalf("minimum equal to the window size rejected: %v", err)
+			}
+
+			t.Setenv(name, "21")
+			if _, err := Load(); err == nil {
+				t.Error("minimum above the window size accepted")
+			}
+		})
+	}
+}
+
+func TestHealthThresholdsMustBeOrdered(t *testing.T) {
+	t.Setenv("HEALTH_DEGRADED_UPTIME", "80")
+	t.Setenv("HEALTH_UNHEALTHY_UPTIME", "60")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Health.DegradedUptime != 80 || cfg.Health.UnhealthyUptime != 60 {
+		t.Errorf("thresholds = %g/%g, want 80/60", cfg.Health.DegradedUptime, cfg.Health.UnhealthyUptime)
+	}
+
+	t.Setenv("HEALTH_UNHEALTHY_UPTIME", "80")
+	if _, err := Load(); err == nil {
+		t.Error("degraded threshold equal to the unhealthy one accepted")
+	}
+}
+
+func TestValidationBoundsLoadedFromEnvironment(t *testing.T) {
+	t.Setenv("EVENT_TEMPERATURE_MIN", "-10")
+	t.Setenv("EVENT_TEMPERATURE_MAX", "120")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := (MetricBounds{Min: -10, Max: 120}); cfg.Validation.TemperatureBounds != want {
+		t.Errorf("temperature bounds = %+v, want %+v", cfg.Validation.TemperatureBounds, want)
+	}
+	if want := (MetricBounds{Min: 0, Max: 10}); cfg.Validation.ConveyorSpeedBounds != want {
+		t.Errorf("conveyor speed bounds = %+v, want the default %+v", cfg.Validation.ConveyorSpeedBounds, want)
+	}
+
+	t.Setenv("EVENT_TEMPERATURE_MIN", "120")
+	if _, err := Load(); err == nil {
+		t.Error("minimum equal to the maximum accepted")
+	}
+}
+
+func TestFaultTypesLoadedFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fault_types.json")
+	os.WriteFile(path, []byte(`{"vision_reject": {"severity": "Critical", "description": "Camera rejected the part"}}`), 0o600)
+	t.Setenv("FAULT_TYPES_FILE", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := (FaultType{Severity: "critical", Description: "Camera rejected the part"}); cfg.Anomaly.FaultTypes["vision_reject"] != want {
+		t.Errorf("vision_reject = %+v, want %+v", cfg.Anomaly.FaultTypes["vision_reject"], want)
+	}
+
+	os.WriteFile(path, []byte(`{"vision_reject": {"severity": "urgent"}}`), 0o600)
+	if _, err := Load(); err == nil {
+		t.Error("unknown severity accepted")
+	}
+}
+
+func TestRebalanceStrategyAndHeartbeatValidated(t *testing.T) {
+	cfg := loadDefaults(t, "KAFKA_REBALANCE_STRATEGY", "KAFKA_SESSION_TIMEOUT", "KAFKA_HEARTBEAT_INTERVAL")
+	if cfg.Kafka.RebalanceStrategy != RebalanceRoundRobin || cfg.Kafka.SessionTimeout != 20*time.Second || cfg.Kafka.HeartbeatInterval != 3*time.Second {
+		t.Errorf("kafka = %s, %s, %s, want roundrobin, 20s and 3s by default", cfg.Kafka.RebalanceStrategy, cfg.Kafka.SessionTimeout, cfg.Kafka.HeartbeatInterval)
+	}
+
+	t.Setenv("KAFKA_REBALANCE_STRATEGY", "Sticky")
+	if cfg, err := Load(); err != nil || cfg.Kafka.RebalanceStrategy != RebalanceSticky {
+		t.Errorf("Load = %v, want the sticky strategy", err)
+	}
+	t.Setenv("KAFKA_REBALANCE_STRATEGY", "cooperative")
+	if _, err := Load(); err == nil {
+		t.Error("unknown rebalance strategy accepted")
+	}
+	t.Setenv("KAFKA_REBALANCE_STRATEGY", "")
+
+	t.Setenv("KAFKA_SESSION_TIMEOUT", "30s")
+	t.Setenv("KAFKA_HEARTBEAT_INTERVAL", "9s")
+	if _, err := Load(); err != nil {
+		t.Errorf("heartbeat under a third of the session timeout rejected: %v", err)
+	}
+	t.Setenv("KAFKA_HEARTBEAT_INTERVAL", "10s")
+	if _, err := Load(); err == nil {
+		t.Error("heartbeat of a third of the session timeout accepted")
+	}
+}
+
+func TestReplicaURLEmptyUnlessReplicaConfigured(t *testing.T) {
+	cfg := loadDefaults(t, "DB_REPLICA_HOST", "DB_REPLICA_PORT")
+	if url := cfg.GetReplicaURL(); url != "" {
+		t.Errorf("replica URL = %q with no replica host, want empty", url)
+	}
+
+	t.Setenv("DB_REPLICA_HOST", "replica.internal")
+	t.Setenv("DB_PORT", "5433")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := strings.Replace(cfg.GetDatabaseURL(), "host="+cfg.Database.Host+" ", "host=replica.internal ", 1)
+	if url := cfg.GetReplicaURL(); url != want {
+		t.Errorf("replica URL = %q, want %q on the primary's port", url, want)
+	}
+}
+
+func TestStatusDemotionsParsed(t *testing.T) {
+	demotions, err := parseStatusDemotions("maintenance_due=Warning, calibration=ok")
+	if err != nil {
+		t.Fatalf("parseStatusDemotions: %v", err)
+	}
+	if want := map[string]string{"maintenance_due": "warning", "calibration": "ok"}; !reflect.DeepEqual(demotions, want) {
+		t.Errorf("demotions = %v, want %v", demotions, want)
+	}
+
+	if _, err := parseStatusDemotions("maintenance_due=fault"); err == nil {
+		t.Error("demotion to fault accepted")
+	}
+}
@@ -2,36 +2,132 @@ package database
 
 import (
 	"backend/models"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// DB wraps the database connection
+// ErrNotFound is returned when an update targets a row that does not exist
+var ErrNotFound = errors.New("not found")
+
+// eventColumns is the column list scanned by scanEvents
+const eventColumns = `id, timestamp, machine_id, sensor_type, conveyor_speed, temperature, robot_arm_angle,
+	status, line, fault_code, raw_data, created_at`
+
+// alertColumns is the column list scanned by scanAlerts
+const alertColumns = `id, event_id, machine_id, alert_type, severity, message, confidence, recommended_action,
+	acknowledged, created_at, acknowledged_at, acknowledged_by, acknowledgement_note, resolved_at, test`
+
+// initialConnectBackoff is the wait after the first failed connection attempt
+const initialConnectBackoff = time.Second
+
+// DB wraps the database connection. Writes and reads that must see them go to the
+// primary pool; heavy read-only queries go to the replica pool when one is configured.
 type DB struct {
 	*sql.DB
+	replica   *sql.DB     // Read replica pool; nil sends all queries to the primary
+	connected atomic.Bool // Set once the database has first been reached
 }
 
-// New creates a new database connection
-func New(databaseURL string) (*DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// New creates the connection pools without connecting, so the server can start while the
+// database is unreachable; call Connect to wait until it can be reached. An empty
+// replicaURL sends read queries to the primary.
+func New(databaseURL, replicaURL string) (*DB, error) {
+	primary, err := openPool(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
+	db := &DB{DB: primary}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+	if replicaURL != "" {
+		if db.replica, err = openPool(replicaURL); err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("failed to open read replica: %v", err)
+		}
+	}
+	return db, nil
+}
+
+// openPool opens and configures a connection pool
+func openPool(databaseURL string) (*sql.DB, error) {
+	pool, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	pool.SetMaxOpenConns(25)
+	pool.SetMaxIdleConns(25)
+	pool.SetConnMaxLifetime(5 * time.Minute)
+
+	return pool, nil
+}
+
+// reader returns the pool for read-only queries that tolerate replication lag: event
+// listings, stats, trends, exports and the audit log. Writes, and reads operators act on
+// right after a write (unacknowledged and current alerts, single alerts, snoozes, rules,
+// parameters, machines and resumed event streams), stay on the primary.
+func (db *DB) reader() *sql.DB {
+	if db.replica != nil {
+		return db.replica
+	}
+	return db.DB
+}
+
+// HasReplica reports whether a read replica is configured
+func (db *DB) HasReplica() bool {
+	return db.replica != nil
+}
+
+// PingReplica checks the read replica, or the primary when none is configured
+func (db *DB) PingReplica(ctx context.Context) error {
+	return db.reader().PingContext(ctx)
+}
+
+// Close closes the primary and replica pools
+func (db *DB) Close() error {
+	if db.replica != nil {
+		if err := db.replica.Close(); err != nil {
+			log.Printf("Failed to close read replica: %v", err)
+		}
+	}
+	return db.DB.Close()
+}
+
+// Connect blocks until the database answers a ping, retrying failed attempts with
+// exponential backoff up to maxBackoff. It returns the context's error if ctx is done first.
+func (db *DB) Connect(ctx context.Context, maxBackoff time.Duration) error {
+	backoff := initialConnectBackoff
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			db.connected.Store(true)
+			return nil
+		}
+
+		log.Printf("Database unavailable, retrying in %s: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, max(maxBackoff, initialConnectBackoff))
+	}
+}
 
-	return &DB{db}, nil
+// Connected reports whether the database has been reached since startup
+func (db *DB) Connected() bool {
+	return db.connected.Load()
 }
 
 // InsertEvent inserts a new event into the database
@@ -42,47 +138,352 @@ func (db *DB) InsertEvent(event *models.SensorEvent) (*models.Event, error) {
 	}
 
 	query := `
-		INSERT INTO events (timestamp, machine_id, sensor_type, conveyor_speed, temperature, robot_arm_angle, status, raw_data)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, timestamp, machine_id, sensor_type, conveyor_speed, temperature, robot_arm_angle, status, raw_data, created_at
-	`
+		INSERT INTO events (timestamp, machine_id, sensor_type, conveyor_speed, temperature, robot_arm_angle, status, line, fault_code, raw_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10)
+		RETURNING ` + eventColumns
 
 	var dbEvent models.Event
 	var rawDataBytes []byte
 
 	err = db.QueryRow(query, event.Timestamp, event.MachineID, event.EventType,
-		event.ConveyorSpeed, event.Temperature, event.RobotArmAngle, event.Status, rawDataJSON).Scan(
+		event.ConveyorSpeed, event.Temperature, event.RobotArmAngle, event.Status, event.Line, event.FaultCode, rawDataJSON).Scan(
 		&dbEvent.ID, &dbEvent.Timestamp, &dbEvent.MachineID, &dbEvent.SensorType,
 		&dbEvent.ConveyorSpeed, &dbEvent.Temperature, &dbEvent.RobotArmAngle,
-		&dbEvent.Status, &rawDataBytes, &dbEvent.CreatedAt)
+		&dbEvent.Status, &dbEvent.Line, &dbEvent.FaultCode, &rawDataBytes, &dbEvent.CreatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert event: %v", err)
 	}
 
-	if err := json.Unmarshal(rawDataBytes, &dbEvent.RawData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal raw data: %v", err)
-	}
+	decodeRawData(&dbEvent, rawDataBytes)
 
 	return &dbEvent, nil
 }
 
-// GetRecentEvents retrieves recent events with pagination
-func (db *DB) GetRecentEvents(limit, offset int, machineID string) ([]models.Event, error) {
+// decodeRawData unmarshals stored raw_data onto the event. Malformed JSON (possible from
+// external writers) yields an empty map and a warning instead of failing the whole query.
+func decodeRawData(event *models.Event, rawDataBytes []byte) {
+	event.RawData = make(map[string]interface{})
+	if len(rawDataBytes) == 0 {
+		return
+	}
+
+	if err := json.Unmarshal(rawDataBytes, &event.RawData); err != nil {
+		log.Printf("Event %d has malformed raw_data: %v", event.ID, err)
+		event.RawData = make(map[string]interface{})
+		event.Warnings = append(event.Warnings, fmt.Sprintf("raw_data could not be decoded: %v", err))
+	}
+}
+
+// EventFilter selects a page of events. When Cursor is set, the page starts after the
+// cursor position and Offset should be zero.
+type EventFilter struct {
+	MachineID string
+	Line      string
+	Area      string // Restricts to machines registered in this area
+	FaultCode string // Canonical fault code, see models.ParseFaultCode
+	Limit     int
+	Offset    int
+	Cursor    *EventCursor
+}
+
+// EventCursor identifies a position in the (timestamp DESC, id DESC) event ordering
+type EventCursor struct {
+	Timestamp time.Time
+	ID        int
+}
+
+// String encodes the cursor as "<RFC3339Nano timestamp>,<id>"
+func (ec EventCursor) String() string {
+	return ec.Timestamp.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(ec.ID)
+}
+
+// ParseEventCursor decodes a cursor produced by EventCursor.String
+func ParseEventCursor(value string) (*EventCursor, error) {
+	timestampPart, idPart, ok := strings.Cut(value, ",")
+	if !ok {
+		return nil, fmt.Errorf("expected <timestamp>,<id>")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, timestampPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %v", err)
+	}
+
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id: %v", err)
+	}
+
+	return &EventCursor{Timestamp: timestamp, ID: id}, nil
+}
+
+// GetRecentEvents retrieves a page of recent events, newest first. Ties on timestamp
+// are broken by id so pagination is stable; keyset pagination via filter.Cursor avoids
+// the drift offset pagination suffers while new events arrive.
+func (db *DB) GetRecentEvents(filter EventFilter) ([]models.Event, error) {
 	query := `
-		SELECT id, timestamp, machine_id, sensor_type, conveyor_speed, temperature, robot_arm_angle, status, raw_data, created_at
+		SELECT ` + eventColumns + `
 		FROM events
-		WHERE ($3 = '' OR machine_id = $3)
-		ORDER BY timestamp DESC
+		WHERE ($3 = '' OR machine_id = $3) AND ($4 = '' OR line = $4)
+			AND ($5::timestamptz IS NULL OR (timestamp, id) < ($5::timestamptz, $6::int))
+			AND ($7 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $7))
+			AND ($8 = '' OR fault_code = $8)
+		ORDER BY timestamp DESC, id DESC
 		LIMIT $1 OFFSET $2
 	`
 
-	rows, err := db.Query(query, limit, offset, machineID)
+	var cursorTimestamp interface{}
+	var cursorID int
+	if filter.Cursor != nil {
+		cursorTimestamp = filter.Cursor.Timestamp
+		cursorID = filter.Cursor.ID
+	}
+
+	rows, err := db.reader().Query(query, filter.Limit, filter.Offset, filter.MachineID, filter.Line, cursorTimestamp, cursorID, filter.Area, filter.FaultCode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %v", err)
 	}
 	defer rows.Close()
 
+	return scanEvents(rows)
+}
+
+// GetEventsAfter retrieves events stored after the given event ID, oldest first. When
+// since is set, only events timestamped after it are returned.
+func (db *DB) GetEventsAfter(afterID int, since *time.Time, limit int) ([]models.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE id > $1 AND ($2::timestamptz IS NULL OR timestamp > $2::timestamptz)
+		ORDER BY id
+		LIMIT $3
+	`
+
+	rows, err := db.Query(query, afterID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events after cursor: %v", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// EventExportFilter selects events from the full event history. Zero values match everything.
+type EventExportFilter struct {
+	MachineID string
+	Line      string
+	Since     time.Time
+	Until     time.Time // Exclusive; zero means no upper bound
+}
+
+// StreamEvents passes every event matching filter to fn, oldest first, without loading
+// the result set into memory. It stops at the first error fn returns.
+func (db *DB) StreamEvents(ctx context.Context, filter EventExportFilter, fn func(models.Event) error) error {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE ($1 = '' OR machine_id = $1) AND ($2 = '' OR line = $2)
+			AND timestamp >= $3
+			AND ($4::timestamptz IS NULL OR timestamp < $4)
+		ORDER BY timestamp, id
+	`
+
+	var until sql.NullTime
+	if !filter.Until.IsZero() {
+		until = sql.NullTime{Time: filter.Until, Valid: true}
+	}
+
+	rows, err := db.reader().QueryContext(ctx, query, filter.MachineID, filter.Line, filter.Since, until)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.Event
+		var rawDataBytes []byte
+		err := rows.Scan(&event.ID, &event.Timestamp, &event.MachineID, &event.SensorType,
+			&event.ConveyorSpeed, &event.Temperature, &event.RobotArmAngle,
+			&event.Status, &event.Line, &event.FaultCode, &rawDataBytes, &event.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan event: %v", err)
+		}
+		decodeRawData(&event, rawDataBytes)
+
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetAlertContext retrieves an alert's machine's events from before to after the alert,
+// oldest first, along with the time the window is centred on: the timestamp of the event
+// that raised the alert when it is linked, otherwise the alert's creation time
+func (db *DB) GetAlertContext(alert *models.Alert, before, after time.Duration, limit int) ([]models.Event, time.Time, error) {
+	anchor := alert.CreatedAt
+	if alert.EventID != nil {
+		err := db.reader().QueryRow(`SELECT timestamp FROM events WHERE id = $1`, *alert.EventID).Scan(&anchor)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, anchor, fmt.Errorf("failed to query alert event: %v", err)
+		}
+	}
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE machine_id = $1 AND timestamp BETWEEN $2 AND $3
+		ORDER BY timestamp, id
+		LIMIT $4
+	`
+
+	rows, err := db.reader().Query(query, alert.MachineID, anchor.Add(-before), anchor.Add(after), limit)
+	if err != nil {
+		return nil, anchor, fmt.Errorf("failed to query alert context: %v", err)
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	return events, anchor, err
+}
+
+// GetMachineEventsBetween retrieves up to limit of a machine's events in [since, until],
+// oldest first
+func (db *DB) GetMachineEventsBetween(machineID string, since, until time.Time, limit int) ([]models.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE machine_id = $1 AND timestamp BETWEEN $2 AND $3
+		ORDER BY timestamp, id
+		LIMIT $4
+	`
+
+	rows, err := db.reader().Query(query, machineID, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query machine events: %v", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetFaultOnsets retrieves the timestamps of a machine's fault onsets since the given
+// time, oldest first: fault events whose preceding event was not a fault
+func (db *DB) GetFaultOnsets(machineID string, since time.Time) ([]time.Time, error) {
+	query := `
+		SELECT timestamp
+		FROM (
+			SELECT timestamp, status, LAG(status) OVER (ORDER BY timestamp, id) AS previous_status
+			FROM events
+			WHERE machine_id = $1 AND timestamp >= $2
+		) e
+		WHERE status = 'fault' AND (previous_status IS NULL OR previous_status <> 'fault')
+		ORDER BY timestamp
+	`
+
+	rows, err := db.reader().Query(query, machineID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fault onsets: %v", err)
+	}
+	defer rows.Close()
+
+	var onsets []time.Time
+	for rows.Next() {
+		var onset time.Time
+		if err := rows.Scan(&onset); err != nil {
+			return nil, fmt.Errorf("failed to scan fault onset: %v", err)
+		}
+		onsets = append(onsets, onset)
+	}
+
+	return onsets, rows.Err()
+}
+
+// AlertHandlingTimes holds how many of a machine's alerts were acknowledged and resolved,
+// and the mean seconds from creation to each (nil when none were)
+type AlertHandlingTimes struct {
+	Acknowledged      int
+	MeanToAcknowledge *float64
+	Resolved          int
+	MeanToResolve     *float64
+}
+
+// GetAlertHandlingTimes measures how quickly a machine's non-test alerts created since
+// the given time were acknowledged and resolved
+func (db *DB) GetAlertHandlingTimes(machineID string, since time.Time) (*AlertHandlingTimes, error) {
+	query := `
+		SELECT
+			COUNT(acknowledged_at),
+			AVG(EXTRACT(EPOCH FROM acknowledged_at - created_at)),
+			COUNT(resolved_at),
+			AVG(EXTRACT(EPOCH FROM resolved_at - created_at))
+		FROM alerts
+		WHERE machine_id = $1 AND created_at >= $2 AND NOT test
+	`
+
+	var times AlertHandlingTimes
+	err := db.reader().QueryRow(query, machineID, since).Scan(
+		&times.Acknowledged, &times.MeanToAcknowledge, &times.Resolved, &times.MeanToResolve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert handling times: %v", err)
+	}
+
+	return &times, nil
+}
+
+// GetLatestEvents retrieves the newest event for every machine
+func (db *DB) GetLatestEvents() ([]models.Event, error) {
+	query := `
+		SELECT DISTINCT ON (machine_id) ` + eventColumns + `
+		FROM events
+		ORDER BY machine_id, timestamp DESC, id DESC
+	`
+
+	rows, err := db.reader().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest events: %v", err)
+	}
+	defer rows.Close()
+
+	return scanEvents(rows)
+}
+
+// GetSeenMachines retrieves every distinct machine ID in the events table with the
+// timestamps of its first and last events, flagging those missing from the machines table
+func (db *DB) GetSeenMachines() ([]models.SeenMachine, error) {
+	query := `
+		SELECT e.machine_id, e.first_seen, e.last_seen, m.machine_id IS NOT NULL as registered
+		FROM (
+			SELECT machine_id, MIN(timestamp) as first_seen, MAX(timestamp) as last_seen
+			FROM events
+			GROUP BY machine_id
+		) e
+		LEFT JOIN machines m ON m.machine_id = e.machine_id
+		ORDER BY e.machine_id
+	`
+
+	rows, err := db.reader().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen machines: %v", err)
+	}
+	defer rows.Close()
+
+	var machines []models.SeenMachine
+	for rows.Next() {
+		var machine models.SeenMachine
+		if err := rows.Scan(&machine.MachineID, &machine.FirstSeen, &machine.LastSeen, &machine.Registered); err != nil {
+			return nil, fmt.Errorf("failed to scan seen machine: %v", err)
+		}
+		machines = append(machines, machine)
+	}
+
+	return machines, rows.Err()
+}
+
+// scanEvents scans event rows selected with the standard events column list
+func scanEvents(rows *sql.Rows) ([]models.Event, error) {
 	var events []models.Event
 	for rows.Next() {
 		var event models.Event
@@ -90,41 +491,49 @@ func (db *DB) GetRecentEvents(limit, offset int, machineID string) ([]models.Eve
 
 		err := rows.Scan(&event.ID, &event.Timestamp, &event.MachineID, &event.SensorType,
 			&event.ConveyorSpeed, &event.Temperature, &event.RobotArmAngle,
-			&event.Status, &rawDataBytes, &event.CreatedAt)
+			&event.Status, &event.Line, &event.FaultCode, &rawDataBytes, &event.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %v", err)
 		}
 
-		if err := json.Unmarshal(rawDataBytes, &event.RawData); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal raw data: %v", err)
-		}
+		decodeRawData(&event, rawDataBytes)
 
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-// GetEventStats retrieves aggregated event statistics
-func (db *DB) GetEventStats(machineID string, since time.Time) (*models.EventStats, error) {
+// GetEventStats retrieves aggregated event statistics, counting events with any of the
+// idle statuses as idle. UptimePercent is left for the caller to compute with
+// EventStats.ComputeUptime, since its definition is configurable.
+func (db *DB) GetEventStats(machineID, area string, since time.Time, idleStatuses []string) (*models.EventStats, error) {
 	query := `
 		SELECT
 			COUNT(*) as total_events,
 			COUNT(*) FILTER (WHERE status = 'fault') as fault_events,
 			COUNT(*) FILTER (WHERE status = 'warning') as warning_events,
+			COUNT(*) FILTER (WHERE status = ANY($4)) as idle_events,
 			COALESCE(AVG(temperature), 0) as avg_temperature,
 			COALESCE(AVG(conveyor_speed), 0) as avg_conveyor_speed,
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY temperature) as p95_temperature,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY temperature) as p99_temperature,
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY conveyor_speed) as p95_conveyor_speed,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY conveyor_speed) as p99_conveyor_speed,
 			MAX(timestamp) as last_event_time
 		FROM events
 		WHERE ($1 = '' OR machine_id = $1) AND timestamp >= $2
+			AND ($3 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $3))
 	`
 
 	var stats models.EventStats
 	var lastEventTime sql.NullTime
 
-	err := db.QueryRow(query, machineID, since).Scan(
-		&stats.TotalEvents, &stats.FaultEvents, &stats.WarningEvents,
-		&stats.AvgTemperature, &stats.AvgConveyorSpeed, &lastEventTime)
+	err := db.reader().QueryRow(query, machineID, since, area, pq.Array(idleStatuses)).Scan(
+		&stats.TotalEvents, &stats.FaultEvents, &stats.WarningEvents, &stats.IdleEvents,
+		&stats.AvgTemperature, &stats.AvgConveyorSpeed,
+		&stats.P95Temperature, &stats.P99Temperature, &stats.P95ConveyorSpeed, &stats.P99ConveyorSpeed,
+		&lastEventTime)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get event stats: %v", err)
@@ -134,10 +543,36 @@ func (db *DB) GetEventStats(machineID string, since time.Time) (*models.EventSta
 		stats.LastEventTime = lastEventTime.Time
 	}
 
-	// Calculate uptime percentage
-	if stats.TotalEvents > 0 {
-		uptimeEvents := stats.TotalEvents - stats.FaultEvents
-		stats.UptimePercent = float64(uptimeEvents) / float64(stats.TotalEvents) * 100
+	return &stats, nil
+}
+
+// GetRawDataStats aggregates a numeric raw_data key. Rows where the key is missing or
+// not a number are ignored; if no rows qualify the aggregates are returned as nil.
+func (db *DB) GetRawDataStats(field, machineID string, since time.Time) (*models.RawDataStats, error) {
+	query := `
+		SELECT
+			COUNT(*) as sample_count,
+			AVG((raw_data->>$1::text)::float) as avg_value,
+			MIN((raw_data->>$1::text)::float) as min_value,
+			MAX((raw_data->>$1::text)::float) as max_value
+		FROM events
+		WHERE ($2 = '' OR machine_id = $2) AND timestamp >= $3
+			AND jsonb_typeof(raw_data->$1::text) = 'number'
+	`
+
+	stats := models.RawDataStats{Field: field}
+	var avgValue, minValue, maxValue sql.NullFloat64
+
+	err := db.reader().QueryRow(query, field, machineID, since).Scan(
+		&stats.SampleCount, &avgValue, &minValue, &maxValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raw data stats: %v", err)
+	}
+
+	if avgValue.Valid {
+		stats.Avg = &avgValue.Float64
+		stats.Min = &minValue.Float64
+		stats.Max = &maxValue.Float64
 	}
 
 	return &stats, nil
@@ -146,11 +581,12 @@ func (db *DB) GetEventStats(machineID string, since time.Time) (*models.EventSta
 // InsertAlert inserts a new alert
 func (db *DB) InsertAlert(alert *models.Alert) error {
 	query := `
-		INSERT INTO alerts (event_id, alert_type, severity, message)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO alerts (event_id, machine_id, alert_type, severity, message, confidence, recommended_action, test)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	_, err := db.Exec(query, alert.EventID, alert.AlertType, alert.Severity, alert.Message)
+	_, err := db.Exec(query, alert.EventID, alert.MachineID, alert.AlertType, alert.Severity, alert.Message, alert.Confidence,
+		alert.RecommendedAction, alert.Test)
 	if err != nil {
 		return fmt.Errorf("failed to insert alert: %v", err)
 	}
@@ -158,50 +594,502 @@ func (db *DB) InsertAlert(alert *models.Alert) error {
 	return nil
 }
 
-// GetUnacknowledgedAlerts retrieves unacknowledged alerts
-func (db *DB) GetUnacknowledgedAlerts() ([]models.Alert, error) {
+// InsertAlertsBatch inserts alerts with one statement, setting each alert's ID and
+// creation time. IDs are drawn from the sequence in row order, so sorted returned IDs
+// match the alerts in order.
+func (db *DB) InsertAlertsBatch(alerts []*models.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	const columnCount = 8
+	values := make([]string, len(alerts))
+	args := make([]interface{}, 0, len(alerts)*columnCount)
+	for i, alert := range alerts {
+		placeholders := make([]string, columnCount)
+		for j := range placeholders {
+			placeholders[j] = "$" + strconv.Itoa(i*columnCount+j+1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, alert.EventID, alert.MachineID, alert.AlertType, alert.Severity, alert.Message, alert.Confidence,
+			alert.RecommendedAction, alert.Test)
+	}
+
+	query := `
+		INSERT INTO alerts (event_id, machine_id, alert_type, severity, message, confidence, recommended_action, test)
+		VALUES ` + strings.Join(values, ", ") + `
+		RETURNING id, created_at
+	`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert alerts: %v", err)
+	}
+	defer rows.Close()
+
+	stored := make([]models.Alert, 0, len(alerts))
+	for rows.Next() {
+		var alert models.Alert
+		if err := rows.Scan(&alert.ID, &alert.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan inserted alert: %v", err)
+		}
+		stored = append(stored, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to insert alerts: %v", err)
+	}
+	if len(stored) != len(alerts) {
+		return fmt.Errorf("failed to insert alerts: %d of %d stored", len(stored), len(alerts))
+	}
+
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+	for i, alert := range alerts {
+		alert.ID = stored[i].ID
+		alert.CreatedAt = stored[i].CreatedAt
+	}
+	return nil
+}
+
+// InsertAlertUnlessDuplicate stores an alert with its own created_at, unless the machine
+// already has a non-test alert of the same type for the same event or created within
+// tolerance of it. It reports whether the alert was stored.
+func (db *DB) InsertAlertUnlessDuplicate(alert *models.Alert, tolerance time.Duration) (bool, error) {
+	query := `
+		INSERT INTO alerts (event_id, machine_id, alert_type, severity, message, confidence, recommended_action, created_at)
+		SELECT $1::integer, $2::varchar, $3::varchar, $4::varchar, $5::text, $6::double precision, $7::text, $8::timestamptz
+		WHERE NOT EXISTS (
+			SELECT 1 FROM alerts
+			WHERE machine_id = $2 AND alert_type = $3 AND NOT test
+				AND (event_id = $1 OR created_at BETWEEN $9 AND $10)
+		)
+	`
+
+	result, err := db.Exec(query, alert.EventID, alert.MachineID, alert.AlertType, alert.Severity, alert.Message, alert.Confidence,
+		alert.RecommendedAction, alert.CreatedAt, alert.CreatedAt.Add(-tolerance), alert.CreatedAt.Add(tolerance))
+	if err != nil {
+		return false, fmt.Errorf("failed to insert alert: %v", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert alert: %v", err)
+	}
+	return inserted > 0, nil
+}
+
+// GetActiveAlertCounts counts the alerts that are neither acknowledged nor resolved, by
+// severity. Test alerts are left out.
+func (db *DB) GetActiveAlertCounts() (map[string]int64, error) {
+	query := `
+		SELECT severity, COUNT(*)
+		FROM alerts
+		WHERE acknowledged = false AND resolved_at IS NULL AND NOT test
+		GROUP BY severity
+	`
+
+	rows, err := db.reader().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active alert counts: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var severity string
+		var count int64
+		if err := rows.Scan(&severity, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan active alert count: %v", err)
+		}
+		counts[severity] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// severityArray binds a severity filter. No severities binds an empty array rather than
+// NULL, since cardinality(NULL) is NULL and would match no rows.
+func severityArray(severities []string) interface{} {
+	if severities == nil {
+		severities = []string{}
+	}
+	return pq.Array(severities)
+}
+
+// GetUnacknowledgedAlerts retrieves unacknowledged alerts, optionally restricted to the
+// given severities and to machines in an area
+func (db *DB) GetUnacknowledgedAlerts(severities []string, area string) ([]models.Alert, error) {
 	query := `
-		SELECT id, event_id, alert_type, severity, message, acknowledged, created_at, acknowledged_at
+		SELECT ` + alertColumns + `
 		FROM alerts
 		WHERE acknowledged = false
+			AND (cardinality($1::text[]) = 0 OR severity = ANY($1))
+			AND ($2 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $2))
 		ORDER BY created_at DESC
 		LIMIT 100
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, severityArray(severities), area)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %v", err)
+	}
+	defer rows.Close()
+
+	return scanAlerts(rows)
+}
+
+// GetAlertsWithEvents retrieves the same alerts as GetUnacknowledgedAlerts, each with the
+// event that triggered it. Alerts without a linked event, or whose event has been deleted,
+// have a nil Event.
+func (db *DB) GetAlertsWithEvents(severities []string, area string) ([]models.AlertWithEvent, error) {
+	query := `
+		SELECT ` + qualifyColumns("a", alertColumns) + `, ` + qualifyColumns("e", eventColumns) + `
+		FROM alerts a
+		LEFT JOIN events e ON e.id = a.event_id
+		WHERE a.acknowledged = false
+			AND (cardinality($1::text[]) = 0 OR a.severity = ANY($1))
+			AND ($2 = '' OR a.machine_id IN (SELECT machine_id FROM machines WHERE area = $2))
+		ORDER BY a.created_at DESC
+		LIMIT 100
+	`
+
+	rows, err := db.Query(query, severityArray(severities), area)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query alerts: %v", err)
 	}
 	defer rows.Close()
 
+	var alerts []models.AlertWithEvent
+	for rows.Next() {
+		var alert models.AlertWithEvent
+		var event models.Event
+		var eventID *int
+		var timestamp, createdAt *time.Time
+		var machineID, sensorType, status, line *string
+		var rawDataBytes []byte
+
+		// Event columns are all NULL when the alert has no matching event
+		destinations := append(alertDestinations(&alert.Alert), &eventID, &timestamp, &machineID, &sensorType,
+			&event.ConveyorSpeed, &event.Temperature, &event.RobotArmAngle,
+			&status, &line, &event.FaultCode, &rawDataBytes, &createdAt)
+		if err := rows.Scan(destinations...); err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %v", err)
+		}
+
+		if eventID != nil {
+			event.ID = *eventID
+			event.Timestamp = *timestamp
+			event.MachineID = *machineID
+			event.SensorType = *sensorType
+			event.Status = *status
+			event.Line = *line
+			event.CreatedAt = *createdAt
+			decodeRawData(&event, rawDataBytes)
+			alert.Event = &event
+		}
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// AlertFilter selects alerts from the full alert history. Zero values match everything.
+type AlertFilter struct {
+	Severities   []string
+	AlertType    string
+	MachineID    string
+	Since        time.Time
+	Until        time.Time // Exclusive; zero means no upper bound
+	Acknowledged *bool
+	IncludeTest  bool // Include synthetic test alerts
+}
+
+// StreamAlerts passes every alert matching filter to fn, oldest first, without loading
+// the result set into memory. It stops at the first error fn returns.
+func (db *DB) StreamAlerts(ctx context.Context, filter AlertFilter, fn func(models.Alert) error) error {
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE (cardinality($1::text[]) = 0 OR severity = ANY($1))
+			AND ($2 = '' OR alert_type = $2)
+			AND ($3 = '' OR machine_id = $3)
+			AND created_at >= $4
+			AND ($5::timestamptz IS NULL OR created_at < $5)
+			AND ($6::boolean IS NULL OR acknowledged = $6)
+			AND ($7 OR NOT test)
+		ORDER BY created_at, id
+	`
+
+	var until sql.NullTime
+	if !filter.Until.IsZero() {
+		until = sql.NullTime{Time: filter.Until, Valid: true}
+	}
+	var acknowledged sql.NullBool
+	if filter.Acknowledged != nil {
+		acknowledged = sql.NullBool{Bool: *filter.Acknowledged, Valid: true}
+	}
+
+	rows, err := db.reader().QueryContext(ctx, query, severityArray(filter.Severities), filter.AlertType,
+		filter.MachineID, filter.Since, until, acknowledged, filter.IncludeTest)
+	if err != nil {
+		return fmt.Errorf("failed to query alerts: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(alert); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetAlert retrieves a single alert by ID
+func (db *DB) GetAlert(alertID int) (*models.Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM alerts WHERE id = $1`
+
+	rows, err := db.Query(query, alertID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert: %v", err)
+	}
+	defer rows.Close()
+
+	alerts, err := scanAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &alerts[0], nil
+}
+
+// SnoozeAlerts records a snooze for a machine and alert type, replacing any existing one
+func (db *DB) SnoozeAlerts(machineID, alertType string, until time.Time) (*models.AlertSnooze, error) {
+	query := `
+		INSERT INTO alert_snoozes (machine_id, alert_type, snoozed_until)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (machine_id, alert_type)
+		DO UPDATE SET snoozed_until = EXCLUDED.snoozed_until, created_at = NOW()
+		RETURNING machine_id, alert_type, snoozed_until, created_at
+	`
+
+	var snooze models.AlertSnooze
+	err := db.QueryRow(query, machineID, alertType, until).Scan(
+		&snooze.MachineID, &snooze.AlertType, &snooze.SnoozedUntil, &snooze.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snooze alerts: %v", err)
+	}
+
+	return &snooze, nil
+}
+
+// GetActiveSnoozes retrieves snoozes that have not yet expired
+func (db *DB) GetActiveSnoozes() ([]models.AlertSnooze, error) {
+	query := `
+		SELECT machine_id, alert_type, snoozed_until, created_at
+		FROM alert_snoozes
+		WHERE snoozed_until > NOW()
+		ORDER BY snoozed_until
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert snoozes: %v", err)
+	}
+	defer rows.Close()
+
+	var snoozes []models.AlertSnooze
+	for rows.Next() {
+		var snooze models.AlertSnooze
+		if err := rows.Scan(&snooze.MachineID, &snooze.AlertType, &snooze.SnoozedUntil, &snooze.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert snooze: %v", err)
+		}
+		snoozes = append(snoozes, snooze)
+	}
+
+	return snoozes, rows.Err()
+}
+
+// SetAnomalyRules records whether each of the given anomaly detection rules is enabled,
+// in one transaction so either every rule is updated or none is
+func (db *DB) SetAnomalyRules(rules map[string]bool) error {
+	query := `
+		INSERT INTO anomaly_rules (rule_name, enabled)
+		VALUES ($1, $2)
+		ON CONFLICT (rule_name)
+		DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+	`
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin anomaly rule update: %v", err)
+	}
+	defer tx.Rollback()
+
+	names := make([]string, 0, len(rules))
+	for rule := range rules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	for _, rule := range names {
+		if _, err := tx.Exec(query, rule, rules[rule]); err != nil {
+			return fmt.Errorf("failed to update anomaly rule %s: %v", rule, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit anomaly rule update: %v", err)
+	}
+	return nil
+}
+
+// GetAnomalyRuleSettings retrieves the recorded enabled state of anomaly detection rules
+func (db *DB) GetAnomalyRuleSettings() (map[string]bool, error) {
+	rows, err := db.Query(`SELECT rule_name, enabled FROM anomaly_rules`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomaly rules: %v", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]bool)
+	for rows.Next() {
+		var rule string
+		var enabled bool
+		if err := rows.Scan(&rule, &enabled); err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly rule: %v", err)
+		}
+		settings[rule] = enabled
+	}
+
+	return settings, rows.Err()
+}
+
+// ResolveAlerts marks the unresolved alerts of a type for a machine as resolved
+func (db *DB) ResolveAlerts(resolution *models.AlertResolution) error {
+	query := `
+		UPDATE alerts
+		SET resolved_at = $3
+		WHERE machine_id = $1 AND alert_type = $2 AND resolved_at IS NULL
+	`
+
+	_, err := db.Exec(query, resolution.MachineID, resolution.AlertType, resolution.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to resolve alerts: %v", err)
+	}
+
+	return nil
+}
+
+// GetCurrentAlerts retrieves the most recent unresolved, unacknowledged alert for each machine and alert type
+func (db *DB) GetCurrentAlerts() ([]models.Alert, error) {
+	query := `
+		SELECT DISTINCT ON (machine_id, alert_type) ` + alertColumns + `
+		FROM alerts
+		WHERE acknowledged = false AND resolved_at IS NULL AND NOT test
+		ORDER BY machine_id, alert_type, created_at DESC, id DESC
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current alerts: %v", err)
+	}
+	defer rows.Close()
+
+	return scanAlerts(rows)
+}
+
+// GetAlertTrend counts alerts per time bucket and severity since the given time
+func (db *DB) GetAlertTrend(since time.Time, interval time.Duration) ([]models.AlertTrendBucket, error) {
+	query := `
+		SELECT
+			to_timestamp(floor(extract(epoch FROM created_at) / $2) * $2) AS bucket_start,
+			severity,
+			COUNT(*) AS alert_count
+		FROM alerts
+		WHERE created_at >= $1 AND NOT test
+		GROUP BY bucket_start, severity
+		ORDER BY bucket_start, severity
+	`
+
+	rows, err := db.reader().Query(query, since, interval.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert trend: %v", err)
+	}
+	defer rows.Close()
+
+	var buckets []models.AlertTrendBucket
+	for rows.Next() {
+		var bucket models.AlertTrendBucket
+		if err := rows.Scan(&bucket.BucketStart, &bucket.Severity, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan alert trend bucket: %v", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// scanAlerts scans alert rows selected with the standard alerts column list
+func scanAlerts(rows *sql.Rows) ([]models.Alert, error) {
 	var alerts []models.Alert
 	for rows.Next() {
-		var alert models.Alert
-		err := rows.Scan(&alert.ID, &alert.EventID, &alert.AlertType, &alert.Severity,
-			&alert.Message, &alert.Acknowledged, &alert.CreatedAt, &alert.AcknowledgedAt)
+		alert, err := scanAlert(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan alert: %v", err)
+			return nil, err
 		}
 		alerts = append(alerts, alert)
 	}
 
-	return alerts, nil
+	return alerts, rows.Err()
+}
+
+// scanAlert scans the current row, selected with alertColumns
+func scanAlert(rows *sql.Rows) (models.Alert, error) {
+	var alert models.Alert
+	if err := rows.Scan(alertDestinations(&alert)...); err != nil {
+		return alert, fmt.Errorf("failed to scan alert: %v", err)
+	}
+	return alert, nil
+}
+
+// alertDestinations returns the scan destinations for alertColumns
+func alertDestinations(alert *models.Alert) []interface{} {
+	return []interface{}{&alert.ID, &alert.EventID, &alert.MachineID, &alert.AlertType, &alert.Severity,
+		&alert.Message, &alert.Confidence, &alert.RecommendedAction, &alert.Acknowledged, &alert.CreatedAt,
+		&alert.AcknowledgedAt, &alert.AcknowledgedBy, &alert.AcknowledgementNote, &alert.ResolvedAt, &alert.Test}
+}
+
+// qualifyColumns prefixes each column of a comma-separated column list with a table alias
+func qualifyColumns(alias, columns string) string {
+	names := strings.Split(columns, ",")
+	for i, name := range names {
+		names[i] = alias + "." + strings.TrimSpace(name)
+	}
+	return strings.Join(names, ", ")
 }
 
-// AcknowledgeAlert marks an alert as acknowledged
-func (db *DB) AcknowledgeAlert(alertID int) error {
+// AcknowledgeAlert marks an alert as acknowledged, recording who acknowledged it and an optional note
+func (db *DB) AcknowledgeAlert(alertID int, acknowledgedBy, note string) error {
 	query := `
 		UPDATE alerts
-		SET acknowledged = true, acknowledged_at = NOW()
+		SET acknowledged = true, acknowledged_at = NOW(),
+			acknowledged_by = NULLIF($2, ''), acknowledgement_note = NULLIF($3, '')
 		WHERE id = $1
 	`
 
-	_, err := db.Exec(query, alertID)
+	result, err := db.Exec(query, alertID, acknowledgedBy, note)
 	if err != nil {
 		return fmt.Errorf("failed to acknowledge alert: %v", err)
 	}
 
-	return nil
+	return requireRowsAffected(result)
 }
 
 // GetProcessParameters retrieves all process parameters
@@ -232,26 +1120,112 @@ func (db *DB) GetProcessParameters() ([]models.ProcessParameter, error) {
 	return params, nil
 }
 
-// UpdateProcessParameter updates a process parameter
-func (db *DB) UpdateProcessParameter(name, value string) error {
+// UpdateProcessParameter updates a process parameter, returning its previous value
+func (db *DB) UpdateProcessParameter(name, value string) (string, error) {
 	query := `
-		UPDATE process_parameters
+		UPDATE process_parameters p
 		SET parameter_value = $2, updated_at = NOW()
-		WHERE parameter_name = $1
+		FROM (SELECT id, parameter_value FROM process_parameters WHERE parameter_name = $1 FOR UPDATE) previous
+		WHERE p.id = previous.id
+		RETURNING previous.parameter_value
 	`
 
-	_, err := db.Exec(query, name, value)
+	var previous string
+	err := db.QueryRow(query, name, value).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update process parameter: %v", err)
+		return "", fmt.Errorf("failed to update process parameter: %v", err)
+	}
+
+	return previous, nil
+}
+
+// InsertAuditEntry records an audit entry
+func (db *DB) InsertAuditEntry(entry *models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (actor, client_ip, action, target, old_value, new_value)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := db.Exec(query, entry.Actor, entry.ClientIP, entry.Action, entry.Target,
+		nullJSON(entry.OldValue), nullJSON(entry.NewValue))
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %v", err)
 	}
 
 	return nil
 }
 
+// nullJSON passes an empty JSON value to the database as NULL
+func nullJSON(value json.RawMessage) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return []byte(value)
+}
+
+// AuditFilter selects audit entries. Zero values match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Limit  int
+}
+
+// GetAuditLog retrieves audit entries matching filter, newest first
+func (db *DB) GetAuditLog(filter AuditFilter) ([]models.AuditEntry, error) {
+	query := `
+		SELECT id, created_at, actor, client_ip, action, target, old_value, new_value
+		FROM audit_log
+		WHERE ($1 = '' OR actor = $1)
+			AND ($2 = '' OR action = $2)
+			AND ($3 = '' OR target = $3)
+			AND created_at >= $4
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5
+	`
+
+	rows, err := db.reader().Query(query, filter.Actor, filter.Action, filter.Target, filter.Since, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var oldValue, newValue []byte
+		err := rows.Scan(&entry.ID, &entry.CreatedAt, &entry.Actor, &entry.ClientIP,
+			&entry.Action, &entry.Target, &oldValue, &newValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		entry.OldValue, entry.NewValue = oldValue, newValue
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// requireRowsAffected returns ErrNotFound when a statement matched no rows
+func requireRowsAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %v", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // GetMachines retrieves all machines
 func (db *DB) GetMachines() ([]models.Machine, error) {
 	query := `
-		SELECT id, machine_id, machine_type, location, status, config, created_at, updated_at
+		SELECT id, machine_id, machine_type, location, area, line, status, config, created_at, updated_at
 		FROM machines
 		ORDER BY machine_id
 	`
@@ -268,7 +1242,7 @@ func (db *DB) GetMachines() ([]models.Machine, error) {
 		var configBytes []byte
 
 		err := rows.Scan(&machine.ID, &machine.MachineID, &machine.MachineType,
-			&machine.Location, &machine.Status, &configBytes,
+			&machine.Location, &machine.Area, &machine.Line, &machine.Status, &configBytes,
 			&machine.CreatedAt, &machine.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan machine: %v", err)
@@ -282,4 +1256,4 @@ func (db *DB) GetMachines() ([]models.Machine, error) {
 	}
 
 	return machines, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"backend/models"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// boundSeverities returns the severity array bound to the last recorded query
+func boundSeverities(t *testing.T) interface{} {
+	t.Helper()
+	if len(statementRecorder.args) == 0 {
+		t.Fatal("no query was run")
+	}
+	args := statementRecorder.args[len(statementRecorder.args)-1]
+	return args[0]
+}
+
+func TestGetUnacknowledgedAlertsWithoutSeverityBindsEmptyArray(t *testing.T) {
+	db := newStatementDB(t, "")
+	if _, err := db.GetUnacknowledgedAlerts(nil, ""); err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+
+	// NULL would make cardinality($1) NULL and filter out every alert
+	if got := boundSeverities(t); got != "{}" {
+		t.Errorf("severity filter bound as %#v, want an empty array", got)
+	}
+}
+
+func TestGetUnacknowledgedAlertsBindsSeverities(t *testing.T) {
+	db := newStatementDB(t, "")
+	if _, err := db.GetUnacknowledgedAlerts([]string{"high", "critical"}, ""); err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+
+	if got := boundSeverities(t); got != `{"high","critical"}` {
+		t.Errorf("severity filter bound as %#v, want the requested severities", got)
+	}
+}
+
+func TestGetAlertsWithEventsWithoutSeverityBindsEmptyArray(t *testing.T) {
+	db := newStatementDB(t, "")
+	if _, err := db.GetAlertsWithEvents(nil, ""); err != nil {
+		t.Fatalf("GetAlertsWithEvents: %v", err)
+	}
+
+	if got := boundSeverities(t); got != "{}" {
+		t.Errorf("severity filter bound as %#v, want an empty array", got)
+	}
+}
+
+func TestStreamAlertsWithoutSeverityBindsEmptyArray(t *testing.T) {
+	db := newStatementDB(t, "")
+	err := db.StreamAlerts(context.Background(), AlertFilter{}, func(models.Alert) error { return nil })
+	if err != nil {
+		t.Fatalf("StreamAlerts: %v", err)
+	}
+
+	if got := boundSeverities(t); got != "{}" {
+		t.Errorf("severity filter bound as %#v, want an empty array", got)
+	}
+}
+
+func TestSetAnomalyRulesUpdatesInOneTransaction(t *testing.T) {
+	db := newStatementDB(t, "")
+	if err := db.SetAnomalyRules(map[string]bool{"speed_instability": false, "repeated_faults": true}); err != nil {
+		t.Fatalf("SetAnomalyRules: %v", err)
+	}
+
+	log := statementRecorder.log
+	if len(log) != 4 || log[0] != "BEGIN" || log[3] != "COMMIT" {
+		t.Errorf("statements = %q, want both upserts within a transaction", log)
+	}
+}
+
+func TestSetAnomalyRulesRollsBackOnFailure(t *testing.T) {
+	db := newStatementDB(t, "anomaly_rules")
+	if err := db.SetAnomalyRules(map[string]bool{"speed_instability": false}); err == nil {
+		t.Fatal("SetAnomalyRules succeeded despite a failed statement")
+	}
+
+	log := statementRecorder.log
+	if last := log[len(log)-1]; last != "ROLLBACK" {
+		t.Errorf("last statement = %q, want ROLLBACK", last)
+	}
+}
+
+// eventRow returns a stored event row, in eventColumns order, with the given raw_data
+func eventRow(id int64, rawData string) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id, now, "conveyor_001", "conveyor", 1.2, 45.0, nil, "ok", "line1", "", []byte(rawData), now}
+}
+
+func TestGetRecentEventsSurvivesMalformedRawData(t *testing.T) {
+	db := newStatementDB(t, "")
+	queueRows(eventRow(3, `{"vibration_level": 0.2}`), eventRow(2, `{"vibration_level": 0.`), eventRow(1, `{}`))
+
+	events, err := db.GetRecentEvents(EventFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetRecentEvents: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want the whole page of 3", len(events))
+	}
+
+	bad := events[1]
+	if len(bad.RawData) != 0 || len(bad.Warnings) != 1 {
+		t.Errorf("malformed row raw_data = %v, warnings = %v, want an empty map and a warning", bad.RawData, bad.Warnings)
+	}
+	if events[0].RawData["vibration_level"] != 0.2 || len(events[0].Warnings) != 0 {
+		t.Errorf("valid row raw_data = %v, warnings = %v", events[0].RawData, events[0].Warnings)
+	}
+}
+
+func TestGetRecentEventsOrdersTiesByID(t *testing.T) {
+	db := newStatementDB(t, "")
+	cursor := &EventCursor{Timestamp: time.Now(), ID: 7}
+	if _, err := db.GetRecentEvents(EventFilter{Limit: 10, Cursor: cursor}); err != nil {
+		t.Fatalf("GetRecentEvents: %v", err)
+	}
+
+	query := statementRecorder.log[len(statementRecorder.log)-1]
+	if !strings.Contains(query, "ORDER BY timestamp DESC, id DESC") || !strings.Contains(query, "(timestamp, id) <") {
+		t.Errorf("query = %s, want pages ordered and sought by (timestamp, id)", query)
+	}
+	if args := statementRecorder.args[0]; args[5] != 7 {
+		t.Errorf("cursor ID bound as %#v, want 7", args[5])
+	}
+}
+
+// startingConnector refuses connections until up is set, like a database still coming up
+type startingConnector struct {
+	up       atomic.Bool
+	attempts atomic.Int32
+}
+
+func (c *startingConnector) Connect(context.Context) (driver.Conn, error) {
+	c.attempts.Add(1)
+	if !c.up.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return statementRecorder.Open("")
+}
+
+func (c *startingConnector) Driver() driver.Driver {
+	return statementRecorder
+}
+
+func TestConnectRetriesUntilDatabaseReachable(t *testing.T) {
+	connector := &startingConnector{}
+	db := &DB{DB: sql.OpenDB(connector)}
+	t.Cleanup(func() { db.Close() })
+
+	connected := make(chan error, 1)
+	go func() { connected <- db.Connect(context.Background(), time.Second) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for connector.attempts.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-connected:
+		t.Fatalf("Connect returned %v while the database was unreachable", err)
+	default:
+	}
+	if db.Connected() {
+		t.Error("Connected reported true before the database was reached")
+	}
+
+	connector.up.Store(true)
+	select {
+	case err := <-connected:
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Connect did not return once the database was reachable")
+	}
+	if !db.Connected() || connector.attempts.Load() < 2 {
+		t.Errorf("connected = %v after %d attempts, want connected after a retry", db.Connected(), connector.attempts.Load())
+	}
+}
+
+func TestConnectAbandonedWhenContextDone(t *testing.T) {
+	db := &DB{DB: sql.OpenDB(&startingConnector{})}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := db.Connect(ctx, time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Connect = %v, want the context's deadline error", err)
+	}
+	if db.Connected() {
+		t.Error("Connected reported true for an unreachable database")
+	}
+}
+
+func TestReadQueriesUseReplicaWhenConfigured(t *testing.T) {
+	primary, replica := &startingConnector{}, &startingConnector{}
+	primary.up.Store(true)
+	replica.up.Store(true)
+	db := &DB{DB: sql.OpenDB(primary), replica: sql.OpenDB(replica)}
+	t.Cleanup(func() { db.Close() })
+
+	db.GetLatestEvents()
+	db.GetEventStats("", "", time.Now().Add(-time.Hour), nil)
+	db.GetAlertTrend(time.Now().Add(-time.Hour), time.Minute)
+	if replica.attempts.Load() == 0 || primary.attempts.Load() != 0 {
+		t.Errorf("reads opened %d replica and %d primary connections, want the replica only", replica.attempts.Load(), primary.attempts.Load())
+	}
+
+	db.InsertEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"})
+	if primary.attempts.Load() == 0 {
+		t.Error("write did not go to the primary")
+	}
+}
+
+func TestReadQueriesFallBackToPrimaryWithoutReplica(t *testing.T) {
+	primary := &startingConnector{}
+	primary.up.Store(true)
+	db := &DB{DB: sql.OpenDB(primary)}
+	t.Cleanup(func() { db.Close() })
+
+	if db.HasReplica() {
+		t.Error("HasReplica reported true with no replica configured")
+	}
+	db.GetLatestEvents()
+	if primary.attempts.Load() == 0 {
+		t.Error("read did not go to the primary")
+	}
+}
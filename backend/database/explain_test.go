@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// newSeededConn returns a connection to the Postgres database named by TEST_DATABASE_URL,
+// with the schema created from init.sql and the migrations in a scratch schema that is
+// dropped afterwards, and seeded with enough events and alerts for the planner to prefer
+// an index over scanning. The test is skipped when TEST_DATABASE_URL is not set.
+func newSeededConn(t *testing.T) *sql.Conn {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	initSQL, err := os.ReadFile("../../database/init.sql")
+	if err != nil {
+		t.Skipf("init.sql not available: %v", err)
+	}
+
+	db, err := New(databaseURL, "")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	schema := fmt.Sprintf("explain_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		conn.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`)
+		conn.Close()
+	})
+
+	statements := []string{`CREATE SCHEMA ` + schema, `SET search_path TO ` + schema, string(initSQL)}
+	statements = append(statements, schemaMigrations...)
+	statements = append(statements,
+		`INSERT INTO events (timestamp, machine_id, sensor_type, temperature, conveyor_speed, status)
+			SELECT NOW() - i * INTERVAL '1 second', 'conveyor_' || (i % 50), 'conveyor', 70, 1.5, 'ok'
+			FROM generate_series(1, 100000) AS i`,
+		`INSERT INTO alerts (machine_id, alert_type, message, acknowledged, created_at)
+			SELECT 'conveyor_' || (i % 50), 'temperature_high', 'seeded', i % 100 <> 0, NOW() - i * INTERVAL '1 minute'
+			FROM generate_series(1, 20000) AS i`,
+		`ANALYZE events`,
+		`ANALYZE alerts`,
+	)
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			t.Fatalf("preparing schema: %v\n%s", err, statement)
+		}
+	}
+	return conn
+}
+
+// explain returns the query plan for query with args
+func explain(t *testing.T, conn *sql.Conn, query string, args ...interface{}) string {
+	t.Helper()
+	rows, err := conn.QueryContext(context.Background(), "EXPLAIN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN: %v\n%s", err, query)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatalf("scanning plan: %v", err)
+		}
+		plan = append(plan, line)
+	}
+	return strings.Join(plan, "\n")
+}
+
+// TestHotQueriesUseIndexes checks the plans of the queries behind GetRecentEvents,
+// GetEventStats and GetUnacknowledgedAlerts, as issued for a single machine and for the
+// open alert list
+func TestHotQueriesUseIndexes(t *testing.T) {
+	conn := newSeededConn(t)
+	since := time.Now().Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{
+			"recent events for a machine",
+			`SELECT ` + eventColumns + `
+			FROM events
+			WHERE ($3 = '' OR machine_id = $3) AND ($4 = '' OR line = $4)
+				AND ($5::timestamptz IS NULL OR (timestamp, id) < ($5::timestamptz, $6::int))
+				AND ($7 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $7))
+				AND ($8 = '' OR fault_code = $8)
+			ORDER BY timestamp DESC, id DESC
+			LIMIT $1 OFFSET $2`,
+			[]interface{}{100, 0, "conveyor_7", "", nil, 0, "", ""},
+		},
+		{
+			"event stats for a machine",
+			`SELECT COUNT(*), COUNT(*) FILTER (WHERE status = ANY($4)), MAX(timestamp)
+			FROM events
+			WHERE ($1 = '' OR machine_id = $1) AND timestamp >= $2
+				AND ($3 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $3))`,
+			[]interface{}{"conveyor_7", since, "", pq.Array([]string{"idle"})},
+		},
+		{
+			"unacknowledged alerts",
+			`SELECT ` + alertColumns + `
+			FROM alerts
+			WHERE acknowledged = false
+				AND (cardinality($1::text[]) = 0 OR severity = ANY($1))
+				AND ($2 = '' OR machine_id IN (SELECT machine_id FROM machines WHERE area = $2))
+			ORDER BY created_at DESC
+			LIMIT 100`,
+			[]interface{}{severityArray(nil), ""},
+		},
+	} {
+		plan := explain(t, conn, tc.query, tc.args...)
+		if strings.Contains(plan, "Seq Scan on events") || strings.Contains(plan, "Seq Scan on alerts") {
+			t.Errorf("%s: plan scans the table:\n%s", tc.name, plan)
+		}
+		if !strings.Contains(plan, "Index") {
+			t.Errorf("%s: plan uses no index:\n%s", tc.name, plan)
+		}
+	}
+}
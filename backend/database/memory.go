@@ -0,0 +1,885 @@
+package database
+
+import (
+	"backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in memory, for tests that run without
+// Postgres. It mirrors the semantics of the queries in DB; nothing survives a restart.
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	events   []models.Event // In ID order
+	alerts   []models.Alert // In ID order
+	snoozes  map[snoozeKey]models.AlertSnooze
+	rules    map[string]bool
+	params   []models.ProcessParameter
+	audit    []models.AuditEntry
+	machines []models.Machine
+}
+
+// snoozeKey identifies a snooze, which is unique per machine and alert type
+type snoozeKey struct {
+	machineID string
+	alertType string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snoozes: make(map[snoozeKey]models.AlertSnooze),
+		rules:   make(map[string]bool),
+	}
+}
+
+// AddMachine registers a machine, as the machines table is seeded for DB
+func (m *MemoryStore) AddMachine(machine models.Machine) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	machine.ID = len(m.machines) + 1
+	machine.CreatedAt, machine.UpdatedAt = now, now
+	m.machines = append(m.machines, machine)
+}
+
+// AddProcessParameter registers a process parameter, as the process_parameters table is
+// seeded for DB
+func (m *MemoryStore) AddProcessParameter(param models.ProcessParameter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	param.ID = len(m.params) + 1
+	param.UpdatedAt = time.Now()
+	m.params = append(m.params, param)
+}
+
+// Connect returns immediately; the store is always reachable
+func (m *MemoryStore) Connect(ctx context.Context, maxBackoff time.Duration) error {
+	return nil
+}
+
+// Connected reports true; the store is always reachable
+func (m *MemoryStore) Connected() bool {
+	return true
+}
+
+// PingContext always succeeds
+func (m *MemoryStore) PingContext(ctx context.Context) error {
+	return nil
+}
+
+// HasReplica reports false; reads and writes share the same data
+func (m *MemoryStore) HasReplica() bool {
+	return false
+}
+
+// PingReplica always succeeds
+func (m *MemoryStore) PingReplica(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// InsertEvent stores an event, assigning its ID and creation time
+func (m *MemoryStore) InsertEvent(event *models.SensorEvent) (*models.Event, error) {
+	rawDataJSON, err := json.Marshal(event.AdditionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal raw data: %v", err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := models.Event{
+		ID:            len(m.events) + 1,
+		Timestamp:     event.Timestamp,
+		MachineID:     event.MachineID,
+		SensorType:    event.EventType,
+		ConveyorSpeed: event.ConveyorSpeed,
+		Temperature:   event.Temperature,
+		RobotArmAngle: event.RobotArmAngle,
+		Status:        event.Status,
+		Line:          event.Line,
+		FaultCode:     nullString(event.FaultCode),
+		CreatedAt:     time.Now(),
+	}
+	decodeRawData(&stored, rawDataJSON)
+	m.events = append(m.events, stored)
+
+	return &stored, nil
+}
+
+// GetRecentEvents retrieves a page of recent events, newest first, like DB.GetRecentEvents
+func (m *MemoryStore) GetRecentEvents(filter EventFilter) ([]models.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	area := m.areaMachines(filter.Area)
+	events := m.filterEvents(func(event *models.Event) bool {
+		if filter.Cursor != nil && !eventBefore(event, filter.Cursor.Timestamp, filter.Cursor.ID) {
+			return false
+		}
+		return (filter.MachineID == "" || event.MachineID == filter.MachineID) &&
+			(filter.Line == "" || event.Line == filter.Line) &&
+			(area == nil || area[event.MachineID]) &&
+			(filter.FaultCode == "" || (event.FaultCode != nil && *event.FaultCode == filter.FaultCode))
+	})
+	sort.Slice(events, func(i, j int) bool {
+		return eventBefore(&events[j], events[i].Timestamp, events[i].ID)
+	})
+
+	return page(events, filter.Offset, filter.Limit), nil
+}
+
+// GetEventsAfter retrieves events stored after the given event ID, oldest first
+func (m *MemoryStore) GetEventsAfter(afterID int, since *time.Time, limit int) ([]models.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	events := m.filterEvents(func(event *models.Event) bool {
+		return event.ID > afterID && (since == nil || event.Timestamp.After(*since))
+	})
+	return page(events, 0, limit), nil
+}
+
+// StreamEvents passes every event matching filter to fn, oldest first, like DB.StreamEvents
+func (m *MemoryStore) StreamEvents(ctx context.Context, filter EventExportFilter, fn func(models.Event) error) error {
+	m.mutex.RLock()
+	events := m.filterEvents(func(event *models.Event) bool {
+		return (filter.MachineID == "" || event.MachineID == filter.MachineID) &&
+			(filter.Line == "" || event.Line == filter.Line) &&
+			!event.Timestamp.Before(filter.Since) &&
+			(filter.Until.IsZero() || event.Timestamp.Before(filter.Until))
+	})
+	m.mutex.RUnlock()
+
+	sortChronologically(events)
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAlertContext retrieves an alert's machine's events around the alert, like
+// DB.GetAlertContext
+func (m *MemoryStore) GetAlertContext(alert *models.Alert, before, after time.Duration, limit int) ([]models.Event, time.Time, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	anchor := alert.CreatedAt
+	if alert.EventID != nil {
+		if event := m.event(*alert.EventID); event != nil {
+			anchor = event.Timestamp
+		}
+	}
+
+	return m.machineEventsBetween(alert.MachineID, anchor.Add(-before), anchor.Add(after), limit), anchor, nil
+}
+
+// GetMachineEventsBetween retrieves up to limit of a machine's events in [since, until],
+// oldest first
+func (m *MemoryStore) GetMachineEventsBetween(machineID string, since, until time.Time, limit int) ([]models.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.machineEventsBetween(machineID, since, until, limit), nil
+}
+
+// GetFaultOnsets retrieves the timestamps of a machine's fault onsets since the given time
+func (m *MemoryStore) GetFaultOnsets(machineID string, since time.Time) ([]time.Time, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	events := m.filterEvents(func(event *models.Event) bool {
+		return event.MachineID == machineID && !event.Timestamp.Before(since)
+	})
+	sortChronologically(events)
+
+	var onsets []time.Time
+	for i, event := range events {
+		if event.Status == "fault" && (i == 0 || events[i-1].Status != "fault") {
+			onsets = append(onsets, event.Timestamp)
+		}
+	}
+	return onsets, nil
+}
+
+// GetLatestEvents retrieves the newest event for every machine
+func (m *MemoryStore) GetLatestEvents() ([]models.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	latest := make(map[string]models.Event)
+	for _, event := range m.events {
+		if current, ok := latest[event.MachineID]; !ok || eventBefore(&current, event.Timestamp, event.ID) {
+			latest[event.MachineID] = event
+		}
+	}
+
+	var events []models.Event
+	for _, event := range latest {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].MachineID < events[j].MachineID })
+	return events, nil
+}
+
+// GetSeenMachines retrieves every machine ID with stored events and their first and last
+// timestamps, flagging those that are not registered
+func (m *MemoryStore) GetSeenMachines() ([]models.SeenMachine, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seen := make(map[string]*models.SeenMachine)
+	for _, event := range m.events {
+		machine, ok := seen[event.MachineID]
+		if !ok {
+			machine = &models.SeenMachine{MachineID: event.MachineID, FirstSeen: event.Timestamp, LastSeen: event.Timestamp}
+			seen[event.MachineID] = machine
+		}
+		if event.Timestamp.Before(machine.FirstSeen) {
+			machine.FirstSeen = event.Timestamp
+		}
+		if event.Timestamp.After(machine.LastSeen) {
+			machine.LastSeen = event.Timestamp
+		}
+	}
+
+	var machines []models.SeenMachine
+	for _, machine := range seen {
+		machine.Registered = m.machine(machine.MachineID) != nil
+		machines = append(machines, *machine)
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].MachineID < machines[j].MachineID })
+	return machines, nil
+}
+
+// GetEventStats retrieves aggregated event statistics, like DB.GetEventStats
+func (m *MemoryStore) GetEventStats(machineID, area string, since time.Time, idleStatuses []string) (*models.EventStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	areaMachines := m.areaMachines(area)
+	var stats models.EventStats
+	var temperatures, speeds mean
+	var temperatureValues, speedValues []float64
+	for _, event := range m.events {
+		if (machineID != "" && event.MachineID != machineID) || event.Timestamp.Before(since) ||
+			(areaMachines != nil && !areaMachines[event.MachineID]) {
+			continue
+		}
+
+		stats.TotalEvents++
+		switch {
+		case event.Status == "fault":
+			stats.FaultEvents++
+		case event.Status == "warning":
+			stats.WarningEvents++
+		}
+		if slices.Contains(idleStatuses, event.Status) {
+			stats.IdleEvents++
+		}
+		temperatures.add(event.Temperature)
+		speeds.add(event.ConveyorSpeed)
+		if event.Temperature != nil {
+			temperatureValues = append(temperatureValues, *event.Temperature)
+		}
+		if event.ConveyorSpeed != nil {
+			speedValues = append(speedValues, *event.ConveyorSpeed)
+		}
+		if event.Timestamp.After(stats.LastEventTime) {
+			stats.LastEventTime = event.Timestamp
+		}
+	}
+	stats.AvgTemperature = temperatures.valueOrZero()
+	stats.AvgConveyorSpeed = speeds.valueOrZero()
+	stats.P95Temperature = models.Percentile(temperatureValues, 0.95)
+	stats.P99Temperature = models.Percentile(temperatureValues, 0.99)
+	stats.P95ConveyorSpeed = models.Percentile(speedValues, 0.95)
+	stats.P99ConveyorSpeed = models.Percentile(speedValues, 0.99)
+
+	return &stats, nil
+}
+
+// GetRawDataStats aggregates a numeric raw_data key, like DB.GetRawDataStats
+func (m *MemoryStore) GetRawDataStats(field, machineID string, since time.Time) (*models.RawDataStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := models.RawDataStats{Field: field}
+	var values mean
+	for _, event := range m.events {
+		if (machineID != "" && event.MachineID != machineID) || event.Timestamp.Before(since) {
+			continue
+		}
+		value, ok := event.RawData[field].(float64)
+		if !ok {
+			continue
+		}
+
+		stats.SampleCount++
+		values.add(&value)
+		if stats.Min == nil || value < *stats.Min {
+			stats.Min = &value
+		}
+		if stats.Max == nil || value > *stats.Max {
+			stats.Max = &value
+		}
+	}
+	stats.Avg = values.value()
+
+	return &stats, nil
+}
+
+// InsertAlert stores a new alert, assigning its ID and creation time
+func (m *MemoryStore) InsertAlert(alert *models.Alert) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.insertAlert(*alert, time.Now(), alert.Test)
+	return nil
+}
+
+// InsertAlertsBatch stores alerts, setting each alert's ID and creation time
+func (m *MemoryStore) InsertAlertsBatch(alerts []*models.Alert) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for _, alert := range alerts {
+		m.insertAlert(*alert, now, alert.Test)
+		alert.ID = len(m.alerts)
+		alert.CreatedAt = now
+	}
+	return nil
+}
+
+// InsertAlertUnlessDuplicate stores an alert with its own created_at unless it duplicates
+// a stored one, like DB.InsertAlertUnlessDuplicate
+func (m *MemoryStore) InsertAlertUnlessDuplicate(alert *models.Alert, tolerance time.Duration) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, stored := range m.alerts {
+		if stored.MachineID != alert.MachineID || stored.AlertType != alert.AlertType || stored.Test {
+			continue
+		}
+		sameEvent := stored.EventID != nil && alert.EventID != nil && *stored.EventID == *alert.EventID
+		if sameEvent || withinTolerance(stored.CreatedAt, alert.CreatedAt, tolerance) {
+			return false, nil
+		}
+	}
+
+	m.insertAlert(*alert, alert.CreatedAt, false)
+	return true, nil
+}
+
+// GetAlert retrieves a single alert by ID
+func (m *MemoryStore) GetAlert(alertID int) (*models.Alert, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	alert := m.alert(alertID)
+	if alert == nil {
+		return nil, ErrNotFound
+	}
+	found := *alert
+	return &found, nil
+}
+
+// GetAlertHandlingTimes measures how quickly a machine's non-test alerts created since the
+// given time were acknowledged and resolved
+func (m *MemoryStore) GetAlertHandlingTimes(machineID string, since time.Time) (*AlertHandlingTimes, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var times AlertHandlingTimes
+	var toAcknowledge, toResolve mean
+	for _, alert := range m.alerts {
+		if alert.MachineID != machineID || alert.CreatedAt.Before(since) || alert.Test {
+			continue
+		}
+		if alert.AcknowledgedAt != nil {
+			times.Acknowledged++
+			seconds := alert.AcknowledgedAt.Sub(alert.CreatedAt).Seconds()
+			toAcknowledge.add(&seconds)
+		}
+		if alert.ResolvedAt != nil {
+			times.Resolved++
+			seconds := alert.ResolvedAt.Sub(alert.CreatedAt).Seconds()
+			toResolve.add(&seconds)
+		}
+	}
+	times.MeanToAcknowledge = toAcknowledge.value()
+	times.MeanToResolve = toResolve.value()
+
+	return &times, nil
+}
+
+// GetActiveAlertCounts counts the non-test alerts that are neither acknowledged nor
+// resolved, by severity
+func (m *MemoryStore) GetActiveAlertCounts() (map[string]int64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, alert := range m.alerts {
+		if !alert.Acknowledged && alert.ResolvedAt == nil && !alert.Test {
+			counts[alert.Severity]++
+		}
+	}
+	return counts, nil
+}
+
+// GetUnacknowledgedAlerts retrieves unacknowledged alerts, like DB.GetUnacknowledgedAlerts
+func (m *MemoryStore) GetUnacknowledgedAlerts(severities []string, area string) ([]models.Alert, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.unacknowledgedAlerts(severities, area), nil
+}
+
+// GetAlertsWithEvents retrieves the same alerts as GetUnacknowledgedAlerts, each with the
+// event that triggered it
+func (m *MemoryStore) GetAlertsWithEvents(severities []string, area string) ([]models.AlertWithEvent, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var alerts []models.AlertWithEvent
+	for _, alert := range m.unacknowledgedAlerts(severities, area) {
+		withEvent := models.AlertWithEvent{Alert: alert}
+		if alert.EventID != nil {
+			if event := m.event(*alert.EventID); event != nil {
+				found := *event
+				withEvent.Event = &found
+			}
+		}
+		alerts = append(alerts, withEvent)
+	}
+	return alerts, nil
+}
+
+// StreamAlerts passes every alert matching filter to fn, oldest first. It stops at the
+// first error fn returns or when ctx is done.
+func (m *MemoryStore) StreamAlerts(ctx context.Context, filter AlertFilter, fn func(models.Alert) error) error {
+	m.mutex.RLock()
+	var alerts []models.Alert
+	for _, alert := range m.alerts {
+		if (len(filter.Severities) == 0 || slices.Contains(filter.Severities, alert.Severity)) &&
+			(filter.AlertType == "" || alert.AlertType == filter.AlertType) &&
+			(filter.MachineID == "" || alert.MachineID == filter.MachineID) &&
+			!alert.CreatedAt.Before(filter.Since) &&
+			(filter.Until.IsZero() || alert.CreatedAt.Before(filter.Until)) &&
+			(filter.Acknowledged == nil || alert.Acknowledged == *filter.Acknowledged) &&
+			(filter.IncludeTest || !alert.Test) {
+			alerts = append(alerts, alert)
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.Before(alerts[j].CreatedAt) })
+	for _, alert := range alerts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCurrentAlerts retrieves the most recent unresolved, unacknowledged alert for each
+// machine and alert type
+func (m *MemoryStore) GetCurrentAlerts() ([]models.Alert, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	current := make(map[snoozeKey]models.Alert)
+	for _, alert := range m.alerts {
+		if alert.Acknowledged || alert.ResolvedAt != nil || alert.Test {
+			continue
+		}
+		key := snoozeKey{machineID: alert.MachineID, alertType: alert.AlertType}
+		if latest, ok := current[key]; !ok || !alert.CreatedAt.Before(latest.CreatedAt) {
+			current[key] = alert
+		}
+	}
+
+	var alerts []models.Alert
+	for _, alert := range current {
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].MachineID != alerts[j].MachineID {
+			return alerts[i].MachineID < alerts[j].MachineID
+		}
+		return alerts[i].AlertType < alerts[j].AlertType
+	})
+	return alerts, nil
+}
+
+// GetAlertTrend counts non-test alerts per time bucket and severity since the given time
+func (m *MemoryStore) GetAlertTrend(since time.Time, interval time.Duration) ([]models.AlertTrendBucket, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	type bucketKey struct {
+		start    int64
+		severity string
+	}
+	counts := make(map[bucketKey]int64)
+	seconds := interval.Seconds()
+	for _, alert := range m.alerts {
+		if alert.CreatedAt.Before(since) || alert.Test {
+			continue
+		}
+		// Buckets are aligned to the Unix epoch, as in the SQL query
+		start := math.Floor(float64(alert.CreatedAt.UnixNano())/1e9/seconds) * seconds
+		counts[bucketKey{start: int64(start * 1e9), severity: alert.Severity}]++
+	}
+
+	var buckets []models.AlertTrendBucket
+	for key, count := range counts {
+		buckets = append(buckets, models.AlertTrendBucket{
+			BucketStart: time.Unix(0, key.start),
+			Severity:    key.severity,
+			Count:       count,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if !buckets[i].BucketStart.Equal(buckets[j].BucketStart) {
+			return buckets[i].BucketStart.Before(buckets[j].BucketStart)
+		}
+		return buckets[i].Severity < buckets[j].Severity
+	})
+	return buckets, nil
+}
+
+// AcknowledgeAlert marks an alert as acknowledged, returning ErrNotFound if it does not exist
+func (m *MemoryStore) AcknowledgeAlert(alertID int, acknowledgedBy, note string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	alert := m.alert(alertID)
+	if alert == nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	alert.Acknowledged = true
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgedBy = nullString(acknowledgedBy)
+	alert.AcknowledgementNote = nullString(note)
+	return nil
+}
+
+// ResolveAlerts marks the unresolved alerts of a type for a machine as resolved
+func (m *MemoryStore) ResolveAlerts(resolution *models.AlertResolution) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range m.alerts {
+		alert := &m.alerts[i]
+		if alert.MachineID == resolution.MachineID && alert.AlertType == resolution.AlertType && alert.ResolvedAt == nil {
+			resolvedAt := resolution.ResolvedAt
+			alert.ResolvedAt = &resolvedAt
+		}
+	}
+	return nil
+}
+
+// SnoozeAlerts records a snooze for a machine and alert type, replacing any existing one
+func (m *MemoryStore) SnoozeAlerts(machineID, alertType string, until time.Time) (*models.AlertSnooze, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snooze := models.AlertSnooze{MachineID: machineID, AlertType: alertType, SnoozedUntil: until, CreatedAt: time.Now()}
+	m.snoozes[snoozeKey{machineID: machineID, alertType: alertType}] = snooze
+	return &snooze, nil
+}
+
+// GetActiveSnoozes retrieves snoozes that have not yet expired
+func (m *MemoryStore) GetActiveSnoozes() ([]models.AlertSnooze, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	var snoozes []models.AlertSnooze
+	for _, snooze := range m.snoozes {
+		if snooze.SnoozedUntil.After(now) {
+			snoozes = append(snoozes, snooze)
+		}
+	}
+	sort.Slice(snoozes, func(i, j int) bool { return snoozes[i].SnoozedUntil.Before(snoozes[j].SnoozedUntil) })
+	return snoozes, nil
+}
+
+// SetAnomalyRules records whether each of the given anomaly detection rules is enabled
+func (m *MemoryStore) SetAnomalyRules(rules map[string]bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for rule, enabled := range rules {
+		m.rules[rule] = enabled
+	}
+	return nil
+}
+
+// GetAnomalyRuleSettings retrieves the recorded enabled state of anomaly detection rules
+func (m *MemoryStore) GetAnomalyRuleSettings() (map[string]bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	settings := make(map[string]bool, len(m.rules))
+	for rule, enabled := range m.rules {
+		settings[rule] = enabled
+	}
+	return settings, nil
+}
+
+// GetProcessParameters retrieves all process parameters
+func (m *MemoryStore) GetProcessParameters() ([]models.ProcessParameter, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	params := slices.Clone(m.params)
+	sort.Slice(params, func(i, j int) bool { return params[i].ParameterName < params[j].ParameterName })
+	return params, nil
+}
+
+// UpdateProcessParameter updates a process parameter, returning its previous value
+func (m *MemoryStore) UpdateProcessParameter(name, value string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range m.params {
+		param := &m.params[i]
+		if param.ParameterName == name {
+			previous := param.ParameterValue
+			param.ParameterValue = value
+			param.UpdatedAt = time.Now()
+			return previous, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// InsertAuditEntry records an audit entry
+func (m *MemoryStore) InsertAuditEntry(entry *models.AuditEntry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := *entry
+	stored.ID = int64(len(m.audit) + 1)
+	stored.CreatedAt = time.Now()
+	if len(stored.OldValue) == 0 {
+		stored.OldValue = nil
+	}
+	if len(stored.NewValue) == 0 {
+		stored.NewValue = nil
+	}
+	m.audit = append(m.audit, stored)
+	return nil
+}
+
+// GetAuditLog retrieves audit entries matching filter, newest first
+func (m *MemoryStore) GetAuditLog(filter AuditFilter) ([]models.AuditEntry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	entries := []models.AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := m.audit[i]
+		if (filter.Actor == "" || entry.Actor == filter.Actor) &&
+			(filter.Action == "" || entry.Action == filter.Action) &&
+			(filter.Target == "" || entry.Target == filter.Target) &&
+			!entry.CreatedAt.Before(filter.Since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// GetMachines retrieves all machines
+func (m *MemoryStore) GetMachines() ([]models.Machine, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	machines := slices.Clone(m.machines)
+	sort.Slice(machines, func(i, j int) bool { return machines[i].MachineID < machines[j].MachineID })
+	return machines, nil
+}
+
+// filterEvents returns copies of the stored events matching keep, in ID order
+func (m *MemoryStore) filterEvents(keep func(*models.Event) bool) []models.Event {
+	var events []models.Event
+	for i := range m.events {
+		if keep(&m.events[i]) {
+			events = append(events, m.events[i])
+		}
+	}
+	return events
+}
+
+// machineEventsBetween returns up to limit of a machine's events in [since, until], oldest first
+func (m *MemoryStore) machineEventsBetween(machineID string, since, until time.Time, limit int) []models.Event {
+	events := m.filterEvents(func(event *models.Event) bool {
+		return event.MachineID == machineID && !event.Timestamp.Before(since) && !event.Timestamp.After(until)
+	})
+	sortChronologically(events)
+	return page(events, 0, limit)
+}
+
+// event returns the stored event with the given ID, or nil
+func (m *MemoryStore) event(eventID int) *models.Event {
+	if eventID < 1 || eventID > len(m.events) {
+		return nil
+	}
+	return &m.events[eventID-1]
+}
+
+// alert returns the stored alert with the given ID, or nil
+func (m *MemoryStore) alert(alertID int) *models.Alert {
+	if alertID < 1 || alertID > len(m.alerts) {
+		return nil
+	}
+	return &m.alerts[alertID-1]
+}
+
+// machine returns the registered machine with the given ID, or nil
+func (m *MemoryStore) machine(machineID string) *models.Machine {
+	for i := range m.machines {
+		if m.machines[i].MachineID == machineID {
+			return &m.machines[i]
+		}
+	}
+	return nil
+}
+
+// areaMachines returns the set of machine IDs registered in an area, or nil when area is
+// empty and no restriction applies
+func (m *MemoryStore) areaMachines(area string) map[string]bool {
+	if area == "" {
+		return nil
+	}
+	machines := make(map[string]bool)
+	for _, machine := range m.machines {
+		if machine.Area == area {
+			machines[machine.MachineID] = true
+		}
+	}
+	return machines
+}
+
+// insertAlert stores a copy of alert with a new ID, the given creation time and no
+// acknowledgement or resolution
+func (m *MemoryStore) insertAlert(alert models.Alert, createdAt time.Time, test bool) {
+	alert.ID = len(m.alerts) + 1
+	alert.CreatedAt = createdAt
+	alert.Test = test
+	alert.Acknowledged = false
+	alert.AcknowledgedAt, alert.AcknowledgedBy, alert.AcknowledgementNote, alert.ResolvedAt = nil, nil, nil, nil
+	m.alerts = append(m.alerts, alert)
+}
+
+// unacknowledgedAlerts returns up to 100 unacknowledged alerts, newest first, optionally
+// restricted to the given severities and to machines in an area
+func (m *MemoryStore) unacknowledgedAlerts(severities []string, area string) []models.Alert {
+	areaMachines := m.areaMachines(area)
+	var alerts []models.Alert
+	for _, alert := range m.alerts {
+		if !alert.Acknowledged && (len(severities) == 0 || slices.Contains(severities, alert.Severity)) &&
+			(areaMachines == nil || areaMachines[alert.MachineID]) {
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	return page(alerts, 0, 100)
+}
+
+// eventBefore reports whether an event precedes the (timestamp, id) position
+func eventBefore(event *models.Event, timestamp time.Time, id int) bool {
+	if !event.Timestamp.Equal(timestamp) {
+		return event.Timestamp.Before(timestamp)
+	}
+	return event.ID < id
+}
+
+// sortChronologically orders events by timestamp, then ID
+func sortChronologically(events []models.Event) {
+	sort.Slice(events, func(i, j int) bool { return eventBefore(&events[i], events[j].Timestamp, events[j].ID) })
+}
+
+// withinTolerance reports whether two times are at most tolerance apart
+func withinTolerance(a, b time.Time, tolerance time.Duration) bool {
+	difference := a.Sub(b)
+	return difference >= -tolerance && difference <= tolerance
+}
+
+// page applies an offset and limit to a result set; a limit of zero or less returns nothing,
+// as LIMIT 0 does
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) || limit <= 0 {
+		return nil
+	}
+	items = items[offset:]
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// nullString stores an empty string as NULL
+func nullString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// mean accumulates the average of optional values, skipping nil ones as AVG skips NULLs
+type mean struct {
+	sum   float64
+	count int
+}
+
+func (a *mean) add(value *float64) {
+	if value != nil {
+		a.sum += *value
+		a.count++
+	}
+}
+
+// value returns the average, or nil when no values were added
+func (a *mean) value() *float64 {
+	if a.count == 0 {
+		return nil
+	}
+	average := a.sum / float64(a.count)
+	return &average
+}
+
+// valueOrZero returns the average, or 0 when no values were added
+func (a *mean) valueOrZero() float64 {
+	if average := a.value(); average != nil {
+		return *average
+	}
+	return 0
+}
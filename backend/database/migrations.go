@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// migrationLockID keys the advisory lock serializing schema migrations across replicas
+// of the server starting at once
+const migrationLockID = 4_181_204_001
+
+// schemaMigrations bring a database created from an older database/init.sql up to the
+// current schema. Postgres runs init.sql only when it creates the database, so every
+// table, column and index added since must also be listed here. Each statement is
+// idempotent and they run at every startup; keep them in sync with init.sql.
+var schemaMigrations = []string{
+	// Events: production line (derived from the Kafka topic) and parsed fault codes
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS line VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE events ADD COLUMN IF NOT EXISTS fault_code VARCHAR(50)`,
+
+	// Alerts: machine attribution, confidence, fault taxonomy guidance, acknowledgement
+	// details, resolution and test alerts
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS machine_id VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION CHECK (confidence BETWEEN 0 AND 1)`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS recommended_action TEXT`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_by VARCHAR(100)`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledgement_note TEXT`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ`,
+	`ALTER TABLE alerts ADD COLUMN IF NOT EXISTS test BOOLEAN NOT NULL DEFAULT FALSE`,
+	// Alerts stored before machine attribution take the machine of their event
+	`UPDATE alerts SET machine_id = events.machine_id FROM events
+		WHERE alerts.machine_id = '' AND alerts.event_id = events.id`,
+
+	// Machines: plant area and production line grouping
+	`ALTER TABLE machines ADD COLUMN IF NOT EXISTS area VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE machines ADD COLUMN IF NOT EXISTS line VARCHAR(50) NOT NULL DEFAULT ''`,
+
+	`CREATE TABLE IF NOT EXISTS alert_snoozes (
+		machine_id VARCHAR(50) NOT NULL,
+		alert_type VARCHAR(50) NOT NULL,
+		snoozed_until TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (machine_id, alert_type)
+	)`,
+	`CREATE TABLE IF NOT EXISTS anomaly_rules (
+		rule_name VARCHAR(50) PRIMARY KEY,
+		enabled BOOLEAN NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		actor VARCHAR(100) NOT NULL DEFAULT '',
+		client_ip VARCHAR(45) NOT NULL DEFAULT '',
+		action VARCHAR(50) NOT NULL,
+		target VARCHAR(100) NOT NULL DEFAULT '',
+		old_value JSONB,
+		new_value JSONB
+	)`,
+
+	`CREATE INDEX IF NOT EXISTS idx_events_machine_timestamp ON events(machine_id, timestamp DESC, id DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_line ON events(line)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_fault_code ON events(fault_code) WHERE fault_code IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_alerts_acknowledged_created_at ON alerts(acknowledged, created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_machines_area ON machines(area)`,
+	`CREATE INDEX IF NOT EXISTS idx_alerts_machine_type ON alerts(machine_id, alert_type, created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at DESC)`,
+}
+
+// Migrate applies the schema migrations on the primary in one transaction, so a failure
+// leaves the schema as it was
+func (db *DB) Migrate(ctx context.Context) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin schema migration: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock schema migration: %v", err)
+	}
+	for _, statement := range schemaMigrations {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to migrate schema: %v\n%s", err, statement)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schema migration: %v", err)
+	}
+
+	log.Printf("Database schema up to date (%d migrations checked)", len(schemaMigrations))
+	return nil
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// statementDriver is a database/sql driver that records the statements and transaction
+// boundaries it sees, failing statements containing failOn. Queries return no rows
+// unless rows were queued for the next query.
+type statementDriver struct {
+	mutex  sync.Mutex
+	log    []string
+	args   [][]driver.Value // Bound values of each query, valuers resolved
+	rows   [][]driver.Value // Result set of the next query
+	failOn string
+}
+
+func (d *statementDriver) Open(string) (driver.Conn, error) {
+	return &statementConn{driver: d}, nil
+}
+
+func (d *statementDriver) record(entry string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.log = append(d.log, entry)
+}
+
+type statementConn struct {
+	driver *statementDriver
+}
+
+func (c *statementConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *statementConn) Close() error { return nil }
+
+func (c *statementConn) Begin() (driver.Tx, error) {
+	c.driver.record("BEGIN")
+	return statementTx{c.driver}, nil
+}
+
+func (c *statementConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *statementConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(query)
+	if c.driver.failOn != "" && strings.Contains(query, c.driver.failOn) {
+		return nil, errors.New("permission denied")
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *statementConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+		if valuer, ok := arg.Value.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+
+	c.driver.record(query)
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+	c.driver.args = append(c.driver.args, values)
+	rows := c.driver.rows
+	c.driver.rows = nil
+	if rows == nil {
+		return emptyRows{}, nil
+	}
+	return &queuedRows{rows: rows}, nil
+}
+
+// emptyRows is a result set with no rows
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// queuedRows is a result set queued by a test, with unnamed columns
+type queuedRows struct {
+	rows [][]driver.Value
+}
+
+func (r *queuedRows) Columns() []string { return make([]string, len(r.rows[0])) }
+func (r *queuedRows) Close() error      { return nil }
+
+func (r *queuedRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type statementTx struct {
+	driver *statementDriver
+}
+
+func (tx statementTx) Commit() error   { tx.driver.record("COMMIT"); return nil }
+func (tx statementTx) Rollback() error { tx.driver.record("ROLLBACK"); return nil }
+
+var statementRecorder = &statementDriver{}
+
+func init() {
+	sql.Register("statement-recorder", statementRecorder)
+}
+
+// newStatementDB returns a DB on the statement recorder, failing statements containing failOn
+func newStatementDB(t *testing.T, failOn string) *DB {
+	t.Helper()
+	primary, err := sql.Open("statement-recorder", "primary")
+	if err != nil {
+		t.Fatalf("opening primary: %v", err)
+	}
+	primary.SetMaxOpenConns(1)
+	t.Cleanup(func() { primary.Close() })
+
+	statementRecorder.mutex.Lock()
+	statementRecorder.log, statementRecorder.args, statementRecorder.rows, statementRecorder.failOn = nil, nil, nil, failOn
+	statementRecorder.mutex.Unlock()
+	return &DB{DB: primary}
+}
+
+// queueRows sets the rows returned by the next query on the statement recorder
+func queueRows(rows ...[]driver.Value) {
+	statementRecorder.mutex.Lock()
+	defer statementRecorder.mutex.Unlock()
+	statementRecorder.rows = rows
+}
+
+func TestMigrateAppliesMigrationsInOneTransaction(t *testing.T) {
+	db := newStatementDB(t, "")
+	if err := db.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	log := statementRecorder.log
+	if len(log) != len(schemaMigrations)+3 {
+		t.Fatalf("ran %d statements, want BEGIN, the lock, %d migrations and COMMIT", len(log), len(schemaMigrations))
+	}
+	if log[0] != "BEGIN" || !strings.Contains(log[1], "pg_advisory_xact_lock") || log[len(log)-1] != "COMMIT" {
+		t.Errorf("statements = %q, want the migrations locked within a transaction", log)
+	}
+	for i, statement := range schemaMigrations {
+		if log[i+2] != statement {
+			t.Errorf("statement %d = %q, want %q", i, log[i+2], statement)
+		}
+	}
+}
+
+func TestMigrateRollsBackOnFailure(t *testing.T) {
+	db := newStatementDB(t, "ALTER TABLE machines")
+	if err := db.Migrate(context.Background()); err == nil {
+		t.Fatal("Migrate succeeded despite a failed statement")
+	}
+
+	log := statementRecorder.log
+	if last := log[len(log)-1]; last != "ROLLBACK" {
+		t.Errorf("last statement = %q, want ROLLBACK", last)
+	}
+	for _, statement := range log {
+		if statement == "COMMIT" {
+			t.Error("failed migration committed")
+		}
+	}
+}
+
+func TestSchemaMigrationsAreIdempotent(t *testing.T) {
+	for _, statement := range schemaMigrations {
+		if !strings.Contains(statement, "IF NOT EXISTS") && !strings.Contains(statement, "WHERE alerts.machine_id = ''") {
+			t.Errorf("statement is not safe to re-run at every startup:\n%s", statement)
+		}
+	}
+}
+
+// TestSchemaMigrationsMatchInitSQL checks that every column upgrade and index in
+// database/init.sql is also applied at startup
+func TestSchemaMigrationsMatchInitSQL(t *testing.T) {
+	initSQL, err := os.ReadFile("../../database/init.sql")
+	if err != nil {
+		t.Skipf("init.sql not available: %v", err)
+	}
+
+	migrations := strings.Join(schemaMigrations, "\n")
+	upgrades := regexp.MustCompile(`(?m)^(ALTER TABLE .*ADD COLUMN IF NOT EXISTS .*|CREATE INDEX IF NOT EXISTS .*);$`)
+	for _, match := range upgrades.FindAllStringSubmatch(string(initSQL), -1) {
+		statement := match[1]
+		if baseIndex(statement) {
+			continue
+		}
+		if !strings.Contains(migrations, statement) {
+			t.Errorf("init.sql statement not applied at startup:\n%s", statement)
+		}
+	}
+}
+
+// baseIndex reports whether an index statement is part of the original schema, which
+// every database already has
+func baseIndex(statement string) bool {
+	return strings.Contains(statement, "idx_events_timestamp ") ||
+		strings.Contains(statement, "idx_events_status ") ||
+		strings.Contains(statement, "idx_alerts_created_at ")
+}
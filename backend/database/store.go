@@ -0,0 +1,61 @@
+package database
+
+import (
+	"backend/models"
+	"context"
+	"time"
+)
+
+// Store is the persistence layer used by the handlers and services. DB implements it on
+// Postgres; MemoryStore keeps everything in memory.
+type Store interface {
+	// Connect blocks until the store can be reached or ctx is done
+	Connect(ctx context.Context, maxBackoff time.Duration) error
+	Connected() bool
+	PingContext(ctx context.Context) error
+	HasReplica() bool
+	PingReplica(ctx context.Context) error
+	Close() error
+
+	InsertEvent(event *models.SensorEvent) (*models.Event, error)
+	GetRecentEvents(filter EventFilter) ([]models.Event, error)
+	GetEventsAfter(afterID int, since *time.Time, limit int) ([]models.Event, error)
+	StreamEvents(ctx context.Context, filter EventExportFilter, fn func(models.Event) error) error
+	GetAlertContext(alert *models.Alert, before, after time.Duration, limit int) ([]models.Event, time.Time, error)
+	GetMachineEventsBetween(machineID string, since, until time.Time, limit int) ([]models.Event, error)
+	GetFaultOnsets(machineID string, since time.Time) ([]time.Time, error)
+	GetLatestEvents() ([]models.Event, error)
+	GetSeenMachines() ([]models.SeenMachine, error)
+	GetEventStats(machineID, area string, since time.Time, idleStatuses []string) (*models.EventStats, error)
+	GetRawDataStats(field, machineID string, since time.Time) (*models.RawDataStats, error)
+
+	InsertAlert(alert *models.Alert) error
+	InsertAlertsBatch(alerts []*models.Alert) error
+	InsertAlertUnlessDuplicate(alert *models.Alert, tolerance time.Duration) (bool, error)
+	GetAlert(alertID int) (*models.Alert, error)
+	GetAlertHandlingTimes(machineID string, since time.Time) (*AlertHandlingTimes, error)
+	GetActiveAlertCounts() (map[string]int64, error)
+	GetUnacknowledgedAlerts(severities []string, area string) ([]models.Alert, error)
+	GetAlertsWithEvents(severities []string, area string) ([]models.AlertWithEvent, error)
+	StreamAlerts(ctx context.Context, filter AlertFilter, fn func(models.Alert) error) error
+	GetCurrentAlerts() ([]models.Alert, error)
+	GetAlertTrend(since time.Time, interval time.Duration) ([]models.AlertTrendBucket, error)
+	AcknowledgeAlert(alertID int, acknowledgedBy, note string) error
+	ResolveAlerts(resolution *models.AlertResolution) error
+
+	SnoozeAlerts(machineID, alertType string, until time.Time) (*models.AlertSnooze, error)
+	GetActiveSnoozes() ([]models.AlertSnooze, error)
+	SetAnomalyRules(rules map[string]bool) error
+	GetAnomalyRuleSettings() (map[string]bool, error)
+
+	GetProcessParameters() ([]models.ProcessParameter, error)
+	UpdateProcessParameter(name, value string) (string, error)
+	InsertAuditEntry(entry *models.AuditEntry) error
+	GetAuditLog(filter AuditFilter) ([]models.AuditEntry, error)
+	GetMachines() ([]models.Machine, error)
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
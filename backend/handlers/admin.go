@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/services"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin rejects requests without the admin bearer token. Admin endpoints are
+// disabled entirely when no token is configured.
+func (h *Handler) RequireAdmin(c *gin.Context) {
+	if h.cfg.Server.AdminToken == "" {
+		writeError(c, http.StatusForbidden, ErrCodeForbidden, "Admin endpoints are disabled", nil)
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.Server.AdminToken)) != 1 {
+		c.Header("WWW-Authenticate", "Bearer")
+		writeError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Admin token required", nil)
+		return
+	}
+
+	c.Next()
+}
+
+// GetDetectorState dumps the anomaly detector's per-machine state for debugging
+func (h *Handler) GetDetectorState(c *gin.Context) {
+	c.JSON(http.StatusOK, h.anomalyDetector.Snapshot())
+}
+
+// FireTestAlert raises a synthetic alert through the same path as detected alerts, so
+// storage and WebSocket delivery can be verified end to end. The alert is flagged as a
+// test, which keeps it out of current alerts and alert statistics.
+func (h *Handler) FireTestAlert(c *gin.Context) {
+	var request struct {
+		MachineID string `json:"machine_id" binding:"required"`
+		AlertType string `json:"alert_type"`
+		Severity  string `json:"severity"`
+		Message   string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeBodyError(c, "Invalid request body", err)
+		return
+	}
+
+	alert := &models.Alert{
+		MachineID: h.validator.NormalizeMachineID(request.MachineID),
+		AlertType: request.AlertType,
+		Severity:  strings.ToLower(request.Severity),
+		Message:   request.Message,
+		CreatedAt: time.Now(),
+		Test:      true,
+	}
+	if alert.AlertType == "" {
+		alert.AlertType = "test"
+	}
+	if alert.Severity == "" {
+		alert.Severity = "low"
+	}
+	if _, ok := models.SeverityLevels[alert.Severity]; !ok {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Invalid severity: %s", request.Severity), nil)
+		return
+	}
+	if alert.Message == "" {
+		alert.Message = fmt.Sprintf("Test alert for machine %s", alert.MachineID)
+	}
+
+	h.alertSink(alert)
+	h.audit(c, "", AuditAlertTest, "machine:"+alert.MachineID, nil, alert)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Test alert fired",
+		"alert":   alert,
+	})
+}
+
+// defaultReprocessTolerance is how close an existing alert of the same type must be to a
+// replayed event for the regenerated alert to count as a duplicate, when none is given
+const defaultReprocessTolerance = time.Minute
+
+// ReprocessAlerts replays a machine's stored events in a time window through the current
+// detection rules and stores any alerts they raise that are not already recorded, e.g.
+// after thresholds are tightened or a rule is re-enabled
+func (h *Handler) ReprocessAlerts(c *gin.Context) {
+	var request struct {
+		MachineID string    `json:"machine_id" binding:"required"`
+		Since     time.Time `json:"since" binding:"required"`
+		Until     time.Time `json:"until"`
+		Tolerance string    `json:"tolerance"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeBodyError(c, "Invalid request body", err)
+		return
+	}
+
+	if request.Until.IsZero() {
+		request.Until = time.Now()
+	}
+	if !request.Until.After(request.Since) {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "until must be after since", nil)
+		return
+	}
+	if maxLookback := h.cfg.Server.MaxLookback; maxLookback > 0 && request.Until.Sub(request.Since) > maxLookback {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+			fmt.Sprintf("Time range exceeds the maximum of %g days", maxLookback.Hours()/24), nil)
+		return
+	}
+
+	tolerance := defaultReprocessTolerance
+	if request.Tolerance != "" {
+		parsed, err := time.ParseDuration(request.Tolerance)
+		if err != nil || parsed < 0 {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tolerance (expected a non-negative duration)", err)
+			return
+		}
+		tolerance = parsed
+	}
+
+	machineID := h.validator.NormalizeMachineID(request.MachineID)
+	result, err := services.Reprocess(h.db, h.anomalyDetector, machineID, request.Since, request.Until, tolerance)
+	if errors.Is(err, services.ErrReprocessTooLarge) {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to reprocess events", err)
+		return
+	}
+	h.audit(c, "", AuditAnomalyReprocess, "machine:"+machineID, nil, result)
+
+	c.JSON(http.StatusOK, result)
+}
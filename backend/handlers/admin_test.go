@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"backend/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fireTestAlert posts a test alert through the admin check with the given bearer token
+func fireTestAlert(handler *Handler, token, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/alerts/test", handler.RequireAdmin, handler.FireTestAlert)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/alerts/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestFireTestAlertRequiresAdminToken(t *testing.T) {
+	handler, store := newTestHandler(t)
+	body := `{"machine_id": "conveyor_001"}`
+
+	expectStatus(t, fireTestAlert(handler, "", body), http.StatusForbidden)
+
+	handler.cfg.Server.AdminToken = "secret"
+	expectStatus(t, fireTestAlert(handler, "", body), http.StatusUnauthorized)
+	expectStatus(t, fireTestAlert(handler, "wrong", body), http.StatusUnauthorized)
+
+	if alerts, _ := store.GetUnacknowledgedAlerts(nil, ""); len(alerts) != 0 {
+		t.Errorf("stored %d alerts from rejected requests", len(alerts))
+	}
+}
+
+func TestFireTestAlertStoresTestAlert(t *testing.T) {
+	handler, store := newTestHandler(t)
+	handler.cfg.Server.AdminToken = "secret"
+
+	recorder := fireTestAlert(handler, "secret", `{"machine_id": "conveyor_001", "severity": "HIGH"}`)
+	expectStatus(t, recorder, http.StatusAccepted)
+
+	alerts, err := store.GetUnacknowledgedAlerts(nil, "")
+	if err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("stored %d alerts, want 1", len(alerts))
+	}
+	alert := alerts[0]
+	if !alert.Test || alert.AlertType != "test" || alert.Severity != "high" || alert.MachineID != "conveyor_001" {
+		t.Errorf("alert = %+v, want a high test alert for conveyor_001", alert)
+	}
+
+	// Test alerts stay out of the active counts
+	if counts, _ := store.GetActiveAlertCounts(); len(counts) != 0 {
+		t.Errorf("active alert counts = %v, want none", counts)
+	}
+
+	recorder = fireTestAlert(handler, "secret", `{"machine_id": "conveyor_001", "severity": "urgent"}`)
+	expectStatus(t, recorder, http.StatusBadRequest)
+}
+
+func TestReprocessAlertsSkipsStoredDuplicates(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for i := 0; i < 3; i++ {
+		insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(150)},
+			time.Duration(30-10*i)*time.Minute)
+	}
+	body := fmt.Sprintf(`{"machine_id": "conveyor_001", "since": %q}`, time.Now().Add(-time.Hour).Format(time.RFC3339))
+
+	recorder := request(handler.ReprocessAlerts, "POST", "/anomaly/reprocess", "/anomaly/reprocess", body)
+	expectStatus(t, recorder, http.StatusOK)
+	var first models.ReprocessResult
+	decode(t, recorder, &first)
+	if first.EventsReplayed != 3 || first.AlertsDetected == 0 || first.AlertsInserted != first.AlertsDetected {
+		t.Fatalf("first reprocess = %+v, want 3 events replayed and every detected alert inserted", first)
+	}
+
+	recorder = request(handler.ReprocessAlerts, "POST", "/anomaly/reprocess", "/anomaly/reprocess", body)
+	expectStatus(t, recorder, http.StatusOK)
+	var second models.ReprocessResult
+	decode(t, recorder, &second)
+	if second.AlertsInserted != 0 || second.DuplicatesSkipped != first.AlertsInserted {
+		t.Errorf("second reprocess = %+v, want all %d alerts skipped as duplicates", second, first.AlertsInserted)
+	}
+
+	alerts, _ := store.GetUnacknowledgedAlerts(nil, "")
+	if len(alerts) != first.AlertsInserted {
+		t.Errorf("stored %d alerts, want %d", len(alerts), first.AlertsInserted)
+	}
+}
+
+func TestReprocessAlertsRejectsInvalidRange(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	since := time.Now().Add(-time.Hour)
+
+	for _, body := range []string{
+		fmt.Sprintf(`{"machine_id": "conveyor_001", "since": %q, "until": %q}`, since.Format(time.RFC3339), since.Add(-time.Minute).Format(time.RFC3339)),
+		fmt.Sprintf(`{"machine_id": "conveyor_001", "since": %q, "tolerance": "-1m"}`, since.Format(time.RFC3339)),
+		`{"machine_id": "conveyor_001"}`,
+	} {
+		recorder := request(handler.ReprocessAlerts, "POST", "/anomaly/reprocess", "/anomaly/reprocess", body)
+		expectStatus(t, recorder, http.StatusBadRequest)
+	}
+}
+
+func TestGetDetectorStateRequiresAdminToken(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	handler.cfg.Server.AdminToken = "secret"
+	ingest(t, handler, "conveyor_001", "ok", `, "temperature": 60`)
+
+	router := gin.New()
+	router.GET("/debug/detector", handler.RequireAdmin, handler.GetDetectorState)
+	get := func(token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/debug/detector", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	expectStatus(t, get(""), http.StatusUnauthorized)
+
+	recorder := get("secret")
+	expectStatus(t, recorder, http.StatusOK)
+	var snapshot struct {
+		Machines map[string]struct {
+			WindowFill int `json:"window_fill"`
+		} `json:"machines"`
+	}
+	decode(t, recorder, &snapshot)
+	if machine, ok := snapshot.Machines["conveyor_001"]; !ok || machine.WindowFill != 1 {
+		t.Errorf("machines = %+v, want conveyor_001 with one windowed event", snapshot.Machines)
+	}
+}
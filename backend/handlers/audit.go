@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/websocket"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audited actions, recorded in the action column
+const (
+	AuditThresholdsUpdate = "thresholds.update"
+	AuditRulesUpdate      = "anomaly_rules.update"
+	AuditAnomalyReprocess = "anomaly.reprocess"
+	AuditParameterUpdate  = "parameter.update"
+	AuditAlertAcknowledge = "alert.acknowledge"
+	AuditAlertSnooze      = "alert.snooze"
+	AuditAlertTest        = "alert.test"
+	AuditMachineReset     = "machine.reset"
+)
+
+// auditActorHeader names the operator making a request, for requests without a named actor
+const auditActorHeader = "X-Actor"
+
+// websocketAdminActor is recorded as the actor of changes made by WebSocket admin clients
+const websocketAdminActor = "websocket-admin"
+
+// defaultAuditLimit is the page size of the audit log listing when no limit is given
+const defaultAuditLimit = 100
+
+// audit records a mutating operation with the values before and after it. The change has
+// already been applied, so a failure to record it is logged rather than failing the request.
+func (h *Handler) audit(c *gin.Context, actor, action, target string, oldValue, newValue interface{}) {
+	if actor == "" {
+		actor = strings.TrimSpace(c.GetHeader(auditActorHeader))
+	}
+	h.recordAudit(&models.AuditEntry{
+		Actor:    actor,
+		ClientIP: c.ClientIP(),
+		Action:   action,
+		Target:   target,
+		OldValue: auditValue(oldValue),
+		NewValue: auditValue(newValue),
+	})
+}
+
+// recordAudit stores an audit entry, logging failures
+func (h *Handler) recordAudit(entry *models.AuditEntry) {
+	if err := h.db.InsertAuditEntry(entry); err != nil {
+		log.Printf("Failed to record audit entry %s %s: %v", entry.Action, entry.Target, err)
+	}
+}
+
+// auditValue encodes a before or after value; nil is recorded as no value
+func auditValue(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to encode audit value: %v", err)
+		return nil
+	}
+	return encoded
+}
+
+// GetAuditLog lists audit entries, newest first. Filters: actor, action, target, since
+// (lookback, default 7d) and limit (default 100, clamped to the page size cap).
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	filter := database.AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+	}
+
+	since, err := parseSince(c.DefaultQuery("since", "7d"), h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+	filter.Since = since
+
+	if filter.Limit, err = h.pageLimit(c, defaultAuditLimit); err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid limit parameter", err)
+		return
+	}
+
+	entries, err := h.db.GetAuditLog(filter)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve audit log", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// auditedAdminActions records the changes WebSocket admin clients make before applying them
+type auditedAdminActions struct {
+	h *Handler
+}
+
+// AuditedAdminActions returns the admin actions for WebSocket admin clients, audited
+func (h *Handler) AuditedAdminActions() websocket.AdminActions {
+	return auditedAdminActions{h: h}
+}
+
+// ResetMachine discards a machine's detector state
+func (a auditedAdminActions) ResetMachine(machineID string) bool {
+	existed := a.h.anomalyDetector.ResetMachine(machineID)
+	a.h.recordAudit(&models.AuditEntry{
+		Actor:  websocketAdminActor,
+		Action: AuditMachineReset,
+		Target: "machine:" + machineID,
+	})
+	return existed
+}
+
+// UpdateThresholds replaces the anomaly thresholds
+func (a auditedAdminActions) UpdateThresholds(thresholds *models.AnomalyThresholds) {
+	previous := a.h.anomalyDetector.GetThresholds()
+	a.h.anomalyDetector.UpdateThresholds(thresholds)
+	a.h.recordAudit(&models.AuditEntry{
+		Actor:    websocketAdminActor,
+		Action:   AuditThresholdsUpdate,
+		Target:   "anomaly_thresholds",
+		OldValue: auditValue(previous),
+		NewValue: auditValue(thresholds),
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDashboard returns everything the home screen shows in one response: fleet statistics
+// over the since lookback (default 1h), active alert counts by severity, a summary of
+// machine statuses and each machine's latest event. Machines flagged offline by the
+// anomaly detector are summarized as offline rather than by their last reported status.
+func (h *Handler) GetDashboard(c *gin.Context) {
+	sinceParam := c.DefaultQuery("since", "1h")
+	since, err := parseSince(sinceParam, h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	unit, err := h.temperatureUnit(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unit parameter", err)
+		return
+	}
+
+	stats, err := h.db.GetEventStats("", "", since, h.cfg.Validation.IdleStatuses)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve event statistics", err)
+		return
+	}
+	stats.ComputeUptime(h.cfg.Health.UptimeCountsWarnings)
+	stats.ConvertTemperatures(unit)
+
+	alertCounts, err := h.db.GetActiveAlertCounts()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert counts", err)
+		return
+	}
+
+	latest, err := h.db.GetLatestEvents()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve latest events", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":            stats,
+		"health":           healthStatus(stats.UptimePercent, h.cfg.Health),
+		"temperature_unit": unit,
+		"period": gin.H{
+			"since":    since.In(location).Format(time.RFC3339),
+			"timezone": location.String(),
+			"duration": sinceParam,
+		},
+		"alerts":        activeAlertSummary(alertCounts),
+		"machines":      h.machineStatusSummary(latest),
+		"latest_events": latest,
+	})
+}
+
+// activeAlertSummary reports active alert counts for every severity, including those
+// without any alerts, and their total
+func activeAlertSummary(counts map[string]int64) gin.H {
+	bySeverity := make(map[string]int64, len(models.SeverityLevels))
+	var total int64
+	for severity := range models.SeverityLevels {
+		bySeverity[severity] = counts[severity]
+		total += counts[severity]
+	}
+	return gin.H{
+		"total":       total,
+		"by_severity": bySeverity,
+	}
+}
+
+// machineStatusSummary counts the machines that have sent events by their current status:
+// offline if the anomaly detector has flagged them, otherwise their latest event's status
+func (h *Handler) machineStatusSummary(latest []models.Event) gin.H {
+	byStatus := make(map[string]int)
+	for _, event := range latest {
+		status := event.Status
+		if h.anomalyDetector.IsOffline(event.MachineID) {
+			status = "offline"
+		}
+		byStatus[status]++
+	}
+	return gin.H{
+		"total":     len(latest),
+		"by_status": byStatus,
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetDashboardSummarizesAlertsAndMachines(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for i, event := range []models.SensorEvent{
+		{MachineID: "conveyor_001", Status: "fault"},
+		{MachineID: "conveyor_001", Status: "ok"},
+		{MachineID: "conveyor_002", Status: "fault"},
+		{MachineID: "robot_001", Status: "warning"},
+	} {
+		event.EventType = "conveyor"
+		insertEvent(t, store, event, time.Duration(10-i)*time.Minute)
+	}
+
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_002", AlertType: "fault", Severity: "high", Message: "jam"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_002", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+	insertAlert(t, store, models.Alert{MachineID: "robot_001", AlertType: "warning", Severity: "low", Message: "drift"})
+	acknowledged := insertAlert(t, store, models.Alert{MachineID: "robot_001", AlertType: "fault", Severity: "critical", Message: "stop"})
+	if err := store.AcknowledgeAlert(acknowledged.ID, "ana", ""); err != nil {
+		t.Fatalf("AcknowledgeAlert: %v", err)
+	}
+	insertAlert(t, store, models.Alert{MachineID: "robot_001", AlertType: "test", Severity: "critical", Message: "test", Test: true})
+
+	recorder := request(handler.GetDashboard, "GET", "/dashboard", "/dashboard", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Alerts struct {
+			Total      int64            `json:"total"`
+			BySeverity map[string]int64 `json:"by_severity"`
+		} `json:"alerts"`
+		Machines struct {
+			Total    int            `json:"total"`
+			ByStatus map[string]int `json:"by_status"`
+		} `json:"machines"`
+		LatestEvents []models.Event `json:"latest_events"`
+	}
+	decode(t, recorder, &body)
+
+	if body.Alerts.Total != 3 || body.Alerts.BySeverity["high"] != 2 || body.Alerts.BySeverity["low"] != 1 {
+		t.Errorf("alerts = %+v, want 2 high and 1 low active", body.Alerts)
+	}
+	if count, ok := body.Alerts.BySeverity["critical"]; !ok || count != 0 {
+		t.Errorf("by_severity = %v, want critical reported as 0", body.Alerts.BySeverity)
+	}
+	if body.Machines.Total != 3 || body.Machines.ByStatus["ok"] != 1 || body.Machines.ByStatus["fault"] != 1 || body.Machines.ByStatus["warning"] != 1 {
+		t.Errorf("machines = %+v, want one each of ok, fault and warning", body.Machines)
+	}
+	if len(body.LatestEvents) != 3 {
+		t.Errorf("got %d latest events, want one per machine", len(body.LatestEvents))
+	}
+}
+
+func TestGetDashboardRejectsInvalidParameters(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	for _, query := range []string{"since=soon", "tz=Mars/Base", "unit=kelvin"} {
+		recorder := request(handler.GetDashboard, "GET", "/dashboard", "/dashboard?"+query, "")
+		expectStatus(t, recorder, http.StatusBadRequest)
+	}
+}
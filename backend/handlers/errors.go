@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Machine-readable error codes returned in API error responses
+const (
+	ErrCodeInvalidRequest  = "invalid_request"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeInternal        = "internal_error"
+	ErrCodeUnauthorized    = "unauthorized"
+	ErrCodeForbidden       = "forbidden"
+	ErrCodeRequestTooLarge = "request_too_large"
+)
+
+// APIError is the error envelope returned by all API endpoints. The human-readable
+// message stays under "error" so existing clients that display it keep working.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// writeError aborts the request with an APIError. err, if non-nil, is reported as details.
+func writeError(c *gin.Context, status int, code, message string, err error) {
+	apiErr := APIError{
+		Code:    code,
+		Message: message,
+	}
+	if err != nil {
+		apiErr.Details = err.Error()
+	}
+	c.AbortWithStatusJSON(status, apiErr)
+}
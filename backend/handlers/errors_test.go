@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/kafka"
+	"backend/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingEventsStore fails every latest events query
+type failingEventsStore struct {
+	database.Store
+}
+
+func (failingEventsStore) GetLatestEvents() ([]models.Event, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestErrorEnvelopeCodes(t *testing.T) {
+	handler, store := newTestHandler(t)
+	failing, _ := newTestHandler(t)
+	failing.db = failingEventsStore{store}
+
+	for _, tc := range []struct {
+		name     string
+		recorder *httptest.ResponseRecorder
+		status   int
+		want     APIError
+	}{
+		{
+			"bad input",
+			request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", "/alerts/abc/acknowledge", ""),
+			http.StatusBadRequest,
+			APIError{Code: ErrCodeInvalidRequest, Message: "Invalid alert ID"},
+		},
+		{
+			"not found",
+			request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", "/alerts/42/acknowledge", ""),
+			http.StatusNotFound,
+			APIError{Code: ErrCodeNotFound, Message: "Alert not found"},
+		},
+		{
+			"internal",
+			request(failing.GetLatestEvents, "GET", "/events/latest", "/events/latest", ""),
+			http.StatusInternalServerError,
+			APIError{Code: ErrCodeInternal, Message: "Failed to retrieve latest events", Details: "connection refused"},
+		},
+	} {
+		if tc.recorder.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, tc.recorder.Code, tc.status)
+		}
+		var got APIError
+		decode(t, tc.recorder, &got)
+		if got != tc.want {
+			t.Errorf("%s: error = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// unreachableStore fails every database ping
+type unreachableStore struct {
+	database.Store
+}
+
+func (unreachableStore) PingContext(context.Context) error {
+	return errors.New("connection refused")
+}
+
+// startingStore is unreachable until up is set, like a database still coming up
+type startingStore struct {
+	database.Store
+	up *atomic.Bool
+}
+
+func (s startingStore) GetEventStats(machineID, area string, since time.Time, idleStatuses []string) (*models.EventStats, error) {
+	if !s.up.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return s.Store.GetEventStats(machineID, area, since, idleStatuses)
+}
+
+func (s startingStore) Connected() bool {
+	return s.up.Load()
+}
+
+func TestSystemHealthDegradedUntilDatabaseReachable(t *testing.T) {
+	handler, store := newTestHandler(t)
+	up := &atomic.Bool{}
+	handler.db = startingStore{store, up}
+	handler.kafka = kafka.NewConnector(handler.cfg.Kafka, handler.validator) // Never connected
+
+	var health struct {
+		Status   string `json:"status"`
+		Database struct {
+			Status string `json:"status"`
+		} `json:"database"`
+	}
+	recorder := request(handler.GetSystemHealth, "GET", "/health", "/health", "")
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, recorder, &health)
+	if health.Status != "degraded" || health.Database.Status != "connecting" {
+		t.Errorf("health = %s with database %s before connecting, want degraded and connecting", health.Status, health.Database.Status)
+	}
+
+	up.Store(true)
+	recorder = request(handler.GetSystemHealth, "GET", "/health", "/health", "")
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, recorder, &health)
+	if health.Database.Status != "connected" {
+		t.Errorf("database = %s once reachable, want connected", health.Database.Status)
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushEvery is how many rows are written between flushes of a streamed export
+const exportFlushEvery = 500
+
+// alertExportHeader is the CSV header row of an alert export
+var alertExportHeader = []string{
+	"id", "created_at", "machine_id", "alert_type", "severity", "message", "confidence", "event_id",
+	"acknowledged", "acknowledged_at", "acknowledged_by", "acknowledgement_note", "resolved_at", "test",
+	"recommended_action",
+}
+
+// ExportAlerts streams the alert history as CSV (default) or JSON for reporting.
+// Filters: severity (comma-separated), alert_type, machine_id, since (lookback, default
+// 24h), until (RFC3339, exclusive), acknowledged (true/false) and include_test (true
+// to include synthetic test alerts, which are left out by default). CSV timestamps are
+// written in the display time zone, or the zone named by tz; JSON keeps them in UTC.
+func (h *Handler) ExportAlerts(c *gin.Context) {
+	filter, err := h.alertExportFilter(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid export filter", err)
+		return
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	format, ok := startExport(c, "alerts")
+	if !ok {
+		return
+	}
+	if format == "csv" {
+		err = h.exportAlertsCSV(c, filter, location)
+	} else {
+		err = h.exportAlertsJSON(c, filter)
+	}
+
+	// Headers have been sent, so a failure part-way can only truncate the output
+	if err != nil {
+		log.Printf("Alert export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// startExport validates the format query parameter (csv, the default, or json) and sets
+// the download headers of an export named after kind. It reports false, having written
+// the error response, when the format is invalid.
+func startExport(c *gin.Context, kind string) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid format (expected csv or json)", nil)
+		return "", false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", kind, time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	return format, true
+}
+
+// alertExportFilter builds the alert filter from the query parameters
+func (h *Handler) alertExportFilter(c *gin.Context) (database.AlertFilter, error) {
+	var filter database.AlertFilter
+	var err error
+
+	if filter.Severities, err = parseSeverities(c.Query("severity")); err != nil {
+		return filter, err
+	}
+	filter.AlertType = c.Query("alert_type")
+	filter.MachineID = h.validator.NormalizeMachineID(c.Query("machine_id"))
+
+	if filter.Since, err = parseSince(c.DefaultQuery("since", "24h"), h.cfg.Server.MaxLookback); err != nil {
+		return filter, err
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return filter, fmt.Errorf("invalid until %q (expected RFC3339)", until)
+		}
+	}
+
+	if param := c.Query("acknowledged"); param != "" {
+		acknowledged, err := strconv.ParseBool(param)
+		if err != nil {
+			return filter, fmt.Errorf("invalid acknowledged %q", param)
+		}
+		filter.Acknowledged = &acknowledged
+	}
+
+	if param := c.Query("include_test"); param != "" {
+		if filter.IncludeTest, err = strconv.ParseBool(param); err != nil {
+			return filter, fmt.Errorf("invalid include_test %q", param)
+		}
+	}
+
+	return filter, nil
+}
+
+// exportAlertsCSV writes the matching alerts as CSV rows
+func (h *Handler) exportAlertsCSV(c *gin.Context, filter database.AlertFilter, location *time.Location) error {
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(alertExportHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamAlerts(c.Request.Context(), filter, func(alert models.Alert) error {
+		if err := writer.Write(alertCSVRow(alert, location)); err != nil {
+			return err
+		}
+		if count++; count%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// exportAlertsJSON writes the matching alerts as a JSON array
+func (h *Handler) exportAlertsJSON(c *gin.Context, filter database.AlertFilter) error {
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamAlerts(c.Request.Context(), filter, func(alert models.Alert) error {
+		encoded, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			c.Writer.WriteString(",")
+		}
+		if _, err := c.Writer.Write(encoded); err != nil {
+			return err
+		}
+		if count++; count%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Writer.WriteString("]")
+	return err
+}
+
+// alertCSVRow formats an alert in alertExportHeader order, with timestamps in location.
+// Missing values are empty.
+func alertCSVRow(alert models.Alert, location *time.Location) []string {
+	row := []string{
+		strconv.Itoa(alert.ID),
+		alert.CreatedAt.In(location).Format(time.RFC3339),
+		alert.MachineID,
+		alert.AlertType,
+		alert.Severity,
+		alert.Message,
+		"", "",
+		strconv.FormatBool(alert.Acknowledged),
+		"", "", "", "",
+		strconv.FormatBool(alert.Test),
+		"",
+	}
+
+	if alert.Confidence != nil {
+		row[6] = strconv.FormatFloat(*alert.Confidence, 'f', 3, 64)
+	}
+	if alert.EventID != nil {
+		row[7] = strconv.Itoa(*alert.EventID)
+	}
+	if alert.AcknowledgedAt != nil {
+		row[9] = alert.AcknowledgedAt.In(location).Format(time.RFC3339)
+	}
+	if alert.AcknowledgedBy != nil {
+		row[10] = *alert.AcknowledgedBy
+	}
+	if alert.AcknowledgementNote != nil {
+		row[11] = *alert.AcknowledgementNote
+	}
+	if alert.ResolvedAt != nil {
+		row[12] = alert.ResolvedAt.In(location).Format(time.RFC3339)
+	}
+	if alert.RecommendedAction != nil {
+		row[14] = *alert.RecommendedAction
+	}
+
+	return row
+}
+
+// eventExportHeader is the CSV header row of an event export
+var eventExportHeader = []string{
+	"id", "timestamp", "machine_id", "sensor_type", "line", "status", "conveyor_speed", "temperature",
+	"robot_arm_angle", "fault_code", "raw_data", "created_at",
+}
+
+// ExportEvents streams the event history as CSV (default) or JSON for analysts. Filters:
+// machine_id, line, since (lookback, default 24h) and until (RFC3339, exclusive).
+// Temperatures are in Celsius, as stored, and raw_data keys configured for redaction are
+// left out. CSV timestamps are written in the display time zone, or the zone named by
+// tz, with raw_data as a JSON object; JSON keeps them in UTC.
+func (h *Handler) ExportEvents(c *gin.Context) {
+	filter := database.EventExportFilter{
+		MachineID: h.validator.NormalizeMachineID(c.Query("machine_id")),
+		Line:      c.Query("line"),
+	}
+	var err error
+	if filter.Since, err = parseSince(c.DefaultQuery("since", "24h"), h.cfg.Server.MaxLookback); err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid export filter", err)
+		return
+	}
+	if until := c.Query("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid export filter",
+				fmt.Errorf("invalid until %q (expected RFC3339)", until))
+			return
+		}
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	format, ok := startExport(c, "events")
+	if !ok {
+		return
+	}
+	if format == "csv" {
+		err = h.exportEventsCSV(c, filter, location)
+	} else {
+		err = h.exportEventsJSON(c, filter)
+	}
+
+	// Headers have been sent, so a failure part-way can only truncate the output
+	if err != nil {
+		log.Printf("Event export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// exportEventsCSV writes the matching events as CSV rows
+func (h *Handler) exportEventsCSV(c *gin.Context, filter database.EventExportFilter, location *time.Location) error {
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(eventExportHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamEvents(c.Request.Context(), filter, func(event models.Event) error {
+		row, err := eventCSVRow(event, location)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		if count++; count%exportFlushEvery == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// exportEventsJSON writes the matching events as a JSON array
+func (h *Handler) exportEventsJSON(c *gin.Context, filter database.EventExportFilter) error {
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	count := 0
+	err := h.db.StreamEvents(c.Request.Context(), filter, func(event models.Event) error {
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			c.Writer.WriteString(",")
+		}
+		if _, err := c.Writer.Write(encoded); err != nil {
+			return err
+		}
+		if count++; count%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Writer.WriteString("]")
+	return err
+}
+
+// eventCSVRow formats an event in eventExportHeader order, with timestamps in location.
+// Missing values are empty.
+func eventCSVRow(event models.Event, location *time.Location) ([]string, error) {
+	rawData, err := json.Marshal(event.RawData.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	row := []string{
+		strconv.Itoa(event.ID),
+		event.Timestamp.In(location).Format(time.RFC3339Nano),
+		event.MachineID,
+		event.SensorType,
+		event.Line,
+		event.Status,
+		formatMetric(event.ConveyorSpeed),
+		formatMetric(event.Temperature),
+		formatMetric(event.RobotArmAngle),
+		"",
+		string(rawData),
+		event.CreatedAt.In(location).Format(time.RFC3339),
+	}
+	if event.FaultCode != nil {
+		row[9] = *event.FaultCode
+	}
+	return row, nil
+}
+
+// formatMetric formats a metric reading, or returns "" when it was not reported
+func formatMetric(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redactionEvent is an event whose raw_data mixes a redacted key with a kept one
+var redactionEvent = models.SensorEvent{
+	MachineID:      "conveyor_001",
+	EventType:      "conveyor",
+	Status:         "ok",
+	Temperature:    float(70),
+	ConveyorSpeed:  float(1.5),
+	AdditionalData: models.AdditionalData{"internal_code": "X-17", "vibration": 0.4},
+}
+
+// redactInternalCode redacts the internal_code raw_data key for the rest of the test
+func redactInternalCode(t *testing.T) {
+	models.SetRedactedRawData([]string{"internal_code"})
+	t.Cleanup(func() { models.SetRedactedRawData(nil) })
+}
+
+func TestExportEventsCSVRedactsRawData(t *testing.T) {
+	redactInternalCode(t)
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, redactionEvent, time.Minute)
+
+	recorder := request(handler.ExportEvents, "GET", "/events/export", "/events/export?format=csv", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	records, err := csv.NewReader(recorder.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d CSV records, want header and 1 row", len(records))
+	}
+	header, row := records[0], records[1]
+	column := func(name string) string { return row[slices.Index(header, name)] }
+
+	if strings.Contains(column("raw_data"), "internal_code") {
+		t.Errorf("raw_data = %s, want internal_code redacted", column("raw_data"))
+	}
+	if !strings.Contains(column("raw_data"), `"vibration":0.4`) {
+		t.Errorf("raw_data = %s, want vibration kept", column("raw_data"))
+	}
+	if column("machine_id") != "conveyor_001" || column("temperature") != "70" || column("conveyor_speed") != "1.5" {
+		t.Errorf("row = %v, want telemetry fields kept", row)
+	}
+}
+
+func TestExportEventsJSONRedactsRawData(t *testing.T) {
+	redactInternalCode(t)
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, redactionEvent, time.Minute)
+
+	recorder := request(handler.ExportEvents, "GET", "/events/export", "/events/export?format=json", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var events []map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+		t.Fatalf("decoding JSON: %v; body: %s", err, recorder.Body.String())
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	rawData := events[0]["raw_data"].(map[string]interface{})
+	if _, ok := rawData["internal_code"]; ok {
+		t.Errorf("raw_data = %v, want internal_code redacted", rawData)
+	}
+	if rawData["vibration"] != 0.4 {
+		t.Errorf("raw_data = %v, want vibration kept", rawData)
+	}
+	if events[0]["temperature"] != 70.0 {
+		t.Errorf("temperature = %v, want 70", events[0]["temperature"])
+	}
+}
+
+func TestExportEventsRejectsUnknownFormat(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	recorder := request(handler.ExportEvents, "GET", "/events/export", "/events/export?format=xml", "")
+	expectStatus(t, recorder, http.StatusBadRequest)
+}
+
+func TestExportEventsFiltersByMachineAndSince(t *testing.T) {
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", Status: "ok"}, time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", Status: "ok"}, 2*time.Hour)
+	insertEvent(t, store, models.SensorEvent{MachineID: "robot_001", Status: "ok"}, time.Minute)
+
+	recorder := request(handler.ExportEvents, "GET", "/events/export",
+		"/events/export?format=json&machine_id=conveyor_001&since=1h", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var events []models.Event
+	if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+		t.Fatalf("decoding JSON: %v", err)
+	}
+	if len(events) != 1 || events[0].MachineID != "conveyor_001" {
+		t.Errorf("events = %+v, want only conveyor_001's last hour", events)
+	}
+}
+
+func TestExportAlertsMatchesFilteredQuery(t *testing.T) {
+	handler, store := newTestHandler(t)
+	hot := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "repeated_faults", Severity: "high", Message: "faults"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "machine_online", Severity: "low", Message: "back"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_002", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+	if err := store.AcknowledgeAlert(hot.ID, "alice", "cooling fixed"); err != nil {
+		t.Fatalf("AcknowledgeAlert: %v", err)
+	}
+
+	for _, tc := range []struct {
+		query string
+		rows  int
+	}{
+		{"", 4},
+		{"&machine_id=conveyor_001", 3},
+		{"&machine_id=conveyor_001&severity=high", 2},
+		{"&alert_type=temperature_high", 2},
+		{"&acknowledged=true", 1},
+		{"&acknowledged=false&severity=high", 2},
+	} {
+		recorder := request(handler.ExportAlerts, "GET", "/alerts/export", "/alerts/export?format=csv"+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+			t.Errorf("%s: Content-Type = %s, want text/csv", tc.query, contentType)
+		}
+		if disposition := recorder.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, `attachment; filename="alerts-`) {
+			t.Errorf("%s: Content-Disposition = %s, want an alerts attachment", tc.query, disposition)
+		}
+
+		records, err := csv.NewReader(recorder.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: reading CSV: %v", tc.query, err)
+		}
+		if !slices.Equal(records[0], alertExportHeader) {
+			t.Errorf("%s: header = %v, want %v", tc.query, records[0], alertExportHeader)
+		}
+		if rows := len(records) - 1; rows != tc.rows {
+			t.Errorf("%s: %d CSV rows, want %d", tc.query, rows, tc.rows)
+		}
+
+		recorder = request(handler.ExportAlerts, "GET", "/alerts/export", "/alerts/export?format=json"+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		var alerts []models.Alert
+		decode(t, recorder, &alerts)
+		if len(alerts) != tc.rows {
+			t.Errorf("%s: %d JSON alerts, want %d", tc.query, len(alerts), tc.rows)
+		}
+	}
+
+	recorder := request(handler.ExportAlerts, "GET", "/alerts/export", "/alerts/export?acknowledged=true", "")
+	records, _ := csv.NewReader(recorder.Body).ReadAll()
+	row := records[1]
+	column := func(name string) string { return row[slices.Index(alertExportHeader, name)] }
+	if column("acknowledged") != "true" || column("acknowledged_by") != "alice" || column("acknowledgement_note") != "cooling fixed" || column("acknowledged_at") == "" {
+		t.Errorf("row = %v, want the acknowledgement metadata", row)
+	}
+}
@@ -1,43 +1,71 @@
 package handlers
 
 import (
+	"backend/config"
 	"backend/database"
+	"backend/kafka"
 	"backend/models"
 	"backend/services"
 	"backend/websocket"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// rawDataFieldPattern restricts raw_data keys accepted for aggregation
+var rawDataFieldPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+
 // Handler contains all the dependencies needed for HTTP handlers
 type Handler struct {
-	db               *database.DB
-	hub              *websocket.Hub
-	anomalyDetector  *services.AnomalyDetector
+	cfg             *config.Config
+	db              database.Store
+	hub             *websocket.Hub
+	anomalyDetector *services.AnomalyDetector
+	kafka           *kafka.Connector
+	validator       *services.EventValidator
+	processor       *services.EventProcessor
+	alertSink       func(*models.Alert) // Stores and broadcasts alerts, as detected alerts are
 }
 
-// New creates a new handler instance
-func New(db *database.DB, hub *websocket.Hub, anomalyDetector *services.AnomalyDetector) *Handler {
+// New creates a new handler instance. The connector's consumer is nil until Kafka is reachable.
+// alertSink delivers alerts raised through the API, such as test alerts.
+func New(cfg *config.Config, db database.Store, hub *websocket.Hub, anomalyDetector *services.AnomalyDetector, connector *kafka.Connector,
+	validator *services.EventValidator, processor *services.EventProcessor, alertSink func(*models.Alert)) *Handler {
 	return &Handler{
+		cfg:             cfg,
 		db:              db,
 		hub:             hub,
 		anomalyDetector: anomalyDetector,
+		kafka:           connector,
+		validator:       validator,
+		processor:       processor,
+		alertSink:       alertSink,
 	}
 }
 
-// GetEvents retrieves recent events with pagination
+// GetEvents retrieves recent events with pagination. limit defaults to 50 and is clamped
+// to the configured page size cap.
 func (h *Handler) GetEvents(c *gin.Context) {
-	limit := 50 // default
 	offset := 0 // default
-	machineID := c.Query("machine_id")
+	machineID := h.validator.NormalizeMachineID(c.Query("machine_id"))
+	line := c.Query("line")
+	area := c.Query("area")
 
-	if l := c.Query("limit"); l != "" {
-		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 && parsedLimit <= 1000 {
-			limit = parsedLimit
-		}
+	limit, err := h.pageLimit(c, 50)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid limit parameter", err)
+		return
 	}
 
 	if o := c.Query("offset"); o != "" {
@@ -46,83 +74,341 @@ func (h *Handler) GetEvents(c *gin.Context) {
 		}
 	}
 
-	events, err := h.db.GetRecentEvents(limit, offset, machineID)
+	filter := database.EventFilter{
+		MachineID: machineID,
+		Line:      line,
+		Area:      area,
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if faultCode := models.ParseFaultCode(c.Query("fault_code")); faultCode != nil {
+		filter.FaultCode = faultCode.Code
+	}
+
+	// Keyset pagination: resume after the last event of the previous page
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, err := database.ParseEventCursor(cursorParam)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid cursor", err)
+			return
+		}
+		filter.Cursor = cursor
+		filter.Offset = 0
+	}
+
+	events, err := h.db.GetRecentEvents(filter)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve events", err)
+		return
+	}
+
+	pagination := gin.H{
+		"limit":  limit,
+		"offset": filter.Offset,
+		"count":  len(events),
+	}
+	if len(events) == limit {
+		last := events[len(events)-1]
+		pagination["next_cursor"] = database.EventCursor{Timestamp: last.Timestamp, ID: last.ID}.String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":     events,
+		"pagination": pagination,
+	})
+}
+
+// GetLatestEvents retrieves the newest event for every machine
+func (h *Handler) GetLatestEvents(c *gin.Context) {
+	events, err := h.db.GetLatestEvents()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve events",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve latest events", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"events": events,
-		"pagination": gin.H{
-			"limit":  limit,
-			"offset": offset,
-			"count":  len(events),
-		},
+		"count":  len(events),
+	})
+}
+
+// GetSeenMachines lists the distinct machine IDs that have sent events, including
+// machines that were never registered. ?registered=false returns only unregistered ones.
+func (h *Handler) GetSeenMachines(c *gin.Context) {
+	machines, err := h.db.GetSeenMachines()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve machines seen in events", err)
+		return
+	}
+
+	if param := c.Query("registered"); param != "" {
+		registered, err := strconv.ParseBool(param)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid registered parameter", err)
+			return
+		}
+
+		filtered := machines[:0]
+		for _, machine := range machines {
+			if machine.Registered == registered {
+				filtered = append(filtered, machine)
+			}
+		}
+		machines = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"machines": machines,
+		"count":    len(machines),
 	})
 }
 
 // GetEventStats retrieves event statistics
 func (h *Handler) GetEventStats(c *gin.Context) {
-	machineID := c.Query("machine_id")
+	machineID := h.validator.NormalizeMachineID(c.Query("machine_id"))
 	sinceParam := c.DefaultQuery("since", "24h")
 
-	// Parse since parameter
-	var since time.Time
-	switch sinceParam {
-	case "1h":
-		since = time.Now().Add(-1 * time.Hour)
-	case "24h":
-		since = time.Now().Add(-24 * time.Hour)
-	case "7d":
-		since = time.Now().Add(-7 * 24 * time.Hour)
-	case "30d":
-		since = time.Now().Add(-30 * 24 * time.Hour)
-	default:
-		if duration, err := time.ParseDuration(sinceParam); err == nil {
-			since = time.Now().Add(-duration)
-		} else {
-			since = time.Now().Add(-24 * time.Hour)
-		}
+	since, err := parseSince(sinceParam, h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	unit, err := h.temperatureUnit(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unit parameter", err)
+		return
 	}
 
-	stats, err := h.db.GetEventStats(machineID, since)
+	stats, err := h.db.GetEventStats(machineID, c.Query("area"), since, h.cfg.Validation.IdleStatuses)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve event statistics",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve event statistics", err)
 		return
 	}
+	stats.ComputeUptime(h.cfg.Health.UptimeCountsWarnings)
 
-	// Add real-time statistics from anomaly detector
+	// Add real-time statistics from anomaly detector; averages of metrics the window holds
+	// no readings of keep the stored value
 	if machineID != "" {
 		if machineStats := h.anomalyDetector.GetMachineStats(machineID); machineStats != nil {
-			stats.AvgTemperature = machineStats["avg_temperature"].(float64)
-			stats.AvgConveyorSpeed = machineStats["avg_conveyor_speed"].(float64)
+			if avgTemperature, ok := machineStats["avg_temperature"].(*float64); ok && avgTemperature != nil {
+				stats.AvgTemperature = *avgTemperature
+			}
+			if avgSpeed, ok := machineStats["avg_conveyor_speed"].(*float64); ok && avgSpeed != nil {
+				stats.AvgConveyorSpeed = *avgSpeed
+			}
 		}
 	}
+	stats.ConvertTemperatures(unit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":            stats,
+		"temperature_unit": unit,
+		"period": gin.H{
+			"since":    since.In(location).Format(time.RFC3339),
+			"timezone": location.String(),
+			"duration": sinceParam,
+		},
+	})
+}
+
+// GetRawDataStats aggregates a numeric raw_data field over a time range
+func (h *Handler) GetRawDataStats(c *gin.Context) {
+	field := c.Query("field")
+	if !rawDataFieldPattern.MatchString(field) {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid or missing field parameter", nil)
+		return
+	}
+
+	machineID := h.validator.NormalizeMachineID(c.Query("machine_id"))
+	sinceParam := c.DefaultQuery("since", "24h")
+	since, err := parseSince(sinceParam, h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	stats, err := h.db.GetRawDataStats(field, machineID, since)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve raw data statistics", err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"stats": stats,
 		"period": gin.H{
-			"since":    since.Format(time.RFC3339),
+			"since":    since.In(location).Format(time.RFC3339),
+			"timezone": location.String(),
 			"duration": sinceParam,
 		},
 	})
 }
 
-// GetAlerts retrieves unacknowledged alerts
+// parseSince converts a lookback given in days (7d, 30d) or as a Go duration (1h, 90m)
+// into a start time. Unparseable, non-positive and over-long lookbacks are rejected
+// rather than defaulted, so client mistakes surface instead of returning the wrong range.
+func parseSince(sinceParam string, maxLookback time.Duration) (time.Time, error) {
+	var lookback time.Duration
+	if days, ok := strings.CutSuffix(sinceParam, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid lookback %q", sinceParam)
+		}
+		lookback = time.Duration(n) * 24 * time.Hour
+	} else {
+		duration, err := time.ParseDuration(sinceParam)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid lookback %q (expected e.g. 1h, 24h or 7d)", sinceParam)
+		}
+		lookback = duration
+	}
+
+	if lookback <= 0 {
+		return time.Time{}, fmt.Errorf("lookback %q must be positive", sinceParam)
+	}
+	if maxLookback > 0 && lookback > maxLookback {
+		return time.Time{}, fmt.Errorf("lookback %q exceeds the maximum of %g days", sinceParam, maxLookback.Hours()/24)
+	}
+
+	return time.Now().Add(-lookback), nil
+}
+
+// IngestEvents accepts a single sensor event or an array of events over HTTP and runs
+// them through the same validation and processing pipeline as Kafka events. A batch is
+// rejected as a whole if any event fails validation.
+func (h *Handler) IngestEvents(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		writeBodyError(c, "Failed to read request body", err)
+		return
+	}
+
+	var events []*models.SensorEvent
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &events)
+	} else {
+		var event models.SensorEvent
+		err = json.Unmarshal(trimmed, &event)
+		events = append(events, &event)
+	}
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid event payload", err)
+		return
+	}
+
+	if len(events) == 0 {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "No events provided", nil)
+		return
+	}
+
+	for i, event := range events {
+		if event == nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Invalid event at index %d", i), nil)
+			return
+		}
+		h.validator.Normalize(event)
+		if err := h.validator.Validate(event); err != nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Invalid event at index %d", i), err)
+			return
+		}
+	}
+
+	eventIDs := make([]int, 0, len(events))
+	for _, event := range events {
+		dbEvent, err := h.processor.Process(event)
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to store event", err)
+			return
+		}
+		eventIDs = append(eventIDs, dbEvent.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Events ingested successfully",
+		"count":     len(eventIDs),
+		"event_ids": eventIDs,
+	})
+}
+
+// GetAlerts retrieves unacknowledged alerts, optionally filtered by a comma-separated
+// severity list and by machine area. include=event embeds each alert's triggering event,
+// saving clients a lookup per alert.
 func (h *Handler) GetAlerts(c *gin.Context) {
-	alerts, err := h.db.GetUnacknowledgedAlerts()
+	severities, err := parseSeverities(c.Query("severity"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error(), nil)
+		return
+	}
+
+	var includeEvent bool
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "event":
+			includeEvent = true
+		default:
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Invalid include: %s (expected event)", include), nil)
+			return
+		}
+	}
+
+	var alerts interface{}
+	var count int
+	if includeEvent {
+		withEvents, err := h.db.GetAlertsWithEvents(severities, c.Query("area"))
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alerts", err)
+			return
+		}
+		alerts, count = withEvents, len(withEvents)
+	} else {
+		plain, err := h.db.GetUnacknowledgedAlerts(severities, c.Query("area"))
+		if err != nil {
+			writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alerts", err)
+			return
+		}
+		alerts, count = plain, len(plain)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"count":  count,
+	})
+}
+
+// parseSeverities parses a comma-separated severity list, rejecting unknown severities
+func parseSeverities(param string) ([]string, error) {
+	var severities []string
+	if param == "" {
+		return severities, nil
+	}
+	for _, severity := range strings.Split(param, ",") {
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if _, ok := models.SeverityLevels[severity]; !ok {
+			return nil, fmt.Errorf("Invalid severity: %s", severity)
+		}
+		severities = append(severities, severity)
+	}
+	return severities, nil
+}
+
+// GetCurrentAlerts retrieves the latest unacknowledged alert per machine and alert type
+func (h *Handler) GetCurrentAlerts(c *gin.Context) {
+	alerts, err := h.db.GetCurrentAlerts()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve alerts",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve current alerts", err)
 		return
 	}
 
@@ -132,29 +418,242 @@ func (h *Handler) GetAlerts(c *gin.Context) {
 	})
 }
 
+// alertTrendIntervals are the bucket sizes accepted by GetAlertStats
+var alertTrendIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// GetAlertStats returns alert counts bucketed by time interval and severity
+func (h *Handler) GetAlertStats(c *gin.Context) {
+	sinceParam := c.DefaultQuery("since", "24h")
+	since, err := parseSince(sinceParam, h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+
+	location, err := h.displayLocation(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tz parameter", err)
+		return
+	}
+
+	intervalParam := c.DefaultQuery("interval", "1h")
+	interval, ok := alertTrendIntervals[intervalParam]
+	if !ok {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid interval (expected 1m, 5m, 15m, 1h, 6h or 1d)", nil)
+		return
+	}
+
+	buckets, err := h.db.GetAlertTrend(since, interval)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert statistics", err)
+		return
+	}
+
+	totals := make(map[string]int64)
+	for _, bucket := range buckets {
+		totals[bucket.Severity] += bucket.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"buckets": buckets,
+		"totals":  totals,
+		"period": gin.H{
+			"since":    since.In(location).Format(time.RFC3339),
+			"timezone": location.String(),
+			"duration": sinceParam,
+			"interval": intervalParam,
+		},
+	})
+}
+
 // AcknowledgeAlert acknowledges a specific alert
 func (h *Handler) AcknowledgeAlert(c *gin.Context) {
 	alertIDParam := c.Param("id")
 	alertID, err := strconv.Atoi(alertIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid alert ID",
-		})
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid alert ID", nil)
+		return
+	}
+
+	// The body is optional so existing clients can keep acknowledging without one
+	var ackRequest struct {
+		AcknowledgedBy string `json:"acknowledged_by"`
+		Note           string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&ackRequest); err != nil {
+			writeBodyError(c, "Invalid request body", err)
+			return
+		}
+	}
+
+	previous, err := h.db.GetAlert(alertID)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(c, http.StatusNotFound, ErrCodeNotFound, "Alert not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert", err)
 		return
 	}
 
-	err = h.db.AcknowledgeAlert(alertID)
+	acknowledgedBy, note := strings.TrimSpace(ackRequest.AcknowledgedBy), strings.TrimSpace(ackRequest.Note)
+	err = h.db.AcknowledgeAlert(alertID, acknowledgedBy, note)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(c, http.StatusNotFound, ErrCodeNotFound, "Alert not found", nil)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to acknowledge alert",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to acknowledge alert", err)
 		return
 	}
+	h.audit(c, acknowledgedBy, AuditAlertAcknowledge, "alert:"+alertIDParam,
+		gin.H{"acknowledged": previous.Acknowledged, "acknowledged_by": previous.AcknowledgedBy, "note": previous.AcknowledgementNote},
+		gin.H{"acknowledged": true, "acknowledged_by": acknowledgedBy, "note": note})
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Alert acknowledged successfully",
-		"alert_id": alertID,
+		"message":         "Alert acknowledged successfully",
+		"alert_id":        alertID,
+		"acknowledged_by": acknowledgedBy,
+		"note":            note,
+	})
+}
+
+// maxSnoozeDuration bounds how long alerts can be silenced in one request
+const maxSnoozeDuration = 7 * 24 * time.Hour
+
+// SnoozeAlert silences new alerts with the same machine and type as the given alert
+// for a duration, without acknowledging it. Alerting resumes when the snooze expires.
+func (h *Handler) SnoozeAlert(c *gin.Context) {
+	alertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid alert ID", nil)
+		return
+	}
+
+	var snoozeRequest struct {
+		Duration string `json:"duration" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&snoozeRequest); err != nil {
+		writeBodyError(c, "Invalid request body", err)
+		return
+	}
+
+	duration, err := time.ParseDuration(snoozeRequest.Duration)
+	if err != nil || duration <= 0 || duration > maxSnoozeDuration {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+			fmt.Sprintf("Invalid duration (expected a positive duration up to %v)", maxSnoozeDuration), err)
+		return
+	}
+
+	alert, err := h.db.GetAlert(alertID)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(c, http.StatusNotFound, ErrCodeNotFound, "Alert not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert", err)
+		return
+	}
+
+	snooze, err := h.db.SnoozeAlerts(alert.MachineID, alert.AlertType, time.Now().Add(duration))
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to snooze alert", err)
+		return
+	}
+	h.anomalyDetector.Snooze(snooze.MachineID, snooze.AlertType, snooze.SnoozedUntil)
+	h.audit(c, "", AuditAlertSnooze, "alert:"+c.Param("id"), nil, snooze)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Alert snoozed successfully",
+		"snooze":  snooze,
+	})
+}
+
+// Bounds of the event window returned around an alert
+const (
+	maxAlertContextWindow = 24 * time.Hour
+	defaultContextLimit   = 500
+	maxContextLimit       = 5000
+)
+
+// GetAlertContext returns the events of an alert's machine surrounding the alert, for root
+// cause analysis. before and after (durations, default 5m and 1m) bound the window, which
+// is centred on the event that raised the alert, or the alert's creation time.
+func (h *Handler) GetAlertContext(c *gin.Context) {
+	alertID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid alert ID", nil)
+		return
+	}
+
+	window := make(map[string]time.Duration, 2)
+	for param, fallback := range map[string]string{"before": "5m", "after": "1m"} {
+		duration, err := time.ParseDuration(c.DefaultQuery(param, fallback))
+		if err != nil || duration < 0 || duration > maxAlertContextWindow {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				fmt.Sprintf("Invalid %s (expected a duration up to %v)", param, maxAlertContextWindow), err)
+			return
+		}
+		window[param] = duration
+	}
+
+	limit := defaultContextLimit
+	if param := c.Query("limit"); param != "" {
+		if limit, err = strconv.Atoi(param); err != nil || limit < 1 || limit > maxContextLimit {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+				fmt.Sprintf("Invalid limit (expected 1 to %d)", maxContextLimit), nil)
+			return
+		}
+	}
+
+	alert, err := h.db.GetAlert(alertID)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(c, http.StatusNotFound, ErrCodeNotFound, "Alert not found", nil)
+		return
+	}
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert", err)
+		return
+	}
+
+	events, anchor, err := h.db.GetAlertContext(alert, window["before"], window["after"], limit)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert context", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alert":  alert,
+		"anchor": anchor,
+		"window": gin.H{
+			"from": anchor.Add(-window["before"]),
+			"to":   anchor.Add(window["after"]),
+		},
+		"events":    events,
+		"count":     len(events),
+		"truncated": len(events) == limit,
+	})
+}
+
+// GetAlertSnoozes lists the snoozes currently in effect
+func (h *Handler) GetAlertSnoozes(c *gin.Context) {
+	snoozes, err := h.db.GetActiveSnoozes()
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve alert snoozes", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"snoozes": snoozes,
+		"count":   len(snoozes),
 	})
 }
 
@@ -162,10 +661,7 @@ func (h *Handler) AcknowledgeAlert(c *gin.Context) {
 func (h *Handler) GetProcessParameters(c *gin.Context) {
 	params, err := h.db.GetProcessParameters()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve process parameters",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve process parameters", err)
 		return
 	}
 
@@ -183,21 +679,20 @@ func (h *Handler) UpdateProcessParameter(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&updateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
+		writeBodyError(c, "Invalid request body", err)
 		return
 	}
 
-	err := h.db.UpdateProcessParameter(updateRequest.ParameterName, updateRequest.ParameterValue)
+	previous, err := h.db.UpdateProcessParameter(updateRequest.ParameterName, updateRequest.ParameterValue)
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(c, http.StatusNotFound, ErrCodeNotFound, "Unknown process parameter", nil)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to update process parameter",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update process parameter", err)
 		return
 	}
+	h.audit(c, "", AuditParameterUpdate, "parameter:"+updateRequest.ParameterName, previous, updateRequest.ParameterValue)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":         "Process parameter updated successfully",
@@ -206,22 +701,45 @@ func (h *Handler) UpdateProcessParameter(c *gin.Context) {
 	})
 }
 
-// GetMachines retrieves all machines
+// GetMachines retrieves all machines, optionally restricted to an area and line
 func (h *Handler) GetMachines(c *gin.Context) {
 	machines, err := h.db.GetMachines()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to retrieve machines",
-			"details": err.Error(),
-		})
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to retrieve machines", err)
 		return
 	}
 
-	// Enhance with real-time statistics
+	area, line := c.Query("area"), c.Query("line")
+	if area != "" || line != "" {
+		grouped := machines[:0]
+		for _, machine := range machines {
+			if (area == "" || machine.Area == area) && (line == "" || machine.Line == line) {
+				grouped = append(grouped, machine)
+			}
+		}
+		machines = grouped
+	}
+
+	// Enhance with real-time statistics, rating recent speed against the rated speed
 	for i := range machines {
-		if stats := h.anomalyDetector.GetMachineStats(machines[i].MachineID); stats != nil {
-			machines[i].Config["real_time_stats"] = stats
+		stats := h.anomalyDetector.GetMachineStats(machines[i].MachineID)
+		if stats == nil {
+			continue
+		}
+
+		ratedSpeed, err := machines[i].RatedSpeed()
+		if err != nil {
+			log.Printf("Invalid machine config, using default rated speed: %v", err)
+		}
+		stats["rated_speed"] = ratedSpeed
+		if avgSpeed, ok := stats["avg_conveyor_speed"].(*float64); ok && avgSpeed != nil {
+			stats["performance_percent"] = *avgSpeed / ratedSpeed * 100
+		}
+
+		if machines[i].Config == nil {
+			machines[i].Config = make(map[string]interface{})
 		}
+		machines[i].Config["real_time_stats"] = stats
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -230,18 +748,53 @@ func (h *Handler) GetMachines(c *gin.Context) {
 	})
 }
 
+// GetMachineReliability returns a machine's mean time between faults and mean times to
+// acknowledge and resolve its alerts over the since lookback (default 30d)
+func (h *Handler) GetMachineReliability(c *gin.Context) {
+	machineID := h.validator.NormalizeMachineID(c.Param("id"))
+
+	since, err := parseSince(c.DefaultQuery("since", "30d"), h.cfg.Server.MaxLookback)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid since parameter", err)
+		return
+	}
+
+	reliability, err := services.ComputeReliability(h.db, machineID, since)
+	if err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to compute machine reliability", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reliability)
+}
+
 // GetSystemHealth returns overall system health information
 func (h *Handler) GetSystemHealth(c *gin.Context) {
-	stats, _ := h.db.GetEventStats("", time.Now().Add(-1*time.Hour))
+	// Until the database is reachable there is no recent activity to report
+	databaseStatus := "connected"
+	stats, err := h.db.GetEventStats("", "", time.Now().Add(-1*time.Hour), h.cfg.Validation.IdleStatuses)
+	if err != nil {
+		databaseStatus = "unavailable"
+		if !h.db.Connected() {
+			databaseStatus = "connecting"
+		}
+		stats = &models.EventStats{}
+	}
+	stats.ComputeUptime(h.cfg.Health.UptimeCountsWarnings)
 
+	connections, peakConnections, maxClients := h.hub.GetConnectionCounts()
 	health := gin.H{
 		"status":     "healthy",
 		"timestamp":  time.Now(),
 		"websocket": gin.H{
 			"connected_clients": h.hub.GetClientCount(),
+			"connections":       connections,
+			"peak_connections":  peakConnections,
+			"max_clients":       maxClients,
+			"avg_latency_ms":    float64(h.hub.GetAverageLatency().Microseconds()) / 1000,
 		},
 		"database": gin.H{
-			"status": "connected",
+			"status": databaseStatus,
 		},
 		"recent_activity": gin.H{
 			"total_events_1h":  stats.TotalEvents,
@@ -249,63 +802,318 @@ func (h *Handler) GetSystemHealth(c *gin.Context) {
 			"warning_events_1h": stats.WarningEvents,
 			"uptime_percent":   stats.UptimePercent,
 		},
-		"thresholds": h.anomalyDetector.GetThresholds(),
+		"thresholds":       thresholdsInUnit(*h.anomalyDetector.GetThresholds(), h.cfg.Units.DisplayTemperature),
+		"temperature_unit": h.cfg.Units.DisplayTemperature,
+		"kafka":            h.kafkaHealth(),
 	}
 
-	// Determine overall health status
-	if stats.UptimePercent < 95.0 {
+	// Determine overall health status; without Kafka no new events arrive, and without the
+	// database they cannot be stored
+	health["status"] = healthStatus(stats.UptimePercent, h.cfg.Health)
+	if databaseStatus != "connected" {
+		health["status"] = "degraded"
+	} else if h.kafka.Consumer() == nil && health["status"] == "healthy" {
 		health["status"] = "degraded"
-	}
-	if stats.UptimePercent < 90.0 {
-		health["status"] = "unhealthy"
 	}
 
 	c.JSON(http.StatusOK, health)
 }
 
-// UpdateAnomalyThresholds updates anomaly detection thresholds
+// readinessTimeout bounds each dependency check made by the readiness probe
+const readinessTimeout = 2 * time.Second
+
+// Liveness reports that the process is running. It checks no dependencies, so a
+// database or Kafka outage does not get the pod restarted.
+func (h *Handler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "alive",
+		"timestamp": time.Now(),
+	})
+}
+
+// Readiness reports whether the server can do useful work: the database must answer a
+// ping and the Kafka consumer must be connected and not stale. Liveness stays up while
+// Kafka is still connecting, so the pod is kept (and reconnects) rather than restarted.
+// It returns 503 otherwise so traffic is routed elsewhere.
+func (h *Handler) Readiness(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+	if err := h.db.PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+		ready = false
+	} else {
+		checks["database"] = "ok"
+	}
+	if h.db.HasReplica() {
+		if err := h.db.PingReplica(ctx); err != nil {
+			checks["database_replica"] = err.Error()
+			ready = false
+		} else {
+			checks["database_replica"] = "ok"
+		}
+	}
+
+	if consumer := h.kafka.Consumer(); consumer == nil {
+		checks["kafka"] = "connecting"
+		ready = false
+	} else if err := consumer.Ready(h.cfg.Health.KafkaStaleAfter); err != nil {
+		checks["kafka"] = err.Error()
+		ready = false
+	} else {
+		checks["kafka"] = "ok"
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":    status,
+		"checks":    checks,
+		"timestamp": time.Now(),
+	})
+}
+
+// kafkaHealth reports consumer availability, per-partition lag and per-topic throughput
+func (h *Handler) kafkaHealth() gin.H {
+	consumer := h.kafka.Consumer()
+	if consumer == nil {
+		return gin.H{
+			"status": "connecting",
+		}
+	}
+
+	lags, err := consumer.Lag()
+	if err != nil {
+		return gin.H{
+			"status": "connected",
+			"error":  err.Error(),
+			"topics": consumer.Throughput(),
+		}
+	}
+
+	var totalLag int64
+	for _, lag := range lags {
+		totalLag += lag.Lag
+	}
+
+	return gin.H{
+		"status":     "connected",
+		"total_lag":  totalLag,
+		"partitions": lags,
+		"topics":     consumer.Throughput(),
+	}
+}
+
+// healthStatus classifies an uptime percentage against the configured thresholds
+func healthStatus(uptimePercent float64, thresholds config.HealthConfig) string {
+	switch {
+	case uptimePercent < thresholds.UnhealthyUptime:
+		return "unhealthy"
+	case uptimePercent < thresholds.DegradedUptime:
+		return "degraded"
+	default:
+		return "healthy"
+	}
+}
+
+// UpdateAnomalyThresholds updates anomaly detection thresholds. Temperatures are read in
+// the unit given by the unit query parameter, defaulting to the display unit.
 func (h *Handler) UpdateAnomalyThresholds(c *gin.Context) {
-	var thresholds models.AnomalyThresholds
-	if err := c.ShouldBindJSON(&thresholds); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid threshold data",
-			"details": err.Error(),
-		})
+	unit, err := h.temperatureUnit(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unit parameter", err)
 		return
 	}
 
-	// Validate thresholds
-	if thresholds.ConveyorSpeedMin < 0 || thresholds.ConveyorSpeedMax <= thresholds.ConveyorSpeedMin {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid conveyor speed thresholds",
-		})
+	var thresholds models.AnomalyThresholds
+	if err := c.ShouldBindJSON(&thresholds); err != nil {
+		writeBodyError(c, "Invalid threshold data", err)
 		return
 	}
 
-	if thresholds.TemperatureMax <= thresholds.TemperatureMin {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid temperature thresholds",
-		})
+	if err := thresholds.Validate(); err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid threshold data", err)
 		return
 	}
 
-	h.anomalyDetector.UpdateThresholds(&thresholds)
+	// Thresholds are stored in Celsius like the events they are compared against
+	canonical := thresholds
+	canonical.TemperatureMin = unit.ToCelsius(thresholds.TemperatureMin)
+	canonical.TemperatureMax = unit.ToCelsius(thresholds.TemperatureMax)
+	previous := h.anomalyDetector.GetThresholds()
+	h.anomalyDetector.UpdateThresholds(&canonical)
+	h.audit(c, "", AuditThresholdsUpdate, "anomaly_thresholds", previous, &canonical) // In Celsius, as stored
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "Anomaly thresholds updated successfully",
-		"thresholds": thresholds,
+		"message":          "Anomaly thresholds updated successfully",
+		"thresholds":       thresholdsInUnit(canonical, unit),
+		"temperature_unit": unit,
 	})
 }
 
 // GetAnomalyThresholds retrieves current anomaly detection thresholds
 func (h *Handler) GetAnomalyThresholds(c *gin.Context) {
-	thresholds := h.anomalyDetector.GetThresholds()
+	unit, err := h.temperatureUnit(c)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid unit parameter", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thresholds":       thresholdsInUnit(*h.anomalyDetector.GetThresholds(), unit),
+		"temperature_unit": unit,
+	})
+}
+
+// GetAnomalyRules lists the anomaly detection rules and whether each is enabled
+func (h *Handler) GetAnomalyRules(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"thresholds": thresholds,
+		"rules": h.anomalyDetector.Rules(),
 	})
 }
 
+// UpdateAnomalyRules enables or disables anomaly detection rules. The body maps rule
+// names to their enabled state, e.g. {"rules": {"speed_instability": false}}; rules not
+// named are left unchanged. Settings are persisted and restored on restart. Every rule is
+// validated before any is changed, and all are persisted together, so a failed request
+// changes nothing.
+func (h *Handler) UpdateAnomalyRules(c *gin.Context) {
+	var updateRequest struct {
+		Rules map[string]bool `json:"rules" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		writeBodyError(c, "Invalid request body", err)
+		return
+	}
+
+	for rule := range updateRequest.Rules {
+		if !services.IsAnomalyRule(rule) {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Unknown anomaly rule: %s", rule), nil)
+			return
+		}
+	}
+
+	previous := make(map[string]bool, len(updateRequest.Rules))
+	for _, rule := range h.anomalyDetector.Rules() {
+		if _, ok := updateRequest.Rules[rule.Name]; ok {
+			previous[rule.Name] = rule.Enabled
+		}
+	}
+
+	if err := h.db.SetAnomalyRules(updateRequest.Rules); err != nil {
+		writeError(c, http.StatusInternalServerError, ErrCodeInternal, "Failed to update anomaly rules", err)
+		return
+	}
+	for rule, enabled := range updateRequest.Rules {
+		h.anomalyDetector.SetRuleEnabled(rule, enabled)
+	}
+	h.audit(c, "", AuditRulesUpdate, "anomaly_rules", previous, updateRequest.Rules)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Anomaly rules updated successfully",
+		"rules":   h.anomalyDetector.Rules(),
+	})
+}
+
+// PreviewAlertTemplate renders an alert message template against a sample event and
+// values without saving it, so operators can check a template before configuring it.
+// Templates that fail to parse or render are rejected with the template error as details.
+func (h *Handler) PreviewAlertTemplate(c *gin.Context) {
+	var previewRequest struct {
+		Template    string              `json:"template" binding:"required"`
+		Event       *models.SensorEvent `json:"event"`
+		Value       string              `json:"value"`
+		Limit       string              `json:"limit"`
+		Description string              `json:"description"`
+		Action      string              `json:"action"`
+		Count       int                 `json:"count"`
+		Total       int                 `json:"total"`
+		Duration    string              `json:"duration"` // e.g. 5m
+	}
+	if err := c.ShouldBindJSON(&previewRequest); err != nil {
+		writeBodyError(c, "Invalid request body", err)
+		return
+	}
+
+	data := services.AlertMessageData{
+		Event:       previewRequest.Event,
+		Value:       previewRequest.Value,
+		Limit:       previewRequest.Limit,
+		Description: previewRequest.Description,
+		Action:      previewRequest.Action,
+		Count:       previewRequest.Count,
+		Total:       previewRequest.Total,
+	}
+	if previewRequest.Event != nil {
+		data.MachineID = previewRequest.Event.MachineID
+	}
+	if previewRequest.Duration != "" {
+		duration, err := time.ParseDuration(previewRequest.Duration)
+		if err != nil {
+			writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid duration", err)
+			return
+		}
+		data.Duration = duration
+	}
+
+	message, err := services.PreviewAlertTemplate(previewRequest.Template, data)
+	if err != nil {
+		writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid alert template", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+// temperatureUnit returns the unit requested by the unit query parameter, or the
+// configured display unit when it is absent
+func (h *Handler) temperatureUnit(c *gin.Context) (models.TemperatureUnit, error) {
+	if param := c.Query("unit"); param != "" {
+		return models.ParseTemperatureUnit(param)
+	}
+	return h.cfg.Units.DisplayTemperature, nil
+}
+
+// displayLocation returns the time zone requested by the tz query parameter (an IANA
+// name such as Europe/Berlin), or the configured display time zone when it is absent.
+// It applies to human-facing timestamps only; stored and streamed times stay in UTC.
+func (h *Handler) displayLocation(c *gin.Context) (*time.Location, error) {
+	if param := c.Query("tz"); param != "" {
+		return time.LoadLocation(param)
+	}
+	return h.cfg.Units.DisplayTimezone, nil
+}
+
+// thresholdsInUnit returns a copy of Celsius thresholds with temperatures converted to unit
+func thresholdsInUnit(thresholds models.AnomalyThresholds, unit models.TemperatureUnit) models.AnomalyThresholds {
+	thresholds.TemperatureMin = unit.FromCelsius(thresholds.TemperatureMin)
+	thresholds.TemperatureMax = unit.FromCelsius(thresholds.TemperatureMax)
+	return thresholds
+}
+
 // WebSocketEndpoint handles WebSocket connections
 func (h *Handler) WebSocketEndpoint(c *gin.Context) {
 	h.hub.HandleWebSocket(c.Writer, c.Request)
-}
\ No newline at end of file
+}
+
+// StatsWebSocketEndpoint handles WebSocket connections that stream only periodic stats
+func (h *Handler) StatsWebSocketEndpoint(c *gin.Context) {
+	h.hub.HandleStatsWebSocket(c.Writer, c.Request)
+}
+
+// Metrics serves the Kafka consumer's lag and per-topic throughput in the Prometheus
+// text exposition format, for scraping
+func (h *Handler) Metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := kafka.WriteMetrics(c.Writer, h.kafka.Consumer()); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
@@ -0,0 +1,994 @@
+package handlers
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/kafka"
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetEventsRedactsRawData(t *testing.T) {
+	redactInternalCode(t)
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, redactionEvent, time.Minute)
+
+	recorder := request(handler.GetEvents, "GET", "/events", "/events", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Events []struct {
+			MachineID string                 `json:"machine_id"`
+			RawData   map[string]interface{} `json:"raw_data"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body.Events) != 1 {
+		t.Fatalf("got %d events, want 1", len(body.Events))
+	}
+	if _, ok := body.Events[0].RawData["internal_code"]; ok {
+		t.Errorf("raw_data = %v, want internal_code redacted", body.Events[0].RawData)
+	}
+	if body.Events[0].RawData["vibration"] != 0.4 {
+		t.Errorf("raw_data = %v, want vibration kept", body.Events[0].RawData)
+	}
+}
+
+func TestGetAlertsIncludeEventRedactsRawData(t *testing.T) {
+	redactInternalCode(t)
+	handler, store := newTestHandler(t)
+	event := insertEvent(t, store, redactionEvent, time.Minute)
+	if err := store.InsertAlert(&models.Alert{
+		EventID:   &event.ID,
+		MachineID: event.MachineID,
+		AlertType: "temperature_high",
+		Severity:  "high",
+		Message:   "Temperature too high",
+	}); err != nil {
+		t.Fatalf("InsertAlert: %v", err)
+	}
+
+	recorder := request(handler.GetAlerts, "GET", "/alerts", "/alerts?include=event", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Alerts []struct {
+			Event *struct {
+				RawData map[string]interface{} `json:"raw_data"`
+			} `json:"event"`
+		} `json:"alerts"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body.Alerts) != 1 || body.Alerts[0].Event == nil {
+		t.Fatalf("response = %s, want 1 alert with its event", recorder.Body.String())
+	}
+	rawData := body.Alerts[0].Event.RawData
+	if _, ok := rawData["internal_code"]; ok {
+		t.Errorf("raw_data = %v, want internal_code redacted", rawData)
+	}
+	if rawData["vibration"] != 0.4 {
+		t.Errorf("raw_data = %v, want vibration kept", rawData)
+	}
+}
+
+func TestIngestEventWithMissingMetricStoresNull(t *testing.T) {
+	handler, store := newTestHandler(t)
+
+	body := `{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "ok",
+		"timestamp": "` + time.Now().Add(-time.Second).Format(time.RFC3339) + `",
+		"conveyor_speed": 1.5, "temperature": null}`
+	recorder := request(handler.IngestEvents, "POST", "/ingest", "/ingest", body)
+	expectStatus(t, recorder, http.StatusCreated)
+
+	events, err := store.GetRecentEvents(database.EventFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetRecentEvents: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("stored %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Temperature != nil || event.RobotArmAngle != nil {
+		t.Errorf("temperature = %v, robot_arm_angle = %v, want both NULL", event.Temperature, event.RobotArmAngle)
+	}
+	if event.ConveyorSpeed == nil || *event.ConveyorSpeed != 1.5 {
+		t.Errorf("conveyor_speed = %v, want 1.5", event.ConveyorSpeed)
+	}
+}
+
+func TestGetEventStatsUsesLiveWindowWithMissingMetrics(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	// The window holds speeds but no temperature readings at all
+	for _, speed := range []float64{1.0, 2.0} {
+		body := fmt.Sprintf(`{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "ok",
+			"timestamp": %q, "conveyor_speed": %g}`, time.Now().Add(-time.Second).Format(time.RFC3339), speed)
+		expectStatus(t, request(handler.IngestEvents, "POST", "/ingest", "/ingest", body), http.StatusCreated)
+	}
+
+	recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?machine_id=conveyor_001", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Stats models.EventStats `json:"stats"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Stats.AvgConveyorSpeed != 1.5 {
+		t.Errorf("avg_conveyor_speed = %v, want 1.5 from the live window", body.Stats.AvgConveyorSpeed)
+	}
+	if body.Stats.AvgTemperature != 0 || body.Stats.P95Temperature != nil {
+		t.Errorf("avg_temperature = %v, p95_temperature = %v, want the stored values for no readings",
+			body.Stats.AvgTemperature, body.Stats.P95Temperature)
+	}
+}
+
+func TestAcknowledgeAlertRecordsOperatorAndAudit(t *testing.T) {
+	handler, store := newTestHandler(t)
+	alert := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+
+	target := fmt.Sprintf("/alerts/%d/acknowledge", alert.ID)
+	recorder := request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", target, `{"acknowledged_by": " ana ", "note": " checked\n"}`)
+	expectStatus(t, recorder, http.StatusOK)
+
+	// The response echoes the values as stored, trimmed
+	var response struct {
+		AcknowledgedBy string `json:"acknowledged_by"`
+		Note           string `json:"note"`
+	}
+	decode(t, recorder, &response)
+	if response.AcknowledgedBy != "ana" || response.Note != "checked" {
+		t.Errorf("response acknowledged_by = %q, note = %q, want ana and checked", response.AcknowledgedBy, response.Note)
+	}
+
+	stored, err := store.GetAlert(alert.ID)
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if !stored.Acknowledged || stored.AcknowledgedAt == nil {
+		t.Fatalf("alert = %+v, want acknowledged", stored)
+	}
+	if stored.AcknowledgedBy == nil || *stored.AcknowledgedBy != "ana" || stored.AcknowledgementNote == nil || *stored.AcknowledgementNote != "checked" {
+		t.Errorf("acknowledged_by = %v, note = %v, want ana and checked", stored.AcknowledgedBy, stored.AcknowledgementNote)
+	}
+
+	entries, err := store.GetAuditLog(database.AuditFilter{Action: AuditAlertAcknowledge, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLog: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "ana" || entries[0].Target != fmt.Sprintf("alert:%d", alert.ID) {
+		t.Errorf("audit log = %+v, want one acknowledgement by ana", entries)
+	}
+}
+
+func TestAcknowledgeAlertWithoutBody(t *testing.T) {
+	handler, store := newTestHandler(t)
+	alert := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+
+	target := fmt.Sprintf("/alerts/%d/acknowledge", alert.ID)
+	expectStatus(t, request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", target, ""), http.StatusOK)
+
+	if stored, err := store.GetAlert(alert.ID); err != nil || !stored.Acknowledged {
+		t.Errorf("alert = %+v (%v), want acknowledged", stored, err)
+	}
+}
+
+func TestAcknowledgeAlertRejectsUnknownAndInvalidIDs(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	recorder := request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", "/alerts/42/acknowledge", "")
+	expectStatus(t, recorder, http.StatusNotFound)
+	recorder = request(handler.AcknowledgeAlert, "PUT", "/alerts/:id/acknowledge", "/alerts/abc/acknowledge", "")
+	expectStatus(t, recorder, http.StatusBadRequest)
+}
+
+func TestSnoozeAlertSuppressesDetectedAlerts(t *testing.T) {
+	handler, store := newTestHandler(t)
+	ingest(t, handler, "conveyor_001", "ok", `, "temperature": 150`)
+	alerts, err := store.GetUnacknowledgedAlerts(nil, "")
+	if err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].AlertType != "temperature_high" {
+		t.Fatalf("alerts = %+v, want one temperature_high", alerts)
+	}
+
+	target := fmt.Sprintf("/alerts/%d/snooze", alerts[0].ID)
+	recorder := request(handler.SnoozeAlert, "POST", "/alerts/:id/snooze", target, `{"duration": "1h"}`)
+	expectStatus(t, recorder, http.StatusOK)
+
+	ingest(t, handler, "conveyor_001", "ok", `, "temperature": 150`)
+	if alerts, _ := store.GetUnacknowledgedAlerts(nil, ""); len(alerts) != 1 {
+		t.Errorf("stored %d alerts, want the snoozed type suppressed", len(alerts))
+	}
+
+	recorder = request(handler.GetAlertSnoozes, "GET", "/alerts/snoozes", "/alerts/snoozes", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var body struct {
+		Snoozes []models.AlertSnooze `json:"snoozes"`
+	}
+	decode(t, recorder, &body)
+	if len(body.Snoozes) != 1 || body.Snoozes[0].MachineID != "conveyor_001" || body.Snoozes[0].AlertType != "temperature_high" {
+		t.Errorf("snoozes = %+v, want temperature_high for conveyor_001", body.Snoozes)
+	}
+}
+
+func TestSnoozeAlertRejectsInvalidDuration(t *testing.T) {
+	handler, store := newTestHandler(t)
+	alert := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+
+	target := fmt.Sprintf("/alerts/%d/snooze", alert.ID)
+	for _, duration := range []string{"soon", "-1h", "0s", "1000h"} {
+		recorder := request(handler.SnoozeAlert, "POST", "/alerts/:id/snooze", target, fmt.Sprintf(`{"duration": %q}`, duration))
+		expectStatus(t, recorder, http.StatusBadRequest)
+	}
+	if snoozes, _ := store.GetActiveSnoozes(); len(snoozes) != 0 {
+		t.Errorf("snoozes = %+v, want none", snoozes)
+	}
+}
+
+func TestGetMachinesFiltersByAreaWithRealTimeStats(t *testing.T) {
+	handler, store := newTestHandler(t)
+	store.AddMachine(models.Machine{MachineID: "conveyor_001", Area: "assembly", Line: "A", Config: map[string]interface{}{"rated_speed": 2.0}})
+	store.AddMachine(models.Machine{MachineID: "conveyor_002", Area: "packaging", Line: "B"})
+	ingest(t, handler, "conveyor_001", "ok", `, "conveyor_speed": 1.5`)
+
+	recorder := request(handler.GetMachines, "GET", "/machines", "/machines?area=assembly", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Machines []models.Machine `json:"machines"`
+		Count    int              `json:"count"`
+	}
+	decode(t, recorder, &body)
+	if body.Count != 1 || len(body.Machines) != 1 || body.Machines[0].MachineID != "conveyor_001" {
+		t.Fatalf("machines = %+v, want conveyor_001 only", body.Machines)
+	}
+	stats, ok := body.Machines[0].Config["real_time_stats"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("config = %v, want real_time_stats", body.Machines[0].Config)
+	}
+	if stats["rated_speed"] != 2.0 || stats["performance_percent"] != 75.0 {
+		t.Errorf("real_time_stats = %v, want rated_speed 2 and performance_percent 75", stats)
+	}
+}
+
+func TestGetMachineReliabilityCountsFaultOnsets(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for i, status := range []string{"ok", "fault", "fault", "ok", "fault", "ok"} {
+		insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: status},
+			time.Duration(6-i)*time.Hour)
+	}
+
+	recorder := request(handler.GetMachineReliability, "GET", "/machines/:id/reliability", "/machines/conveyor_001/reliability?since=1d", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var reliability models.MachineReliability
+	decode(t, recorder, &reliability)
+	if reliability.FaultOnsets != 2 {
+		t.Errorf("fault_onsets = %d, want 2 for two runs of faults", reliability.FaultOnsets)
+	}
+	// Events are timestamped relative to separate readings of the clock, so allow a second
+	if mtbf := reliability.MeanTimeBetweenFaultsSeconds; mtbf == nil || math.Abs(*mtbf-(3*time.Hour).Seconds()) > 1 {
+		t.Errorf("mean_time_between_faults_seconds = %v, want %v", mtbf, (3 * time.Hour).Seconds())
+	}
+}
+
+func TestGetAlertsFiltersBySeverity(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for _, severity := range []string{"low", "medium", "high", "critical"} {
+		insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "speed_low", Severity: severity, Message: severity})
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"high", []string{"high"}},
+		{"high,Critical", []string{"critical", "high"}},
+		{"", []string{"critical", "high", "low", "medium"}},
+	} {
+		recorder := request(handler.GetAlerts, "GET", "/alerts", "/alerts?severity="+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+
+		var body struct {
+			Alerts []models.Alert `json:"alerts"`
+		}
+		decode(t, recorder, &body)
+		var got []string
+		for _, alert := range body.Alerts {
+			got = append(got, alert.Severity)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("severity=%s: got %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	expectStatus(t, request(handler.GetAlerts, "GET", "/alerts", "/alerts?severity=urgent", ""), http.StatusBadRequest)
+}
+
+func TestGetLatestEventsReturnsNewestPerMachine(t *testing.T) {
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, 3*time.Minute)
+	newest := insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "fault"}, time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, 2*time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "robot_001", EventType: "robot", Status: "ok"}, 5*time.Minute)
+	newestRobot := insertEvent(t, store, models.SensorEvent{MachineID: "robot_001", EventType: "robot", Status: "warning"}, 4*time.Minute)
+
+	recorder := request(handler.GetLatestEvents, "GET", "/events/latest", "/events/latest", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Events []models.Event `json:"events"`
+	}
+	decode(t, recorder, &body)
+	got := make(map[string]int)
+	for _, event := range body.Events {
+		if _, seen := got[event.MachineID]; seen {
+			t.Errorf("machine %s returned more than once", event.MachineID)
+		}
+		got[event.MachineID] = event.ID
+	}
+	want := map[string]int{"conveyor_001": newest.ID, "robot_001": newestRobot.ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("latest event IDs = %v, want %v", got, want)
+	}
+}
+
+func TestGetCurrentAlertsReturnsLatestPerMachineAndType(t *testing.T) {
+	handler, store := newTestHandler(t)
+	var latest *models.Alert
+	for i := 1; i <= 3; i++ {
+		latest = insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "speed_low", Severity: "medium", Message: fmt.Sprintf("slow %d", i)})
+	}
+	acknowledged := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "speed_low", Severity: "medium", Message: "slow 4"})
+	if err := store.AcknowledgeAlert(acknowledged.ID, "operator", ""); err != nil {
+		t.Fatalf("AcknowledgeAlert: %v", err)
+	}
+	temperature := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+	other := insertAlert(t, store, models.Alert{MachineID: "conveyor_002", AlertType: "speed_low", Severity: "medium", Message: "slow"})
+
+	recorder := request(handler.GetCurrentAlerts, "GET", "/alerts/current", "/alerts/current", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, recorder, &body)
+	var got []int
+	for _, alert := range body.Alerts {
+		got = append(got, alert.ID)
+	}
+	if want := []int{latest.ID, temperature.ID, other.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("current alert IDs = %v, want %v", got, want)
+	}
+}
+
+func TestGetRawDataStatsSkipsEventsWithoutField(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for _, data := range []models.AdditionalData{
+		{"vibration_level": 0.2},
+		{"vibration_level": 0.6, "power_consumption": 17.5},
+		{"power_consumption": 16.0},
+		{"vibration_level": "n/a"},
+	} {
+		insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", AdditionalData: data}, time.Minute)
+	}
+
+	for _, tc := range []struct {
+		field         string
+		count         int64
+		avg, min, max float64
+	}{
+		{"vibration_level", 2, 0.4, 0.2, 0.6},
+		{"power_consumption", 2, 16.75, 16.0, 17.5},
+	} {
+		recorder := request(handler.GetRawDataStats, "GET", "/events/raw-stats", "/events/raw-stats?field="+tc.field, "")
+		expectStatus(t, recorder, http.StatusOK)
+
+		var body struct {
+			Stats models.RawDataStats `json:"stats"`
+		}
+		decode(t, recorder, &body)
+		stats := body.Stats
+		if stats.SampleCount != tc.count || stats.Avg == nil || math.Abs(*stats.Avg-tc.avg) > 1e-9 ||
+			*stats.Min != tc.min || *stats.Max != tc.max {
+			t.Errorf("%s stats = %d samples, avg %v, min %v, max %v; want %d, %g, %g, %g",
+				tc.field, stats.SampleCount, stats.Avg, stats.Min, stats.Max, tc.count, tc.avg, tc.min, tc.max)
+		}
+	}
+
+	recorder := request(handler.GetRawDataStats, "GET", "/events/raw-stats", "/events/raw-stats?field=torque", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var body struct {
+		Stats models.RawDataStats `json:"stats"`
+	}
+	decode(t, recorder, &body)
+	if body.Stats.SampleCount != 0 || body.Stats.Avg != nil {
+		t.Errorf("stats for a missing field = %+v, want no samples and no average", body.Stats)
+	}
+}
+
+func TestHealthStatusAcrossUptimeThresholds(t *testing.T) {
+	thresholds := config.HealthConfig{DegradedUptime: 80, UnhealthyUptime: 60}
+	for _, tc := range []struct {
+		uptime float64
+		want   string
+	}{
+		{100, "healthy"},
+		{80, "healthy"},
+		{79.9, "degraded"},
+		{60, "degraded"},
+		{59.9, "unhealthy"},
+		{0, "unhealthy"},
+	} {
+		if got := healthStatus(tc.uptime, thresholds); got != tc.want {
+			t.Errorf("uptime %g%%: status = %s, want %s", tc.uptime, got, tc.want)
+		}
+	}
+}
+
+func TestGetAlertStatsBucketsBySeverity(t *testing.T) {
+	handler, store := newTestHandler(t)
+	base := time.Now().Truncate(time.Hour).Add(-3 * time.Hour)
+	for i, seeded := range []struct {
+		after    time.Duration
+		severity string
+	}{
+		{10 * time.Minute, "high"},
+		{20 * time.Minute, "high"},
+		{30 * time.Minute, "critical"},
+		{65 * time.Minute, "high"},
+	} {
+		alert := &models.Alert{MachineID: fmt.Sprintf("conveyor_%03d", i), AlertType: "speed_low", Severity: seeded.severity, CreatedAt: base.Add(seeded.after)}
+		if _, err := store.InsertAlertUnlessDuplicate(alert, 0); err != nil {
+			t.Fatalf("InsertAlertUnlessDuplicate: %v", err)
+		}
+	}
+
+	recorder := request(handler.GetAlertStats, "GET", "/alerts/stats", "/alerts/stats?since=6h&interval=1h", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Buckets []models.AlertTrendBucket `json:"buckets"`
+		Totals  map[string]int64          `json:"totals"`
+	}
+	decode(t, recorder, &body)
+	type bucket struct {
+		start    time.Time
+		severity string
+		count    int64
+	}
+	var got []bucket
+	for _, b := range body.Buckets {
+		got = append(got, bucket{b.BucketStart.UTC(), b.Severity, b.Count})
+	}
+	want := []bucket{
+		{base.UTC(), "critical", 1},
+		{base.UTC(), "high", 2},
+		{base.Add(time.Hour).UTC(), "high", 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buckets = %+v, want %+v", got, want)
+	}
+	if want := map[string]int64{"high": 3, "critical": 1}; !reflect.DeepEqual(body.Totals, want) {
+		t.Errorf("totals = %v, want %v", body.Totals, want)
+	}
+
+	expectStatus(t, request(handler.GetAlertStats, "GET", "/alerts/stats", "/alerts/stats?interval=2h", ""), http.StatusBadRequest)
+}
+
+func TestGetEventsPagesSameTimestampEventsStably(t *testing.T) {
+	handler, store := newTestHandler(t)
+	timestamp := time.Now().Add(-time.Minute).Truncate(time.Second)
+	var want []int
+	for i := 0; i < 5; i++ {
+		stored, err := store.InsertEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: timestamp})
+		if err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+		want = append([]int{stored.ID}, want...) // Newest first, ties broken by ID
+	}
+
+	type page struct {
+		Events []struct {
+			ID int `json:"id"`
+		} `json:"events"`
+		Pagination struct {
+			NextCursor string `json:"next_cursor"`
+		} `json:"pagination"`
+	}
+	fetch := func(query string) page {
+		recorder := request(handler.GetEvents, "GET", "/events", "/events?limit=2"+query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body page
+		decode(t, recorder, &body)
+		return body
+	}
+
+	for _, mode := range []string{"cursor", "offset"} {
+		var got []int
+		query := ""
+		for pages := 0; pages < 3; pages++ {
+			body := fetch(query)
+			for _, event := range body.Events {
+				got = append(got, event.ID)
+			}
+			if mode == "cursor" {
+				query = "&cursor=" + url.QueryEscape(body.Pagination.NextCursor)
+			} else {
+				query = fmt.Sprintf("&offset=%d", len(got))
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s pages = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestGetEventStatsConvertsTemperatureUnit(t *testing.T) {
+	handler, store := newTestHandler(t)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(100)}, time.Minute)
+
+	for _, tc := range []struct {
+		unit string
+		want float64
+	}{
+		{"C", 100},
+		{"fahrenheit", 212},
+	} {
+		recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?unit="+tc.unit, "")
+		expectStatus(t, recorder, http.StatusOK)
+
+		var body struct {
+			Stats models.EventStats `json:"stats"`
+		}
+		decode(t, recorder, &body)
+		if body.Stats.AvgTemperature != tc.want || body.Stats.P95Temperature == nil || *body.Stats.P95Temperature != tc.want {
+			t.Errorf("unit %s: avg = %g, p95 = %v, want %g", tc.unit, body.Stats.AvgTemperature, body.Stats.P95Temperature, tc.want)
+		}
+	}
+
+	expectStatus(t, request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?unit=K", ""), http.StatusBadRequest)
+}
+
+func TestGetEventStatsValidatesSince(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	handler.cfg.Server.MaxLookback = 90 * 24 * time.Hour
+
+	for _, tc := range []struct {
+		since  string
+		status int
+	}{
+		{"7d", http.StatusOK},
+		{"90m", http.StatusOK},
+		{"90d", http.StatusOK},
+		{"yesterday", http.StatusBadRequest},
+		{"7days", http.StatusBadRequest},
+		{"-1h", http.StatusBadRequest},
+		{"91d", http.StatusBadRequest},
+		{"2200h", http.StatusBadRequest},
+	} {
+		recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?since="+url.QueryEscape(tc.since), "")
+		if recorder.Code != tc.status {
+			t.Errorf("since=%s: status = %d, want %d; body: %s", tc.since, recorder.Code, tc.status, recorder.Body.String())
+		}
+	}
+
+	since, err := parseSince("7d", 0)
+	if want := time.Now().Add(-7 * 24 * time.Hour); err != nil || since.Sub(want).Abs() > time.Minute {
+		t.Errorf("parseSince(7d) = %s, %v; want about %s", since, err, want)
+	}
+}
+
+func TestAreaFilterScopesEventsStatsAndAlerts(t *testing.T) {
+	handler, store := newTestHandler(t)
+	store.AddMachine(models.Machine{MachineID: "conveyor_001", Area: "assembly"})
+	store.AddMachine(models.Machine{MachineID: "conveyor_002", Area: "assembly"})
+	store.AddMachine(models.Machine{MachineID: "conveyor_003", Area: "packaging"})
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(60)}, 3*time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "fault", Temperature: float(70)}, 2*time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_002", EventType: "conveyor", Status: "ok", Temperature: float(80)}, time.Minute)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_003", EventType: "conveyor", Status: "fault", Temperature: float(200)}, time.Minute)
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "repeated_faults", Severity: "high", Message: "faults"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_003", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+
+	recorder := request(handler.GetEvents, "GET", "/events", "/events?area=assembly", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var events struct {
+		Events []models.Event `json:"events"`
+	}
+	decode(t, recorder, &events)
+	if len(events.Events) != 3 {
+		t.Errorf("events = %d, want the 3 from assembly machines", len(events.Events))
+	}
+	for _, event := range events.Events {
+		if event.MachineID == "conveyor_003" {
+			t.Errorf("event from packaging machine %s in assembly results", event.MachineID)
+		}
+	}
+
+	recorder = request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?area=assembly", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var stats struct {
+		Stats models.EventStats `json:"stats"`
+	}
+	decode(t, recorder, &stats)
+	if stats.Stats.TotalEvents != 3 || stats.Stats.FaultEvents != 1 || stats.Stats.AvgTemperature != 70 {
+		t.Errorf("stats = %+v, want 3 events, 1 fault and an average of 70 across assembly", stats.Stats)
+	}
+
+	recorder = request(handler.GetAlerts, "GET", "/alerts", "/alerts?area=assembly", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var alerts struct {
+		Alerts []models.Alert `json:"alerts"`
+	}
+	decode(t, recorder, &alerts)
+	if len(alerts.Alerts) != 1 || alerts.Alerts[0].MachineID != "conveyor_001" {
+		t.Errorf("alerts = %+v, want conveyor_001's only", alerts.Alerts)
+	}
+}
+
+func TestGetSeenMachinesListsDistinctMachinesWithSeenBounds(t *testing.T) {
+	handler, store := newTestHandler(t)
+	store.AddMachine(models.Machine{MachineID: "conveyor_001"})
+	first := insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, 3*time.Hour)
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, 2*time.Hour)
+	last := insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, time.Hour)
+	unregistered := insertEvent(t, store, models.SensorEvent{MachineID: "robot_404", EventType: "robot", Status: "ok"}, 30*time.Minute)
+
+	recorder := request(handler.GetSeenMachines, "GET", "/events/machines", "/events/machines", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var body struct {
+		Machines []models.SeenMachine `json:"machines"`
+		Count    int                  `json:"count"`
+	}
+	decode(t, recorder, &body)
+	if body.Count != 2 || len(body.Machines) != 2 {
+		t.Fatalf("machines = %+v, want conveyor_001 and robot_404 once each", body.Machines)
+	}
+
+	conveyor, robot := body.Machines[0], body.Machines[1]
+	if conveyor.MachineID != "conveyor_001" || !conveyor.FirstSeen.Equal(first.Timestamp) || !conveyor.LastSeen.Equal(last.Timestamp) || !conveyor.Registered {
+		t.Errorf("conveyor_001 = %+v, want registered, seen from %s to %s", conveyor, first.Timestamp, last.Timestamp)
+	}
+	if robot.MachineID != "robot_404" || !robot.FirstSeen.Equal(unregistered.Timestamp) || !robot.LastSeen.Equal(unregistered.Timestamp) || robot.Registered {
+		t.Errorf("robot_404 = %+v, want unregistered, seen once at %s", robot, unregistered.Timestamp)
+	}
+
+	recorder = request(handler.GetSeenMachines, "GET", "/events/machines", "/events/machines?registered=false", "")
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, recorder, &body)
+	if body.Count != 1 || body.Machines[0].MachineID != "robot_404" {
+		t.Errorf("unregistered machines = %+v, want robot_404 only", body.Machines)
+	}
+}
+
+func TestReadinessFailsWhileDependencyDownButLivenessStaysUp(t *testing.T) {
+	handler, store := newTestHandler(t)
+	handler.kafka = kafka.NewConnector(handler.cfg.Kafka, handler.validator) // Never connected
+
+	for _, tc := range []struct {
+		name       string
+		db         database.Store
+		check, err string
+	}{
+		{"kafka connecting", store, "kafka", "connecting"},
+		{"database down", unreachableStore{store}, "database", "connection refused"},
+	} {
+		handler.db = tc.db
+
+		recorder := request(handler.Readiness, "GET", "/health/ready", "/health/ready", "")
+		var body struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}
+		decode(t, recorder, &body)
+		if recorder.Code != http.StatusServiceUnavailable || body.Status != "not_ready" || body.Checks[tc.check] != tc.err {
+			t.Errorf("%s: readiness = %d %+v, want 503 with %s %q", tc.name, recorder.Code, body, tc.check, tc.err)
+		}
+
+		if recorder := request(handler.Liveness, "GET", "/health/live", "/health/live", ""); recorder.Code != http.StatusOK {
+			t.Errorf("%s: liveness = %d, want 200", tc.name, recorder.Code)
+		}
+	}
+}
+
+func TestGetEventsFiltersByFaultCode(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	ingest(t, handler, "conveyor_001", "fault", `, "additional_data": {"fault_code": "conv_jam_001"}`)
+	ingest(t, handler, "conveyor_002", "fault", `, "fault_code": "CONV_JAM_001"`)
+	ingest(t, handler, "conveyor_003", "fault", `, "additional_data": {"fault_code": "TEMP_HIGH_002"}`)
+	ingest(t, handler, "conveyor_004", "fault", `, "additional_data": {"fault_code": "vendor-e42"}`)
+	ingest(t, handler, "conveyor_005", "ok", "")
+
+	for _, tc := range []struct {
+		faultCode string
+		want      []string
+	}{
+		{"CONV_JAM_001", []string{"conveyor_001", "conveyor_002"}},
+		{"conv_jam_001", []string{"conveyor_001", "conveyor_002"}},
+		{"TEMP_HIGH_002", []string{"conveyor_003"}},
+		{"vendor-e42", []string{"conveyor_004"}},
+		{"CONV_JAM_002", nil},
+	} {
+		recorder := request(handler.GetEvents, "GET", "/events", "/events?fault_code="+url.QueryEscape(tc.faultCode), "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body struct {
+			Events []models.Event `json:"events"`
+		}
+		decode(t, recorder, &body)
+
+		var machines []string
+		for _, event := range body.Events {
+			machines = append(machines, event.MachineID)
+		}
+		sort.Strings(machines)
+		if strings.Join(machines, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("fault_code=%s: machines = %v, want %v", tc.faultCode, machines, tc.want)
+		}
+	}
+}
+
+func TestUpdateAnomalyThresholdsAuditsBeforeAndAfter(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	previous := *handler.anomalyDetector.GetThresholds()
+	updated := previous
+	updated.TemperatureMax = previous.TemperatureMax + 5
+	body, err := json.Marshal(updated)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	router := gin.New()
+	router.PUT("/anomaly/thresholds", handler.UpdateAnomalyThresholds)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/anomaly/thresholds?unit=celsius", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auditActorHeader, "ana")
+	router.ServeHTTP(recorder, req)
+	expectStatus(t, recorder, http.StatusOK)
+
+	recorder = request(handler.GetAuditLog, "GET", "/audit", "/audit?action="+AuditThresholdsUpdate, "")
+	expectStatus(t, recorder, http.StatusOK)
+	var audit struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	decode(t, recorder, &audit)
+	if len(audit.Entries) != 1 {
+		t.Fatalf("audit entries = %+v, want one threshold update", audit.Entries)
+	}
+	entry := audit.Entries[0]
+	if entry.Actor != "ana" || entry.Target != "anomaly_thresholds" {
+		t.Errorf("entry = %+v, want a change to anomaly_thresholds by ana", entry)
+	}
+
+	var before, after models.AnomalyThresholds
+	if err := json.Unmarshal(entry.OldValue, &before); err != nil || before != previous {
+		t.Errorf("old value = %s, want %+v", entry.OldValue, previous)
+	}
+	if err := json.Unmarshal(entry.NewValue, &after); err != nil || after != updated {
+		t.Errorf("new value = %s, want %+v", entry.NewValue, updated)
+	}
+}
+
+func TestGetAlertContextReturnsWindowAroundLinkedEvent(t *testing.T) {
+	handler, store := newTestHandler(t)
+	ids := make(map[time.Duration]int)
+	for _, ago := range []time.Duration{16 * time.Minute, 14 * time.Minute, 11 * time.Minute, 10 * time.Minute, 9*time.Minute + 30*time.Second, 8 * time.Minute} {
+		ids[ago] = insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, ago).ID
+	}
+	insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_002", EventType: "conveyor", Status: "ok"}, 12*time.Minute)
+	anchor := ids[10*time.Minute]
+	alert := insertAlert(t, store, models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot", EventID: &anchor})
+
+	target := fmt.Sprintf("/alerts/%d/context", alert.ID)
+	recorder := request(handler.GetAlertContext, "GET", "/alerts/:id/context", target, "")
+	expectStatus(t, recorder, http.StatusOK)
+	var body struct {
+		Events    []models.Event `json:"events"`
+		Truncated bool           `json:"truncated"`
+	}
+	decode(t, recorder, &body)
+
+	var got []int
+	for _, event := range body.Events {
+		got = append(got, event.ID)
+	}
+	want := []int{ids[14*time.Minute], ids[11*time.Minute], anchor, ids[9*time.Minute+30*time.Second]}
+	if !reflect.DeepEqual(got, want) || body.Truncated {
+		t.Errorf("context events = %v (truncated %v), want %v: 5m before to 1m after the linked event, oldest first", got, body.Truncated, want)
+	}
+
+	recorder = request(handler.GetAlertContext, "GET", "/alerts/:id/context", target+"?before=1m&after=0s", "")
+	expectStatus(t, recorder, http.StatusOK)
+	decode(t, recorder, &body)
+	if len(body.Events) != 1 || body.Events[0].ID != anchor {
+		t.Errorf("context events = %+v, want the linked event alone", body.Events)
+	}
+
+	for _, query := range []string{"?before=-1m", "?after=soon", "?limit=0"} {
+		expectStatus(t, request(handler.GetAlertContext, "GET", "/alerts/:id/context", target+query, ""), http.StatusBadRequest)
+	}
+	expectStatus(t, request(handler.GetAlertContext, "GET", "/alerts/:id/context", "/alerts/999/context", ""), http.StatusNotFound)
+}
+
+func TestGetEventStatsFormatsSinceInDisplayTimezone(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	handler.cfg.Units.DisplayTimezone = kolkata
+
+	for _, tc := range []struct {
+		query, zone, offset string
+	}{
+		{"", "Asia/Kolkata", "+05:30"},
+		{"&tz=America/St_Johns", "America/St_Johns", "-0"}, // -03:30 or -02:30 depending on DST
+		{"&tz=UTC", "UTC", "Z"},
+	} {
+		recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?since=1h"+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body struct {
+			Period struct {
+				Since    string `json:"since"`
+				Timezone string `json:"timezone"`
+			} `json:"period"`
+		}
+		decode(t, recorder, &body)
+
+		since, err := time.Parse(time.RFC3339, body.Period.Since)
+		if err != nil {
+			t.Fatalf("since %q: %v", body.Period.Since, err)
+		}
+		if body.Period.Timezone != tc.zone || !strings.Contains(body.Period.Since[19:], tc.offset) {
+			t.Errorf("tz%s: since %s in %s, want offset %s in %s", tc.query, body.Period.Since, body.Period.Timezone, tc.offset, tc.zone)
+		}
+		if lookback := time.Since(since); lookback < time.Hour-time.Minute || lookback > time.Hour+time.Minute {
+			t.Errorf("tz%s: since %s is %v ago, want the same instant an hour ago in every zone", tc.query, body.Period.Since, lookback)
+		}
+	}
+
+	expectStatus(t, request(handler.GetEventStats, "GET", "/events/stats", "/events/stats?tz=Mars/Olympus", ""), http.StatusBadRequest)
+}
+
+func TestGetEventStatsUptimeFollowsWarningDefinition(t *testing.T) {
+	handler, store := newTestHandler(t)
+	for i, status := range []string{"ok", "ok", "warning", "fault"} {
+		insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: status}, time.Duration(i+1)*time.Minute)
+	}
+
+	for _, tc := range []struct {
+		countWarnings bool
+		want          float64
+	}{
+		{false, 75},
+		{true, 50},
+	} {
+		handler.cfg.Health.UptimeCountsWarnings = tc.countWarnings
+		recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats", "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body struct {
+			Stats models.EventStats `json:"stats"`
+		}
+		decode(t, recorder, &body)
+		if body.Stats.UptimePercent != tc.want {
+			t.Errorf("warnings counted = %v: uptime = %v, want %v", tc.countWarnings, body.Stats.UptimePercent, tc.want)
+		}
+	}
+}
+
+func TestConfiguredIdleStatusAcceptedAndLeftOutOfDetectionAndUptime(t *testing.T) {
+	t.Setenv("EVENT_STATUSES", "ok,warning,fault,idle")
+	t.Setenv("EVENT_IDLE_STATUSES", "idle")
+	handler, store := newTestHandler(t)
+
+	ingest(t, handler, "conveyor_001", "idle", `, "temperature": 150`)
+	ingest(t, handler, "conveyor_001", "ok", `, "temperature": 60`)
+	ingest(t, handler, "conveyor_001", "fault", "")
+
+	body := fmt.Sprintf(`{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "paused", "timestamp": %q}`, time.Now().Format(time.RFC3339))
+	expectStatus(t, request(handler.IngestEvents, "POST", "/ingest", "/ingest", body), http.StatusBadRequest)
+
+	alerts, err := store.GetUnacknowledgedAlerts(nil, "")
+	if err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+	for _, alert := range alerts {
+		if alert.AlertType == "temperature_high" {
+			t.Errorf("temperature_high raised for a reading taken while idle: %+v", alert)
+		}
+	}
+
+	recorder := request(handler.GetEventStats, "GET", "/events/stats", "/events/stats", "")
+	expectStatus(t, recorder, http.StatusOK)
+	var stats struct {
+		Stats models.EventStats `json:"stats"`
+	}
+	decode(t, recorder, &stats)
+	if stats.Stats.TotalEvents != 3 || stats.Stats.IdleEvents != 1 || stats.Stats.UptimePercent != 50 {
+		t.Errorf("stats = %+v, want 3 events, 1 idle and 50%% uptime over the other two", stats.Stats)
+	}
+}
+
+func TestGetAlertsIncludeEventEmbedsLinkedEventsOnly(t *testing.T) {
+	handler, store := newTestHandler(t)
+	event := insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(95)}, time.Minute)
+	insertAlert(t, store, models.Alert{EventID: &event.ID, MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"})
+	insertAlert(t, store, models.Alert{MachineID: "conveyor_002", AlertType: "machine_offline", Severity: "high", Message: "offline"})
+
+	for _, tc := range []struct {
+		query    string
+		embedded bool
+	}{
+		{"?include=event", true},
+		{"", false},
+	} {
+		recorder := request(handler.GetAlerts, "GET", "/alerts", "/alerts"+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body struct {
+			Alerts []struct {
+				MachineID string                     `json:"machine_id"`
+				Event     map[string]json.RawMessage `json:"event"`
+			} `json:"alerts"`
+		}
+		decode(t, recorder, &body)
+		if len(body.Alerts) != 2 {
+			t.Fatalf("alerts%s = %d, want 2", tc.query, len(body.Alerts))
+		}
+
+		for _, alert := range body.Alerts {
+			linked := alert.MachineID == "conveyor_001"
+			if embedded := alert.Event != nil; embedded != (tc.embedded && linked) {
+				t.Errorf("alerts%s: %s alert has event %v", tc.query, alert.MachineID, alert.Event)
+			}
+			if alert.Event != nil && string(alert.Event["id"]) != fmt.Sprint(event.ID) {
+				t.Errorf("alerts%s: embedded event %s, want event %d", tc.query, alert.Event["id"], event.ID)
+			}
+		}
+	}
+}
+
+func TestPreviewAlertTemplate(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	recorder := request(handler.PreviewAlertTemplate, "POST", "/anomaly/templates/preview", "/anomaly/templates/preview",
+		`{"template": "{{.MachineID}} at {{.Value}} (limit {{.Limit}})", "event": {"machine_id": "conveyor_001"}, "value": "92.5", "limit": "85"}`)
+	expectStatus(t, recorder, http.StatusOK)
+	var preview struct {
+		Message string `json:"message"`
+	}
+	decode(t, recorder, &preview)
+	if want := "conveyor_001 at 92.5 (limit 85)"; preview.Message != want {
+		t.Errorf("message = %q, want %q", preview.Message, want)
+	}
+
+	recorder = request(handler.PreviewAlertTemplate, "POST", "/anomaly/templates/preview", "/anomaly/templates/preview",
+		`{"template": "{{.MachineID"}`)
+	expectStatus(t, recorder, http.StatusBadRequest)
+	var got APIError
+	decode(t, recorder, &got)
+	if got.Code != ErrCodeInvalidRequest || got.Message != "Invalid alert template" || got.Details == "" {
+		t.Errorf("error = %+v, want an invalid template error with the parse error as detail", got)
+	}
+}
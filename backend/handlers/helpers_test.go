@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/models"
+	"backend/services"
+	"backend/websocket"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestHandler creates a handler over an empty in-memory store, configured with the
+// defaults of an unset environment. Events it ingests run through the full processing
+// pipeline; detected alerts are stored, and broadcasts go to a hub with no clients.
+func newTestHandler(t *testing.T) (*Handler, *database.MemoryStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	store := database.NewMemoryStore()
+	storeAlert := func(alert *models.Alert) {
+		if err := store.InsertAlert(alert); err != nil {
+			t.Errorf("InsertAlert: %v", err)
+		}
+	}
+
+	hub := websocket.NewHub(cfg.WebSocket, store)
+	detector := services.NewAnomalyDetector(cfg.Anomaly, nil, storeAlert, nil)
+	processor := services.NewEventProcessor(store, services.NewMachineCache(store, 0), detector, hub)
+	validator := services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature)
+	return New(cfg, store, hub, detector, nil, validator, processor, storeAlert), store
+}
+
+// request runs handler for a request to target, with route registered as its path pattern
+func request(handler gin.HandlerFunc, method, route, target, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, handler)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// insertEvent stores a sensor event timestamped ago before now
+func insertEvent(t *testing.T, store database.Store, event models.SensorEvent, ago time.Duration) *models.Event {
+	t.Helper()
+	event.Timestamp = time.Now().Add(-ago)
+	stored, err := store.InsertEvent(&event)
+	if err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+	return stored
+}
+
+// float returns a pointer to value
+func float(value float64) *float64 {
+	return &value
+}
+
+// expectStatus fails the test unless the response has the wanted status code
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if recorder.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", recorder.Code, want, recorder.Body.String())
+	}
+}
+
+// insertAlert stores an alert, returning it with its ID set
+func insertAlert(t *testing.T, store database.Store, alert models.Alert) *models.Alert {
+	t.Helper()
+	if err := store.InsertAlertsBatch([]*models.Alert{&alert}); err != nil {
+		t.Fatalf("InsertAlertsBatch: %v", err)
+	}
+	return &alert
+}
+
+// ingest posts a sensor event for machineID with the given extra JSON fields, timestamped now
+func ingest(t *testing.T, handler *Handler, machineID, status, fields string) {
+	t.Helper()
+	body := fmt.Sprintf(`{"machine_id": %q, "event_type": "conveyor", "status": %q, "timestamp": %q%s}`,
+		machineID, status, time.Now().Add(-time.Second).Format(time.RFC3339), fields)
+	expectStatus(t, request(handler.IngestEvents, "POST", "/ingest", "/ingest", body), http.StatusCreated)
+}
+
+// decode unmarshals a JSON response body into v
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %s: %v", recorder.Body.String(), err)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitRequestBody rejects request bodies larger than the configured maximum with 413.
+// Bodies with a declared length are rejected up front; others are cut off while being
+// read, and the handler reports the failure through writeBodyError.
+func (h *Handler) LimitRequestBody(c *gin.Context) {
+	maxBytes := h.cfg.Server.MaxBodyBytes
+	if maxBytes <= 0 {
+		c.Next()
+		return
+	}
+
+	if c.Request.ContentLength > maxBytes {
+		writeError(c, http.StatusRequestEntityTooLarge, ErrCodeRequestTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", maxBytes), nil)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	c.Next()
+}
+
+// writeBodyError reports a request body that could not be read or decoded: 413 if it
+// exceeded the size limit, otherwise 400 with message
+func writeBodyError(c *gin.Context, message string, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(c, http.StatusRequestEntityTooLarge, ErrCodeRequestTooLarge,
+			fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), nil)
+		return
+	}
+	writeError(c, http.StatusBadRequest, ErrCodeInvalidRequest, message, err)
+}
+
+// pageLimit parses the limit query parameter of a listing, defaulting to defaultLimit.
+// Limits above the configured page size cap are clamped to it; malformed or
+// non-positive limits are an error.
+func (h *Handler) pageLimit(c *gin.Context, defaultLimit int) (int, error) {
+	maxLimit := h.cfg.Server.MaxPageSize
+
+	param := c.Query("limit")
+	if param == "" {
+		return min(defaultLimit, maxLimit), nil
+	}
+
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid limit %q: expected a positive integer", param)
+	}
+	return min(limit, maxLimit), nil
+}
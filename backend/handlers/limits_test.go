@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"backend/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOversizedRequestBodyRejected(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	handler.cfg.Server.MaxBodyBytes = 64
+	router := gin.New()
+	router.Use(handler.LimitRequestBody)
+	router.PUT("/anomaly/thresholds", handler.UpdateAnomalyThresholds)
+	previous := *handler.anomalyDetector.GetThresholds()
+
+	oversized := `{"temperature_max": 90, "padding": "` + strings.Repeat("x", 100) + `"}`
+	for _, tc := range []struct {
+		name string
+		body io.Reader
+	}{
+		{"declared length", strings.NewReader(oversized)},
+		{"unknown length", io.MultiReader(strings.NewReader(oversized))},
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/anomaly/thresholds", tc.body)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status = %d, want 413", tc.name, recorder.Code)
+		}
+		var got APIError
+		decode(t, recorder, &got)
+		if got.Code != ErrCodeRequestTooLarge {
+			t.Errorf("%s: error = %+v, want %s", tc.name, got, ErrCodeRequestTooLarge)
+		}
+	}
+
+	if thresholds := *handler.anomalyDetector.GetThresholds(); thresholds != previous {
+		t.Errorf("thresholds = %+v after rejected requests, want them unchanged", thresholds)
+	}
+}
+
+func TestPageLimitClampedToConfiguredCap(t *testing.T) {
+	handler, store := newTestHandler(t)
+	handler.cfg.Server.MaxPageSize = 3
+	for i := 0; i < 5; i++ {
+		insertEvent(t, store, models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"}, time.Duration(i)*time.Minute)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", 3},
+		{"?limit=2", 2},
+		{"?limit=5000", 3},
+	} {
+		recorder := request(handler.GetEvents, "GET", "/events", "/events"+tc.query, "")
+		expectStatus(t, recorder, http.StatusOK)
+		var body struct {
+			Events     []models.Event `json:"events"`
+			Pagination struct {
+				Limit int `json:"limit"`
+			} `json:"pagination"`
+		}
+		decode(t, recorder, &body)
+		if len(body.Events) != tc.want || body.Pagination.Limit != tc.want {
+			t.Errorf("events%s: %d events with limit %d, want %d", tc.query, len(body.Events), body.Pagination.Limit, tc.want)
+		}
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=-1", "?limit=many"} {
+		expectStatus(t, request(handler.GetEvents, "GET", "/events", "/events"+query, ""), http.StatusBadRequest)
+	}
+}
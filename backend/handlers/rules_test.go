@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/services"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// failingRulesStore fails every anomaly rule update
+type failingRulesStore struct {
+	database.Store
+}
+
+func (failingRulesStore) SetAnomalyRules(map[string]bool) error {
+	return errors.New("connection refused")
+}
+
+// ruleEnabled returns whether the handler's detector has a rule enabled
+func ruleEnabled(t *testing.T, handler *Handler, name string) bool {
+	t.Helper()
+	for _, rule := range handler.anomalyDetector.Rules() {
+		if rule.Name == name {
+			return rule.Enabled
+		}
+	}
+	t.Fatalf("rule %s not listed", name)
+	return false
+}
+
+func TestUpdateAnomalyRulesPersistsAndApplies(t *testing.T) {
+	handler, store := newTestHandler(t)
+
+	body := `{"rules": {"speed_instability": false, "repeated_faults": false}}`
+	expectStatus(t, request(handler.UpdateAnomalyRules, "PUT", "/anomaly/rules", "/anomaly/rules", body), http.StatusOK)
+
+	settings, err := store.GetAnomalyRuleSettings()
+	if err != nil {
+		t.Fatalf("GetAnomalyRuleSettings: %v", err)
+	}
+	if len(settings) != 2 || settings[services.RuleSpeedInstability] || settings[services.RuleRepeatedFaults] {
+		t.Errorf("stored settings = %v, want both rules disabled", settings)
+	}
+	if ruleEnabled(t, handler, services.RuleSpeedInstability) || ruleEnabled(t, handler, services.RuleRepeatedFaults) {
+		t.Error("detector rules still enabled")
+	}
+}
+
+func TestUpdateAnomalyRulesWithUnknownRuleChangesNothing(t *testing.T) {
+	handler, store := newTestHandler(t)
+
+	body := `{"rules": {"speed_instability": false, "no_such_rule": false}}`
+	expectStatus(t, request(handler.UpdateAnomalyRules, "PUT", "/anomaly/rules", "/anomaly/rules", body), http.StatusBadRequest)
+
+	if settings, _ := store.GetAnomalyRuleSettings(); len(settings) != 0 {
+		t.Errorf("stored settings = %v after a rejected request, want none", settings)
+	}
+	if !ruleEnabled(t, handler, services.RuleSpeedInstability) {
+		t.Error("valid rule in a rejected request was disabled")
+	}
+}
+
+func TestUpdateAnomalyRulesStoreFailureChangesNothing(t *testing.T) {
+	handler, store := newTestHandler(t)
+	handler.db = failingRulesStore{store}
+
+	body := `{"rules": {"speed_instability": false, "repeated_faults": false}}`
+	expectStatus(t, request(handler.UpdateAnomalyRules, "PUT", "/anomaly/rules", "/anomaly/rules", body), http.StatusInternalServerError)
+
+	if !ruleEnabled(t, handler, services.RuleSpeedInstability) || !ruleEnabled(t, handler, services.RuleRepeatedFaults) {
+		t.Error("detector rules changed although persisting them failed")
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"backend/models"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiSchema describes the public models as JSON Schema, generated once from the structs
+var apiSchema = gin.H{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"definitions": gin.H{
+		"SensorEvent":       schemaFor(reflect.TypeOf(models.SensorEvent{})),
+		"Event":             schemaFor(reflect.TypeOf(models.Event{})),
+		"Alert":             schemaFor(reflect.TypeOf(models.Alert{})),
+		"EventStats":        schemaFor(reflect.TypeOf(models.EventStats{})),
+		"AnomalyThresholds": schemaFor(reflect.TypeOf(models.AnomalyThresholds{})),
+	},
+}
+
+// GetSchema returns JSON Schema definitions for the API models
+func (h *Handler) GetSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, apiSchema)
+}
+
+// schemaFor builds a JSON Schema fragment for a Go type. Struct fields follow their json
+// tags: fields without omitempty are required, and pointer fields are nullable.
+func schemaFor(t reflect.Type) gin.H {
+	if t.Kind() == reflect.Pointer {
+		schema := schemaFor(t.Elem())
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []string{typ, "null"}
+		}
+		return schema
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return gin.H{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		// interface{} and other dynamic values accept anything
+		return gin.H{}
+	}
+}
+
+// structSchema builds an object schema from a struct's exported, JSON-visible fields
+func structSchema(t reflect.Type) gin.H {
+	properties := gin.H{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return gin.H{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
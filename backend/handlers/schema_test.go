@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// validateSchema checks value, as decoded from JSON, against the subset of JSON Schema
+// that schemaFor generates
+func validateSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if types, ok := schema["type"]; ok {
+		var allowed []interface{}
+		if list, ok := types.([]interface{}); ok {
+			allowed = list
+		} else {
+			allowed = []interface{}{types}
+		}
+
+		matched := false
+		for _, typ := range allowed {
+			if jsonTypeMatches(typ.(string), value) {
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %v is not of type %v", path, value, types)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required %s", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for name, field := range value {
+			fieldSchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				fieldSchema = additional
+			}
+			if fieldSchema == nil {
+				return fmt.Errorf("%s: unexpected property %s", path, name)
+			}
+			if err := validateSchema(fieldSchema, field, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range value {
+			if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonTypeMatches reports whether a decoded JSON value is of a JSON Schema type
+func jsonTypeMatches(typ string, value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || (typ == "integer" && value == math.Trunc(value))
+	case string:
+		return typ == "string"
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+	return false
+}
+
+func TestSampleEventValidatesAgainstSchema(t *testing.T) {
+	handler, _ := newTestHandler(t)
+	recorder := request(handler.GetSchema, "GET", "/schema", "/schema", "")
+	expectStatus(t, recorder, http.StatusOK)
+
+	var body struct {
+		Definitions map[string]map[string]interface{} `json:"definitions"`
+	}
+	decode(t, recorder, &body)
+	schema := body.Definitions["SensorEvent"]
+	if schema == nil {
+		t.Fatalf("schema = %s, want a SensorEvent definition", recorder.Body.String())
+	}
+
+	encoded, err := json.Marshal(models.SensorEvent{
+		Timestamp:      time.Now(),
+		MachineID:      "conveyor_001",
+		ConveyorSpeed:  float(1.5),
+		Temperature:    float(72),
+		Status:         "ok",
+		EventType:      "conveyor",
+		AdditionalData: models.AdditionalData{"vibration_level": 0.2},
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var sample map[string]interface{}
+	if err := json.Unmarshal(encoded, &sample); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if err := validateSchema(schema, sample, "event"); err != nil {
+		t.Errorf("sample event %s does not match the schema: %v", encoded, err)
+	}
+
+	delete(sample, "machine_id")
+	if err := validateSchema(schema, sample, "event"); err == nil {
+		t.Error("event without machine_id matched the schema")
+	}
+	sample["machine_id"] = 17
+	if err := validateSchema(schema, sample, "event"); err == nil {
+		t.Error("event with a numeric machine_id matched the schema")
+	}
+}
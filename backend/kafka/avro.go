@@ -0,0 +1,414 @@
+package kafka
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// confluentMagicByte prefixes messages framed by schema registry serializers
+const confluentMagicByte = 0
+
+// AvroDecoder decodes Avro messages in the schema registry wire format: a zero magic
+// byte and a 4-byte big-endian schema ID followed by the Avro binary body. Writer
+// schemas are fetched from the registry on first use and cached by ID.
+type AvroDecoder struct {
+	registryURL string
+	httpClient  *http.Client
+	schemas     map[uint32]*avroSchema
+	mutex       sync.RWMutex
+}
+
+// NewAvroDecoder creates a decoder that resolves schemas from the given registry
+func NewAvroDecoder(registryURL string) *AvroDecoder {
+	return &AvroDecoder{
+		registryURL: strings.TrimSuffix(registryURL, "/"),
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		schemas:     make(map[uint32]*avroSchema),
+	}
+}
+
+// Decode decodes an Avro record into event. Record fields are matched against the
+// event's JSON field names; timestamp-millis and timestamp-micros longs become times.
+func (d *AvroDecoder) Decode(data []byte, event *models.SensorEvent) error {
+	if len(data) < 5 || data[0] != confluentMagicByte {
+		return fmt.Errorf("message is not in schema registry wire format")
+	}
+
+	schemaID := binary.BigEndian.Uint32(data[1:5])
+	schema, err := d.schema(schemaID)
+	if err != nil {
+		return err
+	}
+
+	value, err := schema.decode(&avroReader{buf: data[5:]})
+	if err != nil {
+		return fmt.Errorf("failed to decode avro message with schema %d: %v", schemaID, err)
+	}
+
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("avro schema %d does not describe a record", schemaID)
+	}
+
+	// Round-trip through JSON so field mapping follows the SensorEvent json tags
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, event)
+}
+
+// schema returns the parsed writer schema for id, fetching it from the registry if needed
+func (d *AvroDecoder) schema(id uint32) (*avroSchema, error) {
+	d.mutex.RLock()
+	schema, exists := d.schemas[id]
+	d.mutex.RUnlock()
+	if exists {
+		return schema, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schemas/ids/%d", d.registryURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %d: %v", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("schema registry returned %s for schema %d", resp.Status, id)
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid schema registry response for schema %d: %v", id, err)
+	}
+
+	schema, err = parseAvroSchema(json.RawMessage(body.Schema), "", make(map[string]*avroSchema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema %d: %v", id, err)
+	}
+
+	d.mutex.Lock()
+	d.schemas[id] = schema
+	d.mutex.Unlock()
+
+	return schema, nil
+}
+
+// avroSchema is a parsed Avro schema node
+type avroSchema struct {
+	kind     string        // Primitive name, or record, enum, fixed, array, map or union
+	logical  string        // Logical type annotation, e.g. timestamp-millis
+	fields   []avroField   // Record fields, in encoding order
+	items    *avroSchema   // Array element schema
+	values   *avroSchema   // Map value schema
+	branches []*avroSchema // Union branches
+	symbols  []string      // Enum symbols
+	size     int           // Fixed length in bytes
+}
+
+// avroField is a single named field of a record schema
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+// avroPrimitives lists the Avro primitive type names
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses a schema definition. named collects record, enum and fixed
+// definitions so later references by name resolve to them.
+func parseAvroSchema(raw json.RawMessage, namespace string, named map[string]*avroSchema) (*avroSchema, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty schema")
+	}
+
+	switch raw[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return nil, err
+		}
+		if avroPrimitives[name] {
+			return &avroSchema{kind: name}, nil
+		}
+		if schema, exists := named[qualifiedName(name, namespace)]; exists {
+			return schema, nil
+		}
+		if schema, exists := named[name]; exists {
+			return schema, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", name)
+
+	case '[':
+		var branches []json.RawMessage
+		if err := json.Unmarshal(raw, &branches); err != nil {
+			return nil, err
+		}
+		union := &avroSchema{kind: "union"}
+		for _, branch := range branches {
+			schema, err := parseAvroSchema(branch, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, schema)
+		}
+		return union, nil
+
+	case '{':
+		var def struct {
+			Type        json.RawMessage `json:"type"`
+			Name        string          `json:"name"`
+			Namespace   string          `json:"namespace"`
+			LogicalType string          `json:"logicalType"`
+			Fields      []struct {
+				Name string          `json:"name"`
+				Type json.RawMessage `json:"type"`
+			} `json:"fields"`
+			Items   json.RawMessage `json:"items"`
+			Values  json.RawMessage `json:"values"`
+			Symbols []string        `json:"symbols"`
+			Size    int             `json:"size"`
+		}
+		if err := json.Unmarshal(raw, &def); err != nil {
+			return nil, err
+		}
+
+		var kind string
+		if err := json.Unmarshal(def.Type, &kind); err != nil {
+			// The type is itself a nested schema definition
+			return parseAvroSchema(def.Type, namespace, named)
+		}
+
+		if def.Namespace != "" {
+			namespace = def.Namespace
+		}
+
+		switch kind {
+		case "record", "error":
+			record := &avroSchema{kind: "record"}
+			// Register before parsing fields so recursive references resolve
+			named[qualifiedName(def.Name, namespace)] = record
+			for _, field := range def.Fields {
+				schema, err := parseAvroSchema(field.Type, namespace, named)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %v", field.Name, err)
+				}
+				record.fields = append(record.fields, avroField{name: field.Name, schema: schema})
+			}
+			return record, nil
+		case "enum":
+			enum := &avroSchema{kind: "enum", symbols: def.Symbols}
+			named[qualifiedName(def.Name, namespace)] = enum
+			return enum, nil
+		case "fixed":
+			fixed := &avroSchema{kind: "fixed", size: def.Size}
+			named[qualifiedName(def.Name, namespace)] = fixed
+			return fixed, nil
+		case "array":
+			items, err := parseAvroSchema(def.Items, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			return &avroSchema{kind: "array", items: items}, nil
+		case "map":
+			values, err := parseAvroSchema(def.Values, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			return &avroSchema{kind: "map", values: values}, nil
+		default:
+			if !avroPrimitives[kind] {
+				return parseAvroSchema(def.Type, namespace, named)
+			}
+			return &avroSchema{kind: kind, logical: def.LogicalType}, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unexpected schema definition: %s", raw)
+	}
+}
+
+// qualifiedName returns the full name of a named type within namespace
+func qualifiedName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// decode reads a value of this schema from r
+func (s *avroSchema) decode(r *avroReader) (interface{}, error) {
+	switch s.kind {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		switch s.logical {
+		case "timestamp-millis":
+			return time.UnixMilli(v).UTC(), nil
+		case "timestamp-micros":
+			return time.UnixMicro(v).UTC(), nil
+		}
+		return v, nil
+	case "float":
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case "double":
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		return r.bytes()
+	case "string":
+		b, err := r.bytes()
+		return string(b), err
+	case "fixed":
+		return r.next(s.size)
+	case "enum":
+		index, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(s.symbols)) {
+			return nil, fmt.Errorf("enum index %d out of range", index)
+		}
+		return s.symbols[index], nil
+	case "union":
+		index, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(s.branches)) {
+			return nil, fmt.Errorf("union index %d out of range", index)
+		}
+		return s.branches[index].decode(r)
+	case "array":
+		items := []interface{}{}
+		err := r.blocks(func() error {
+			item, err := s.items.decode(r)
+			items = append(items, item)
+			return err
+		})
+		return items, err
+	case "map":
+		values := make(map[string]interface{})
+		err := r.blocks(func() error {
+			key, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			values[string(key)], err = s.values.decode(r)
+			return err
+		})
+		return values, err
+	case "record":
+		record := make(map[string]interface{}, len(s.fields))
+		for _, field := range s.fields {
+			value, err := field.schema.decode(r)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.name, err)
+			}
+			record[field.name] = value
+		}
+		return record, nil
+	default:
+		return nil, fmt.Errorf("unsupported avro type %q", s.kind)
+	}
+}
+
+// avroReader consumes Avro binary encoded data
+type avroReader struct {
+	buf []byte
+}
+
+// next returns the next n bytes
+func (r *avroReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b, nil
+}
+
+// long reads a zig-zag varint, the encoding Avro uses for int and long
+func (r *avroReader) long() (int64, error) {
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid varint")
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+// bytes reads a length-prefixed byte sequence
+func (r *avroReader) bytes() ([]byte, error) {
+	n, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(len(r.buf)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return r.next(int(n))
+}
+
+// blocks reads the blocks of an array or map, calling item once per element
+func (r *avroReader) blocks(item func() error) error {
+	for {
+		count, err := r.long()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// A negative count is followed by the block size in bytes, which is not needed here
+			count = -count
+			if _, err := r.long(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/services"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// initialConnectBackoff is the wait after the first failed connection attempt
+const initialConnectBackoff = time.Second
+
+// Connector establishes the Kafka consumer, retrying with exponential backoff while the
+// brokers are unreachable, so the rest of the server can run without Kafka meanwhile
+type Connector struct {
+	cfg        config.KafkaConfig
+	validator  *services.EventValidator
+	ctx        context.Context
+	cancel     context.CancelFunc
+	consumer   *Consumer
+	stopped    bool
+	mutex      sync.Mutex
+	maxBackoff time.Duration
+}
+
+// NewConnector creates a connector; call Connect to establish the consumer
+func NewConnector(cfg config.KafkaConfig, validator *services.EventValidator) *Connector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Connector{
+		cfg:        cfg,
+		validator:  validator,
+		ctx:        ctx,
+		cancel:     cancel,
+		maxBackoff: max(cfg.ConnectMaxBackoff, initialConnectBackoff),
+	}
+}
+
+// Connect blocks until a consumer is created, retrying failed attempts with backoff.
+// It returns nil if the connector is stopped first.
+func (c *Connector) Connect() *Consumer {
+	backoff := initialConnectBackoff
+	for {
+		consumer, err := NewConsumer(c.cfg, c.validator)
+		if err == nil {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			if c.stopped {
+				consumer.Stop()
+				return nil
+			}
+			c.consumer = consumer
+			return consumer
+		}
+
+		log.Printf("Kafka unavailable, retrying in %s: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return nil
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+}
+
+// Consumer returns the connected consumer, or nil while still connecting
+func (c *Connector) Consumer() *Consumer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.consumer
+}
+
+// Stop abandons any pending connection attempt and stops the consumer if connected
+func (c *Connector) Stop() error {
+	c.cancel()
+
+	c.mutex.Lock()
+	c.stopped = true
+	consumer := c.consumer
+	c.mutex.Unlock()
+
+	if consumer == nil {
+		return nil
+	}
+	return consumer.Stop()
+}
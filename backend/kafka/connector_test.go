@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"backend/services"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// freeAddr returns a local address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestConnectorConnectsOnceBrokerAppears(t *testing.T) {
+	cfg := loadConfig(t)
+	cfg.Kafka.Brokers = freeAddr(t)
+	cfg.Kafka.ConnectMaxBackoff = time.Second
+	connector := NewConnector(cfg.Kafka, services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature))
+	t.Cleanup(func() { connector.Stop() })
+
+	connected := make(chan *Consumer, 1)
+	go func() { connected <- connector.Connect() }()
+
+	time.Sleep(1500 * time.Millisecond)
+	if connector.Consumer() != nil {
+		t.Fatal("consumer connected with no broker listening")
+	}
+
+	broker := sarama.NewMockBrokerAddr(t, 1, cfg.Kafka.Brokers)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetController(broker.BrokerID()),
+	})
+
+	select {
+	case consumer := <-connected:
+		if consumer == nil || connector.Consumer() != consumer {
+			t.Errorf("Connect returned %v, want the connector's consumer", consumer)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("consumer did not connect after the broker appeared")
+	}
+}
+
+func TestConnectorStopAbandonsPendingConnect(t *testing.T) {
+	cfg := loadConfig(t)
+	cfg.Kafka.Brokers = freeAddr(t)
+	connector := NewConnector(cfg.Kafka, services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature))
+
+	connected := make(chan *Consumer, 1)
+	go func() { connected <- connector.Connect() }()
+	time.Sleep(100 * time.Millisecond)
+	if err := connector.Stop(); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+
+	select {
+	case consumer := <-connected:
+		if consumer != nil {
+			t.Error("Connect returned a consumer after Stop")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Connect still retrying after Stop")
+	}
+}
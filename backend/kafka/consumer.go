@@ -1,12 +1,16 @@
 package kafka
 
 import (
+	"backend/config"
 	"backend/models"
+	"backend/services"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -14,69 +18,198 @@ import (
 
 // Consumer handles Kafka message consumption
 type Consumer struct {
+	client        sarama.Client
+	admin         sarama.ClusterAdmin
 	consumerGroup sarama.ConsumerGroup
-	eventChannel  chan *models.SensorEvent
+	groupID       string
+	topics        []string
+	consumed      atomic.Pointer[[]string] // Topics consumed since Start
+	offsets       OffsetSource             // Offsets consumer lag is computed from
+	sensors       *sensorHandler           // Handles topics without a registered handler
+	handlers      map[string]TopicHandler  // Registered per-topic handlers
+	eventChannel  chan *Delivery
 	errorChannel  chan error
+	errors        *errorAggregator // Coalesces errors before they reach errorChannel
+	routing       *routingChecker  // Checks that each machine's events stay on one partition; nil when off
+	fatal         chan error       // Receives the violation that stops consumption under fail mode
+	throughput    *topicThroughput // Messages and bytes consumed per topic
 	stopChannel   chan bool
 	ctx           context.Context
 	cancel        context.CancelFunc
+	session       *sessionState
+	workers       sync.WaitGroup // Goroutines sending on eventChannel/errorChannel
+	stopOnce      sync.Once
+	stopErr       error
+}
+
+// sessionState tracks whether the consumer currently holds a consumer group session
+type sessionState struct {
+	active  atomic.Bool
+	endedAt atomic.Int64 // Unix nanoseconds when the last session ended; 0 if none has
 }
 
 // ConsumerGroupHandler implements sarama.ConsumerGroupHandler
 type ConsumerGroupHandler struct {
-	eventChannel chan *models.SensorEvent
-	errorChannel chan error
+	session      *sessionState
+	eventChannel chan *Delivery
+	errors       *errorAggregator
+	sensors      *sensorHandler
+	handlers     map[string]TopicHandler
+	routing      *routingChecker
+	fatal        chan error
+	throughput   *topicThroughput
 }
 
 // NewConsumer creates a new Kafka consumer
-func NewConsumer(brokers, groupID string, topics []string) (*Consumer, error) {
-	config := sarama.NewConfig()
-	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-	config.Consumer.Group.Session.Timeout = 20 * time.Second
-	config.Consumer.Group.Heartbeat.Interval = 3 * time.Second
-	config.Version = sarama.V2_6_0_0
-
-	brokerList := strings.Split(brokers, ",")
-	consumerGroup, err := sarama.NewConsumerGroup(brokerList, groupID, config)
+func NewConsumer(cfg config.KafkaConfig, validator *services.EventValidator) (*Consumer, error) {
+	decoder, err := NewDecoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	topicDecoders, err := newTopicDecoders(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	brokerList := strings.Split(cfg.Brokers, ",")
+	client, err := sarama.NewClient(brokerList, newConsumerConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %v", err)
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(cfg.GroupID, client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create consumer group: %v", err)
 	}
 
+	// The admin shares the client and closes it when the admin is closed
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		consumerGroup.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to create cluster admin: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
+	errorChannel := make(chan error, 10)
 
 	return &Consumer{
+		client:        client,
+		admin:         admin,
 		consumerGroup: consumerGroup,
-		eventChannel:  make(chan *models.SensorEvent, 100),
-		errorChannel:  make(chan error, 10),
-		stopChannel:   make(chan bool, 1),
-		ctx:           ctx,
-		cancel:        cancel,
+		groupID:       cfg.GroupID,
+		topics:        cfg.Topics,
+		offsets:       clusterOffsets{client: client, admin: admin},
+		sensors: &sensorHandler{
+			decoder:       decoder,
+			topicDecoders: topicDecoders,
+			topicLines:    cfg.TopicLines,
+			validator:     validator,
+		},
+		handlers:     make(map[string]TopicHandler),
+		eventChannel: make(chan *Delivery, 100),
+		errorChannel: errorChannel,
+		errors:       newErrorAggregator(errorChannel, cfg.ErrorWindow),
+		routing:      newRoutingChecker(cfg.PartitionCheck, validator.NormalizeMachineID),
+		fatal:        make(chan error, 1),
+		throughput:   newTopicThroughput(),
+		stopChannel:  make(chan bool, 1),
+		session:      &sessionState{},
+		ctx:          ctx,
+		cancel:       cancel,
 	}, nil
 }
 
-// EventChannel returns the channel for receiving sensor events
-func (c *Consumer) EventChannel() <-chan *models.SensorEvent {
+// EventChannel returns the channel for receiving sensor events. Each delivery must be
+// completed with Done once the event has been stored or has failed.
+func (c *Consumer) EventChannel() <-chan *Delivery {
 	return c.eventChannel
 }
 
-// ErrorChannel returns the channel for receiving errors
+// ErrorChannel returns the channel for receiving errors. Identical errors are coalesced
+// over the configured window into one *AggregatedError carrying their count.
 func (c *Consumer) ErrorChannel() <-chan error {
 	return c.errorChannel
 }
 
-// Start begins consuming messages
+// Fatal returns the channel receiving the error that stops consumption for good: a
+// partition routing violation when the check is in fail mode
+func (c *Consumer) Fatal() <-chan error {
+	return c.fatal
+}
+
+// Throughput returns the messages and bytes consumed from each topic since startup,
+// ordered by topic
+func (c *Consumer) Throughput() []models.TopicThroughput {
+	return c.throughput.snapshot()
+}
+
+// Handle registers the handler for a topic's messages, which are otherwise decoded as
+// sensor events. The topic is consumed even if it is not passed to Start. Handle must
+// be called before Start.
+func (c *Consumer) Handle(topic string, handler TopicHandler) {
+	c.handlers[topic] = handler
+}
+
+// rebalanceStrategies maps the configured rebalance strategy names to sarama's strategies
+var rebalanceStrategies = map[string]sarama.BalanceStrategy{
+	config.RebalanceRange:      sarama.BalanceStrategyRange,
+	config.RebalanceRoundRobin: sarama.BalanceStrategyRoundRobin,
+	config.RebalanceSticky:     sarama.BalanceStrategySticky,
+}
+
+// newConsumerConfig builds the sarama configuration of the consumer group client
+func newConsumerConfig(cfg config.KafkaConfig) *sarama.Config {
+	saramaConfig := sarama.NewConfig()
+	if strategy, ok := rebalanceStrategies[cfg.RebalanceStrategy]; ok {
+		saramaConfig.Consumer.Group.Rebalance.Strategy = strategy
+	} else {
+		saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	}
+	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	saramaConfig.Consumer.Group.Session.Timeout = cfg.SessionTimeout
+	saramaConfig.Consumer.Group.Heartbeat.Interval = cfg.HeartbeatInterval
+	saramaConfig.Version = sarama.V2_6_0_0
+	return saramaConfig
+}
+
+// subscribe records and returns the topics consumed: topics followed by every topic
+// with a registered handler
+func (c *Consumer) subscribe(topics []string) []string {
+	topics = append([]string(nil), topics...)
+	for topic := range c.handlers {
+		if !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	c.consumed.Store(&topics)
+	return topics
+}
+
+// Start begins consuming messages from topics and from every topic with a handler
 func (c *Consumer) Start(topics []string) {
 	log.Println("Starting Kafka consumer...")
 
+	topics = c.subscribe(topics)
+
 	handler := &ConsumerGroupHandler{
+		session:      c.session,
 		eventChannel: c.eventChannel,
-		errorChannel: c.errorChannel,
+		errors:       c.errors,
+		sensors:      c.sensors,
+		handlers:     c.handlers,
+		routing:      c.routing,
+		fatal:        c.fatal,
+		throughput:   c.throughput,
 	}
+	c.logPartitions(topics)
+
+	go c.errors.run()
 
+	c.workers.Add(2)
 	go func() {
-		defer close(c.eventChannel)
-		defer close(c.errorChannel)
+		defer c.workers.Done()
 
 		for {
 			select {
@@ -89,62 +222,163 @@ func (c *Consumer) Start(topics []string) {
 			default:
 				err := c.consumerGroup.Consume(c.ctx, topics, handler)
 				if err != nil {
-					select {
-					case c.errorChannel <- fmt.Errorf("consumer group error: %v", err):
-					default:
-						log.Printf("Error channel full, dropping error: %v", err)
-					}
+					c.errors.report(fmt.Errorf("consumer group error: %v", err))
 					continue
 				}
 			}
 		}
 	}()
 
-	// Handle consumer group errors in a separate goroutine
+	// Handle consumer group errors in a separate goroutine; Errors() is closed when the
+	// consumer group is closed
 	go func() {
+		defer c.workers.Done()
+
 		for err := range c.consumerGroup.Errors() {
-			select {
-			case c.errorChannel <- fmt.Errorf("consumer group error: %v", err):
-			default:
-				log.Printf("Error channel full, dropping error: %v", err)
-			}
+			c.errors.report(fmt.Errorf("consumer group error: %v", err))
 		}
 	}()
+
+	// The channels have a single owner: they are closed once, after every sender has exited
+	go func() {
+		c.workers.Wait()
+		close(c.eventChannel)
+		c.errors.close()
+		close(c.errorChannel)
+	}()
+}
+
+// logPartitions logs each topic's partition count at startup, since keyed routing maps
+// machines to partitions by that count
+func (c *Consumer) logPartitions(topics []string) {
+	for _, topic := range topics {
+		partitions, err := c.client.Partitions(topic)
+		if err != nil {
+			log.Printf("Could not list partitions of topic %s: %v", topic, err)
+			continue
+		}
+		log.Printf("Topic %s has %d partitions; each machine's events must be keyed to one of them", topic, len(partitions))
+	}
 }
 
-// Stop gracefully stops the consumer
+// Stop gracefully stops the consumer. The event and error channels are closed once the
+// consumer goroutines have exited. Calling Stop more than once is safe.
 func (c *Consumer) Stop() error {
-	log.Println("Stopping Kafka consumer...")
+	c.stopOnce.Do(func() {
+		log.Println("Stopping Kafka consumer...")
+
+		select {
+		case c.stopChannel <- true:
+		default:
+		}
+
+		c.cancel()
+		if err := c.consumerGroup.Close(); err != nil {
+			c.admin.Close()
+			c.stopErr = err
+			return
+		}
+		c.stopErr = c.admin.Close()
+	})
+	return c.stopErr
+}
 
-	select {
-	case c.stopChannel <- true:
-	default:
+// Ready reports whether the consumer is connected: it must hold a consumer group session,
+// or have lost its last one no more than staleAfter ago (e.g. during a rebalance).
+func (c *Consumer) Ready(staleAfter time.Duration) error {
+	if c.ctx.Err() != nil {
+		return fmt.Errorf("consumer stopped")
 	}
+	if c.session.active.Load() {
+		return nil
+	}
+
+	endedAt := c.session.endedAt.Load()
+	if endedAt == 0 {
+		return fmt.Errorf("no consumer group session established yet")
+	}
+	if since := time.Since(time.Unix(0, endedAt)); since > staleAfter {
+		return fmt.Errorf("no consumer group session for %s", since.Round(time.Second))
+	}
+	return nil
+}
 
-	c.cancel()
-	return c.consumerGroup.Close()
+// Lag returns the consumer group lag for every partition of the consumed topics: those
+// passed to Start and those with a registered handler. Before Start it covers the
+// configured topics.
+func (c *Consumer) Lag() ([]models.ConsumerLag, error) {
+	topics := c.topics
+	if consumed := c.consumed.Load(); consumed != nil {
+		topics = *consumed
+	}
+	return consumerLag(c.offsets, c.groupID, topics)
 }
 
 // Setup is run at the beginning of a new session, before ConsumeClaim
-func (h *ConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+func (h *ConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.session.active.Store(true)
+	log.Printf("Consumer group session started (generation %d, member %s), assigned partitions: %v",
+		session.GenerationID(), session.MemberID(), session.Claims())
 	return nil
 }
 
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
 func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	h.session.endedAt.Store(time.Now().UnixNano())
+	h.session.active.Store(false)
 	return nil
 }
 
-// ConsumeClaim starts a consumer loop of ConsumerGroupClaim's Messages()
+// ConsumeClaim starts a consumer loop of ConsumerGroupClaim's Messages(). Offsets are
+// marked in order, and only once every earlier event in the partition has been
+// processed successfully. When an event fails to persist the claim returns, which ends
+// the session; consumption resumes from the last marked offset, redelivering the event
+// along with the rest of its batch, if any.
 func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var pending []*Delivery // Deliveries in flight, in offset order
+
 	for {
+		// Wait on the oldest in-flight delivery only; later ones are marked after it
+		var oldest chan error
+		if len(pending) > 0 {
+			oldest = pending[0].result
+		}
+
 		select {
 		case message := <-claim.Messages():
 			if message == nil {
 				return nil
 			}
-			h.processMessage(message)
-			session.MarkMessage(message, "")
+
+			deliveries := h.processMessage(message)
+			if len(deliveries) == 0 {
+				// Handled in place, or undecodable or invalid and never going to succeed;
+				// skip past them
+				if len(pending) == 0 {
+					session.MarkMessage(message, "")
+				} else {
+					pending = append(pending, skipped(message.Offset))
+				}
+				continue
+			}
+
+			for _, delivery := range deliveries {
+				select {
+				case h.eventChannel <- delivery:
+					pending = append(pending, delivery)
+				case <-session.Context().Done():
+					return nil
+				}
+			}
+
+		case err := <-oldest:
+			delivery := pending[0]
+			pending = pending[1:]
+			if err != nil {
+				return fmt.Errorf("event at %s [%d] offset %d not stored, will be redelivered: %v",
+					claim.Topic(), claim.Partition(), delivery.offset, err)
+			}
+			session.MarkOffset(claim.Topic(), claim.Partition(), delivery.next, "")
 
 		case <-session.Context().Done():
 			return nil
@@ -152,79 +386,57 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	}
 }
 
-// processMessage processes an incoming Kafka message
-func (h *ConsumerGroupHandler) processMessage(msg *sarama.ConsumerMessage) {
+// skipped returns an already completed delivery for a message that is not processed, so
+// its offset is marked in turn
+func skipped(offset int64) *Delivery {
+	delivery := newDelivery(nil, offset)
+	delivery.Done(nil)
+	return delivery
+}
+
+// processMessage routes an incoming Kafka message to its topic's handler, by default
+// the sensor event handler. It returns the deliveries of the message's events, if any.
+func (h *ConsumerGroupHandler) processMessage(msg *sarama.ConsumerMessage) []*Delivery {
 	log.Printf("Received message from topic %s [%d] at offset %v",
 		msg.Topic, msg.Partition, msg.Offset)
+	h.throughput.record(msg)
 
-	// Parse the sensor event
-	var event models.SensorEvent
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		select {
-		case h.errorChannel <- fmt.Errorf("failed to unmarshal message: %v", err):
-		default:
-			log.Printf("Error channel full, dropping unmarshal error: %v", err)
-		}
-		return
+	handler, ok := h.handlers[msg.Topic]
+	if !ok {
+		handler = h.sensors
 	}
 
-	// Validate the event
-	if err := validateEvent(&event); err != nil {
-		select {
-		case h.errorChannel <- fmt.Errorf("invalid event: %v", err):
-		default:
-			log.Printf("Error channel full, dropping validation error: %v", err)
-		}
-		return
+	deliveries, err := handler.Handle(msg)
+	if err != nil {
+		h.errors.report(err)
+		return nil
 	}
 
-	// Send event to processing channel
-	select {
-	case h.eventChannel <- &event:
-		log.Printf("Event processed successfully: machine=%s, status=%s, type=%s",
+	for _, delivery := range deliveries {
+		event := delivery.Event
+		log.Printf("Event decoded successfully: machine=%s, status=%s, type=%s",
 			event.MachineID, event.Status, event.EventType)
-	default:
-		log.Printf("Event channel full, dropping event from machine %s", event.MachineID)
+		h.checkRouting(msg, event)
 	}
+	return deliveries
 }
 
-// validateEvent validates a sensor event
-func validateEvent(event *models.SensorEvent) error {
-	if event.MachineID == "" {
-		return fmt.Errorf("machine_id is required")
-	}
-
-	if event.Status == "" {
-		return fmt.Errorf("status is required")
-	}
-
-	if event.EventType == "" {
-		return fmt.Errorf("event_type is required")
-	}
-
-	// Validate status values
-	validStatuses := map[string]bool{
-		"ok":      true,
-		"warning": true,
-		"fault":   true,
-	}
-
-	if !validStatuses[event.Status] {
-		return fmt.Errorf("invalid status: %s", event.Status)
-	}
-
-	// Validate sensor values are within reasonable bounds
-	if event.ConveyorSpeed < 0 || event.ConveyorSpeed > 10 {
-		return fmt.Errorf("conveyor speed out of range: %f", event.ConveyorSpeed)
+// checkRouting reports an event breaking keyed routing, and under fail mode hands the
+// violation to the Fatal channel. The event is still processed.
+func (h *ConsumerGroupHandler) checkRouting(msg *sarama.ConsumerMessage, event *models.SensorEvent) {
+	if h.routing == nil {
+		return
 	}
-
-	if event.Temperature < -50 || event.Temperature > 200 {
-		return fmt.Errorf("temperature out of range: %f", event.Temperature)
+	err := h.routing.check(msg, event)
+	if err == nil {
+		return
 	}
 
-	if event.RobotArmAngle < 0 || event.RobotArmAngle > 360 {
-		return fmt.Errorf("robot arm angle out of range: %f", event.RobotArmAngle)
+	h.errors.report(err)
+	if h.routing.fatal() {
+		select {
+		case h.fatal <- err:
+		default:
+		}
 	}
-
-	return nil
-}
\ No newline at end of file
+}
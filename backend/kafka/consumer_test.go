@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"backend/config"
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// fakeConsumerGroup is a consumer group whose sessions last until the context is
+// cancelled or the group is closed, reporting one error through Errors as it starts
+type fakeConsumerGroup struct {
+	errs      chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeConsumerGroup() *fakeConsumerGroup {
+	group := &fakeConsumerGroup{errs: make(chan error, 1), closed: make(chan struct{})}
+	group.errs <- errors.New("broker unreachable")
+	return group
+}
+
+func (g *fakeConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	}
+}
+
+func (g *fakeConsumerGroup) Errors() <-chan error { return g.errs }
+
+func (g *fakeConsumerGroup) Close() error {
+	g.closeOnce.Do(func() {
+		close(g.closed)
+		close(g.errs)
+	})
+	return nil
+}
+
+func (g *fakeConsumerGroup) Pause(map[string][]int32)  {}
+func (g *fakeConsumerGroup) Resume(map[string][]int32) {}
+func (g *fakeConsumerGroup) PauseAll()                 {}
+func (g *fakeConsumerGroup) ResumeAll()                {}
+
+// fakeClient reports a single partition for every topic
+type fakeClient struct {
+	sarama.Client
+}
+
+func (fakeClient) Partitions(string) ([]int32, error) { return []int32{0}, nil }
+
+// fakeAdmin is a cluster admin that only closes
+type fakeAdmin struct {
+	sarama.ClusterAdmin
+}
+
+func (fakeAdmin) Close() error { return nil }
+
+// newTestConsumer creates a consumer over a fake consumer group
+func newTestConsumer(t *testing.T) *Consumer {
+	t.Helper()
+	cfg := loadConfig(t)
+	handler, _ := newTestConsumerHandler(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	errorChannel := make(chan error, 10)
+
+	return &Consumer{
+		client:        fakeClient{},
+		admin:         fakeAdmin{},
+		consumerGroup: newFakeConsumerGroup(),
+		sensors:       handler.sensors,
+		handlers:      make(map[string]TopicHandler),
+		eventChannel:  make(chan *Delivery, 100),
+		errorChannel:  errorChannel,
+		errors:        newErrorAggregator(errorChannel, 10*time.Millisecond),
+		fatal:         make(chan error, 1),
+		throughput:    newTopicThroughput(),
+		stopChannel:   make(chan bool, 1),
+		session:       &sessionState{},
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// drained waits for a channel to be closed, discarding what is still queued on it
+func drained[T any](t *testing.T, name string, channel <-chan T) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-channel:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s not closed after Stop", name)
+		}
+	}
+}
+
+func TestConsumerStartStopRepeatedly(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 50; i++ {
+		consumer := newTestConsumer(t)
+		consumer.Start([]string{"sensor-events"})
+		if i%2 == 1 {
+			time.Sleep(time.Millisecond) // Let the session start before stopping
+		}
+
+		if err := consumer.Stop(); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		if err := consumer.Stop(); err != nil {
+			t.Fatalf("second Stop: %v", err)
+		}
+		drained(t, "event channel", consumer.EventChannel())
+		drained(t, "error channel", consumer.ErrorChannel())
+	}
+
+	// Goroutines exit shortly after the channels close
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines after stopping, %d before: consumer goroutines leaked", after, before)
+	}
+}
+
+func TestConsumerReadyTracksSession(t *testing.T) {
+	consumer := newTestConsumer(t)
+	handler := &ConsumerGroupHandler{session: consumer.session}
+
+	if err := consumer.Ready(time.Minute); err == nil {
+		t.Error("ready before a session was established")
+	}
+
+	consumer.session.active.Store(true)
+	if err := consumer.Ready(time.Minute); err != nil {
+		t.Errorf("not ready during a session: %v", err)
+	}
+
+	// A session lost in a rebalance is tolerated until it goes stale
+	handler.Cleanup(nil)
+	if err := consumer.Ready(time.Minute); err != nil {
+		t.Errorf("not ready just after a session ended: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := consumer.Ready(time.Millisecond); err == nil {
+		t.Error("ready with a stale session")
+	}
+
+	consumer.session.active.Store(true)
+	consumer.Stop()
+	if err := consumer.Ready(time.Minute); err == nil {
+		t.Error("ready after Stop")
+	}
+}
+
+func TestConsumerConfigReflectsRebalanceStrategyAndTimeouts(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		want     string
+	}{
+		{config.RebalanceRange, sarama.RangeBalanceStrategyName},
+		{config.RebalanceRoundRobin, sarama.RoundRobinBalanceStrategyName},
+		{config.RebalanceSticky, sarama.StickyBalanceStrategyName},
+		{"", sarama.RoundRobinBalanceStrategyName},
+	} {
+		saramaConfig := newConsumerConfig(config.KafkaConfig{RebalanceStrategy: tc.strategy, SessionTimeout: 45 * time.Second, HeartbeatInterval: 5 * time.Second})
+		if got := saramaConfig.Consumer.Group.Rebalance.Strategy.Name(); got != tc.want {
+			t.Errorf("strategy %q configured as %s, want %s", tc.strategy, got, tc.want)
+		}
+		if saramaConfig.Consumer.Group.Session.Timeout != 45*time.Second || saramaConfig.Consumer.Group.Heartbeat.Interval != 5*time.Second {
+			t.Errorf("session timeout = %s, heartbeat interval = %s, want 45s and 5s", saramaConfig.Consumer.Group.Session.Timeout, saramaConfig.Consumer.Group.Heartbeat.Interval)
+		}
+		if err := saramaConfig.Validate(); err != nil {
+			t.Errorf("strategy %q: Validate: %v", tc.strategy, err)
+		}
+	}
+}
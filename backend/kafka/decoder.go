@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Supported Kafka message encodings
+const (
+	FormatJSON = "json"
+	FormatAvro = "avro"
+)
+
+// Decoder turns a raw Kafka message value into a sensor event
+type Decoder interface {
+	Decode(data []byte, event *models.SensorEvent) error
+}
+
+// NewDecoder returns the decoder for the configured message format
+func NewDecoder(cfg config.KafkaConfig) (Decoder, error) {
+	return newDecoderForFormat(cfg.MessageFormat, cfg.SchemaRegistryURL)
+}
+
+// newTopicDecoders returns the decoders of topics whose format overrides the default
+func newTopicDecoders(cfg config.KafkaConfig) (map[string]Decoder, error) {
+	decoders := make(map[string]Decoder, len(cfg.TopicFormats))
+	for topic, format := range cfg.TopicFormats {
+		decoder, err := newDecoderForFormat(format, cfg.SchemaRegistryURL)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %v", topic, err)
+		}
+		decoders[topic] = decoder
+	}
+	return decoders, nil
+}
+
+// newDecoderForFormat returns the decoder for a message format
+func newDecoderForFormat(format, schemaRegistryURL string) (Decoder, error) {
+	switch format {
+	case "", FormatJSON:
+		return JSONDecoder{}, nil
+	case FormatAvro:
+		if schemaRegistryURL == "" {
+			return nil, fmt.Errorf("avro message format requires a schema registry URL")
+		}
+		return NewAvroDecoder(schemaRegistryURL), nil
+	default:
+		return nil, fmt.Errorf("unsupported message format: %s", format)
+	}
+}
+
+// BatchDecoder is implemented by decoders of formats that can carry several events in
+// one message, as edge gateways send to cut per-message overhead
+type BatchDecoder interface {
+	DecodeBatch(data []byte) ([]*models.SensorEvent, error)
+}
+
+// JSONDecoder decodes plain JSON-encoded events
+type JSONDecoder struct{}
+
+// Decode unmarshals a JSON message into event
+func (JSONDecoder) Decode(data []byte, event *models.SensorEvent) error {
+	return json.Unmarshal(data, event)
+}
+
+// DecodeBatch unmarshals a JSON message holding a single event, an array of events or
+// a batch envelope of the form {"events": [...]}
+func (d JSONDecoder) DecodeBatch(data []byte) ([]*models.SensorEvent, error) {
+	var events []*models.SensorEvent
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	var envelope struct {
+		Events json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(trimmed, &envelope); err == nil && len(envelope.Events) > 0 && envelope.Events[0] == '[' {
+		if err := json.Unmarshal(envelope.Events, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	var event models.SensorEvent
+	if err := d.Decode(trimmed, &event); err != nil {
+		return nil, err
+	}
+	return []*models.SensorEvent{&event}, nil
+}
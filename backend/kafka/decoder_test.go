@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"backend/models"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// sensorAvroSchema is a writer schema for sensor events with a nullable temperature
+const sensorAvroSchema = `{
+	"type": "record", "name": "SensorEvent", "namespace": "fleet",
+	"fields": [
+		{"name": "machine_id", "type": "string"},
+		{"name": "event_type", "type": "string"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["ok", "warning", "fault"]}},
+		{"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "temperature", "type": ["null", "double"]}
+	]
+}`
+
+// newTestRegistry serves sensorAvroSchema as schema 7, counting the lookups
+func newTestRegistry(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var lookups atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		if r.URL.Path != "/schemas/ids/7" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"schema": sensorAvroSchema})
+	}))
+	t.Cleanup(server.Close)
+	return server, &lookups
+}
+
+// avroMessage encodes a sensor event with sensorAvroSchema in the schema registry wire
+// format under schemaID
+func avroMessage(schemaID uint32, machineID string, timestamp time.Time, temperature *float64) []byte {
+	message := []byte{confluentMagicByte}
+	message = binary.BigEndian.AppendUint32(message, schemaID)
+
+	appendString := func(s string) {
+		message = binary.AppendVarint(message, int64(len(s)))
+		message = append(message, s...)
+	}
+	appendString(machineID)
+	appendString("conveyor")
+	message = binary.AppendVarint(message, 2) // fault
+	message = binary.AppendVarint(message, timestamp.UnixMilli())
+	if temperature == nil {
+		message = binary.AppendVarint(message, 0)
+	} else {
+		message = binary.AppendVarint(message, 1)
+		message = binary.LittleEndian.AppendUint64(message, math.Float64bits(*temperature))
+	}
+	return message
+}
+
+func TestJSONDecoderDecodesEvent(t *testing.T) {
+	var event models.SensorEvent
+	err := JSONDecoder{}.Decode([]byte(`{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "ok", "temperature": 71.5}`), &event)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if event.MachineID != "conveyor_001" || event.Temperature == nil || *event.Temperature != 71.5 {
+		t.Errorf("event = %+v, want conveyor_001 at 71.5", event)
+	}
+}
+
+func TestAvroDecoderDecodesWithRegistrySchema(t *testing.T) {
+	registry, lookups := newTestRegistry(t)
+	decoder := NewAvroDecoder(registry.URL + "/")
+	timestamp := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	temperature := 88.5
+
+	for _, tc := range []struct {
+		machineID   string
+		temperature *float64
+	}{
+		{"conveyor_001", &temperature},
+		{"conveyor_002", nil},
+	} {
+		var event models.SensorEvent
+		if err := decoder.Decode(avroMessage(7, tc.machineID, timestamp, tc.temperature), &event); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+
+		if event.MachineID != tc.machineID || event.EventType != "conveyor" || event.Status != "fault" || !event.Timestamp.Equal(timestamp) {
+			t.Errorf("event = %+v, want a conveyor fault from %s at %s", event, tc.machineID, timestamp)
+		}
+		if (event.Temperature == nil) != (tc.temperature == nil) || (event.Temperature != nil && *event.Temperature != *tc.temperature) {
+			t.Errorf("temperature = %v, want %v", event.Temperature, tc.temperature)
+		}
+	}
+
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("registry queried %d times, want the schema cached after the first", got)
+	}
+}
+
+func TestAvroDecodeFailuresReportedOnErrorChannel(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+	cfg := loadConfig(t)
+	cfg.Kafka.MessageFormat = FormatAvro
+	cfg.Kafka.SchemaRegistryURL = registry.URL
+	handler, errs := newTestConsumerHandler(t, cfg)
+
+	for _, value := range [][]byte{
+		avroMessage(9, "conveyor_001", time.Now(), nil),          // Unknown schema
+		avroMessage(7, "conveyor_001", time.Now(), nil)[:8],      // Truncated body
+		[]byte(`{"machine_id": "conveyor_001", "status": "ok"}`), // Not wire format
+	} {
+		if deliveries := handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.sensor", Value: value}); len(deliveries) != 0 {
+			t.Errorf("undecodable message %q delivered", value)
+		}
+		select {
+		case <-errs:
+		default:
+			t.Errorf("undecodable message %q not reported", value)
+		}
+	}
+
+	deliveries := handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.sensor", Value: avroMessage(7, "conveyor_001", time.Now(), nil)})
+	if len(deliveries) != 1 {
+		t.Errorf("valid avro message yielded %d deliveries, want 1", len(deliveries))
+	}
+}
@@ -0,0 +1,41 @@
+package kafka
+
+import "backend/models"
+
+// Delivery is a consumed event awaiting processing. Its offset is committed only once
+// Done reports success, so events that fail to persist are redelivered (at-least-once).
+type Delivery struct {
+	Event  *models.SensorEvent
+	offset int64
+	next   int64 // Offset committed once processed: past the message, or the message itself for all but the last event of a batch
+	result chan error
+}
+
+// newDelivery wraps an event decoded from the message at offset
+func newDelivery(event *models.SensorEvent, offset int64) *Delivery {
+	return &Delivery{
+		Event:  event,
+		offset: offset,
+		next:   offset + 1,
+		result: make(chan error, 1),
+	}
+}
+
+// newBatchDeliveries wraps the events of a batch message at offset. Only the last
+// delivery moves the committed offset past the message; as deliveries are committed in
+// order, the message is redelivered whole unless every event in it was processed.
+func newBatchDeliveries(events []*models.SensorEvent, offset int64) []*Delivery {
+	deliveries := make([]*Delivery, len(events))
+	for i, event := range events {
+		deliveries[i] = newDelivery(event, offset)
+		if i < len(events)-1 {
+			deliveries[i].next = offset
+		}
+	}
+	return deliveries
+}
+
+// Done reports the outcome of processing the event. It must be called exactly once.
+func (d *Delivery) Done(err error) {
+	d.result <- err
+}
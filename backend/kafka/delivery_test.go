@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// fakeSession records the offsets marked during a consumer group session
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	mutex  sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.marked = append(s.marked, offset)
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+// fakeClaim serves queued messages of a single partition
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *fakeClaim) Topic() string                            { return "sensor-events" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+
+func TestFailedStoreLeavesOffsetUnmarked(t *testing.T) {
+	handler, _ := newTestConsumerHandler(t, loadConfig(t))
+	handler.eventChannel = make(chan *Delivery, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+
+	for offset := int64(10); offset <= 11; offset++ {
+		message := eventMessage("sensor-events", time.Now(), "")
+		message.Offset = offset
+		claim.messages <- message
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- handler.ConsumeClaim(session, claim) }()
+
+	// The first event is stored; storing the second fails
+	for _, outcome := range []error{nil, errors.New("connection refused")} {
+		select {
+		case delivery := <-handler.eventChannel:
+			delivery.Done(outcome)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event delivered")
+		}
+	}
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Error("ConsumeClaim ended without error after a failed store, want the session restarted")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ConsumeClaim did not end after a failed store")
+	}
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	if len(session.marked) != 1 || session.marked[0] != 11 {
+		t.Errorf("marked offsets %v, want only 11 so the failed message at offset 11 is redelivered", session.marked)
+	}
+}
+
+func TestBatchOffsetMarkedOnlyAfterEveryEventStored(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		outcomes []error
+		wantPast bool
+	}{
+		{"all stored", []error{nil, nil, nil}, true},
+		{"last store fails", []error{nil, nil, errors.New("connection refused")}, false},
+	} {
+		handler, _ := newTestConsumerHandler(t, loadConfig(t))
+		handler.eventChannel = make(chan *Delivery, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		session := &fakeSession{ctx: ctx}
+		claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+
+		timestamp := time.Now().Add(-time.Second).Format(time.RFC3339)
+		event := fmt.Sprintf(`{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "ok", "timestamp": %q}`, timestamp)
+		claim.messages <- &sarama.ConsumerMessage{Topic: "sensor-events", Offset: 20, Value: []byte("[" + strings.Repeat(event+",", 2) + event + "]")}
+
+		result := make(chan error, 1)
+		go func() { result <- handler.ConsumeClaim(session, claim) }()
+
+		for _, outcome := range tc.outcomes {
+			select {
+			case delivery := <-handler.eventChannel:
+				delivery.Done(outcome)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: batch event not delivered", tc.name)
+			}
+		}
+		if tc.wantPast {
+			waitForMarked(t, session, 21)
+			close(claim.messages)
+		}
+
+		select {
+		case err := <-result:
+			if (err == nil) != tc.wantPast {
+				t.Errorf("%s: ConsumeClaim returned %v", tc.name, err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: ConsumeClaim did not end", tc.name)
+		}
+		cancel()
+
+		session.mutex.Lock()
+		if markedPast := slices.Contains(session.marked, 21); markedPast != tc.wantPast {
+			t.Errorf("%s: marked offsets %v, want the offset past the batch marked = %v", tc.name, session.marked, tc.wantPast)
+		}
+		session.mutex.Unlock()
+	}
+}
+
+// waitForMarked waits until the session has marked offset
+func waitForMarked(t *testing.T, session *fakeSession, offset int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		session.mutex.Lock()
+		marked := slices.Contains(session.marked, offset)
+		session.mutex.Unlock()
+		if marked {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("offset %d never marked", offset)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
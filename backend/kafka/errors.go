@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxDistinctErrors bounds the distinct messages aggregated per window; further distinct
+// errors are counted together so a flood of unique errors cannot grow memory
+const maxDistinctErrors = 100
+
+// overflowMessage is reported for the errors beyond maxDistinctErrors in a window
+const overflowMessage = "other consumer errors"
+
+// AggregatedError reports how often an identical error occurred within a window
+type AggregatedError struct {
+	Message string
+	Count   int
+	First   time.Time
+	Last    time.Time
+}
+
+// Error implements the error interface
+func (e *AggregatedError) Error() string {
+	if e.Count == 1 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (x%d between %s and %s)", e.Message, e.Count,
+		e.First.Format(time.TimeOnly), e.Last.Format(time.TimeOnly))
+}
+
+// errorAggregator coalesces identical errors over a window before surfacing them on the
+// error channel. Reporting never blocks and never drops: a bad batch of messages becomes
+// one counted error per window rather than flooding, or overflowing, the channel.
+type errorAggregator struct {
+	out     chan error
+	window  time.Duration
+	pending map[string]*AggregatedError
+	order   []string // Pending messages in order of first occurrence
+	mutex   sync.Mutex
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newErrorAggregator creates an aggregator surfacing errors on out every window.
+// A window of 0 surfaces each error as it is reported.
+func newErrorAggregator(out chan error, window time.Duration) *errorAggregator {
+	return &errorAggregator{
+		out:     out,
+		window:  window,
+		pending: make(map[string]*AggregatedError),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// report records an error occurrence
+func (a *errorAggregator) report(err error) {
+	now := time.Now()
+	if a.window <= 0 {
+		a.send(&AggregatedError{Message: err.Error(), Count: 1, First: now, Last: now})
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	message := err.Error()
+	aggregated, exists := a.pending[message]
+	if !exists && len(a.pending) >= maxDistinctErrors {
+		message = overflowMessage
+		aggregated, exists = a.pending[message]
+	}
+	if !exists {
+		aggregated = &AggregatedError{Message: message, First: now}
+		a.pending[message] = aggregated
+		a.order = append(a.order, message)
+	}
+	aggregated.Count++
+	aggregated.Last = now
+}
+
+// run surfaces the aggregated errors every window until close is called
+func (a *errorAggregator) run() {
+	defer close(a.stopped)
+	if a.window <= 0 {
+		<-a.stop
+		return
+	}
+
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case <-a.stop:
+			a.flush()
+			return
+		}
+	}
+}
+
+// close surfaces the errors still pending and stops run. Nothing may be reported after.
+func (a *errorAggregator) close() {
+	close(a.stop)
+	<-a.stopped
+}
+
+// flush surfaces the pending errors in order of first occurrence
+func (a *errorAggregator) flush() {
+	a.mutex.Lock()
+	pending, order := a.pending, a.order
+	a.pending, a.order = make(map[string]*AggregatedError), nil
+	a.mutex.Unlock()
+
+	for _, message := range order {
+		a.send(pending[message])
+	}
+}
+
+// send surfaces an error on the channel, logging it instead if the channel is full
+func (a *errorAggregator) send(err *AggregatedError) {
+	select {
+	case a.out <- err:
+	default:
+		log.Printf("Error channel full, logging error: %v", err)
+	}
+}
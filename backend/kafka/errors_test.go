@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// drainErrors returns the errors queued on errs
+func drainErrors(errs chan error) []*AggregatedError {
+	var reports []*AggregatedError
+	for {
+		select {
+		case err := <-errs:
+			reports = append(reports, err.(*AggregatedError))
+		default:
+			return reports
+		}
+	}
+}
+
+func TestRepeatedErrorsCoalesceIntoCountedReport(t *testing.T) {
+	errs := make(chan error, 10)
+	aggregator := newErrorAggregator(errs, time.Hour)
+	go aggregator.run()
+
+	for i := 0; i < 500; i++ {
+		aggregator.report(errors.New("failed to decode message: unexpected end of JSON input"))
+	}
+	aggregator.report(errors.New("failed to validate event: missing machine_id"))
+	aggregator.report(errors.New("failed to decode message: unexpected end of JSON input"))
+	if queued := len(errs); queued != 0 {
+		t.Fatalf("%d errors surfaced before the window ended, want none", queued)
+	}
+	aggregator.close()
+
+	reports := drainErrors(errs)
+	if len(reports) != 2 {
+		t.Fatalf("reports = %v, want one per distinct error", reports)
+	}
+	if reports[0].Message != "failed to decode message: unexpected end of JSON input" || reports[0].Count != 501 {
+		t.Errorf("first report = %+v, want the decode error counted 501 times", reports[0])
+	}
+	if reports[1].Message != "failed to validate event: missing machine_id" || reports[1].Count != 1 {
+		t.Errorf("second report = %+v, want the validation error counted once", reports[1])
+	}
+}
+
+func TestDistinctErrorsBeyondLimitCountedTogether(t *testing.T) {
+	errs := make(chan error, maxDistinctErrors+10)
+	aggregator := newErrorAggregator(errs, time.Hour)
+	go aggregator.run()
+
+	for i := 0; i < maxDistinctErrors+25; i++ {
+		aggregator.report(fmt.Errorf("unexpected error %d", i))
+	}
+	aggregator.close()
+
+	reports := drainErrors(errs)
+	if len(reports) != maxDistinctErrors+1 {
+		t.Fatalf("%d reports, want %d distinct errors and one overflow", len(reports), maxDistinctErrors)
+	}
+	if overflow := reports[len(reports)-1]; overflow.Message != overflowMessage || overflow.Count != 25 {
+		t.Errorf("overflow report = %+v, want the 25 errors beyond the limit", overflow)
+	}
+}
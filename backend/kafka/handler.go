@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"backend/models"
+	"backend/services"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// TopicHandler consumes the messages of a topic. It returns the deliveries for the
+// event processing pipeline, or none when the message was handled in place (or holds no
+// events the pipeline should see). A message whose handler fails is reported and skipped, so a
+// handler should only fail for messages that will never succeed. Handlers are called
+// concurrently for different partitions.
+type TopicHandler interface {
+	Handle(msg *sarama.ConsumerMessage) ([]*Delivery, error)
+}
+
+// HandlerFunc adapts a function to a TopicHandler for topics whose messages are not
+// sensor events, e.g. vision inspections or energy meter readings
+type HandlerFunc func(msg *sarama.ConsumerMessage) error
+
+// Handle calls f and hands nothing to the event pipeline
+func (f HandlerFunc) Handle(msg *sarama.ConsumerMessage) ([]*Delivery, error) {
+	return nil, f(msg)
+}
+
+// sensorHandler decodes sensor events, tags them with their production line and
+// validates them for the processing pipeline. Topics may use different encodings, and
+// decoders implementing BatchDecoder accept messages carrying several events.
+type sensorHandler struct {
+	decoder       Decoder            // Decoder for topics without their own format
+	topicDecoders map[string]Decoder // Per-topic decoders overriding decoder
+	topicLines    map[string]string
+	validator     *services.EventValidator
+}
+
+// Handle decodes and validates the sensor events of a message. Invalid events in a
+// batch are reported and dropped; the rest are still processed.
+func (h *sensorHandler) Handle(msg *sarama.ConsumerMessage) ([]*Delivery, error) {
+	events, err := h.decode(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message: %v", err)
+	}
+
+	valid := events[:0]
+	var invalid error
+	for i, event := range events {
+		if event == nil {
+			invalid = fmt.Errorf("invalid event at index %d: null", i)
+			continue
+		}
+
+		// Tag the event with the production line its topic belongs to
+		event.Line = h.lineForTopic(msg.Topic)
+
+		// Convert to storage units, then validate the event
+		h.validator.Normalize(event)
+		if err := h.validator.Validate(event); err != nil {
+			if len(events) == 1 {
+				invalid = fmt.Errorf("invalid event: %v", err)
+			} else {
+				invalid = fmt.Errorf("invalid event at index %d: %v", i, err)
+			}
+			continue
+		}
+		valid = append(valid, event)
+	}
+
+	if len(valid) == 0 {
+		if invalid == nil {
+			invalid = fmt.Errorf("empty event batch")
+		}
+		return nil, invalid
+	}
+	if invalid != nil {
+		log.Printf("Dropped %d of %d events in batch at %s [%d] offset %d, last error: %v",
+			len(events)-len(valid), len(events), msg.Topic, msg.Partition, msg.Offset, invalid)
+	}
+
+	return newBatchDeliveries(valid, msg.Offset), nil
+}
+
+// decode parses the events of a message with its topic's decoder
+func (h *sensorHandler) decode(msg *sarama.ConsumerMessage) ([]*models.SensorEvent, error) {
+	decoder, ok := h.topicDecoders[msg.Topic]
+	if !ok {
+		decoder = h.decoder
+	}
+
+	if batch, ok := decoder.(BatchDecoder); ok {
+		return batch.DecodeBatch(msg.Value)
+	}
+
+	var event models.SensorEvent
+	if err := decoder.Decode(msg.Value, &event); err != nil {
+		return nil, err
+	}
+	return []*models.SensorEvent{&event}, nil
+}
+
+// lineForTopic resolves the production line for a topic. Topics without an explicit
+// mapping use their first dot-separated segment, so "line2.sensor" maps to "line2".
+func (h *sensorHandler) lineForTopic(topic string) string {
+	if line, ok := h.topicLines[topic]; ok {
+		return line
+	}
+	line, _, _ := strings.Cut(topic, ".")
+	return line
+}
@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/services"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// newTestConsumerHandler creates a handler decoding JSON sensor events validated against
+// cfg, with consumer errors surfaced on the returned channel as they are reported
+func newTestConsumerHandler(t *testing.T, cfg *config.Config) (*ConsumerGroupHandler, chan error) {
+	t.Helper()
+	decoder, err := NewDecoder(cfg.Kafka)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	errs := make(chan error, 10)
+	validator := services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature)
+	return &ConsumerGroupHandler{
+		sensors:    &sensorHandler{decoder: decoder, validator: validator},
+		handlers:   map[string]TopicHandler{},
+		errors:     newErrorAggregator(errs, 0),
+		throughput: newTopicThroughput(),
+	}, errs
+}
+
+// loadConfig loads the configuration of an unset environment
+func loadConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	return cfg
+}
+
+// eventMessage builds a sensor event message on topic with the given extra JSON fields
+func eventMessage(topic string, timestamp time.Time, fields string) *sarama.ConsumerMessage {
+	value := fmt.Sprintf(`{"machine_id": "conveyor_001", "event_type": "conveyor", "status": "ok", "timestamp": %q%s}`,
+		timestamp.Format(time.RFC3339), fields)
+	return &sarama.ConsumerMessage{Topic: topic, Value: []byte(value)}
+}
+
+func TestFutureTimestampsBeyondSkewRejected(t *testing.T) {
+	cfg := loadConfig(t)
+	cfg.Validation.MaxClockSkew = time.Minute
+	handler, errs := newTestConsumerHandler(t, cfg)
+
+	for _, tc := range []struct {
+		name     string
+		ahead    time.Duration
+		accepted bool
+	}{
+		{"in range", -time.Second, true},
+		{"within skew", 30 * time.Second, true},
+		{"far future", time.Hour, false},
+	} {
+		deliveries := handler.processMessage(eventMessage("line1.sensor", time.Now().Add(tc.ahead), ""))
+		if accepted := len(deliveries) == 1; accepted != tc.accepted {
+			t.Errorf("%s: accepted = %v, want %v", tc.name, accepted, tc.accepted)
+		}
+
+		select {
+		case err := <-errs:
+			if tc.accepted {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+		default:
+			if !tc.accepted {
+				t.Errorf("%s: rejected event not reported on the error channel", tc.name)
+			}
+		}
+	}
+}
+
+func TestEventsOutsideConfiguredBoundsRejected(t *testing.T) {
+	cfg := loadConfig(t)
+	cfg.Validation.ConveyorSpeedBounds = config.MetricBounds{Min: 0, Max: 3}
+	cfg.Validation.TemperatureBounds = config.MetricBounds{Min: 10, Max: 90}
+	handler, errs := newTestConsumerHandler(t, cfg)
+
+	for _, tc := range []struct {
+		fields   string
+		accepted bool
+	}{
+		{`, "conveyor_speed": 2.5, "temperature": 60`, true},
+		{`, "conveyor_speed": 3, "temperature": 90`, true},
+		{`, "conveyor_speed": 4.5`, false},
+		{`, "temperature": 95`, false},
+		{`, "temperature": 5`, false},
+		{`, "robot_arm_angle": 400`, false},
+	} {
+		deliveries := handler.processMessage(eventMessage("line1.sensor", time.Now(), tc.fields))
+		if accepted := len(deliveries) == 1; accepted != tc.accepted {
+			t.Errorf("event with%s: accepted = %v, want %v", tc.fields, accepted, tc.accepted)
+		}
+
+		select {
+		case err := <-errs:
+			if tc.accepted {
+				t.Errorf("event with%s: unexpected error %v", tc.fields, err)
+			}
+		default:
+			if !tc.accepted {
+				t.Errorf("event with%s: rejected event not reported on the error channel", tc.fields)
+			}
+		}
+	}
+}
+
+func TestMessagesRoutedToTheirTopicHandler(t *testing.T) {
+	handler, errs := newTestConsumerHandler(t, loadConfig(t))
+	var energy []string
+	handler.handlers["line1.energy"] = HandlerFunc(func(msg *sarama.ConsumerMessage) error {
+		energy = append(energy, string(msg.Value))
+		return nil
+	})
+	handler.handlers["line1.vision"] = HandlerFunc(func(msg *sarama.ConsumerMessage) error {
+		return fmt.Errorf("unreadable inspection %s", msg.Value)
+	})
+
+	if deliveries := handler.processMessage(eventMessage("line1.sensor", time.Now(), "")); len(deliveries) != 1 {
+		t.Errorf("sensor topic yielded %d deliveries, want the decoded event", len(deliveries))
+	}
+	meterReading := `{"meter_id": "meter_7", "kwh": 12.5}`
+	if deliveries := handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.energy", Value: []byte(meterReading)}); len(deliveries) != 0 {
+		t.Errorf("energy topic yielded %d deliveries, want none for the event pipeline", len(deliveries))
+	}
+	if len(energy) != 1 || energy[0] != meterReading {
+		t.Errorf("energy handler received %v, want the meter reading", energy)
+	}
+
+	handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.vision", Value: []byte("frame_42")})
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "unreadable inspection frame_42") {
+			t.Errorf("error = %v, want the vision handler's", err)
+		}
+	default:
+		t.Error("vision handler error not reported on the error channel")
+	}
+}
+
+func TestConsumerSubscribesToHandledTopics(t *testing.T) {
+	consumer := newTestConsumer(t)
+	consumer.Handle("line1.energy", HandlerFunc(func(*sarama.ConsumerMessage) error { return nil }))
+
+	topics := consumer.subscribe([]string{"line1.sensor", "line1.energy"})
+	if want := []string{"line1.sensor", "line1.energy"}; !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %v, want %v without duplicates", topics, want)
+	}
+	if topics := consumer.subscribe([]string{"line1.sensor"}); !reflect.DeepEqual(topics, []string{"line1.sensor", "line1.energy"}) {
+		t.Errorf("topics = %v, want the handled topic added", topics)
+	}
+}
+
+func TestBatchMessageDecodedIntoEachEvent(t *testing.T) {
+	handler, errs := newTestConsumerHandler(t, loadConfig(t))
+	timestamp := time.Now().Add(-time.Second).Format(time.RFC3339)
+	event := func(machineID string, temperature float64) string {
+		return fmt.Sprintf(`{"machine_id": %q, "event_type": "conveyor", "status": "ok", "timestamp": %q, "temperature": %g}`, machineID, timestamp, temperature)
+	}
+	batch := strings.Join([]string{event("conveyor_001", 60), event("conveyor_002", 61), event("conveyor_003", 62)}, ",")
+
+	for _, tc := range []struct {
+		name, value string
+		want        []string
+	}{
+		{"array", "[" + batch + "]", []string{"conveyor_001", "conveyor_002", "conveyor_003"}},
+		{"envelope", `{"gateway": "edge_1", "events": [` + batch + `]}`, []string{"conveyor_001", "conveyor_002", "conveyor_003"}},
+		{"single event", event("conveyor_004", 63), []string{"conveyor_004"}},
+		{"batch with an invalid event", "[" + event("conveyor_001", 60) + `, {"status": "ok"}, ` + event("conveyor_003", 62) + "]", []string{"conveyor_001", "conveyor_003"}},
+	} {
+		deliveries := handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.sensor", Offset: 30, Value: []byte(tc.value)})
+
+		var got []string
+		for _, delivery := range deliveries {
+			got = append(got, delivery.Event.MachineID)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: delivered %v, want %v", tc.name, got, tc.want)
+		}
+		if len(deliveries) > 0 && deliveries[len(deliveries)-1].next != 31 {
+			t.Errorf("%s: last delivery commits up to %d, want past the message", tc.name, deliveries[len(deliveries)-1].next)
+		}
+		for _, delivery := range deliveries[:max(len(deliveries)-1, 0)] {
+			if delivery.next != 30 {
+				t.Errorf("%s: delivery for %s commits past the message before the rest of the batch", tc.name, delivery.Event.MachineID)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		t.Errorf("%d errors reported, want none: invalid events in a batch are logged and dropped", len(errs))
+	}
+}
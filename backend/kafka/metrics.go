@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// family starts a metric family with its help text and type
+func (m metricsWriter) family(name, help, metricType string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes one sample; labels are name, value pairs
+func (m metricsWriter) sample(name string, value int64, labels ...string) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		m.w.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, " %d\n", value)
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteMetrics writes the consumer's metrics in the Prometheus text exposition format:
+// whether it is connected, its lag per partition and the messages and bytes consumed
+// per topic. consumer is nil while still connecting. Lag is omitted, and the failure
+// logged, when the offsets cannot be fetched.
+func WriteMetrics(w io.Writer, consumer *Consumer) error {
+	m := metricsWriter{w: bufio.NewWriter(w)}
+
+	m.family("fleetstream_kafka_consumer_connected", "Whether the Kafka consumer is connected.", "gauge")
+	if consumer == nil {
+		m.sample("fleetstream_kafka_consumer_connected", 0)
+		return m.w.Flush()
+	}
+	m.sample("fleetstream_kafka_consumer_connected", 1)
+
+	if lags, err := consumer.Lag(); err != nil {
+		log.Printf("Failed to compute consumer lag for metrics: %v", err)
+	} else {
+		m.family("fleetstream_kafka_consumer_lag", "Messages between the consumer group's committed offset and the log end offset.", "gauge")
+		for _, lag := range lags {
+			m.sample("fleetstream_kafka_consumer_lag", lag.Lag,
+				"topic", lag.Topic, "partition", strconv.Itoa(int(lag.Partition)))
+		}
+	}
+
+	throughput := consumer.Throughput()
+	m.family("fleetstream_kafka_messages_consumed_total", "Messages consumed from each Kafka topic since startup.", "counter")
+	for _, topic := range throughput {
+		m.sample("fleetstream_kafka_messages_consumed_total", topic.Messages, "topic", topic.Topic)
+	}
+	m.family("fleetstream_kafka_bytes_consumed_total", "Key and value bytes consumed from each Kafka topic since startup.", "counter")
+	for _, topic := range throughput {
+		m.sample("fleetstream_kafka_bytes_consumed_total", topic.Bytes, "topic", topic.Topic)
+	}
+
+	return m.w.Flush()
+}
@@ -0,0 +1,88 @@
+package kafka
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func TestWriteMetricsWhileConnecting(t *testing.T) {
+	var out strings.Builder
+	if err := WriteMetrics(&out, nil); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	if !strings.Contains(out.String(), "\nfleetstream_kafka_consumer_connected 0\n") {
+		t.Errorf("metrics = %q, want the consumer reported disconnected", out.String())
+	}
+	if strings.Contains(out.String(), "fleetstream_kafka_consumer_lag") {
+		t.Errorf("metrics = %q, want no lag without a consumer", out.String())
+	}
+}
+
+func TestWriteMetricsReportsLag(t *testing.T) {
+	consumer := &Consumer{
+		groupID:    "backend",
+		topics:     []string{"line1.sensor", "line1.vision"},
+		offsets:    newFakeOffsets(),
+		throughput: newTopicThroughput(),
+	}
+
+	var out strings.Builder
+	if err := WriteMetrics(&out, consumer); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+
+	for _, line := range []string{
+		"fleetstream_kafka_consumer_connected 1",
+		"# TYPE fleetstream_kafka_consumer_lag gauge",
+		`fleetstream_kafka_consumer_lag{topic="line1.sensor",partition="0"} 20`,
+		`fleetstream_kafka_consumer_lag{topic="line1.sensor",partition="1"} 0`,
+		`fleetstream_kafka_consumer_lag{topic="line1.vision",partition="0"} 15`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestWriteMetricsEscapesLabels(t *testing.T) {
+	var out strings.Builder
+	m := metricsWriter{w: bufio.NewWriter(&out)}
+	m.sample("metric", 1, "topic", "a\"b\\c\nd")
+	m.w.Flush()
+
+	if want := `metric{topic="a\"b\\c\nd"} 1` + "\n"; out.String() != want {
+		t.Errorf("sample = %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteMetricsReportsThroughput(t *testing.T) {
+	consumer := &Consumer{
+		groupID:    "backend",
+		offsets:    newFakeOffsets(),
+		throughput: newTopicThroughput(),
+	}
+	consumer.throughput.record(&sarama.ConsumerMessage{Topic: "line1.sensor", Key: []byte("m1"), Value: []byte("1234")})
+	consumer.throughput.record(&sarama.ConsumerMessage{Topic: "line1.sensor", Value: []byte("12")})
+	consumer.throughput.record(&sarama.ConsumerMessage{Topic: "line2.sensor", Value: []byte("123")})
+
+	var out strings.Builder
+	if err := WriteMetrics(&out, consumer); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+
+	for _, line := range []string{
+		"# TYPE fleetstream_kafka_messages_consumed_total counter",
+		`fleetstream_kafka_messages_consumed_total{topic="line1.sensor"} 2`,
+		`fleetstream_kafka_messages_consumed_total{topic="line2.sensor"} 1`,
+		"# TYPE fleetstream_kafka_bytes_consumed_total counter",
+		`fleetstream_kafka_bytes_consumed_total{topic="line1.sensor"} 8`,
+		`fleetstream_kafka_bytes_consumed_total{topic="line2.sensor"} 3`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, out.String())
+		}
+	}
+}
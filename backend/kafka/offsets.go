@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"backend/models"
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/IBM/sarama"
+)
+
+// OffsetSource supplies the partition offsets consumer lag is computed from
+type OffsetSource interface {
+	// Partitions lists a topic's partitions
+	Partitions(topic string) ([]int32, error)
+	// LogEndOffset returns the offset the next message produced to a partition will get
+	LogEndOffset(topic string, partition int32) (int64, error)
+	// CommittedOffsets returns a consumer group's committed offset for each of the given
+	// partitions; partitions without a committed offset are absent or -1
+	CommittedOffsets(groupID string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error)
+}
+
+// clusterOffsets reads offsets from the cluster through sarama's client and admin APIs
+type clusterOffsets struct {
+	client sarama.Client
+	admin  sarama.ClusterAdmin
+}
+
+func (o clusterOffsets) Partitions(topic string) ([]int32, error) {
+	return o.client.Partitions(topic)
+}
+
+func (o clusterOffsets) LogEndOffset(topic string, partition int32) (int64, error) {
+	return o.client.GetOffset(topic, partition, sarama.OffsetNewest)
+}
+
+func (o clusterOffsets) CommittedOffsets(groupID string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	response, err := o.admin.ListConsumerGroupOffsets(groupID, topicPartitions)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := make(map[string]map[int32]int64, len(response.Blocks))
+	for topic, blocks := range response.Blocks {
+		committed[topic] = make(map[int32]int64, len(blocks))
+		for partition, block := range blocks {
+			committed[topic][partition] = block.Offset
+		}
+	}
+	return committed, nil
+}
+
+// consumerLag computes a consumer group's lag on every partition of topics, ordered by
+// topic and partition. Lag is the difference between the partition's log end offset and
+// the group's committed offset; partitions without a committed offset report the full
+// log end offset.
+func consumerLag(source OffsetSource, groupID string, topics []string) ([]models.ConsumerLag, error) {
+	topicPartitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		partitions, err := source.Partitions(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list partitions for topic %s: %v", topic, err)
+		}
+		topicPartitions[topic] = partitions
+	}
+
+	committed, err := source.CommittedOffsets(groupID, topicPartitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %v", err)
+	}
+
+	var lags []models.ConsumerLag
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			logEnd, err := source.LogEndOffset(topic, partition)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch log end offset for %s [%d]: %v", topic, partition, err)
+			}
+
+			committedOffset, ok := committed[topic][partition]
+			if !ok {
+				committedOffset = -1
+			}
+
+			lags = append(lags, models.ConsumerLag{
+				Topic:           topic,
+				Partition:       partition,
+				CommittedOffset: committedOffset,
+				LogEndOffset:    logEnd,
+				Lag:             computeLag(committedOffset, logEnd),
+			})
+		}
+	}
+
+	slices.SortFunc(lags, func(a, b models.ConsumerLag) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	return lags, nil
+}
+
+// computeLag calculates partition lag from a committed offset and log end offset
+func computeLag(committedOffset, logEndOffset int64) int64 {
+	if committedOffset < 0 {
+		return logEndOffset
+	}
+	if committedOffset > logEndOffset {
+		return 0
+	}
+	return logEndOffset - committedOffset
+}
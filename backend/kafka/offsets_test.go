@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"backend/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeOffsets is an OffsetSource serving fixed partitions and offsets
+type fakeOffsets struct {
+	partitions map[string][]int32
+	logEnd     map[string]map[int32]int64
+	committed  map[string]map[int32]int64
+	requested  map[string][]int32 // Partitions whose committed offsets were requested
+}
+
+func (f *fakeOffsets) Partitions(topic string) ([]int32, error) {
+	partitions, ok := f.partitions[topic]
+	if !ok {
+		return nil, errors.New("unknown topic")
+	}
+	return partitions, nil
+}
+
+func (f *fakeOffsets) LogEndOffset(topic string, partition int32) (int64, error) {
+	return f.logEnd[topic][partition], nil
+}
+
+func (f *fakeOffsets) CommittedOffsets(groupID string, topicPartitions map[string][]int32) (map[string]map[int32]int64, error) {
+	f.requested = topicPartitions
+	return f.committed, nil
+}
+
+func newFakeOffsets() *fakeOffsets {
+	return &fakeOffsets{
+		partitions: map[string][]int32{"line1.sensor": {1, 0}, "line1.vision": {0}},
+		logEnd: map[string]map[int32]int64{
+			"line1.sensor": {0: 120, 1: 80},
+			"line1.vision": {0: 15},
+		},
+		committed: map[string]map[int32]int64{
+			"line1.sensor": {0: 100, 1: 90}, // Partition 1 committed past a truncated log
+		},
+	}
+}
+
+func TestConsumerLag(t *testing.T) {
+	lags, err := consumerLag(newFakeOffsets(), "backend", []string{"line1.vision", "line1.sensor"})
+	if err != nil {
+		t.Fatalf("consumerLag: %v", err)
+	}
+
+	want := []models.ConsumerLag{
+		{Topic: "line1.sensor", Partition: 0, CommittedOffset: 100, LogEndOffset: 120, Lag: 20},
+		{Topic: "line1.sensor", Partition: 1, CommittedOffset: 90, LogEndOffset: 80, Lag: 0},
+		{Topic: "line1.vision", Partition: 0, CommittedOffset: -1, LogEndOffset: 15, Lag: 15}, // Nothing committed yet
+	}
+	if !reflect.DeepEqual(lags, want) {
+		t.Errorf("lags = %+v, want %+v", lags, want)
+	}
+}
+
+func TestConsumerLagFailsForUnknownTopic(t *testing.T) {
+	if _, err := consumerLag(newFakeOffsets(), "backend", []string{"line9.sensor"}); err == nil {
+		t.Error("consumerLag succeeded for a topic without partitions")
+	}
+}
+
+func TestLagCoversHandledTopics(t *testing.T) {
+	offsets := newFakeOffsets()
+	consumer := &Consumer{
+		groupID:  "backend",
+		topics:   []string{"line1.sensor"},
+		offsets:  offsets,
+		handlers: map[string]TopicHandler{"line1.vision": HandlerFunc(nil)},
+	}
+
+	lags, err := consumer.Lag()
+	if err != nil {
+		t.Fatalf("Lag: %v", err)
+	}
+	if len(lags) != 2 {
+		t.Fatalf("got %d partition lags before Start, want the 2 of the configured topic", len(lags))
+	}
+
+	consumer.subscribe(consumer.topics)
+	lags, err = consumer.Lag()
+	if err != nil {
+		t.Fatalf("Lag: %v", err)
+	}
+	if len(lags) != 3 || lags[2].Topic != "line1.vision" {
+		t.Errorf("lags = %+v, want the handled topic's partition included", lags)
+	}
+	if _, ok := offsets.requested["line1.vision"]; !ok {
+		t.Errorf("committed offsets requested for %v, want the handled topic included", offsets.requested)
+	}
+}
@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/models"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// routingChecker enforces keyed routing: every event of a machine must arrive on the same
+// partition of its topic, keyed by the machine ID, so one consumer sees the machine's
+// events in order and the detector never builds partial windows for it on two instances.
+// A machine seen on a second partition means producers are not keying by machine ID, or
+// the topic's partition count changed under hash partitioning.
+type routingChecker struct {
+	mode       string
+	normalize  func(machineID string) string // Canonicalizes message keys as event machine IDs are
+	mutex      sync.Mutex
+	partitions map[string]map[string]int32 // Partition each machine was first seen on, by topic
+}
+
+// newRoutingChecker creates a checker for the configured mode, or returns nil when the
+// check is off. normalize is the machine ID normalization events were validated with.
+func newRoutingChecker(mode string, normalize func(machineID string) string) *routingChecker {
+	if mode == config.PartitionCheckOff {
+		return nil
+	}
+	return &routingChecker{
+		mode:       mode,
+		normalize:  normalize,
+		partitions: make(map[string]map[string]int32),
+	}
+}
+
+// check returns an error when an event of msg breaks keyed routing. The event's machine
+// ID has been normalized, so the key is normalized the same way before comparing.
+func (r *routingChecker) check(msg *sarama.ConsumerMessage, event *models.SensorEvent) error {
+	if len(msg.Key) > 0 && r.normalize(string(msg.Key)) != event.MachineID {
+		return fmt.Errorf("partition routing violated: message at %s [%d] offset %d is keyed %q but carries machine %s",
+			msg.Topic, msg.Partition, msg.Offset, msg.Key, event.MachineID)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	machines, ok := r.partitions[msg.Topic]
+	if !ok {
+		machines = make(map[string]int32)
+		r.partitions[msg.Topic] = machines
+	}
+	partition, seen := machines[event.MachineID]
+	if !seen {
+		machines[event.MachineID] = msg.Partition
+		return nil
+	}
+	if partition != msg.Partition {
+		return fmt.Errorf("partition routing violated: machine %s seen on %s partitions %d and %d; producers must key events by machine ID",
+			event.MachineID, msg.Topic, partition, msg.Partition)
+	}
+	return nil
+}
+
+// fatal reports whether a violation must stop the consumer
+func (r *routingChecker) fatal() bool {
+	return r.mode == config.PartitionCheckFail
+}
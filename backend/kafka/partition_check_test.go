@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/models"
+	"backend/services"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// newLowercaseConsumerHandler creates a handler decoding JSON sensor events with machine
+// IDs folded to lower case, checking routing in fail mode
+func newLowercaseConsumerHandler(t *testing.T) *ConsumerGroupHandler {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	cfg.Validation.MachineIDCase = "lower"
+	decoder, err := NewDecoder(cfg.Kafka)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	validator := services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature)
+	return &ConsumerGroupHandler{
+		sensors:    &sensorHandler{decoder: decoder, validator: validator},
+		handlers:   map[string]TopicHandler{},
+		errors:     newErrorAggregator(make(chan error, 10), 0),
+		routing:    newRoutingChecker(config.PartitionCheckFail, validator.NormalizeMachineID),
+		fatal:      make(chan error, 1),
+		throughput: newTopicThroughput(),
+	}
+}
+
+// sensorMessage builds a JSON sensor event message for machineID keyed by key
+func sensorMessage(key, machineID string, partition int32, offset int64) *sarama.ConsumerMessage {
+	value := fmt.Sprintf(`{"machine_id": %q, "event_type": "conveyor", "status": "ok", "timestamp": %q}`,
+		machineID, time.Now().Add(-time.Second).Format(time.RFC3339))
+	return &sarama.ConsumerMessage{Topic: "line1.sensor", Partition: partition, Offset: offset, Key: []byte(key), Value: []byte(value)}
+}
+
+func TestRoutingCheckNormalizesMixedCaseKeys(t *testing.T) {
+	handler := newLowercaseConsumerHandler(t)
+
+	deliveries := handler.processMessage(sensorMessage("Conveyor_001", "Conveyor_001", 0, 1))
+	if len(deliveries) != 1 || deliveries[0].Event.MachineID != "conveyor_001" {
+		t.Fatalf("deliveries = %v, want one event for conveyor_001", deliveries)
+	}
+	handler.processMessage(sensorMessage("CONVEYOR_001", "conveyor_001", 0, 2))
+
+	select {
+	case err := <-handler.fatal:
+		t.Fatalf("mixed-case key reported as a violation: %v", err)
+	default:
+	}
+}
+
+func TestRoutingCheckReportsMismatchedKey(t *testing.T) {
+	handler := newLowercaseConsumerHandler(t)
+
+	handler.processMessage(sensorMessage("Conveyor_002", "Conveyor_001", 0, 1))
+
+	select {
+	case <-handler.fatal:
+	default:
+		t.Fatal("key of another machine not reported as a violation")
+	}
+}
+
+func TestRoutingCheckReportsSecondPartition(t *testing.T) {
+	checker := newRoutingChecker(config.PartitionCheckWarn, func(machineID string) string { return machineID })
+	event := &models.SensorEvent{MachineID: "conveyor_001"}
+
+	if err := checker.check(&sarama.ConsumerMessage{Topic: "line1.sensor", Partition: 0}, event); err != nil {
+		t.Fatalf("first message reported: %v", err)
+	}
+	if err := checker.check(&sarama.ConsumerMessage{Topic: "line1.sensor", Partition: 1}, event); err == nil {
+		t.Error("machine seen on a second partition not reported")
+	}
+	if err := checker.check(&sarama.ConsumerMessage{Topic: "line2.sensor", Partition: 1}, event); err != nil {
+		t.Errorf("machine on another topic reported: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"backend/config"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// MachineStatus is the message published when a machine's status changes. Messages are
+// keyed by machine ID on a compacted topic, so the topic retains each machine's latest status.
+type MachineStatus struct {
+	MachineID      string    `json:"machine_id"`
+	Status         string    `json:"status"`
+	PreviousStatus string    `json:"previous_status,omitempty"` // Empty for the first status seen since startup
+	ChangedAt      time.Time `json:"changed_at"`
+	EventID        int       `json:"event_id,omitempty"` // Event that carried the new status
+}
+
+// StatusPublisher publishes machine status changes to a compacted Kafka topic
+type StatusPublisher struct {
+	producer sarama.AsyncProducer
+	topic    string
+	statuses map[string]string // Last published status per machine
+	mutex    sync.Mutex
+	done     chan struct{}
+}
+
+// NewStatusPublisher connects a producer for cfg.StatusTopic, creating the topic with
+// log compaction if it does not exist yet
+func NewStatusPublisher(cfg config.KafkaConfig) (*StatusPublisher, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_6_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Errors = true
+	config.Producer.Partitioner = sarama.NewHashPartitioner // One partition per machine keeps its changes ordered
+
+	brokerList := strings.Split(cfg.Brokers, ",")
+	if err := ensureCompactedTopic(brokerList, config, cfg.StatusTopic); err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewAsyncProducer(brokerList, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create status producer: %v", err)
+	}
+
+	publisher := &StatusPublisher{
+		producer: producer,
+		topic:    cfg.StatusTopic,
+		statuses: make(map[string]string),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(publisher.done)
+		for err := range producer.Errors() {
+			log.Printf("Failed to publish machine status: %v", err)
+		}
+	}()
+
+	return publisher, nil
+}
+
+// ensureCompactedTopic creates topic with cleanup.policy=compact unless it already exists
+func ensureCompactedTopic(brokers []string, config *sarama.Config, topic string) error {
+	admin, err := sarama.NewClusterAdmin(brokers, config)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster admin: %v", err)
+	}
+	defer admin.Close()
+
+	compact := "compact"
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     -1, // Broker defaults
+		ReplicationFactor: -1,
+		ConfigEntries:     map[string]*string{"cleanup.policy": &compact},
+	}, false)
+	if err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+		return fmt.Errorf("failed to create status topic %s: %v", topic, err)
+	}
+	return nil
+}
+
+// Observe publishes the event's status if it differs from the machine's last published status
+func (p *StatusPublisher) Observe(event *models.SensorEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	previous, seen := p.statuses[event.MachineID]
+	if seen && previous == event.Status {
+		return
+	}
+	p.statuses[event.MachineID] = event.Status
+
+	value, err := json.Marshal(MachineStatus{
+		MachineID:      event.MachineID,
+		Status:         event.Status,
+		PreviousStatus: previous,
+		ChangedAt:      event.Timestamp,
+		EventID:        event.ID,
+	})
+	if err != nil {
+		log.Printf("Failed to encode machine status: %v", err)
+		return
+	}
+
+	// Sent under the lock so a machine's changes are queued in the order they were observed
+	p.producer.Input() <- &sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   sarama.StringEncoder(event.MachineID),
+		Value: sarama.ByteEncoder(value),
+	}
+}
+
+// Close flushes pending messages and closes the producer. Observe must not be called after Close.
+func (p *StatusPublisher) Close() error {
+	p.producer.AsyncClose()
+	<-p.done
+	return nil
+}
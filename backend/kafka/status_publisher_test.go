@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"backend/models"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+// newTestStatusPublisher returns a publisher over a mock producer that returns its
+// successes, and the producer
+func newTestStatusPublisher(t *testing.T) (*StatusPublisher, *mocks.AsyncProducer) {
+	t.Helper()
+	config := mocks.NewTestConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+
+	publisher := &StatusPublisher{
+		producer: producer,
+		topic:    "machine-status",
+		statuses: make(map[string]string),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(publisher.done)
+		for range producer.Errors() {
+		}
+	}()
+	return publisher, producer
+}
+
+func TestStatusChangePublishesOneKeyedMessage(t *testing.T) {
+	publisher, producer := newTestStatusPublisher(t)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndSucceed()
+
+	changedAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	for _, event := range []*models.SensorEvent{
+		{ID: 1, MachineID: "conveyor_001", Status: "ok", Timestamp: changedAt.Add(-time.Minute)},
+		{ID: 2, MachineID: "conveyor_001", Status: "ok", Timestamp: changedAt.Add(-time.Second)},
+		{ID: 3, MachineID: "conveyor_001", Status: "fault", Timestamp: changedAt},
+		{ID: 4, MachineID: "conveyor_001", Status: "fault", Timestamp: changedAt.Add(time.Second)},
+	} {
+		publisher.Observe(event)
+	}
+
+	var published []*sarama.ProducerMessage
+	for i := 0; i < 2; i++ {
+		select {
+		case message := <-producer.Successes():
+			published = append(published, message)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("published %d messages, want 2", len(published))
+		}
+	}
+	publisher.Close()
+
+	change := published[1]
+	if key, _ := change.Key.Encode(); change.Topic != "machine-status" || string(key) != "conveyor_001" {
+		t.Errorf("message on %s keyed %s, want machine-status keyed conveyor_001", change.Topic, key)
+	}
+	value, _ := change.Value.Encode()
+	var status MachineStatus
+	if err := json.Unmarshal(value, &status); err != nil {
+		t.Fatalf("decoding %s: %v", value, err)
+	}
+	want := MachineStatus{MachineID: "conveyor_001", Status: "fault", PreviousStatus: "ok", ChangedAt: changedAt, EventID: 3}
+	if status != want {
+		t.Errorf("status = %+v, want %+v", status, want)
+	}
+}
@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"backend/models"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// topicThroughput counts the messages and bytes consumed from each topic since startup,
+// so operators can tell which line is busiest
+type topicThroughput struct {
+	mutex  sync.Mutex
+	topics map[string]*models.TopicThroughput
+}
+
+// newTopicThroughput creates empty per-topic counters
+func newTopicThroughput() *topicThroughput {
+	return &topicThroughput{topics: make(map[string]*models.TopicThroughput)}
+}
+
+// record counts a message consumed from its topic; bytes include the key and value
+func (t *topicThroughput) record(msg *sarama.ConsumerMessage) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	counters, ok := t.topics[msg.Topic]
+	if !ok {
+		counters = &models.TopicThroughput{Topic: msg.Topic}
+		t.topics[msg.Topic] = counters
+	}
+	counters.Messages++
+	counters.Bytes += int64(len(msg.Key) + len(msg.Value))
+}
+
+// snapshot returns a copy of the counters, ordered by topic
+func (t *topicThroughput) snapshot() []models.TopicThroughput {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	snapshot := make([]models.TopicThroughput, 0, len(t.topics))
+	for _, counters := range t.topics {
+		snapshot = append(snapshot, *counters)
+	}
+	slices.SortFunc(snapshot, func(a, b models.TopicThroughput) int {
+		return strings.Compare(a.Topic, b.Topic)
+	})
+	return snapshot
+}
@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"backend/models"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func TestProcessMessageCountsThroughputPerTopic(t *testing.T) {
+	ignore := HandlerFunc(func(*sarama.ConsumerMessage) error { return nil })
+	handler := &ConsumerGroupHandler{
+		handlers:   map[string]TopicHandler{"line1.sensor": ignore, "line2.sensor": ignore},
+		throughput: newTopicThroughput(),
+	}
+
+	for _, msg := range []*sarama.ConsumerMessage{
+		{Topic: "line2.sensor", Key: []byte("robot_001"), Value: []byte(`{"a":1}`)},
+		{Topic: "line1.sensor", Value: []byte(`{}`)},
+		{Topic: "line2.sensor", Value: []byte(`{"b":22}`)},
+	} {
+		handler.processMessage(msg)
+	}
+
+	want := []models.TopicThroughput{
+		{Topic: "line1.sensor", Messages: 1, Bytes: 2},
+		{Topic: "line2.sensor", Messages: 2, Bytes: 9 + 7 + 8},
+	}
+	if got := handler.throughput.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("throughput = %+v, want %+v", got, want)
+	}
+}
+
+func TestThroughputCountsFailedMessages(t *testing.T) {
+	handler := &ConsumerGroupHandler{
+		handlers:   map[string]TopicHandler{"line1.sensor": HandlerFunc(func(*sarama.ConsumerMessage) error { return errors.New("bad message") })},
+		errors:     newErrorAggregator(make(chan error, 1), 0),
+		throughput: newTopicThroughput(),
+	}
+	handler.processMessage(&sarama.ConsumerMessage{Topic: "line1.sensor", Value: []byte("bad")})
+
+	if got := handler.throughput.snapshot(); len(got) != 1 || got[0].Messages != 1 || got[0].Bytes != 3 {
+		t.Errorf("throughput = %+v, want the failed message counted", got)
+	}
+}
@@ -35,81 +35,141 @@ func main() {
 
 	log.Printf("Starting FactoryFlow Backend Server on port %s", cfg.Server.Port)
 
+	if err := models.SetOmittedMetrics(cfg.Output.OmitMetrics); err != nil {
+		log.Fatalf("Invalid EVENT_OMIT_METRICS: %v", err)
+	}
+	models.SetRedactedRawData(cfg.Output.RedactRawData)
+
 	// Initialize database
-	db, err := database.New(cfg.GetDatabaseURL())
+	db, err := database.New(cfg.GetDatabaseURL(), cfg.GetReplicaURL())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
-	log.Println("Database connection established")
+	// Reach the database in the background, so the server starts and reports itself degraded
+	// during a database outage. dbReady is closed once it is reachable; startup work that
+	// needs it waits for dbReady and is abandoned if shutdown begins first.
+	startCtx, cancelStart := context.WithCancel(context.Background())
+	defer cancelStart()
+	dbReady := make(chan struct{})
+	go func() {
+		if err := db.Connect(startCtx, cfg.Database.ConnectMaxBackoff); err != nil {
+			return
+		}
+		log.Println("Database connection established")
+
+		// Databases created from an older schema gain the tables and columns added since
+		if err := db.Migrate(startCtx); err != nil {
+			if startCtx.Err() != nil {
+				return // Shutting down
+			}
+			log.Fatalf("Failed to migrate database schema: %v", err)
+		}
+		close(dbReady)
+	}()
 
 	// Initialize WebSocket hub
-	wsHub := websocket.NewHub()
+	wsHub := websocket.NewHub(cfg.WebSocket, db)
 	go wsHub.Run()
 
 	log.Println("WebSocket hub started")
 
-	// Initialize anomaly detector with alert callback
-	alertCallback := func(alert *models.Alert) {
-		// Store alert in database
-		if err := db.InsertAlert(alert); err != nil {
-			log.Printf("Failed to store alert: %v", err)
-		} else {
-			log.Printf("Alert created: %s - %s", alert.AlertType, alert.Message)
+	// Alerts are stored in batches, so a storm does not compound database load, and are
+	// broadcast to WebSocket clients once stored, carrying their IDs. Resolutions are
+	// applied in order with them, so the alerts of a resolved condition are resolved too.
+	alertBatcher := services.NewAlertBatcher(db, cfg.Database.AlertBatchSize, cfg.Database.AlertBatchInterval,
+		wsHub.BroadcastAlert, wsHub.BroadcastResolved)
+	alertBatcher.Start()
+
+	// The detector calls these with its lock held, so they only queue
+	alertCallback := alertBatcher.Add
+	resolveCallback := alertBatcher.Resolve
+
+	anomalyDetector := services.NewAnomalyDetector(cfg.Anomaly, services.RealClock{}, alertCallback, resolveCallback)
+	anomalyDetector.Start()
+	defer anomalyDetector.Stop()
+
+	// Cache machine metadata for event enrichment
+	machineCache := services.NewMachineCache(db, cfg.Server.MachineRefresh)
+	defer machineCache.Stop()
+
+	// Load stored state once the database is reachable; events are not consumed until then
+	stateLoaded := make(chan struct{})
+	go func() {
+		select {
+		case <-dbReady:
+		case <-startCtx.Done():
+			return
 		}
+		restoreDetectorState(db, anomalyDetector)
+		machineCache.Start()
+		close(stateLoaded)
+	}()
 
-		// Broadcast alert to WebSocket clients
-		wsHub.BroadcastAlert(alert)
-	}
+	// Shared validation and processing pipeline for Kafka and HTTP ingestion
+	validator := services.NewEventValidator(cfg.Validation, cfg.Units.IngestTemperature)
+	processor := services.NewEventProcessor(db, machineCache, anomalyDetector, wsHub)
 
-	anomalyDetector := services.NewAnomalyDetector(alertCallback)
+	// Connect to Kafka in the background, so the API serves stored data while brokers are
+	// unreachable, then process its events. processingDone is closed once the consumer
+	// has stopped and every delivered event has been processed.
+	connector := kafka.NewConnector(cfg.Kafka, validator)
+	processingDone := make(chan struct{})
+	go func() {
+		defer close(processingDone)
 
-	// Initialize Kafka consumer (optional)
-	consumer, err := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.GroupID, cfg.Kafka.Topics)
-	if err != nil {
-		log.Printf("Warning: Failed to initialize Kafka consumer: %v", err)
-		log.Println("Continuing without Kafka - running in demo mode")
-		consumer = nil // Set to nil so we can check later
-	} else {
-		defer consumer.Stop()
+		select {
+		case <-stateLoaded:
+		case <-startCtx.Done():
+			return
+		}
+
+		consumer := connector.Connect()
+		if consumer == nil {
+			return
+		}
 		log.Printf("Kafka consumer initialized, topics: %v", cfg.Kafka.Topics)
-		// Start Kafka consumer
 		consumer.Start(cfg.Kafka.Topics)
-	}
 
-	// Process events from Kafka (only if Kafka is available)
-	if consumer != nil {
-		go func() {
-			for {
-				select {
-				case event := <-consumer.EventChannel():
-					if event != nil {
-						// Store event in database
-						dbEvent, err := db.InsertEvent(event)
-						if err != nil {
-							log.Printf("Failed to store event: %v", err)
-							continue
-						}
-
-						// Analyze for anomalies
-						anomalyDetector.AnalyzeEvent(event)
-
-						// Broadcast to WebSocket clients
-						wsHub.BroadcastEvent(event)
-
-						log.Printf("Event processed: ID=%d, Machine=%s, Status=%s",
-							dbEvent.ID, event.MachineID, event.Status)
-					}
-
-				case err := <-consumer.ErrorChannel():
-					log.Printf("Kafka consumer error: %v", err)
+		// Publish machine status changes back to Kafka (optional)
+		if cfg.Kafka.StatusTopic != "" {
+			publisher, err := kafka.NewStatusPublisher(cfg.Kafka)
+			if err != nil {
+				log.Printf("Warning: Failed to initialize machine status publisher: %v", err)
+			} else {
+				log.Printf("Publishing machine status changes to %s", cfg.Kafka.StatusTopic)
+				processor.SetStatusObserver(publisher)
+				defer publisher.Close()
+				defer processor.SetStatusObserver(nil)
+			}
+		}
+
+		pool := services.NewProcessingPool(processor, cfg.Kafka.Workers, cfg.Kafka.WorkerQueueSize)
+		pool.Start()
+
+		errors := consumer.ErrorChannel()
+		for {
+			select {
+			case delivery, ok := <-consumer.EventChannel():
+				if !ok {
+					pool.Stop()
+					return
+				}
+				pool.Submit(delivery.Event, delivery.Done)
+
+			case err, ok := <-errors:
+				if !ok {
+					errors = nil // Closed with the event channel; stop selecting it
+					continue
 				}
+				log.Printf("Kafka consumer error: %v", err)
+
+			case err := <-consumer.Fatal():
+				log.Fatalf("Stopping: %v (KAFKA_PARTITION_CHECK=fail)", err)
 			}
-		}()
-	} else {
-		log.Println("Kafka not available - API endpoints will work but no real-time events")
-	}
+		}
+	}()
 
 	// Periodic statistics broadcast
 	go func() {
@@ -117,11 +177,12 @@ func main() {
 		defer ticker.Stop()
 
 		for range ticker.C {
-			stats, err := db.GetEventStats("", time.Now().Add(-1*time.Hour))
+			stats, err := db.GetEventStats("", "", time.Now().Add(-1*time.Hour), cfg.Validation.IdleStatuses)
 			if err != nil {
 				log.Printf("Failed to get stats: %v", err)
 				continue
 			}
+			stats.ComputeUptime(cfg.Health.UptimeCountsWarnings)
 
 			wsHub.BroadcastStats(map[string]interface{}{
 				"system_stats":      stats,
@@ -132,7 +193,8 @@ func main() {
 	}()
 
 	// Initialize HTTP handlers
-	handler := handlers.New(db, wsHub, anomalyDetector)
+	handler := handlers.New(cfg, db, wsHub, anomalyDetector, connector, validator, processor, alertCallback)
+	wsHub.SetAdminActions(handler.AuditedAdminActions())
 
 	// Setup Gin router
 	if gin.Mode() == gin.ReleaseMode {
@@ -143,25 +205,7 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	// Setup CORS middleware
-	c := cors.New(cors.Options{
-    AllowedOrigins:   []string{"https://8jmxm2bjvs.us-east-1.awsapprunner.com/"},
-    AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-    AllowedHeaders:   []string{"*"},
-    ExposedHeaders:   []string{"*"},
-    AllowCredentials: true,
-    MaxAge:           300,
-})
-
-
-	router.Use(func(ctx *gin.Context) {
-		c.HandlerFunc(ctx.Writer, ctx.Request)
-		if ctx.Request.Method == "OPTIONS" {
-			ctx.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-		ctx.Next()
-	})
+	router.Use(corsMiddleware(cfg.Server.AllowOrigins))
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -171,17 +215,34 @@ func main() {
 			"version":   "1.0.0",
 		})
 	})
+	router.GET("/health/live", handler.Liveness)
+	router.GET("/health/ready", handler.Readiness)
+	router.GET("/metrics", handler.Metrics)
 
 	// API routes
-	api := router.Group("/api")
+	api := router.Group("/api", handler.LimitRequestBody)
 	{
 		// Events
 		api.GET("/events", handler.GetEvents)
 		api.GET("/events/stats", handler.GetEventStats)
+		api.GET("/events/latest", handler.GetLatestEvents)
+		api.GET("/events/raw-stats", handler.GetRawDataStats)
+		api.GET("/events/machines", handler.GetSeenMachines)
+		api.GET("/events/export", handler.ExportEvents)
+
+		// Ingestion for devices that cannot publish to Kafka
+		api.POST("/ingest", handler.IngestEvents)
 
 		// Alerts
 		api.GET("/alerts", handler.GetAlerts)
+		api.GET("/alerts/current", handler.GetCurrentAlerts)
+		api.GET("/alerts/stats", handler.GetAlertStats)
+		api.GET("/alerts/export", handler.ExportAlerts)
+		api.GET("/alerts/snoozes", handler.GetAlertSnoozes)
+		api.GET("/alerts/:id/context", handler.GetAlertContext)
 		api.PUT("/alerts/:id/acknowledge", handler.AcknowledgeAlert)
+		api.POST("/alerts/:id/snooze", handler.SnoozeAlert)
+		api.POST("/alerts/test", handler.RequireAdmin, handler.FireTestAlert)
 
 		// Process parameters
 		api.GET("/parameters", handler.GetProcessParameters)
@@ -189,17 +250,36 @@ func main() {
 
 		// Machines
 		api.GET("/machines", handler.GetMachines)
+		api.GET("/machines/:id/reliability", handler.GetMachineReliability)
+
+		// Model schema for client developers
+		api.GET("/schema", handler.GetSchema)
 
 		// System health
 		api.GET("/system/health", handler.GetSystemHealth)
 
+		// Home screen summary in one call
+		api.GET("/dashboard", handler.GetDashboard)
+
 		// Anomaly detection
 		api.GET("/anomaly/thresholds", handler.GetAnomalyThresholds)
-		api.PUT("/anomaly/thresholds", handler.UpdateAnomalyThresholds)
+		api.PUT("/anomaly/thresholds", handler.RequireAdmin, handler.UpdateAnomalyThresholds)
+		api.GET("/anomaly/rules", handler.GetAnomalyRules)
+		api.PUT("/anomaly/rules", handler.RequireAdmin, handler.UpdateAnomalyRules)
+		api.POST("/anomaly/templates/preview", handler.PreviewAlertTemplate)
+		api.POST("/anomaly/reprocess", handler.RequireAdmin, handler.ReprocessAlerts)
+
+		// Audit trail of configuration changes
+		api.GET("/audit", handler.RequireAdmin, handler.GetAuditLog)
+
+		// Diagnostics for support engineers
+		debug := api.Group("/debug", handler.RequireAdmin)
+		debug.GET("/detector", handler.GetDetectorState)
 	}
 
-	// WebSocket endpoint
+	// WebSocket endpoints; /ws/stats streams only the periodic stats
 	router.GET("/ws", handler.WebSocketEndpoint)
+	router.GET("/ws/stats", handler.StatsWebSocketEndpoint)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -223,14 +303,92 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	cancelStart()
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// All shutdown steps share one deadline so a slow dependency cannot stall exit
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
-	}
+	shutdownStep(ctx, "HTTP server", func() error {
+		return server.Shutdown(ctx)
+	})
+	shutdownStep(ctx, "WebSocket hub", func() error {
+		return wsHub.Shutdown(ctx)
+	})
+	shutdownStep(ctx, "Kafka consumer", func() error {
+		err := connector.Stop()
+		<-processingDone
+		return err
+	})
+	shutdownStep(ctx, "Alert batcher", func() error {
+		alertBatcher.Stop()
+		return nil
+	})
 
 	log.Println("Server stopped")
-}
\ No newline at end of file
+}
+
+// restoreDetectorState applies the snoozes and rule settings stored by operators, which
+// outlive a restart
+func restoreDetectorState(db database.Store, anomalyDetector *services.AnomalyDetector) {
+	if snoozes, err := db.GetActiveSnoozes(); err != nil {
+		log.Printf("Warning: Failed to load alert snoozes: %v", err)
+	} else {
+		for _, snooze := range snoozes {
+			anomalyDetector.Snooze(snooze.MachineID, snooze.AlertType, snooze.SnoozedUntil)
+		}
+	}
+
+	// Restore detection rules switched off by operators
+	if rules, err := db.GetAnomalyRuleSettings(); err != nil {
+		log.Printf("Warning: Failed to load anomaly rule settings: %v", err)
+	} else {
+		for rule, enabled := range rules {
+			if err := anomalyDetector.SetRuleEnabled(rule, enabled); err != nil {
+				log.Printf("Warning: Ignoring stored setting: %v", err)
+			}
+		}
+	}
+}
+
+// corsMiddleware applies CORS for the allowed origins. rs/cors answers preflight requests
+// in full, writing the Access-Control-* headers and the status, so the chain stops there;
+// other requests, including OPTIONS requests that are not preflights, get their CORS
+// headers and are routed as usual.
+func corsMiddleware(allowOrigins []string) gin.HandlerFunc {
+	c := cors.New(cors.Options{
+		AllowedOrigins:       allowOrigins,
+		AllowedMethods:       []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders:       []string{"*"},
+		ExposedHeaders:       []string{"Content-Disposition"}, // Export filenames; "*" is not honoured with credentials
+		AllowCredentials:     true,
+		MaxAge:               300,
+		OptionsSuccessStatus: http.StatusNoContent,
+	})
+
+	return func(ctx *gin.Context) {
+		c.HandlerFunc(ctx.Writer, ctx.Request)
+		if ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != "" {
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// shutdownStep runs one shutdown step, abandoning it if the shared deadline passes first
+func shutdownStep(ctx context.Context, name string, step func() error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- step()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("%s shutdown error: %v", name, err)
+		}
+	case <-ctx.Done():
+		log.Printf("%s did not stop before the shutdown deadline", name)
+	}
+}
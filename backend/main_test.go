@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestShutdownCompletesWithinBudgetDespiteSlowStep(t *testing.T) {
+	budget := 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	var stopped []string
+
+	start := time.Now()
+	shutdownStep(ctx, "HTTP server", func() error {
+		stopped = append(stopped, "HTTP server")
+		return nil
+	})
+	shutdownStep(ctx, "Kafka consumer", func() error {
+		<-stuck // A consumer that never finishes draining
+		return nil
+	})
+	shutdownStep(ctx, "Alert batcher", func() error {
+		<-stuck
+		return nil
+	})
+	elapsed := time.Since(start)
+
+	if elapsed > budget+50*time.Millisecond {
+		t.Errorf("shutdown took %v, want within the %v budget shared by every step", elapsed, budget)
+	}
+	if len(stopped) != 1 {
+		t.Errorf("steps completed = %v, want the HTTP server stopped before the slow step", stopped)
+	}
+}
+
+func TestCORSPreflightAnsweredOnce(t *testing.T) {
+	router := gin.New()
+	router.Use(corsMiddleware([]string{"https://dashboard.example.com"}))
+	handled := 0
+	router.PUT("/api/alerts/:id/acknowledge", func(c *gin.Context) { handled++ })
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/api/alerts/1/acknowledge", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", recorder.Code)
+	}
+	headers := recorder.Header()
+	if got := headers.Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "https://dashboard.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %v, want the origin once", got)
+	}
+	if got := headers.Get("Access-Control-Allow-Methods"); got != http.MethodPut {
+		t.Errorf("Access-Control-Allow-Methods = %q, want PUT", got)
+	}
+	if got := headers.Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("Access-Control-Allow-Headers = %q, want Content-Type", got)
+	}
+	if headers.Get("Access-Control-Allow-Credentials") != "true" || headers.Get("Access-Control-Max-Age") != "300" {
+		t.Errorf("headers = %v, want credentials allowed and a max age of 300", headers)
+	}
+	if handled != 0 {
+		t.Error("preflight request reached the route handler")
+	}
+}
+
+func TestCORSPreflightRejectsUnknownOriginAndMethod(t *testing.T) {
+	router := gin.New()
+	router.Use(corsMiddleware([]string{"https://dashboard.example.com"}))
+
+	for _, tc := range []struct{ origin, method string }{
+		{"https://elsewhere.example.com", http.MethodPut},
+		{"https://dashboard.example.com", http.MethodPatch},
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodOptions, "/api/alerts", nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		router.ServeHTTP(recorder, req)
+
+		if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s from %s: Access-Control-Allow-Origin = %q, want none", tc.method, tc.origin, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AdditionalData holds the free-form values a sensor reports alongside its metrics, stored
+// as raw_data. Values decoded from JSON are float64 whatever they looked like on the
+// wire, while other decoders produce sized integers or json.Number, so values should be
+// read through the typed accessors rather than type-asserted.
+type AdditionalData map[string]interface{}
+
+// Int returns the value of key as an int. Whole numbers of any numeric type, including
+// float64 from JSON, and numeric strings are converted; fallback is returned when the key
+// is missing or its value is not a whole number that fits in an int.
+func (d AdditionalData) Int(key string, fallback int) int {
+	number, ok := d.number(key)
+	if !ok || number != math.Trunc(number) || number < math.MinInt || number >= math.MaxInt {
+		return fallback
+	}
+	return int(number)
+}
+
+// Float returns the value of key as a float64. Numbers of any numeric type and numeric
+// strings are converted; fallback is returned when the key is missing or not numeric.
+func (d AdditionalData) Float(key string, fallback float64) float64 {
+	if number, ok := d.number(key); ok {
+		return number
+	}
+	return fallback
+}
+
+// String returns the value of key as a string. Numbers and booleans are formatted, with
+// whole numbers written without a fraction; fallback is returned when the key is missing
+// or holds a nested value.
+func (d AdditionalData) String(key string, fallback string) string {
+	switch value := d[key].(type) {
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	}
+	if number, ok := toFloat(d[key]); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fallback
+}
+
+// number returns the value of key as a float64, parsing numeric strings
+func (d AdditionalData) number(key string) (float64, bool) {
+	if value, ok := d[key].(string); ok {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil && !math.IsNaN(number) && !math.IsInf(number, 0)
+	}
+	return toFloat(d[key])
+}
+
+// toFloat converts a value of any numeric type to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAdditionalDataCoercion(t *testing.T) {
+	var data AdditionalData
+	if err := json.Unmarshal([]byte(`{
+		"cycle_count": 1200,
+		"vibration_level": 0.35,
+		"shift": "2",
+		"operator": "ana",
+		"calibrated": true,
+		"nested": {"a": 1}
+	}`), &data); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	data["sized"] = int32(7)
+	data["number"] = json.Number("42")
+	data["huge"] = 1e300
+
+	for _, tc := range []struct {
+		key  string
+		want int
+	}{
+		{"cycle_count", 1200}, // float64 from JSON
+		{"sized", 7},
+		{"number", 42},
+		{"shift", 2},
+		{"vibration_level", -1}, // Not a whole number
+		{"huge", -1},            // Does not fit in an int
+		{"operator", -1},
+		{"calibrated", -1},
+		{"missing", -1},
+	} {
+		if got := data.Int(tc.key, -1); got != tc.want {
+			t.Errorf("Int(%s) = %d, want %d", tc.key, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		key  string
+		want float64
+	}{
+		{"vibration_level", 0.35},
+		{"cycle_count", 1200},
+		{"sized", 7},
+		{"shift", 2},
+		{"operator", -1},
+		{"nested", -1},
+	} {
+		if got := data.Float(tc.key, -1); got != tc.want {
+			t.Errorf("Float(%s) = %v, want %v", tc.key, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		key, want string
+	}{
+		{"operator", "ana"},
+		{"cycle_count", "1200"}, // Without a fraction
+		{"vibration_level", "0.35"},
+		{"calibrated", "true"},
+		{"number", "42"},
+		{"nested", "none"},
+		{"missing", "none"},
+	} {
+		if got := data.String(tc.key, "none"); got != tc.want {
+			t.Errorf("String(%s) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MetricFields are the SensorEvent metric fields that can be omitted per event type
+var MetricFields = []string{"conveyor_speed", "temperature", "robot_arm_angle"}
+
+// omittedMetrics maps an event type to the metric fields left out when it is serialized
+var omittedMetrics map[string]map[string]bool
+
+// SetOmittedMetrics configures which metric fields are left out of serialized events of
+// each type, so consumers are not sent misleading zeros for metrics a sensor does not
+// report. Event types without an entry emit every field. It must be called before
+// events are serialized.
+func SetOmittedMetrics(fields map[string][]string) error {
+	omitted := make(map[string]map[string]bool, len(fields))
+	for eventType, names := range fields {
+		omitted[eventType] = make(map[string]bool, len(names))
+		for _, name := range names {
+			if !isMetricField(name) {
+				return fmt.Errorf("unknown metric field %q for event type %q", name, eventType)
+			}
+			omitted[eventType][name] = true
+		}
+	}
+	omittedMetrics = omitted
+	return nil
+}
+
+// redactedRawData holds the raw_data keys left out when events are serialized
+var redactedRawData map[string]bool
+
+// SetRedactedRawData configures raw_data keys, such as internal codes, that are left out
+// of events served to clients. Stored events keep them. It must be called before events
+// are serialized.
+func SetRedactedRawData(keys []string) {
+	redacted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		redacted[key] = true
+	}
+	redactedRawData = redacted
+}
+
+// Redacted returns the data without the keys configured by SetRedactedRawData. The data
+// itself is returned when it holds none of them.
+func (d AdditionalData) Redacted() AdditionalData {
+	found := false
+	for key := range d {
+		if redactedRawData[key] {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return d
+	}
+
+	redacted := make(AdditionalData, len(d))
+	for key, value := range d {
+		if !redactedRawData[key] {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// MarshalJSON encodes the stored event with its redacted raw_data keys left out
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event // Drops the method set to avoid recursion
+
+	e.RawData = e.RawData.Redacted()
+	return json.Marshal(event(e))
+}
+
+// isMetricField reports whether name is one of MetricFields
+func isMetricField(name string) bool {
+	for _, field := range MetricFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON encodes the event, leaving out the metric fields configured as irrelevant
+// for its event type and the redacted additional data keys
+func (e SensorEvent) MarshalJSON() ([]byte, error) {
+	type sensorEvent SensorEvent // Drops the method set to avoid recursion
+
+	e.AdditionalData = e.AdditionalData.Redacted()
+	omit := omittedMetrics[e.EventType]
+	if len(omit) == 0 {
+		return json.Marshal(sensorEvent(e))
+	}
+
+	// The outer fields shadow the embedded ones; nil metrics are dropped by omitempty
+	out := struct {
+		sensorEvent
+		ConveyorSpeed *float64 `json:"conveyor_speed,omitempty"`
+		Temperature   *float64 `json:"temperature,omitempty"`
+		RobotArmAngle *float64 `json:"robot_arm_angle,omitempty"`
+	}{sensorEvent: sensorEvent(e)}
+
+	if !omit["conveyor_speed"] {
+		out.ConveyorSpeed = e.ConveyorSpeed
+	}
+	if !omit["temperature"] {
+		out.Temperature = e.Temperature
+	}
+	if !omit["robot_arm_angle"] {
+		out.RobotArmAngle = e.RobotArmAngle
+	}
+
+	return json.Marshal(out)
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventMarshalJSONRedactsRawData(t *testing.T) {
+	SetRedactedRawData([]string{"internal_code"})
+	t.Cleanup(func() { SetRedactedRawData(nil) })
+
+	temperature := 71.5
+	event := Event{
+		ID:          1,
+		MachineID:   "conveyor_001",
+		Temperature: &temperature,
+		RawData:     AdditionalData{"internal_code": "X-17", "vibration": 0.4},
+	}
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	rawData := decoded["raw_data"].(map[string]interface{})
+	if _, ok := rawData["internal_code"]; ok {
+		t.Errorf("raw_data = %v, want internal_code redacted", rawData)
+	}
+	if rawData["vibration"] != 0.4 {
+		t.Errorf("raw_data vibration = %v, want 0.4", rawData["vibration"])
+	}
+	if decoded["temperature"] != 71.5 || decoded["machine_id"] != "conveyor_001" {
+		t.Errorf("telemetry fields = %v, want them kept", decoded)
+	}
+	if _, ok := event.RawData["internal_code"]; !ok {
+		t.Error("redaction modified the event's own raw_data")
+	}
+}
+
+func TestSensorEventMarshalJSONRedactsAdditionalData(t *testing.T) {
+	SetRedactedRawData([]string{"internal_code"})
+	t.Cleanup(func() { SetRedactedRawData(nil) })
+
+	encoded, err := json.Marshal(SensorEvent{
+		MachineID:      "conveyor_001",
+		AdditionalData: AdditionalData{"internal_code": "X-17", "vibration": 0.4},
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded struct {
+		AdditionalData map[string]interface{} `json:"additional_data"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if _, ok := decoded.AdditionalData["internal_code"]; ok {
+		t.Errorf("additional_data = %v, want internal_code redacted", decoded.AdditionalData)
+	}
+	if decoded.AdditionalData["vibration"] != 0.4 {
+		t.Errorf("additional_data vibration = %v, want 0.4", decoded.AdditionalData["vibration"])
+	}
+}
+
+func TestRedactedWithoutRedactedKeysReturnsData(t *testing.T) {
+	SetRedactedRawData([]string{"internal_code"})
+	t.Cleanup(func() { SetRedactedRawData(nil) })
+
+	data := AdditionalData{"vibration": 0.4}
+	if redacted := data.Redacted(); len(redacted) != 1 || redacted["vibration"] != 0.4 {
+		t.Errorf("Redacted() = %v, want %v", redacted, data)
+	}
+}
+
+func TestSensorEventMarshalJSONOmitsMetricsPerEventType(t *testing.T) {
+	if err := SetOmittedMetrics(map[string][]string{"robot": {"conveyor_speed", "temperature"}}); err != nil {
+		t.Fatalf("SetOmittedMetrics: %v", err)
+	}
+	t.Cleanup(func() { SetOmittedMetrics(nil) })
+
+	zero, angle := 0.0, 45.0
+	for _, tc := range []struct {
+		eventType string
+		want      []string
+		omitted   []string
+	}{
+		{"robot", []string{"robot_arm_angle"}, []string{"conveyor_speed", "temperature"}},
+		{"conveyor", MetricFields, nil},
+	} {
+		encoded, err := json.Marshal(SensorEvent{MachineID: "cell_001", EventType: tc.eventType, Status: "ok", ConveyorSpeed: &zero, Temperature: &zero, RobotArmAngle: &angle})
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+
+		for _, field := range tc.want {
+			if _, ok := decoded[field]; !ok {
+				t.Errorf("%s event %s lacks %s", tc.eventType, encoded, field)
+			}
+		}
+		for _, field := range tc.omitted {
+			if _, ok := decoded[field]; ok {
+				t.Errorf("%s event %s includes omitted %s", tc.eventType, encoded, field)
+			}
+		}
+		if decoded["machine_id"] != "cell_001" || decoded["status"] != "ok" {
+			t.Errorf("%s event %s lost its other fields", tc.eventType, encoded)
+		}
+	}
+
+	if err := SetOmittedMetrics(map[string][]string{"robot": {"vibration"}}); err == nil {
+		t.Error("unknown metric field accepted")
+	}
+}
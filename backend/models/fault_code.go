@@ -0,0 +1,56 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// faultCodePattern matches codes of the form SUBSYSTEM_CONDITION_NNN, e.g. CONV_JAM_001
+var faultCodePattern = regexp.MustCompile(`^([A-Z]+)_([A-Z]+)_([0-9]+)$`)
+
+// faultSubsystems maps the known fault code prefixes to the subsystem they refer to
+var faultSubsystems = map[string]string{
+	"CONV":  "conveyor",
+	"TEMP":  "temperature",
+	"ROBOT": "robot_arm",
+	"MAINT": "maintenance",
+}
+
+// FaultCode is a machine-reported fault code broken into its parts
+type FaultCode struct {
+	Code      string `json:"code"`                // Canonical code; unknown codes are kept as reported
+	Subsystem string `json:"subsystem,omitempty"` // e.g. conveyor, for known codes
+	Condition string `json:"condition,omitempty"` // e.g. JAM, for known codes
+	Sequence  int    `json:"sequence,omitempty"`  // Numeric suffix, for known codes
+	Known     bool   `json:"known"`
+}
+
+// ParseFaultCode parses a fault code. Codes with a known subsystem prefix are
+// canonicalized to upper case; anything else is preserved as-is apart from surrounding
+// whitespace. It returns nil for an empty code.
+func ParseFaultCode(code string) *FaultCode {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil
+	}
+
+	parts := faultCodePattern.FindStringSubmatch(strings.ToUpper(code))
+	if parts == nil {
+		return &FaultCode{Code: code}
+	}
+
+	subsystem, known := faultSubsystems[parts[1]]
+	if !known {
+		return &FaultCode{Code: code}
+	}
+
+	sequence, _ := strconv.Atoi(parts[3])
+	return &FaultCode{
+		Code:      parts[0],
+		Subsystem: subsystem,
+		Condition: parts[2],
+		Sequence:  sequence,
+		Known:     true,
+	}
+}
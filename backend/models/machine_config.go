@@ -0,0 +1,27 @@
+package models
+
+import "fmt"
+
+// DefaultRatedSpeed is the nominal conveyor speed in m/s assumed for machines whose
+// config does not set rated_speed
+const DefaultRatedSpeed = 1.5
+
+// RatedSpeed returns the machine's rated (nominal) conveyor speed in m/s from the
+// rated_speed key of its config. Missing values yield DefaultRatedSpeed; values that are
+// not positive numbers yield DefaultRatedSpeed and an error describing the problem.
+func (m Machine) RatedSpeed() (float64, error) {
+	value, exists := m.Config["rated_speed"]
+	if !exists || value == nil {
+		return DefaultRatedSpeed, nil
+	}
+
+	speed, ok := value.(float64) // JSON numbers decode as float64
+	if !ok {
+		return DefaultRatedSpeed, fmt.Errorf("machine %s: rated_speed must be a number, got %T", m.MachineID, value)
+	}
+	if speed <= 0 {
+		return DefaultRatedSpeed, fmt.Errorf("machine %s: rated_speed must be positive, got %g", m.MachineID, speed)
+	}
+
+	return speed, nil
+}
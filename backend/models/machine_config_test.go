@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestRatedSpeedFromMachineConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  map[string]interface{}
+		want    float64
+		invalid bool
+	}{
+		{"configured", map[string]interface{}{"rated_speed": 2.4}, 2.4, false},
+		{"missing", map[string]interface{}{"zone": "A"}, DefaultRatedSpeed, false},
+		{"no config", nil, DefaultRatedSpeed, false},
+		{"null", map[string]interface{}{"rated_speed": nil}, DefaultRatedSpeed, false},
+		{"not a number", map[string]interface{}{"rated_speed": "fast"}, DefaultRatedSpeed, true},
+		{"zero", map[string]interface{}{"rated_speed": 0.0}, DefaultRatedSpeed, true},
+		{"negative", map[string]interface{}{"rated_speed": -1.0}, DefaultRatedSpeed, true},
+	} {
+		speed, err := Machine{MachineID: "conveyor_001", Config: tc.config}.RatedSpeed()
+		if speed != tc.want || (err != nil) != tc.invalid {
+			t.Errorf("%s: RatedSpeed() = %g, %v; want %g with error %t", tc.name, speed, err, tc.want, tc.invalid)
+		}
+	}
+}
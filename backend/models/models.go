@@ -1,33 +1,107 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
 // Event represents a sensor event from the database
 type Event struct {
-	ID              int                    `json:"id" db:"id"`
-	Timestamp       time.Time              `json:"timestamp" db:"timestamp"`
-	MachineID       string                 `json:"machine_id" db:"machine_id"`
-	SensorType      string                 `json:"sensor_type" db:"sensor_type"`
-	ConveyorSpeed   *float64               `json:"conveyor_speed" db:"conveyor_speed"`
-	Temperature     *float64               `json:"temperature" db:"temperature"`
-	RobotArmAngle   *float64               `json:"robot_arm_angle" db:"robot_arm_angle"`
-	Status          string                 `json:"status" db:"status"`
-	RawData         map[string]interface{} `json:"raw_data" db:"raw_data"`
-	CreatedAt       time.Time              `json:"created_at" db:"created_at"`
+	ID            int            `json:"id" db:"id"`
+	Timestamp     time.Time      `json:"timestamp" db:"timestamp"`
+	MachineID     string         `json:"machine_id" db:"machine_id"`
+	SensorType    string         `json:"sensor_type" db:"sensor_type"`
+	ConveyorSpeed *float64       `json:"conveyor_speed" db:"conveyor_speed"`
+	Temperature   *float64       `json:"temperature" db:"temperature"`
+	RobotArmAngle *float64       `json:"robot_arm_angle" db:"robot_arm_angle"`
+	Status        string         `json:"status" db:"status"`
+	Line          string         `json:"line" db:"line"`
+	FaultCode     *string        `json:"fault_code" db:"fault_code"`
+	RawData       AdditionalData `json:"raw_data" db:"raw_data"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	Warnings      []string       `json:"warnings,omitempty" db:"-"`
+}
+
+// SensorEvent converts a stored event back into the sensor event it was recorded from
+func (e Event) SensorEvent() *SensorEvent {
+	event := &SensorEvent{
+		ID:             e.ID,
+		Timestamp:      e.Timestamp,
+		MachineID:      e.MachineID,
+		ConveyorSpeed:  e.ConveyorSpeed,
+		Temperature:    e.Temperature,
+		RobotArmAngle:  e.RobotArmAngle,
+		Status:         e.Status,
+		EventType:      e.SensorType,
+		Line:           e.Line,
+		AdditionalData: e.RawData,
+	}
+	if e.FaultCode != nil {
+		event.FaultCode = *e.FaultCode
+	}
+	return event
 }
 
 // Alert represents an alert in the system
 type Alert struct {
-	ID              int       `json:"id" db:"id"`
-	EventID         *int      `json:"event_id" db:"event_id"`
-	AlertType       string    `json:"alert_type" db:"alert_type"`
-	Severity        string    `json:"severity" db:"severity"`
-	Message         string    `json:"message" db:"message"`
-	Acknowledged    bool      `json:"acknowledged" db:"acknowledged"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	AcknowledgedAt  *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	ID                  int        `json:"id" db:"id"`
+	EventID             *int       `json:"event_id" db:"event_id"`
+	MachineID           string     `json:"machine_id" db:"machine_id"`
+	AlertType           string     `json:"alert_type" db:"alert_type"`
+	Severity            string     `json:"severity" db:"severity"`
+	Message             string     `json:"message" db:"message"`
+	Confidence          *float64   `json:"confidence,omitempty" db:"confidence"`                 // 0-1 signal strength of trend/pattern alerts
+	RecommendedAction   *string    `json:"recommended_action,omitempty" db:"recommended_action"` // Operator guidance for status alerts
+	Acknowledged        bool       `json:"acknowledged" db:"acknowledged"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	AcknowledgedAt      *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	AcknowledgedBy      *string    `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgementNote *string    `json:"acknowledgement_note" db:"acknowledgement_note"`
+	ResolvedAt          *time.Time `json:"resolved_at" db:"resolved_at"` // Set when the condition cleared
+	Test                bool       `json:"test" db:"test"`               // Synthetic alert fired to verify delivery; excluded from metrics
+}
+
+// AlertWithEvent is an alert together with the event that triggered it. Event is null for
+// alerts that are not linked to an event.
+type AlertWithEvent struct {
+	Alert
+	Event *Event `json:"event"`
+}
+
+// AlertResolution reports that the condition behind a machine's alerts has cleared
+type AlertResolution struct {
+	MachineID  string    `json:"machine_id"`
+	AlertType  string    `json:"alert_type"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// AlertSnooze suppresses new alerts of one type for a machine until it expires
+type AlertSnooze struct {
+	MachineID    string    `json:"machine_id" db:"machine_id"`
+	AlertType    string    `json:"alert_type" db:"alert_type"`
+	SnoozedUntil time.Time `json:"snoozed_until" db:"snoozed_until"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// SeverityLevels ranks the valid alert severities from least to most severe
+var SeverityLevels = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+// AuditEntry records who changed what and when, with the values before and after
+type AuditEntry struct {
+	ID        int64           `json:"id" db:"id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	Actor     string          `json:"actor" db:"actor"`         // Operator named by the request, if any
+	ClientIP  string          `json:"client_ip" db:"client_ip"` // Address the change came from
+	Action    string          `json:"action" db:"action"`       // e.g. thresholds.update
+	Target    string          `json:"target" db:"target"`       // What was changed, e.g. an alert or parameter
+	OldValue  json.RawMessage `json:"old_value" db:"old_value"`
+	NewValue  json.RawMessage `json:"new_value" db:"new_value"`
 }
 
 // ProcessParameter represents a configurable process parameter
@@ -46,31 +120,87 @@ type Machine struct {
 	MachineID   string                 `json:"machine_id" db:"machine_id"`
 	MachineType string                 `json:"machine_type" db:"machine_type"`
 	Location    string                 `json:"location" db:"location"`
+	Area        string                 `json:"area" db:"area"` // Plant area grouping, e.g. assembly
+	Line        string                 `json:"line" db:"line"` // Production line within the area
 	Status      string                 `json:"status" db:"status"`
 	Config      map[string]interface{} `json:"config" db:"config"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
 }
 
-// SensorEvent represents incoming sensor data from Kafka
+// ReprocessResult reports a replay of a machine's stored events through the current
+// detection rules
+type ReprocessResult struct {
+	MachineID         string    `json:"machine_id"`
+	Since             time.Time `json:"since"`
+	Until             time.Time `json:"until"`
+	EventsReplayed    int       `json:"events_replayed"`
+	AlertsDetected    int       `json:"alerts_detected"`
+	AlertsInserted    int       `json:"alerts_inserted"`
+	DuplicatesSkipped int       `json:"duplicates_skipped"` // Matched an alert already stored
+}
+
+// MachineReliability summarizes how often a machine faults and how quickly its alerts are
+// handled over a period. Means are in seconds and null when there is nothing to average.
+type MachineReliability struct {
+	MachineID string    `json:"machine_id"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+
+	// A fault onset is a fault event whose preceding event was not a fault, so a run of
+	// consecutive fault readings counts once. MTBF is the mean gap between onsets.
+	FaultOnsets                  int      `json:"fault_onsets"`
+	MeanTimeBetweenFaultsSeconds *float64 `json:"mean_time_between_faults_seconds"`
+
+	// Time from an alert's creation to its acknowledgement or resolution, over alerts
+	// created in the period (test alerts excluded)
+	AlertsAcknowledged           int      `json:"alerts_acknowledged"`
+	MeanTimeToAcknowledgeSeconds *float64 `json:"mean_time_to_acknowledge_seconds"`
+	AlertsResolved               int      `json:"alerts_resolved"`
+	MeanTimeToResolveSeconds     *float64 `json:"mean_time_to_resolve_seconds"`
+}
+
+// SeenMachine is a machine ID found in the events table, registered or not
+type SeenMachine struct {
+	MachineID  string    `json:"machine_id" db:"machine_id"`
+	FirstSeen  time.Time `json:"first_seen" db:"first_seen"`
+	LastSeen   time.Time `json:"last_seen" db:"last_seen"`
+	Registered bool      `json:"registered" db:"registered"` // Present in the machines table
+}
+
+// SensorEvent represents incoming sensor data from Kafka. Metrics are nil when the
+// sensor did not report them (null or absent in the payload) and are stored as NULL.
 type SensorEvent struct {
-	Timestamp      time.Time              `json:"timestamp"`
-	MachineID      string                 `json:"machine_id"`
-	ConveyorSpeed  float64                `json:"conveyor_speed"`
-	Temperature    float64                `json:"temperature"`
-	RobotArmAngle  float64                `json:"robot_arm_angle"`
-	Status         string                 `json:"status"`
-	EventType      string                 `json:"event_type"`
-	AdditionalData map[string]interface{} `json:"additional_data,omitempty"`
+	ID             int            `json:"id,omitempty"` // Assigned once the event is stored
+	Timestamp      time.Time      `json:"timestamp"`
+	MachineID      string         `json:"machine_id"`
+	ConveyorSpeed  *float64       `json:"conveyor_speed"`
+	Temperature    *float64       `json:"temperature"`
+	RobotArmAngle  *float64       `json:"robot_arm_angle"`
+	Status         string         `json:"status"`
+	EventType      string         `json:"event_type"`
+	Line           string         `json:"line,omitempty"`
+	FaultCode      string         `json:"fault_code,omitempty"` // Parsed from additional_data.fault_code when not set
+	MachineType    string         `json:"machine_type,omitempty"`
+	Location       string         `json:"location,omitempty"`
+	AdditionalData AdditionalData `json:"additional_data,omitempty"`
 }
 
-// WebSocketMessage represents a message sent to WebSocket clients
+// WebSocketMessage represents a message sent to WebSocket clients. The hub adds a "seq"
+// field numbering the messages of each connection, so clients can detect dropped messages.
 type WebSocketMessage struct {
 	Type      string      `json:"type"`
 	Data      interface{} `json:"data"`
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// AnomalyRule is a detection rule of the anomaly detector and whether it is enabled
+type AnomalyRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
 // AnomalyThresholds defines thresholds for anomaly detection
 type AnomalyThresholds struct {
 	ConveyorSpeedMin float64 `json:"conveyor_speed_min"`
@@ -79,15 +209,124 @@ type AnomalyThresholds struct {
 	TemperatureMax   float64 `json:"temperature_max"`
 	RobotAngleMin    float64 `json:"robot_angle_min"`
 	RobotAngleMax    float64 `json:"robot_angle_max"`
+
+	// Severities of the alerts raised when each threshold is crossed. Empty ones are left
+	// unchanged when thresholds are updated.
+	ConveyorSpeedSeverity   string `json:"conveyor_speed_severity"`
+	TemperatureLowSeverity  string `json:"temperature_low_severity"`
+	TemperatureHighSeverity string `json:"temperature_high_severity"`
+	RobotAngleSeverity      string `json:"robot_angle_severity"`
+}
+
+// Validate checks that each threshold range is well-formed and each severity, if set, is
+// a known severity level
+func (t *AnomalyThresholds) Validate() error {
+	if t.ConveyorSpeedMin < 0 || t.ConveyorSpeedMax <= t.ConveyorSpeedMin {
+		return fmt.Errorf("invalid conveyor speed thresholds")
+	}
+	if t.TemperatureMax <= t.TemperatureMin {
+		return fmt.Errorf("invalid temperature thresholds")
+	}
+	for field, severity := range t.severities() {
+		if *severity != "" && SeverityLevels[*severity] == 0 {
+			return fmt.Errorf("invalid %s %q: expected low, medium, high or critical", field, *severity)
+		}
+	}
+	return nil
+}
+
+// FillSeverities sets each severity left empty to the one in from
+func (t *AnomalyThresholds) FillSeverities(from *AnomalyThresholds) {
+	inherited := from.severities()
+	for field, severity := range t.severities() {
+		if *severity == "" {
+			*severity = *inherited[field]
+		}
+	}
+}
+
+// severities returns pointers to the threshold severities by JSON field name
+func (t *AnomalyThresholds) severities() map[string]*string {
+	return map[string]*string{
+		"conveyor_speed_severity":   &t.ConveyorSpeedSeverity,
+		"temperature_low_severity":  &t.TemperatureLowSeverity,
+		"temperature_high_severity": &t.TemperatureHighSeverity,
+		"robot_angle_severity":      &t.RobotAngleSeverity,
+	}
 }
 
 // EventStats represents aggregated event statistics
 type EventStats struct {
-	TotalEvents    int64   `json:"total_events"`
-	FaultEvents    int64   `json:"fault_events"`
-	WarningEvents  int64   `json:"warning_events"`
-	AvgTemperature float64 `json:"avg_temperature"`
-	AvgConveyorSpeed float64 `json:"avg_conveyor_speed"`
-	UptimePercent  float64 `json:"uptime_percent"`
-	LastEventTime  time.Time `json:"last_event_time"`
-}
\ No newline at end of file
+	TotalEvents      int64     `json:"total_events"`
+	FaultEvents      int64     `json:"fault_events"`
+	WarningEvents    int64     `json:"warning_events"`
+	IdleEvents       int64     `json:"idle_events"` // Events with an idle status, left out of uptime
+	AvgTemperature   float64   `json:"avg_temperature"`
+	AvgConveyorSpeed float64   `json:"avg_conveyor_speed"`
+	P95Temperature   *float64  `json:"p95_temperature"` // Nil when no event in the period reported the metric
+	P99Temperature   *float64  `json:"p99_temperature"`
+	P95ConveyorSpeed *float64  `json:"p95_conveyor_speed"`
+	P99ConveyorSpeed *float64  `json:"p99_conveyor_speed"`
+	UptimePercent    float64   `json:"uptime_percent"`
+	LastEventTime    time.Time `json:"last_event_time"`
+}
+
+// ConvertTemperatures converts the Celsius temperature aggregates into unit for display
+func (s *EventStats) ConvertTemperatures(unit TemperatureUnit) {
+	s.AvgTemperature = unit.FromCelsius(s.AvgTemperature)
+	for _, temperature := range []*float64{s.P95Temperature, s.P99Temperature} {
+		if temperature != nil {
+			*temperature = unit.FromCelsius(*temperature)
+		}
+	}
+}
+
+// ComputeUptime sets UptimePercent to the share of non-idle events not reporting downtime:
+// faults, and warnings too when countWarnings is set. The result is clamped to [0, 100] so
+// inconsistent counts cannot produce a nonsensical percentage; with no non-idle events it is 0.
+func (s *EventStats) ComputeUptime(countWarnings bool) {
+	counted := s.TotalEvents - s.IdleEvents
+	if counted <= 0 {
+		s.UptimePercent = 0
+		return
+	}
+
+	downtimeEvents := s.FaultEvents
+	if countWarnings {
+		downtimeEvents += s.WarningEvents
+	}
+	uptime := float64(counted-downtimeEvents) / float64(counted) * 100
+	s.UptimePercent = min(max(uptime, 0), 100)
+}
+
+// ConsumerLag represents the Kafka consumer group lag for a single partition
+type ConsumerLag struct {
+	Topic           string `json:"topic"`
+	Partition       int32  `json:"partition"`
+	CommittedOffset int64  `json:"committed_offset"`
+	LogEndOffset    int64  `json:"log_end_offset"`
+	Lag             int64  `json:"lag"`
+}
+
+// TopicThroughput counts the messages and bytes consumed from a Kafka topic since startup
+type TopicThroughput struct {
+	Topic    string `json:"topic"`
+	Messages int64  `json:"messages"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// RawDataStats represents aggregates of a numeric raw_data field
+type RawDataStats struct {
+	Field       string   `json:"field"`
+	SampleCount int64    `json:"sample_count"`
+	Avg         *float64 `json:"avg"`
+	Min         *float64 `json:"min"`
+	Max         *float64 `json:"max"`
+}
+
+// AlertTrendBucket represents the number of alerts of one severity within a time bucket
+type AlertTrendBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	Severity    string    `json:"severity"`
+	Count       int64     `json:"count"`
+}
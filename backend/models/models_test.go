@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestComputeUptime(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		stats         EventStats
+		countWarnings bool
+		want          float64
+	}{
+		{"warnings count as uptime", EventStats{TotalEvents: 10, FaultEvents: 1, WarningEvents: 2}, false, 90},
+		{"warnings count against uptime", EventStats{TotalEvents: 10, FaultEvents: 1, WarningEvents: 2}, true, 70},
+		{"idle events left out", EventStats{TotalEvents: 10, FaultEvents: 1, IdleEvents: 5}, false, 80},
+		{"only idle events", EventStats{TotalEvents: 4, IdleEvents: 4}, false, 0},
+		{"no events", EventStats{}, true, 0},
+		{"more downtime than events clamped to 0", EventStats{TotalEvents: 3, FaultEvents: 2, WarningEvents: 4}, true, 0},
+		{"negative fault count clamped to 100", EventStats{TotalEvents: 3, FaultEvents: -2}, false, 100},
+	} {
+		stats := tc.stats
+		stats.ComputeUptime(tc.countWarnings)
+		if stats.UptimePercent != tc.want {
+			t.Errorf("%s: uptime = %v, want %v", tc.name, stats.UptimePercent, tc.want)
+		}
+	}
+}
+
+func TestThresholdSeveritiesValidated(t *testing.T) {
+	thresholds := AnomalyThresholds{ConveyorSpeedMax: 5, TemperatureMax: 85, RobotAngleSeverity: "high"}
+	if err := thresholds.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	thresholds.RobotAngleSeverity = "urgent"
+	if err := thresholds.Validate(); err == nil {
+		t.Error("unknown robot angle severity accepted")
+	}
+}
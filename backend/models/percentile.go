@@ -0,0 +1,23 @@
+package models
+
+import (
+	"math"
+	"slices"
+)
+
+// Percentile returns the p-th percentile (0 to 1) of values, interpolating linearly
+// between the closest ranks like Postgres percentile_cont. It returns nil for no values.
+func Percentile(values []float64, p float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	rank := min(max(p, 0), 1) * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	result := sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+	return &result
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentileInterpolatesBetweenRanks(t *testing.T) {
+	// 1 to 100, out of order
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64((i*37)%100 + 1)
+	}
+
+	for _, tc := range []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{0.5, 50.5},
+		{0.95, 95.05},
+		{0.99, 99.01},
+		{1, 100},
+		{1.5, 100}, // Clamped
+	} {
+		got := Percentile(values, tc.p)
+		if got == nil || math.Abs(*got-tc.want) > 1e-9 {
+			t.Errorf("Percentile(%g) = %v, want %g", tc.p, got, tc.want)
+		}
+	}
+	if values[0] != 1 || values[1] != 38 {
+		t.Error("Percentile reordered its input")
+	}
+}
+
+func TestPercentileOfFewValues(t *testing.T) {
+	if got := Percentile(nil, 0.95); got != nil {
+		t.Errorf("Percentile of no values = %g, want nil", *got)
+	}
+	if got := Percentile([]float64{42}, 0.99); got == nil || *got != 42 {
+		t.Errorf("Percentile of one value = %v, want 42", got)
+	}
+	if got := Percentile([]float64{10, 20}, 0.95); got == nil || math.Abs(*got-19.5) > 1e-9 {
+		t.Errorf("Percentile of two values = %v, want 19.5", got)
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TemperatureUnit identifies a temperature scale. Temperatures are stored in Celsius;
+// other units are applied only at the ingest and display boundaries.
+type TemperatureUnit string
+
+const (
+	Celsius    TemperatureUnit = "C"
+	Fahrenheit TemperatureUnit = "F"
+)
+
+// ParseTemperatureUnit parses "C"/"celsius" or "F"/"fahrenheit", case-insensitively
+func ParseTemperatureUnit(value string) (TemperatureUnit, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "c", "celsius":
+		return Celsius, nil
+	case "f", "fahrenheit":
+		return Fahrenheit, nil
+	default:
+		return "", fmt.Errorf("unknown temperature unit %q (expected C or F)", value)
+	}
+}
+
+// FromCelsius converts a Celsius temperature into this unit
+func (u TemperatureUnit) FromCelsius(celsius float64) float64 {
+	if u == Fahrenheit {
+		return celsius*9/5 + 32
+	}
+	return celsius
+}
+
+// ToCelsius converts a temperature in this unit into Celsius
+func (u TemperatureUnit) ToCelsius(value float64) float64 {
+	if u == Fahrenheit {
+		return (value - 32) * 5 / 9
+	}
+	return value
+}
+
+// Symbol returns the display symbol for the unit
+func (u TemperatureUnit) Symbol() string {
+	if u == Fahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
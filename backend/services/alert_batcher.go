@@ -0,0 +1,216 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// alertBacklogLimit bounds the alerts and resolutions held while the database is
+// failing; beyond it the oldest are dropped
+const alertBacklogLimit = 10000
+
+// batchEntry is a queued alert to store or resolution to apply
+type batchEntry struct {
+	alert      *models.Alert
+	resolution *models.AlertResolution
+}
+
+// AlertBatcher buffers alerts and stores them in batches, flushing when size alerts are
+// pending or every interval, so an alert storm costs one write per batch rather than one
+// per alert. Alerts are handed to onStored, e.g. for broadcast, only once stored, so they
+// carry their IDs. Resolutions are applied in order with the alerts, after the alerts
+// raised before them are stored. Writes run on the batcher's goroutine, never the
+// caller's; a failed write is kept and retried on the next flush.
+type AlertBatcher struct {
+	store      database.Store
+	size       int
+	interval   time.Duration
+	onStored   func(*models.Alert)
+	onResolved func(*models.AlertResolution)
+
+	mutex   sync.Mutex
+	pending []batchEntry
+	alerts  int // Alerts among pending
+	stopped bool
+
+	flushMutex sync.Mutex    // Serializes flushes so entries are written in the order queued
+	flushNow   chan struct{} // Wakes the flush loop when a batch fills or a resolution is queued
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewAlertBatcher creates a batcher storing up to size alerts per write. An interval of 0
+// flushes only when a batch fills or a resolution is queued; a size of 1 stores each
+// alert as it is added. onStored and onResolved are called after each write succeeds.
+func NewAlertBatcher(store database.Store, size int, interval time.Duration,
+	onStored func(*models.Alert), onResolved func(*models.AlertResolution)) *AlertBatcher {
+	return &AlertBatcher{
+		store:      store,
+		size:       max(size, 1),
+		interval:   interval,
+		onStored:   onStored,
+		onResolved: onResolved,
+		flushNow:   make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start launches the flush loop
+func (b *AlertBatcher) Start() {
+	go func() {
+		defer close(b.done)
+
+		var tick <-chan time.Time
+		if b.interval > 0 {
+			ticker := time.NewTicker(b.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-tick:
+				b.Flush()
+			case <-b.flushNow:
+				b.Flush()
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Add queues a copy of an alert for storage, waking the flush loop if the batch is full.
+// Once the batcher has stopped the alert is stored immediately.
+func (b *AlertBatcher) Add(alert *models.Alert) {
+	queued := *alert
+	b.enqueue(batchEntry{alert: &queued}, func() bool { return b.alerts >= b.size })
+}
+
+// Resolve queues a resolution, applied once the alerts queued before it are stored. The
+// flush loop is woken so the resolution is not held for an interval.
+func (b *AlertBatcher) Resolve(resolution *models.AlertResolution) {
+	queued := *resolution
+	b.enqueue(batchEntry{resolution: &queued}, func() bool { return true })
+}
+
+// enqueue appends an entry, then wakes the flush loop if due reports it should run, or
+// flushes in place once the batcher has stopped
+func (b *AlertBatcher) enqueue(entry batchEntry, due func() bool) {
+	b.mutex.Lock()
+	b.pending = append(b.pending, entry)
+	if entry.alert != nil {
+		b.alerts++
+	}
+	flush, stopped := due(), b.stopped
+	b.mutex.Unlock()
+
+	if stopped {
+		b.Flush()
+		return
+	}
+	if flush {
+		select {
+		case b.flushNow <- struct{}{}:
+		default: // A flush is already due
+		}
+	}
+}
+
+// Flush writes the pending entries in order: runs of alerts in batches of up to size,
+// and resolutions between them. It stops at the first failed write, keeping that entry
+// and those after it pending for the next flush.
+func (b *AlertBatcher) Flush() {
+	b.flushMutex.Lock()
+	defer b.flushMutex.Unlock()
+
+	b.mutex.Lock()
+	entries := b.pending
+	b.pending, b.alerts = nil, 0
+	b.mutex.Unlock()
+
+	for len(entries) > 0 {
+		written, err := b.write(entries)
+		entries = entries[written:]
+		if err != nil {
+			log.Printf("Failed to store alerts, %d pending writes kept for retry: %v", len(entries), err)
+			b.requeue(entries)
+			return
+		}
+	}
+}
+
+// write writes the leading resolution or run of alerts of entries, returning how many
+// entries it wrote
+func (b *AlertBatcher) write(entries []batchEntry) (int, error) {
+	if resolution := entries[0].resolution; resolution != nil {
+		if err := b.store.ResolveAlerts(resolution); err != nil {
+			return 0, err
+		}
+		log.Printf("Alert resolved: %s - %s", resolution.MachineID, resolution.AlertType)
+		if b.onResolved != nil {
+			b.onResolved(resolution)
+		}
+		return 1, nil
+	}
+
+	var batch []*models.Alert
+	for _, entry := range entries {
+		if entry.alert == nil || len(batch) == b.size {
+			break
+		}
+		batch = append(batch, entry.alert)
+	}
+	if err := b.store.InsertAlertsBatch(batch); err != nil {
+		return 0, err
+	}
+	for _, alert := range batch {
+		log.Printf("Alert created: %d %s - %s", alert.ID, alert.AlertType, alert.Message)
+		if b.onStored != nil {
+			b.onStored(alert)
+		}
+	}
+	return len(batch), nil
+}
+
+// requeue puts unwritten entries back ahead of those queued since, dropping the oldest
+// beyond the backlog limit
+func (b *AlertBatcher) requeue(entries []batchEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.pending = append(append([]batchEntry(nil), entries...), b.pending...)
+	if excess := len(b.pending) - alertBacklogLimit; excess > 0 {
+		log.Printf("Alert backlog full, dropping the %d oldest pending alerts and resolutions", excess)
+		b.pending = b.pending[excess:]
+	}
+
+	b.alerts = 0
+	for _, entry := range b.pending {
+		if entry.alert != nil {
+			b.alerts++
+		}
+	}
+}
+
+// Pending returns the number of alerts and resolutions not yet written
+func (b *AlertBatcher) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.pending)
+}
+
+// Stop ends the flush loop and writes the pending entries. Alerts and resolutions queued
+// afterwards are written immediately.
+func (b *AlertBatcher) Stop() {
+	b.mutex.Lock()
+	b.stopped = true
+	b.mutex.Unlock()
+
+	close(b.stop)
+	<-b.done
+	b.Flush()
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyStore fails the next failures alert writes, then passes them to the memory store
+type flakyStore struct {
+	database.Store
+	failures int
+}
+
+func (s *flakyStore) InsertAlertsBatch(alerts []*models.Alert) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	return s.Store.InsertAlertsBatch(alerts)
+}
+
+func (s *flakyStore) ResolveAlerts(resolution *models.AlertResolution) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	return s.Store.ResolveAlerts(resolution)
+}
+
+// batchRecorder collects the alerts and resolutions a batcher reports written
+type batchRecorder struct {
+	mutex       sync.Mutex
+	stored      []models.Alert
+	resolutions []models.AlertResolution
+}
+
+func (r *batchRecorder) alert(alert *models.Alert) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stored = append(r.stored, *alert)
+}
+
+func (r *batchRecorder) resolve(resolution *models.AlertResolution) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resolutions = append(r.resolutions, *resolution)
+}
+
+func (r *batchRecorder) storedCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.stored)
+}
+
+func newTestBatcher(store database.Store, size int) (*AlertBatcher, *batchRecorder) {
+	recorder := &batchRecorder{}
+	return NewAlertBatcher(store, size, 0, recorder.alert, recorder.resolve), recorder
+}
+
+func testAlert(machineID, alertType string) *models.Alert {
+	return &models.Alert{MachineID: machineID, AlertType: alertType, Severity: "high", Message: alertType}
+}
+
+func TestAlertBatcherReportsStoredAlertsWithIDs(t *testing.T) {
+	batcher, recorder := newTestBatcher(database.NewMemoryStore(), 10)
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Add(testAlert("M-2", "speed"))
+
+	if got := recorder.storedCount(); got != 0 {
+		t.Fatalf("reported %d alerts before they were stored", got)
+	}
+	batcher.Flush()
+
+	if len(recorder.stored) != 2 {
+		t.Fatalf("reported %d stored alerts, want 2", len(recorder.stored))
+	}
+	for i, alert := range recorder.stored {
+		if alert.ID != i+1 || alert.CreatedAt.IsZero() {
+			t.Errorf("alert %d reported with ID %d and created_at %v, want ID %d and a created_at", i, alert.ID, alert.CreatedAt, i+1)
+		}
+	}
+}
+
+func TestAlertBatcherKeepsFailedAlertsForRetry(t *testing.T) {
+	memory := database.NewMemoryStore()
+	store := &flakyStore{Store: memory, failures: 1}
+	batcher, recorder := newTestBatcher(store, 10)
+
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Flush()
+	if got := recorder.storedCount(); got != 0 {
+		t.Fatalf("reported %d alerts after a failed write", got)
+	}
+	if got := batcher.Pending(); got != 1 {
+		t.Fatalf("%d writes pending after a failed write, want 1", got)
+	}
+
+	batcher.Add(testAlert("M-1", "speed"))
+	batcher.Flush()
+	if got := batcher.Pending(); got != 0 {
+		t.Fatalf("%d writes pending after a successful flush, want 0", got)
+	}
+	if len(recorder.stored) != 2 || recorder.stored[0].AlertType != "temperature" || recorder.stored[1].AlertType != "speed" {
+		t.Fatalf("stored alerts %+v, want temperature then speed", recorder.stored)
+	}
+	alerts, err := memory.GetUnacknowledgedAlerts(nil, "")
+	if err != nil {
+		t.Fatalf("reading alerts: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Errorf("store holds %d alerts, want 2", len(alerts))
+	}
+}
+
+func TestAlertBatcherResolvesAfterStoringEarlierAlerts(t *testing.T) {
+	memory := database.NewMemoryStore()
+	batcher, recorder := newTestBatcher(memory, 10)
+
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Resolve(&models.AlertResolution{MachineID: "M-1", AlertType: "temperature", ResolvedAt: time.Now()})
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Flush()
+
+	if len(recorder.stored) != 2 || len(recorder.resolutions) != 1 {
+		t.Fatalf("reported %d alerts and %d resolutions, want 2 and 1", len(recorder.stored), len(recorder.resolutions))
+	}
+	first, err := memory.GetAlert(recorder.stored[0].ID)
+	if err != nil {
+		t.Fatalf("reading first alert: %v", err)
+	}
+	if first.ResolvedAt == nil {
+		t.Error("alert raised before the resolution was not resolved")
+	}
+	second, err := memory.GetAlert(recorder.stored[1].ID)
+	if err != nil {
+		t.Fatalf("reading second alert: %v", err)
+	}
+	if second.ResolvedAt != nil {
+		t.Error("alert raised after the resolution was resolved")
+	}
+}
+
+func TestAlertBatcherKeepsFailedResolutionOrder(t *testing.T) {
+	memory := database.NewMemoryStore()
+	store := &flakyStore{Store: memory}
+	batcher, recorder := newTestBatcher(store, 10)
+
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Flush()
+	store.failures = 1
+	batcher.Resolve(&models.AlertResolution{MachineID: "M-1", AlertType: "temperature", ResolvedAt: time.Now()})
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Flush()
+
+	if len(recorder.resolutions) != 0 || len(recorder.stored) != 1 {
+		t.Fatalf("writes after a failed resolution were not held: %d resolutions, %d alerts", len(recorder.resolutions), len(recorder.stored))
+	}
+	batcher.Flush()
+
+	if len(recorder.resolutions) != 1 || len(recorder.stored) != 2 {
+		t.Fatalf("reported %d resolutions and %d alerts after retry, want 1 and 2", len(recorder.resolutions), len(recorder.stored))
+	}
+	latest, err := memory.GetAlert(recorder.stored[1].ID)
+	if err != nil {
+		t.Fatalf("reading latest alert: %v", err)
+	}
+	if latest.ResolvedAt != nil {
+		t.Error("alert raised after the retried resolution was resolved")
+	}
+}
+
+func TestAlertBatcherFlushesFullBatchInBackground(t *testing.T) {
+	batcher, recorder := newTestBatcher(database.NewMemoryStore(), 2)
+	batcher.Start()
+	defer batcher.Stop()
+
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Add(testAlert("M-2", "temperature"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for recorder.storedCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("full batch not flushed, %d alerts reported", recorder.storedCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAlertBatcherStopFlushesPending(t *testing.T) {
+	batcher, recorder := newTestBatcher(database.NewMemoryStore(), 10)
+	batcher.Start()
+	batcher.Add(testAlert("M-1", "temperature"))
+	batcher.Stop()
+
+	if got := recorder.storedCount(); got != 1 {
+		t.Fatalf("reported %d alerts after stop, want 1", got)
+	}
+	batcher.Add(testAlert("M-2", "temperature"))
+	if got := recorder.storedCount(); got != 2 {
+		t.Fatalf("alert added after stop was not stored immediately, %d reported", got)
+	}
+}
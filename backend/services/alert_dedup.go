@@ -0,0 +1,67 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// alertDeduper suppresses alerts identical to one emitted within the window. Alerts are
+// identified by a hash of their machine, type and message, so repeats of the same
+// trend or pattern message are caught while alerts with different details still pass.
+type alertDeduper struct {
+	window     time.Duration
+	lastSent   map[[sha256.Size]byte]time.Time
+	lastPrune  time.Time
+	suppressed uint64
+	mutex      sync.Mutex
+}
+
+// newAlertDeduper creates a deduper, or returns nil when window disables deduplication
+func newAlertDeduper(window time.Duration) *alertDeduper {
+	if window <= 0 {
+		return nil
+	}
+	return &alertDeduper{
+		window:   window,
+		lastSent: make(map[[sha256.Size]byte]time.Time),
+	}
+}
+
+// allow reports whether an alert may be emitted at now, counting it as a suppressed
+// duplicate if not
+func (d *alertDeduper) allow(alert *models.Alert, now time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.prune(now)
+
+	key := sha256.Sum256([]byte(alert.MachineID + "\x00" + alert.AlertType + "\x00" + alert.Message))
+	if sent, ok := d.lastSent[key]; ok && now.Sub(sent) < d.window {
+		d.suppressed++
+		return false
+	}
+	d.lastSent[key] = now
+	return true
+}
+
+// prune drops expired hashes, at most once per window. The caller must hold d.mutex.
+func (d *alertDeduper) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.window {
+		return
+	}
+	for key, sent := range d.lastSent {
+		if now.Sub(sent) >= d.window {
+			delete(d.lastSent, key)
+		}
+	}
+	d.lastPrune = now
+}
+
+// suppressedCount returns how many duplicate alerts have been suppressed
+func (d *alertDeduper) suppressedCount() uint64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.suppressed
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestAlertDeduperSuppressesIdenticalMessagesWithinWindow(t *testing.T) {
+	deduper := newAlertDeduper(time.Minute)
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	alert := func(machineID, alertType, message string) *models.Alert {
+		return &models.Alert{MachineID: machineID, AlertType: alertType, Message: message}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		alert   *models.Alert
+		at      time.Duration
+		allowed bool
+	}{
+		{"first", alert("conveyor_001", "temperature_trend", "Temperature rising 3.0°C/event"), 0, true},
+		{"identical", alert("conveyor_001", "temperature_trend", "Temperature rising 3.0°C/event"), 10 * time.Second, false},
+		{"different message", alert("conveyor_001", "temperature_trend", "Temperature rising 4.5°C/event"), 20 * time.Second, true},
+		{"different type", alert("conveyor_001", "speed_trend", "Temperature rising 3.0°C/event"), 20 * time.Second, true},
+		{"different machine", alert("conveyor_002", "temperature_trend", "Temperature rising 3.0°C/event"), 20 * time.Second, true},
+		{"identical after the window", alert("conveyor_001", "temperature_trend", "Temperature rising 3.0°C/event"), 61 * time.Second, true},
+	} {
+		if allowed := deduper.allow(tc.alert, start.Add(tc.at)); allowed != tc.allowed {
+			t.Errorf("%s: allowed = %v, want %v", tc.name, allowed, tc.allowed)
+		}
+	}
+
+	if suppressed := deduper.suppressedCount(); suppressed != 1 {
+		t.Errorf("suppressed = %d, want the one identical alert", suppressed)
+	}
+	if newAlertDeduper(0) != nil {
+		t.Error("deduper created with deduplication disabled")
+	}
+}
+
+func TestDetectorDedupCountsSuppressedAlerts(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.DedupWindow = time.Hour
+	detector, clock, recorder := newTestDetector(cfg)
+	hot := detector.GetThresholds().TemperatureMax + 10
+
+	for i := 0; i < 3; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(hot), Timestamp: clock.Now()})
+		clock.Advance(10 * time.Minute)
+	}
+
+	if count := countType(recorder, "temperature_high"); count != 1 {
+		t.Errorf("%d temperature_high alerts, want repeats of the identical alert suppressed", count)
+	}
+	if suppressed := detector.Snapshot().SuppressedDuplicates; suppressed != 2 {
+		t.Errorf("suppressed duplicates = %d, want 2", suppressed)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"log"
+	"text/template"
+	"time"
+)
+
+// defaultAlertTemplates are the built-in alert messages. Status alerts use the template
+// for their event type if one is configured, otherwise "fault" or "warning".
+var defaultAlertTemplates = map[string]string{
+	"machine_offline":          "Machine {{.MachineID}} has not sent events for {{.Duration}}",
+	"machine_online":           "Machine {{.MachineID}} is sending events again",
+	"conveyor_speed_low":       "Conveyor speed below minimum threshold: {{.Value}} m/s (min: {{.Limit}})",
+	"conveyor_speed_high":      "Conveyor speed above maximum threshold: {{.Value}} m/s (max: {{.Limit}})",
+	"temperature_low":          "Temperature below minimum threshold: {{.Value}} (min: {{.Limit}})",
+	"temperature_high":         "Temperature above maximum threshold: {{.Value}} (max: {{.Limit}})",
+	"robot_angle_invalid":      "Robot arm angle out of valid range: {{.Value}}° (range: {{.Limit}})",
+	"fault":                    "{{if .Description}}Machine fault: {{.Description}}{{else}}Machine fault detected: {{.Event.EventType}}{{end}}",
+	"warning":                  "Warning condition: {{if .Description}}{{.Description}}{{else}}{{.Event.EventType}}{{end}}",
+	"rapid_temperature_change": "Rapid temperature change detected on machine {{.MachineID}}",
+	"speed_instability":        "Conveyor speed instability detected on machine {{.MachineID}}",
+	"repeated_faults":          "Multiple faults detected in recent history ({{.Count}} faults in last {{.Total}} events)",
+	"event_rate_drop":          "Machine {{.MachineID}} sent {{.Value}} events in the last {{.Duration}}, below its baseline of {{.Limit}}",
+}
+
+// AlertMessageData is the data available to alert message templates
+type AlertMessageData struct {
+	MachineID   string
+	Event       *models.SensorEvent // Triggering event; nil for machine_offline
+	Value       string              // Observed value, formatted in the display unit
+	Limit       string              // Threshold or range that was crossed, formatted likewise
+	Description string              // Fault description reported by the machine, else from the fault taxonomy
+	Action      string              // Recommended action from the fault taxonomy, for status alerts
+	Count       int                 // Matching events, for pattern alerts
+	Total       int                 // Events examined, for pattern alerts
+	Duration    time.Duration       // Time since the machine was last seen, for machine_offline; rate interval, for event_rate_drop
+}
+
+// AlertTemplates renders alert messages from per-type templates
+type AlertTemplates struct {
+	templates map[string]*template.Template
+	defaults  map[string]*template.Template
+}
+
+// NewAlertTemplates builds the alert templates, applying overrides on top of the built-in
+// defaults. Overrides that fail to parse are logged and the default is kept.
+func NewAlertTemplates(overrides map[string]string) *AlertTemplates {
+	t := &AlertTemplates{
+		templates: make(map[string]*template.Template),
+		defaults:  make(map[string]*template.Template),
+	}
+
+	for alertType, text := range defaultAlertTemplates {
+		t.defaults[alertType] = template.Must(template.New(alertType).Parse(text))
+		t.templates[alertType] = t.defaults[alertType]
+	}
+
+	for alertType, text := range overrides {
+		tmpl, err := template.New(alertType).Parse(text)
+		if err != nil {
+			log.Printf("Invalid alert template for %s, using default: %v", alertType, err)
+			continue
+		}
+		t.templates[alertType] = tmpl
+	}
+
+	return t
+}
+
+// Render produces the message for an alert type, trying each candidate type in turn.
+// A custom template that fails to execute falls back to the built-in default.
+func (t *AlertTemplates) Render(data AlertMessageData, alertTypes ...string) string {
+	for _, alertType := range alertTypes {
+		tmpl, exists := t.templates[alertType]
+		if !exists {
+			continue
+		}
+
+		message, err := execute(tmpl, data)
+		if err == nil {
+			return message
+		}
+		log.Printf("Failed to render alert template for %s: %v", alertType, err)
+
+		if fallback, exists := t.defaults[alertType]; exists && fallback != tmpl {
+			if message, err := execute(fallback, data); err == nil {
+				return message
+			}
+		}
+	}
+	return ""
+}
+
+// PreviewAlertTemplate parses and renders a template against sample data without storing
+// it. It returns the parse or execution error, e.g. a reference to an unknown field.
+func PreviewAlertTemplate(text string, data AlertMessageData) (string, error) {
+	tmpl, err := template.New("preview").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+// execute runs a template against data
+func execute(tmpl *template.Template, data AlertMessageData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+)
+
+func TestAlertTemplatesRenderCustomAndDefault(t *testing.T) {
+	templates := NewAlertTemplates(map[string]string{
+		"temperature_high":  "Température trop élevée sur {{.MachineID}} ({{.Event.EventType}}) : {{.Value}} > {{.Limit}}",
+		"speed_instability": "{{.Missing}}",  // Fails to execute: falls back to the default
+		"repeated_faults":   "{{if .Count}}", // Fails to parse: the default is kept
+	})
+	data := AlertMessageData{
+		MachineID: "conveyor_001",
+		Event:     &models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor"},
+		Value:     "92.0°C",
+		Limit:     "85.0°C",
+		Count:     3,
+		Total:     10,
+	}
+
+	for _, tc := range []struct {
+		alertType, want string
+	}{
+		{"temperature_high", "Température trop élevée sur conveyor_001 (conveyor) : 92.0°C > 85.0°C"},
+		{"temperature_low", "Temperature below minimum threshold: 92.0°C (min: 85.0°C)"},
+		{"speed_instability", "Conveyor speed instability detected on machine conveyor_001"},
+		{"repeated_faults", "Multiple faults detected in recent history (3 faults in last 10 events)"},
+	} {
+		if got := templates.Render(data, tc.alertType); got != tc.want {
+			t.Errorf("%s: message = %q, want %q", tc.alertType, got, tc.want)
+		}
+	}
+
+	// Status alerts try the event type's template before the generic one
+	if got := templates.Render(AlertMessageData{Event: data.Event}, "conveyor", "fault"); got != "Machine fault detected: conveyor" {
+		t.Errorf("fault message = %q, want the default fault template", got)
+	}
+}
@@ -1,18 +1,60 @@
 package services
 
 import (
+	"backend/config"
 	"backend/models"
 	"fmt"
 	"log"
+	"math"
 	"sync"
+	"time"
+)
+
+// Detection criteria for trend and pattern anomalies
+const (
+	temperatureChangeRateLimit = 2.0 // °C per second across the last 5 events
+	speedSpreadLimit           = 0.5 // Spread of recent conveyor speeds
 )
 
 // AnomalyDetector handles fault detection and anomaly analysis
 type AnomalyDetector struct {
-	thresholds    *models.AnomalyThresholds
-	slidingWindow map[string]*SlidingWindow
-	mutex         sync.RWMutex
-	alertCallback func(*models.Alert)
+	thresholds       *models.AnomalyThresholds
+	slidingWindow    map[string]*SlidingWindow
+	lastSeen         map[string]time.Time // Last event arrival time per machine
+	offline          map[string]bool      // Machines currently flagged as offline
+	offlineTimeout   time.Duration
+	windowTTL        time.Duration
+	windowSize       int
+	trendMinEvents   int
+	patternMinEvents int
+	pattern          config.PatternRule              // Repeated fault rule for machines without an override
+	patternOverrides map[string]config.PatternRule   // Repeated fault rules by machine
+	trendMaxGap      time.Duration                   // Largest gap between consecutive events that trends may span
+	resolveAfter     time.Duration                   // How long a condition must stay clear before it resolves
+	conditions       map[string]map[string]time.Time // Active alert types per machine, with when each cleared (zero while violated)
+	temperatureUnit  models.TemperatureUnit          // Unit used for temperatures in alert messages
+	messages         *AlertTemplates                 // Alert message templates
+	faultTypes       *FaultTaxonomy                  // Severity, description and action of status alerts by event type
+	statusDemotions  map[string]string               // Less severe status analyzed in place of the reported one, by event type
+	idleStatuses     map[string]bool                 // Statuses of machines that are not producing; skip threshold and trend detection
+	clock            Clock                           // Time source for liveness, snoozes and background tasks
+	disabledRules    map[string]bool                 // Detection rules switched off by operators
+	dedup            *alertDeduper                   // Suppresses repeated identical alerts; nil disables
+	eventDedup       *eventDeduper                   // Skips events already analyzed, e.g. redeliveries; nil disables
+	rates            map[string]*eventRate           // Event counts per interval by machine, for rate drop detection
+	rateInterval     time.Duration                   // Interval events are counted over; 0 disables rate drop detection
+	rateBaseline     int                             // Completed intervals averaged into the baseline rate
+	rateDropFraction float64                         // Fraction of the baseline below which an interval counts as a drop
+	warmups          map[string]*machineWarmup       // Machines still building a baseline since their window was created
+	warmupEvents     int                             // Events a new window must see before trend and pattern detection run
+	warmupDuration   time.Duration                   // Time a new window must exist before trend and pattern detection run
+	stopChannel      chan struct{}
+	stopOnce         sync.Once
+	mutex            sync.RWMutex
+	snoozed          map[string]time.Time // Snooze expiry per machine and alert type
+	snoozeMutex      sync.Mutex           // Guards snoozed; alerts are emitted while mutex is held
+	alertCallback    func(*models.Alert)
+	resolveCallback  func(*models.AlertResolution)
 }
 
 // SlidingWindow maintains recent events for a machine
@@ -23,8 +65,14 @@ type SlidingWindow struct {
 	full     bool
 }
 
-// NewAnomalyDetector creates a new anomaly detector
-func NewAnomalyDetector(alertCallback func(*models.Alert)) *AnomalyDetector {
+// NewAnomalyDetector creates a new anomaly detector. resolveCallback is invoked when a
+// threshold or status condition that raised alerts has stayed clear for the resolve period.
+// clock may be nil to use the wall clock.
+func NewAnomalyDetector(cfg config.AnomalyConfig, clock Clock, alertCallback func(*models.Alert), resolveCallback func(*models.AlertResolution)) *AnomalyDetector {
+	if clock == nil {
+		clock = RealClock{}
+	}
+
 	return &AnomalyDetector{
 		thresholds: &models.AnomalyThresholds{
 			ConveyorSpeedMin: 0.1,
@@ -33,9 +81,142 @@ func NewAnomalyDetector(alertCallback func(*models.Alert)) *AnomalyDetector {
 			TemperatureMax:   85.0,
 			RobotAngleMin:    0.0,
 			RobotAngleMax:    180.0,
+
+			ConveyorSpeedSeverity:   "high",
+			TemperatureLowSeverity:  "medium",
+			TemperatureHighSeverity: "high",
+			RobotAngleSeverity:      "medium",
 		},
-		slidingWindow: make(map[string]*SlidingWindow),
-		alertCallback: alertCallback,
+		slidingWindow:    make(map[string]*SlidingWindow),
+		lastSeen:         make(map[string]time.Time),
+		offline:          make(map[string]bool),
+		snoozed:          make(map[string]time.Time),
+		offlineTimeout:   cfg.OfflineTimeout,
+		windowTTL:        cfg.WindowTTL,
+		windowSize:       cfg.WindowSize,
+		trendMinEvents:   cfg.TrendMinEvents,
+		patternMinEvents: cfg.PatternMinEvents,
+		pattern:          cfg.Pattern,
+		patternOverrides: cfg.PatternOverrides,
+		trendMaxGap:      cfg.TrendMaxGap,
+		resolveAfter:     cfg.ResolveAfter,
+		conditions:       make(map[string]map[string]time.Time),
+		disabledRules:    make(map[string]bool),
+		dedup:            newAlertDeduper(cfg.DedupWindow),
+		eventDedup:       newEventDeduper(cfg.EventDedupWindow),
+		rates:            make(map[string]*eventRate),
+		rateInterval:     cfg.RateInterval,
+		rateBaseline:     cfg.RateBaseline,
+		rateDropFraction: cfg.RateDropFraction,
+		warmups:          make(map[string]*machineWarmup),
+		warmupEvents:     cfg.WarmupEvents,
+		warmupDuration:   cfg.WarmupDuration,
+		temperatureUnit:  cfg.TemperatureUnit,
+		messages:         NewAlertTemplates(cfg.MessageTemplates),
+		faultTypes:       NewFaultTaxonomy(cfg.FaultTypes),
+		statusDemotions:  cfg.StatusDemotions,
+		idleStatuses:     stringSet(cfg.IdleStatuses),
+		clock:            clock,
+		stopChannel:      make(chan struct{}),
+		alertCallback:    alertCallback,
+		resolveCallback:  resolveCallback,
+	}
+}
+
+// Start launches the background offline watchdog, event rate check and stale window
+// sweeper
+func (ad *AnomalyDetector) Start() {
+	if ad.offlineTimeout > 0 {
+		// Check at a fraction of the timeout so offline detection is reasonably prompt
+		go ad.runEvery(max(ad.offlineTimeout/4, time.Second), ad.checkOfflineMachines)
+	}
+
+	if ad.rateInterval > 0 {
+		go ad.runEvery(max(ad.rateInterval/4, time.Second), ad.checkEventRates)
+	}
+
+	if ad.windowTTL > 0 {
+		go ad.runEvery(max(ad.windowTTL/10, time.Second), ad.evictStaleWindows)
+	}
+}
+
+// runEvery invokes task on every tick until the detector is stopped
+func (ad *AnomalyDetector) runEvery(interval time.Duration, task func(now time.Time)) {
+	ticker := ad.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C():
+			task(now)
+		case <-ad.stopChannel:
+			return
+		}
+	}
+}
+
+// Stop terminates the background tasks
+func (ad *AnomalyDetector) Stop() {
+	ad.stopOnce.Do(func() {
+		close(ad.stopChannel)
+	})
+}
+
+// checkOfflineMachines raises machine_offline alerts for machines silent longer than the timeout
+func (ad *AnomalyDetector) checkOfflineMachines(now time.Time) {
+	var alerts []*models.Alert
+
+	ad.mutex.Lock()
+	if !ad.ruleEnabled(RuleMachineOffline) {
+		ad.mutex.Unlock()
+		return
+	}
+	for machineID, lastSeen := range ad.lastSeen {
+		if ad.offline[machineID] || now.Sub(lastSeen) < ad.offlineTimeout {
+			continue
+		}
+		ad.offline[machineID] = true
+		alerts = append(alerts, &models.Alert{
+			MachineID: machineID,
+			AlertType: "machine_offline",
+			Severity:  "high",
+			Message: ad.messages.Render(AlertMessageData{
+				MachineID: machineID,
+				Duration:  now.Sub(lastSeen).Round(time.Second),
+			}, "machine_offline"),
+		})
+	}
+	ad.mutex.Unlock()
+
+	// Invoke callbacks outside the lock so they can safely query the detector
+	for _, alert := range alerts {
+		ad.emitAlert(alert.MachineID, alert)
+	}
+}
+
+// evictStaleWindows drops the sliding windows and event samples of machines that have not
+// sent an event within the window TTL, so decommissioned machines do not leak memory.
+// Alert lifecycle state is kept while it is open: the liveness of machines the offline
+// watchdog tracks, so machine_offline is raised and later cleared by machine_online, and
+// active conditions, which resolve once the machine reports again.
+func (ad *AnomalyDetector) evictStaleWindows(now time.Time) {
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
+
+	for machineID, lastSeen := range ad.lastSeen {
+		if now.Sub(lastSeen) < ad.windowTTL {
+			continue
+		}
+		if _, exists := ad.slidingWindow[machineID]; exists {
+			delete(ad.slidingWindow, machineID)
+			delete(ad.rates, machineID)
+			delete(ad.warmups, machineID)
+			log.Printf("Evicted sliding window for machine %s (idle since %s)", machineID, lastSeen.Format(time.RFC3339))
+		}
+		if ad.offlineTimeout == 0 && len(ad.conditions[machineID]) == 0 {
+			delete(ad.lastSeen, machineID)
+			delete(ad.conditions, machineID)
+		}
 	}
 }
 
@@ -70,6 +251,14 @@ func (sw *SlidingWindow) GetEvents() []*models.SensorEvent {
 	return result
 }
 
+// Len returns the number of events in the window
+func (sw *SlidingWindow) Len() int {
+	if sw.full {
+		return sw.maxSize
+	}
+	return sw.position
+}
+
 // GetRecentEvents returns the N most recent events
 func (sw *SlidingWindow) GetRecentEvents(n int) []*models.SensorEvent {
 	events := sw.GetEvents()
@@ -84,134 +273,299 @@ func (ad *AnomalyDetector) AnalyzeEvent(event *models.SensorEvent) {
 	ad.mutex.Lock()
 	defer ad.mutex.Unlock()
 
+	// A redelivered event must not count twice towards windows, trends and patterns
+	now := ad.clock.Now()
+	if ad.eventDedup != nil && ad.eventDedup.duplicate(event, now) {
+		return
+	}
+
+	event = ad.demote(event)
+
 	// Get or create sliding window for this machine
 	window, exists := ad.slidingWindow[event.MachineID]
 	if !exists {
-		window = NewSlidingWindow(50) // Keep last 50 events
+		window = NewSlidingWindow(ad.windowSize)
 		ad.slidingWindow[event.MachineID] = window
+		ad.warmups[event.MachineID] = &machineWarmup{started: now}
 	}
 
 	// Add event to sliding window
 	window.Add(event)
 
+	// Track liveness and announce recovery of machines flagged offline
+	ad.lastSeen[event.MachineID] = now
+	if ad.offline[event.MachineID] {
+		delete(ad.offline, event.MachineID)
+		delete(ad.rates, event.MachineID) // The silence is not a rate drop; start a fresh baseline
+		ad.emitAlert(event.MachineID, &models.Alert{
+			AlertType: "machine_online",
+			Severity:  "low",
+			Message:   ad.messages.Render(AlertMessageData{MachineID: event.MachineID, Event: event}, "machine_online"),
+		})
+	}
+
 	// Perform anomaly detection
+	ad.trackEventRate(event, now)
 	ad.detectThresholdViolations(event)
-	ad.detectTrendAnomalies(event, window)
-	ad.detectPatternAnomalies(event, window)
+	if !ad.warmingUp(event.MachineID, now) {
+		ad.detectTrendAnomalies(event, window)
+		ad.detectPatternAnomalies(event, window)
+	}
+}
+
+// emitAlert attributes an alert to a machine and hands it to the alert callback,
+// unless alerts of its type are snoozed for that machine or it duplicates a recent alert
+func (ad *AnomalyDetector) emitAlert(machineID string, alert *models.Alert) {
+	alert.MachineID = machineID
+	now := ad.clock.Now()
+	if ad.isSnoozed(machineID, alert.AlertType, now) {
+		return
+	}
+	if ad.dedup != nil && !ad.dedup.allow(alert, now) {
+		return
+	}
+	if ad.alertCallback != nil {
+		ad.alertCallback(alert)
+	}
+}
+
+// Snooze suppresses alerts of the given type for a machine until the given time
+func (ad *AnomalyDetector) Snooze(machineID, alertType string, until time.Time) {
+	ad.snoozeMutex.Lock()
+	defer ad.snoozeMutex.Unlock()
+	ad.snoozed[snoozeKey(machineID, alertType)] = until
+}
+
+// isSnoozed reports whether alerts of the given type are snoozed for a machine at now.
+// Expired snoozes are discarded so alerting resumes.
+func (ad *AnomalyDetector) isSnoozed(machineID, alertType string, now time.Time) bool {
+	ad.snoozeMutex.Lock()
+	defer ad.snoozeMutex.Unlock()
+
+	key := snoozeKey(machineID, alertType)
+	until, exists := ad.snoozed[key]
+	if !exists {
+		return false
+	}
+	if !now.Before(until) {
+		delete(ad.snoozed, key)
+		return false
+	}
+	return true
+}
+
+// snoozeKey identifies a machine and alert type pair
+func snoozeKey(machineID, alertType string) string {
+	return machineID + "/" + alertType
 }
 
 // detectThresholdViolations detects simple threshold violations
 func (ad *AnomalyDetector) detectThresholdViolations(event *models.SensorEvent) {
 	var alerts []*models.Alert
 
-	// Check conveyor speed
-	if event.ConveyorSpeed < ad.thresholds.ConveyorSpeedMin {
-		alerts = append(alerts, &models.Alert{
-			AlertType: "conveyor_speed_low",
-			Severity:  "high",
-			Message:   fmt.Sprintf("Conveyor speed below minimum threshold: %.2f m/s (min: %.2f)", event.ConveyorSpeed, ad.thresholds.ConveyorSpeedMin),
-		})
-	} else if event.ConveyorSpeed > ad.thresholds.ConveyorSpeedMax {
-		alerts = append(alerts, &models.Alert{
-			AlertType: "conveyor_speed_high",
-			Severity:  "high",
-			Message:   fmt.Sprintf("Conveyor speed above maximum threshold: %.2f m/s (max: %.2f)", event.ConveyorSpeed, ad.thresholds.ConveyorSpeedMax),
-		})
+	// violation builds a threshold alert whose message reports the observed value and limit
+	violation := func(alertType, severity, value, limit string) *models.Alert {
+		return &models.Alert{
+			AlertType: alertType,
+			Severity:  severity,
+			Message: ad.messages.Render(AlertMessageData{
+				MachineID: event.MachineID,
+				Event:     event,
+				Value:     value,
+				Limit:     limit,
+			}, alertType),
+		}
+	}
+
+	// Idle machines are expected to read outside the production thresholds, e.g. a stopped
+	// conveyor, so thresholds are not checked; conditions raised earlier clear as usual
+	checkThresholds := ad.ruleEnabled(RuleThresholds) && !ad.idleStatuses[event.Status]
+
+	// Check conveyor speed; metrics the event did not report are skipped
+	if speed := event.ConveyorSpeed; checkThresholds && speed != nil {
+		value := fmt.Sprintf("%.2f", *speed)
+		if *speed < ad.thresholds.ConveyorSpeedMin {
+			alerts = append(alerts, violation("conveyor_speed_low", ad.thresholds.ConveyorSpeedSeverity, value, fmt.Sprintf("%.2f", ad.thresholds.ConveyorSpeedMin)))
+		} else if *speed > ad.thresholds.ConveyorSpeedMax {
+			alerts = append(alerts, violation("conveyor_speed_high", ad.thresholds.ConveyorSpeedSeverity, value, fmt.Sprintf("%.2f", ad.thresholds.ConveyorSpeedMax)))
+		}
 	}
 
 	// Check temperature
-	if event.Temperature < ad.thresholds.TemperatureMin {
-		alerts = append(alerts, &models.Alert{
-			AlertType: "temperature_low",
-			Severity:  "medium",
-			Message:   fmt.Sprintf("Temperature below minimum threshold: %.1f°C (min: %.1f)", event.Temperature, ad.thresholds.TemperatureMin),
-		})
-	} else if event.Temperature > ad.thresholds.TemperatureMax {
-		alerts = append(alerts, &models.Alert{
-			AlertType: "temperature_high",
-			Severity:  "high",
-			Message:   fmt.Sprintf("Temperature above maximum threshold: %.1f°C (max: %.1f)", event.Temperature, ad.thresholds.TemperatureMax),
-		})
+	if temperature := event.Temperature; checkThresholds && temperature != nil {
+		value := ad.formatTemperature(*temperature)
+		if *temperature < ad.thresholds.TemperatureMin {
+			alerts = append(alerts, violation("temperature_low", ad.thresholds.TemperatureLowSeverity, value, ad.formatTemperature(ad.thresholds.TemperatureMin)))
+		} else if *temperature > ad.thresholds.TemperatureMax {
+			alerts = append(alerts, violation("temperature_high", ad.thresholds.TemperatureHighSeverity, value, ad.formatTemperature(ad.thresholds.TemperatureMax)))
+		}
 	}
 
 	// Check robot arm angle
-	if event.RobotArmAngle < ad.thresholds.RobotAngleMin || event.RobotArmAngle > ad.thresholds.RobotAngleMax {
-		alerts = append(alerts, &models.Alert{
-			AlertType: "robot_angle_invalid",
-			Severity:  "medium",
-			Message:   fmt.Sprintf("Robot arm angle out of valid range: %.1f° (range: %.1f-%.1f)", event.RobotArmAngle, ad.thresholds.RobotAngleMin, ad.thresholds.RobotAngleMax),
-		})
+	if angle := event.RobotArmAngle; checkThresholds && angle != nil && (*angle < ad.thresholds.RobotAngleMin || *angle > ad.thresholds.RobotAngleMax) {
+		alerts = append(alerts, violation("robot_angle_invalid", ad.thresholds.RobotAngleSeverity, fmt.Sprintf("%.1f", *angle),
+			fmt.Sprintf("%.1f-%.1f", ad.thresholds.RobotAngleMin, ad.thresholds.RobotAngleMax)))
 	}
 
-	// Process status-based alerts
-	if event.Status == "fault" {
-		severity := "high"
-		message := fmt.Sprintf("Machine fault detected: %s", event.EventType)
+	// Process status-based alerts, classified by the fault taxonomy
+	if ad.ruleEnabled(RuleStatus) && (event.Status == "fault" || event.Status == "warning") {
+		faultType := ad.faultTypes.Lookup(event.EventType, event.Status)
 
-		if faultDesc, ok := event.AdditionalData["description"].(string); ok {
-			message = fmt.Sprintf("Machine fault: %s", faultDesc)
+		data := AlertMessageData{
+			MachineID:   event.MachineID,
+			Event:       event,
+			Description: faultType.Description,
+			Action:      faultType.Action,
+		}
+		if faultDesc := event.AdditionalData.String("description", ""); faultDesc != "" {
+			data.Description = faultDesc
 		}
 
-		alerts = append(alerts, &models.Alert{
-			AlertType: event.EventType,
-			Severity:  severity,
-			Message:   message,
-		})
-	} else if event.Status == "warning" {
-		alerts = append(alerts, &models.Alert{
+		alert := &models.Alert{
 			AlertType: event.EventType,
-			Severity:  "medium",
-			Message:   fmt.Sprintf("Warning condition: %s", event.EventType),
-		})
+			Severity:  faultType.Severity,
+			Message:   ad.messages.Render(data, event.EventType, event.Status),
+		}
+		if faultType.Action != "" {
+			alert.RecommendedAction = &faultType.Action
+		}
+		alerts = append(alerts, alert)
 	}
 
 	// Send alerts
 	for _, alert := range alerts {
-		if ad.alertCallback != nil {
-			ad.alertCallback(alert)
+		ad.emitAlert(event.MachineID, alert)
+	}
+
+	ad.trackConditions(event, alerts)
+}
+
+// trackConditions records the alert types an event violated and resolves conditions
+// that have stayed clear, measured in event time, for the resolve period
+func (ad *AnomalyDetector) trackConditions(event *models.SensorEvent, alerts []*models.Alert) {
+	if ad.resolveAfter <= 0 {
+		return
+	}
+
+	conditions := ad.conditions[event.MachineID]
+	if conditions == nil {
+		if len(alerts) == 0 {
+			return
+		}
+		conditions = make(map[string]time.Time)
+		ad.conditions[event.MachineID] = conditions
+	}
+
+	violated := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		violated[alert.AlertType] = true
+		conditions[alert.AlertType] = time.Time{}
+	}
+
+	for alertType, clearSince := range conditions {
+		if violated[alertType] || !reportsMetricFor(event, alertType) {
+			continue // An unreported metric says nothing about whether its condition cleared
+		}
+		if clearSince.IsZero() {
+			conditions[alertType] = event.Timestamp
+			continue
+		}
+		if event.Timestamp.Sub(clearSince) < ad.resolveAfter {
+			continue
 		}
+
+		delete(conditions, alertType)
+		if ad.resolveCallback != nil {
+			ad.resolveCallback(&models.AlertResolution{
+				MachineID:  event.MachineID,
+				AlertType:  alertType,
+				ResolvedAt: event.Timestamp,
+			})
+		}
+	}
+
+	if len(conditions) == 0 {
+		delete(ad.conditions, event.MachineID)
+	}
+}
+
+// reportsMetricFor reports whether an event carries the metric a threshold alert type is
+// based on. Alert types not tied to a metric are always considered reported.
+func reportsMetricFor(event *models.SensorEvent, alertType string) bool {
+	switch alertType {
+	case "conveyor_speed_low", "conveyor_speed_high":
+		return event.ConveyorSpeed != nil
+	case "temperature_low", "temperature_high":
+		return event.Temperature != nil
+	case "robot_angle_invalid":
+		return event.RobotArmAngle != nil
+	default:
+		return true
 	}
 }
 
-// detectTrendAnomalies detects anomalies based on trends
+// detectTrendAnomalies detects anomalies based on trends. Trends are not evaluated while
+// the recent events span a gap longer than the configured maximum, e.g. after a machine
+// was offline, since rates computed across the gap are meaningless.
 func (ad *AnomalyDetector) detectTrendAnomalies(event *models.SensorEvent, window *SlidingWindow) {
-	recentEvents := window.GetRecentEvents(10)
-	if len(recentEvents) < 5 {
+	if ad.idleStatuses[event.Status] {
+		return // Readings change freely while a machine is idle or being set up
+	}
+
+	recentEvents := window.GetRecentEvents(max(10, ad.trendMinEvents))
+	if len(recentEvents) < ad.trendMinEvents {
 		return // Not enough data
 	}
 
+	if ad.trendMaxGap > 0 && hasGap(recentEvents, ad.trendMaxGap) {
+		return // Series is discontinuous
+	}
+
 	// Check for rapid temperature rise
-	if ad.detectRapidTemperatureChange(recentEvents) {
+	if changeRate, detected := ad.detectRapidTemperatureChange(recentEvents); detected && ad.ruleEnabled(RuleRapidTemperatureChange) {
 		alert := &models.Alert{
-			AlertType: "rapid_temperature_change",
-			Severity:  "medium",
-			Message:   fmt.Sprintf("Rapid temperature change detected on machine %s", event.MachineID),
-		}
-		if ad.alertCallback != nil {
-			ad.alertCallback(alert)
+			AlertType:  "rapid_temperature_change",
+			Severity:   "medium",
+			Message:    ad.messages.Render(AlertMessageData{MachineID: event.MachineID, Event: event}, "rapid_temperature_change"),
+			Confidence: confidenceScore(math.Abs(changeRate), temperatureChangeRateLimit),
 		}
+		ad.emitAlert(event.MachineID, alert)
 	}
 
 	// Check for conveyor speed instability
-	if ad.detectSpeedInstability(recentEvents) {
+	if spread, detected := ad.detectSpeedInstability(recentEvents); detected && ad.ruleEnabled(RuleSpeedInstability) {
 		alert := &models.Alert{
-			AlertType: "speed_instability",
-			Severity:  "medium",
-			Message:   fmt.Sprintf("Conveyor speed instability detected on machine %s", event.MachineID),
-		}
-		if ad.alertCallback != nil {
-			ad.alertCallback(alert)
+			AlertType:  "speed_instability",
+			Severity:   "medium",
+			Message:    ad.messages.Render(AlertMessageData{MachineID: event.MachineID, Event: event}, "speed_instability"),
+			Confidence: confidenceScore(spread, speedSpreadLimit),
 		}
+		ad.emitAlert(event.MachineID, alert)
+	}
+}
+
+// patternRule returns the repeated fault rule that applies to a machine
+func (ad *AnomalyDetector) patternRule(machineID string) config.PatternRule {
+	if rule, ok := ad.patternOverrides[machineID]; ok {
+		return rule
 	}
+	return ad.pattern
 }
 
 // detectPatternAnomalies detects pattern-based anomalies
 func (ad *AnomalyDetector) detectPatternAnomalies(event *models.SensorEvent, window *SlidingWindow) {
-	recentEvents := window.GetRecentEvents(20)
-	if len(recentEvents) < 10 {
+	if !ad.ruleEnabled(RuleRepeatedFaults) {
 		return
 	}
 
+	// Patterns are evaluated once the window holds the minimum events, and then only over
+	// the rule's lookback, so faults further back do not count towards the limit
+	if window.Len() < ad.patternMinEvents {
+		return
+	}
+	rule := ad.patternRule(event.MachineID)
+	recentEvents := window.GetRecentEvents(rule.Lookback)
+
 	// Check for repeated faults
 	faultCount := 0
 	for _, e := range recentEvents {
@@ -220,40 +574,48 @@ func (ad *AnomalyDetector) detectPatternAnomalies(event *models.SensorEvent, win
 		}
 	}
 
-	if faultCount >= 3 {
+	if faultCount >= rule.FaultLimit {
 		alert := &models.Alert{
 			AlertType: "repeated_faults",
 			Severity:  "high",
-			Message:   fmt.Sprintf("Multiple faults detected in recent history (%d faults in last 20 events)", faultCount),
-		}
-		if ad.alertCallback != nil {
-			ad.alertCallback(alert)
+			Message: ad.messages.Render(AlertMessageData{
+				MachineID: event.MachineID,
+				Event:     event,
+				Count:     faultCount,
+				Total:     len(recentEvents),
+			}, "repeated_faults"),
+			Confidence: confidenceScore(float64(faultCount), float64(rule.FaultLimit)),
 		}
+		ad.emitAlert(event.MachineID, alert)
 	}
 }
 
-// detectRapidTemperatureChange checks for rapid temperature changes
-func (ad *AnomalyDetector) detectRapidTemperatureChange(events []*models.SensorEvent) bool {
+// detectRapidTemperatureChange checks for rapid temperature changes, returning the
+// observed change rate in °C per second. Events without a temperature are ignored.
+func (ad *AnomalyDetector) detectRapidTemperatureChange(events []*models.SensorEvent) (float64, bool) {
+	events = withMetric(events, func(e *models.SensorEvent) *float64 { return e.Temperature })
 	if len(events) < 5 {
-		return false
+		return 0, false
 	}
 
 	// Calculate temperature change rate over last 5 events
-	tempChange := events[len(events)-1].Temperature - events[len(events)-5].Temperature
+	tempChange := *events[len(events)-1].Temperature - *events[len(events)-5].Temperature
 	timeSpan := events[len(events)-1].Timestamp.Sub(events[len(events)-5].Timestamp).Seconds()
 
 	if timeSpan > 0 {
 		changeRate := tempChange / timeSpan
-		return changeRate > 2.0 || changeRate < -2.0 // 2°C per second threshold
+		return changeRate, math.Abs(changeRate) > temperatureChangeRateLimit
 	}
 
-	return false
+	return 0, false
 }
 
-// detectSpeedInstability checks for unstable conveyor speed
-func (ad *AnomalyDetector) detectSpeedInstability(events []*models.SensorEvent) bool {
+// detectSpeedInstability checks for unstable conveyor speed, returning the observed
+// spread. Events without a conveyor speed are ignored.
+func (ad *AnomalyDetector) detectSpeedInstability(events []*models.SensorEvent) (float64, bool) {
+	events = withMetric(events, func(e *models.SensorEvent) *float64 { return e.ConveyorSpeed })
 	if len(events) < 5 {
-		return false
+		return 0, false
 	}
 
 	// Calculate standard deviation of recent speeds
@@ -261,26 +623,81 @@ func (ad *AnomalyDetector) detectSpeedInstability(events []*models.SensorEvent)
 	n := float64(len(events))
 
 	for _, event := range events {
-		sum += event.ConveyorSpeed
-		sumSquares += event.ConveyorSpeed * event.ConveyorSpeed
+		sum += *event.ConveyorSpeed
+		sumSquares += *event.ConveyorSpeed * *event.ConveyorSpeed
 	}
 
 	mean := sum / n
-	variance := (sumSquares / n) - (mean * mean)
-	stdDev := variance * variance // Simplified calculation
+	variance := max((sumSquares/n)-(mean*mean), 0) // Rounding can leave it just below zero
+	stdDev := math.Sqrt(variance)
 
 	// If standard deviation is high, speed is unstable
-	return stdDev > 0.5
+	return stdDev, stdDev > speedSpreadLimit
+}
+
+// withMetric returns the events that reported the metric selected by value
+func withMetric(events []*models.SensorEvent, value func(*models.SensorEvent) *float64) []*models.SensorEvent {
+	reported := make([]*models.SensorEvent, 0, len(events))
+	for _, event := range events {
+		if value(event) != nil {
+			reported = append(reported, event)
+		}
+	}
+	return reported
+}
+
+// hasGap reports whether any two consecutive events are further apart than maxGap
+func hasGap(events []*models.SensorEvent, maxGap time.Duration) bool {
+	for i := 1; i < len(events); i++ {
+		if events[i].Timestamp.Sub(events[i-1].Timestamp) > maxGap {
+			return true
+		}
+	}
+	return false
 }
 
-// UpdateThresholds updates the anomaly detection thresholds
+// confidenceScore maps how far an observed value exceeds its detection criterion onto
+// 0-1: a value at the criterion scores 0.5, approaching 1 as the excess grows
+func confidenceScore(observed, criterion float64) *float64 {
+	score := observed / (observed + criterion)
+	return &score
+}
+
+// UpdateThresholds updates the anomaly detection thresholds. Severities left empty keep
+// their current values.
 func (ad *AnomalyDetector) UpdateThresholds(thresholds *models.AnomalyThresholds) {
 	ad.mutex.Lock()
 	defer ad.mutex.Unlock()
+	thresholds.FillSeverities(ad.thresholds)
 	ad.thresholds = thresholds
 	log.Printf("Updated anomaly detection thresholds: %+v", thresholds)
 }
 
+// ResetMachine discards a machine's sliding window and tracked state, so detection
+// starts afresh from its next event. It reports whether any state existed.
+func (ad *AnomalyDetector) ResetMachine(machineID string) bool {
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
+
+	_, exists := ad.slidingWindow[machineID]
+	delete(ad.slidingWindow, machineID)
+	delete(ad.lastSeen, machineID)
+	delete(ad.offline, machineID)
+	delete(ad.conditions, machineID)
+	delete(ad.rates, machineID)
+	delete(ad.warmups, machineID)
+
+	log.Printf("Reset anomaly detection state for machine %s", machineID)
+	return exists
+}
+
+// IsOffline reports whether a machine is currently flagged as offline
+func (ad *AnomalyDetector) IsOffline(machineID string) bool {
+	ad.mutex.RLock()
+	defer ad.mutex.RUnlock()
+	return ad.offline[machineID]
+}
+
 // GetThresholds returns current thresholds
 func (ad *AnomalyDetector) GetThresholds() *models.AnomalyThresholds {
 	ad.mutex.RLock()
@@ -288,7 +705,13 @@ func (ad *AnomalyDetector) GetThresholds() *models.AnomalyThresholds {
 	return ad.thresholds
 }
 
-// GetMachineStats returns statistics for a specific machine
+// formatTemperature renders a Celsius temperature in the configured display unit
+func (ad *AnomalyDetector) formatTemperature(celsius float64) string {
+	return fmt.Sprintf("%.1f%s", ad.temperatureUnit.FromCelsius(celsius), ad.temperatureUnit.Symbol())
+}
+
+// GetMachineStats returns statistics for a specific machine, including tail percentiles
+// of temperature and conveyor speed over its sliding window
 func (ad *AnomalyDetector) GetMachineStats(machineID string) map[string]interface{} {
 	ad.mutex.RLock()
 	defer ad.mutex.RUnlock()
@@ -303,25 +726,53 @@ func (ad *AnomalyDetector) GetMachineStats(machineID string) map[string]interfac
 		return nil
 	}
 
-	var avgTemp, avgSpeed, avgAngle float64
 	faultCount := 0
-
 	for _, event := range events {
-		avgTemp += event.Temperature
-		avgSpeed += event.ConveyorSpeed
-		avgAngle += event.RobotArmAngle
 		if event.Status == "fault" {
 			faultCount++
 		}
 	}
 
+	temperature := func(e *models.SensorEvent) *float64 { return e.Temperature }
+	speed := func(e *models.SensorEvent) *float64 { return e.ConveyorSpeed }
+
 	n := float64(len(events))
 	return map[string]interface{}{
-		"event_count":          len(events),
-		"avg_temperature":      avgTemp / n,
-		"avg_conveyor_speed":   avgSpeed / n,
-		"avg_robot_arm_angle":  avgAngle / n,
-		"fault_rate":           float64(faultCount) / n,
-		"last_event_time":      events[len(events)-1].Timestamp,
-	}
-}
\ No newline at end of file
+		"event_count":         len(events),
+		"avg_temperature":     averageMetric(events, temperature),
+		"avg_conveyor_speed":  averageMetric(events, speed),
+		"avg_robot_arm_angle": averageMetric(events, func(e *models.SensorEvent) *float64 { return e.RobotArmAngle }),
+		"p95_temperature":     percentileMetric(events, temperature, 0.95),
+		"p99_temperature":     percentileMetric(events, temperature, 0.99),
+		"p95_conveyor_speed":  percentileMetric(events, speed, 0.95),
+		"p99_conveyor_speed":  percentileMetric(events, speed, 0.99),
+		"fault_rate":          float64(faultCount) / n,
+		"last_event_time":     events[len(events)-1].Timestamp,
+	}
+}
+
+// percentileMetric returns the p-th percentile of a metric over the events that reported
+// it, or nil if none did
+func percentileMetric(events []*models.SensorEvent, value func(*models.SensorEvent) *float64, p float64) *float64 {
+	reported := withMetric(events, value)
+	values := make([]float64, len(reported))
+	for i, event := range reported {
+		values[i] = *value(event)
+	}
+	return models.Percentile(values, p)
+}
+
+// averageMetric averages a metric over the events that reported it, or returns nil if none did
+func averageMetric(events []*models.SensorEvent, value func(*models.SensorEvent) *float64) *float64 {
+	reported := withMetric(events, value)
+	if len(reported) == 0 {
+		return nil
+	}
+
+	var sum float64
+	for _, event := range reported {
+		sum += *value(event)
+	}
+	average := sum / float64(len(reported))
+	return &average
+}
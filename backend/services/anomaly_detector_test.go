@@ -0,0 +1,711 @@
+package services
+
+import (
+	"backend/config"
+	"backend/models"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAnomalyConfig returns a detector configuration with the built-in defaults and
+// background tasks switched off, for tests to adjust
+func testAnomalyConfig() config.AnomalyConfig {
+	return config.AnomalyConfig{
+		WindowSize:       50,
+		TrendMinEvents:   5,
+		PatternMinEvents: 10,
+		Pattern:          config.PatternRule{Lookback: 10, FaultLimit: 3},
+		RateBaseline:     10,
+		RateDropFraction: 0.5,
+		TemperatureUnit:  models.Celsius,
+	}
+}
+
+// alertRecorder collects the alerts and resolutions a detector emits
+type alertRecorder struct {
+	alerts      []*models.Alert
+	resolutions []*models.AlertResolution
+}
+
+func (r *alertRecorder) alert(alert *models.Alert) {
+	r.alerts = append(r.alerts, alert)
+}
+
+func (r *alertRecorder) resolve(resolution *models.AlertResolution) {
+	r.resolutions = append(r.resolutions, resolution)
+}
+
+// types returns the types of the recorded alerts, in order
+func (r *alertRecorder) types() []string {
+	types := make([]string, len(r.alerts))
+	for i, alert := range r.alerts {
+		types[i] = alert.AlertType
+	}
+	return types
+}
+
+// newTestDetector creates a detector driven by a fake clock, recording what it emits
+func newTestDetector(cfg config.AnomalyConfig) (*AnomalyDetector, *FakeClock, *alertRecorder) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	recorder := &alertRecorder{}
+	return NewAnomalyDetector(cfg, clock, recorder.alert, recorder.resolve), clock, recorder
+}
+
+// float returns a pointer to value
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestAnalyzeEventWithMissingMetrics(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+
+	for i := 0; i < 20; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{
+			MachineID: "conveyor_001",
+			EventType: "conveyor",
+			Status:    "ok",
+			Timestamp: clock.Now(),
+		})
+		clock.Advance(time.Second)
+	}
+
+	if len(recorder.alerts) != 0 {
+		t.Errorf("alerts = %v, want none for events without metrics", recorder.types())
+	}
+	stats := detector.GetMachineStats("conveyor_001")
+	if stats["avg_temperature"].(*float64) != nil || stats["p95_conveyor_speed"].(*float64) != nil {
+		t.Errorf("stats = %v, want nil aggregates for unreported metrics", stats)
+	}
+}
+
+func TestAnalyzeEventSkipsOnlyMissingMetrics(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+
+	detector.AnalyzeEvent(&models.SensorEvent{
+		MachineID:   "conveyor_001",
+		EventType:   "conveyor",
+		Status:      "ok",
+		Timestamp:   clock.Now(),
+		Temperature: float(150),
+	})
+
+	if types := recorder.types(); len(types) != 1 || types[0] != "temperature_high" {
+		t.Errorf("alerts = %v, want only temperature_high", types)
+	}
+}
+
+// waitForAlert returns the next alert sent on alerts, failing the test if none arrives
+func waitForAlert(t *testing.T, alerts <-chan *models.Alert) *models.Alert {
+	t.Helper()
+	select {
+	case alert := <-alerts:
+		return alert
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert raised")
+		return nil
+	}
+}
+
+// waitForTickers waits until the clock has count active tickers, i.e. the detector's
+// background tasks have started
+func waitForTickers(t *testing.T, clock *FakeClock, count int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Tickers() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d tickers started, want %d", clock.Tickers(), count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOfflineWatchdogRaisesAfterTimeout(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.OfflineTimeout = time.Minute
+
+	// The watchdog raises alerts on its own goroutine
+	alerts := make(chan *models.Alert, 10)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	detector := NewAnomalyDetector(cfg, clock, func(alert *models.Alert) { alerts <- alert }, nil)
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+
+	detector.checkOfflineMachines(clock.Now().Add(59 * time.Second))
+	if detector.IsOffline("conveyor_001") || len(alerts) != 0 {
+		t.Fatal("machine flagged offline before the timeout")
+	}
+
+	detector.Start()
+	defer detector.Stop()
+	waitForTickers(t, clock, 1)
+	clock.Advance(75 * time.Second)
+
+	alert := waitForAlert(t, alerts)
+	if alert.AlertType != "machine_offline" || alert.MachineID != "conveyor_001" {
+		t.Fatalf("alert = %s for %s, want machine_offline for conveyor_001", alert.AlertType, alert.MachineID)
+	}
+	if !detector.IsOffline("conveyor_001") {
+		t.Error("machine not flagged offline after the alert")
+	}
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+	if alert := waitForAlert(t, alerts); alert.AlertType != "machine_online" {
+		t.Errorf("alert = %s after the machine reported again, want machine_online", alert.AlertType)
+	}
+	if detector.IsOffline("conveyor_001") {
+		t.Error("machine still flagged offline after reporting again")
+	}
+}
+
+func TestConditionResolvesOnceClearForResolveAfter(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.ResolveAfter = 30 * time.Second
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyze := func(temperature float64) {
+		detector.AnalyzeEvent(&models.SensorEvent{
+			MachineID:   "conveyor_001",
+			EventType:   "conveyor",
+			Status:      "ok",
+			Timestamp:   clock.Now(),
+			Temperature: float(temperature),
+		})
+	}
+
+	analyze(150)
+	if types := recorder.types(); len(types) != 1 || types[0] != "temperature_high" {
+		t.Fatalf("alerts = %v, want temperature_high", types)
+	}
+
+	clock.Advance(10 * time.Second)
+	analyze(50) // Clear from here
+	clock.Advance(20 * time.Second)
+	analyze(50)
+	if len(recorder.resolutions) != 0 {
+		t.Fatalf("resolved after %s clear, want %s", 20*time.Second, cfg.ResolveAfter)
+	}
+
+	clock.Advance(10 * time.Second)
+	analyze(50)
+	if len(recorder.resolutions) != 1 {
+		t.Fatalf("%d resolutions after %s clear, want 1", len(recorder.resolutions), cfg.ResolveAfter)
+	}
+	resolution := recorder.resolutions[0]
+	if resolution.AlertType != "temperature_high" || !resolution.ResolvedAt.Equal(clock.Now()) {
+		t.Errorf("resolution = %s at %v, want temperature_high at %v", resolution.AlertType, resolution.ResolvedAt, clock.Now())
+	}
+
+	clock.Advance(time.Minute)
+	analyze(50)
+	if len(recorder.resolutions) != 1 {
+		t.Errorf("%d resolutions, want a resolved condition to resolve once", len(recorder.resolutions))
+	}
+}
+
+func TestConditionViolatedAgainRestartsResolveAfter(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.ResolveAfter = 30 * time.Second
+	detector, clock, recorder := newTestDetector(cfg)
+
+	for _, step := range []struct {
+		advance     time.Duration
+		temperature float64
+	}{{0, 150}, {10 * time.Second, 50}, {15 * time.Second, 150}, {10 * time.Second, 50}, {25 * time.Second, 50}} {
+		clock.Advance(step.advance)
+		detector.AnalyzeEvent(&models.SensorEvent{
+			MachineID:   "conveyor_001",
+			EventType:   "conveyor",
+			Status:      "ok",
+			Timestamp:   clock.Now(),
+			Temperature: float(step.temperature),
+		})
+	}
+
+	if len(recorder.resolutions) != 0 {
+		t.Errorf("resolved %s after the condition recurred, want %s clear", 25*time.Second, cfg.ResolveAfter)
+	}
+}
+
+// analyzeAtRate feeds a machine count evenly spaced events over interval
+func analyzeAtRate(detector *AnomalyDetector, clock *FakeClock, count int, interval time.Duration) {
+	for i := 0; i < count; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+		clock.Advance(interval / time.Duration(count))
+	}
+}
+
+func TestEventRateDropRaisedAfterInterval(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.RateInterval = time.Minute
+	cfg.RateBaseline = 3
+	detector, clock, recorder := newTestDetector(cfg)
+
+	for i := 0; i < cfg.RateBaseline; i++ {
+		analyzeAtRate(detector, clock, 10, cfg.RateInterval)
+	}
+	analyzeAtRate(detector, clock, 2, cfg.RateInterval)
+	if len(recorder.alerts) != 0 {
+		t.Fatalf("alerts = %v before the slow interval completed", recorder.types())
+	}
+
+	// Without the background check running, the slow interval closes when the next event arrives
+	analyzeAtRate(detector, clock, 2, cfg.RateInterval)
+	if types := recorder.types(); len(types) != 1 || types[0] != "event_rate_drop" {
+		t.Fatalf("alerts = %v, want event_rate_drop", types)
+	}
+
+	// A drop is reported once until the rate recovers
+	analyzeAtRate(detector, clock, 2, cfg.RateInterval)
+	analyzeAtRate(detector, clock, 10, cfg.RateInterval)
+	if len(recorder.alerts) != 1 {
+		t.Errorf("alerts = %v, want one event_rate_drop per drop", recorder.types())
+	}
+}
+
+func TestEventRateSilenceStartsFreshBaseline(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.RateInterval = time.Minute
+	cfg.RateBaseline = 3
+	detector, clock, recorder := newTestDetector(cfg)
+
+	for i := 0; i < cfg.RateBaseline; i++ {
+		analyzeAtRate(detector, clock, 10, cfg.RateInterval)
+	}
+	clock.Advance(time.Duration(cfg.RateBaseline+1) * cfg.RateInterval)
+	analyzeAtRate(detector, clock, 2, cfg.RateInterval)
+	analyzeAtRate(detector, clock, 2, cfg.RateInterval)
+
+	if len(recorder.alerts) != 0 {
+		t.Errorf("alerts = %v, want none against a baseline older than the silence", recorder.types())
+	}
+}
+
+func TestEventRateDropRaisedForSilentMachine(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.RateInterval = time.Minute
+	cfg.RateBaseline = 3
+
+	// The rate check raises alerts on its own goroutine
+	alerts := make(chan *models.Alert, 10)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC))
+	detector := NewAnomalyDetector(cfg, clock, func(alert *models.Alert) { alerts <- alert }, nil)
+
+	for i := 0; i < cfg.RateBaseline; i++ {
+		analyzeAtRate(detector, clock, 10, cfg.RateInterval)
+	}
+
+	detector.Start()
+	defer detector.Stop()
+	waitForTickers(t, clock, 1)
+	clock.Advance(cfg.RateInterval + time.Second)
+
+	alert := waitForAlert(t, alerts)
+	if alert.AlertType != "event_rate_drop" || alert.MachineID != "conveyor_001" {
+		t.Fatalf("alert = %s for %s, want event_rate_drop for conveyor_001", alert.AlertType, alert.MachineID)
+	}
+	if !strings.Contains(alert.Message, "sent 0 events") {
+		t.Errorf("message = %q, want the empty interval reported", alert.Message)
+	}
+}
+
+// analyzeStatuses feeds a machine one event per status, a second apart, returning how
+// many repeated_faults alerts were raised
+func analyzeStatuses(detector *AnomalyDetector, clock *FakeClock, recorder *alertRecorder, machineID string, statuses ...string) int {
+	before := countType(recorder, "repeated_faults")
+	for _, status := range statuses {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: machineID, EventType: "conveyor", Status: status, Timestamp: clock.Now()})
+		clock.Advance(time.Second)
+	}
+	return countType(recorder, "repeated_faults") - before
+}
+
+// countType counts the recorded alerts of a type
+func countType(recorder *alertRecorder, alertType string) int {
+	count := 0
+	for _, alert := range recorder.alerts {
+		if alert.AlertType == alertType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSnoozeSuppressesAlertsUntilExpiry(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+	detector.Snooze("conveyor_001", "temperature_high", clock.Now().Add(time.Hour))
+
+	analyzeHot := func(machineID string) {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: machineID, EventType: "conveyor", Status: "ok", Temperature: float(150), Timestamp: clock.Now()})
+	}
+	analyzeHot("conveyor_001")
+	analyzeHot("conveyor_002")
+	if len(recorder.alerts) != 1 || recorder.alerts[0].MachineID != "conveyor_002" {
+		t.Fatalf("alerts = %v, want temperature_high for conveyor_002 only while conveyor_001 is snoozed", recorder.types())
+	}
+
+	clock.Advance(time.Hour)
+	analyzeHot("conveyor_001")
+	if count := countType(recorder, "temperature_high"); count != 2 {
+		t.Errorf("temperature_high raised %d times, want alerting for conveyor_001 resumed after the snooze", count)
+	}
+}
+
+func TestRepeatedFaultsFireAtConfiguredLimit(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.PatternMinEvents = 5
+	cfg.Pattern = config.PatternRule{Lookback: 5, FaultLimit: 2}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_001", "ok", "ok", "ok", "ok", "fault"); fired != 0 {
+		t.Fatalf("repeated_faults raised %d times for 1 fault, want none below the limit", fired)
+	}
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_001", "fault"); fired != 1 {
+		t.Fatalf("repeated_faults raised %d times at 2 faults in 5 events, want 1", fired)
+	}
+}
+
+func TestRepeatedFaultsCountOnlyLookback(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.PatternMinEvents = 10
+	cfg.Pattern = config.PatternRule{Lookback: 5, FaultLimit: 2}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	// Both faults are among the minimum 10 events but outside the last 5
+	fired := analyzeStatuses(detector, clock, recorder, "conveyor_001",
+		"fault", "fault", "ok", "ok", "ok", "ok", "ok", "ok", "ok", "ok")
+	if fired != 0 {
+		t.Errorf("repeated_faults raised %d times for faults outside the lookback, want none", fired)
+	}
+}
+
+func TestRepeatedFaultsWaitForMinimumEvents(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.PatternMinEvents = 10
+	cfg.Pattern = config.PatternRule{Lookback: 5, FaultLimit: 2}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_001", "ok", "ok", "ok", "fault", "fault"); fired != 0 {
+		t.Fatalf("repeated_faults raised %d times before the window held 10 events", fired)
+	}
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_001", "ok", "ok", "ok", "fault", "fault"); fired != 1 {
+		t.Errorf("repeated_faults raised %d times once the window held 10 events, want 1", fired)
+	}
+}
+
+func TestRepeatedFaultsUseMachineOverride(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.PatternMinEvents = 5
+	cfg.Pattern = config.PatternRule{Lookback: 5, FaultLimit: 3}
+	cfg.PatternOverrides = map[string]config.PatternRule{"conveyor_002": {Lookback: 5, FaultLimit: 2}}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	statuses := []string{"ok", "ok", "ok", "fault", "fault"}
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_001", statuses...); fired != 0 {
+		t.Errorf("repeated_faults raised %d times under the default rule, want none", fired)
+	}
+	if fired := analyzeStatuses(detector, clock, recorder, "conveyor_002", statuses...); fired != 1 {
+		t.Errorf("repeated_faults raised %d times under the override, want 1", fired)
+	}
+}
+
+func TestIdenticalEventsKeptWithoutEventDedup(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.PatternMinEvents = 3
+	cfg.Pattern = config.PatternRule{Lookback: 3, FaultLimit: 3}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	// Distinct readings reported within the same second carry identical content
+	timestamp := clock.Now()
+	for i := 0; i < 3; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "fault", Timestamp: timestamp})
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	if got := detector.slidingWindow["conveyor_001"].Len(); got != 3 {
+		t.Errorf("window holds %d events, want all 3 identical events kept", got)
+	}
+	if fired := countType(recorder, "repeated_faults"); fired != 1 {
+		t.Errorf("repeated_faults raised %d times, want 1 for 3 faults", fired)
+	}
+}
+
+func TestIdenticalEventsSkippedWithinEventDedupWindow(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.EventDedupWindow = time.Minute
+	detector, clock, _ := newTestDetector(cfg)
+
+	timestamp := clock.Now()
+	for i := 0; i < 3; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: timestamp})
+		clock.Advance(10 * time.Second)
+	}
+	if got := detector.slidingWindow["conveyor_001"].Len(); got != 1 {
+		t.Errorf("window holds %d events, want redeliveries skipped", got)
+	}
+
+	clock.Advance(time.Minute)
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: timestamp})
+	if got := detector.slidingWindow["conveyor_001"].Len(); got != 2 {
+		t.Errorf("window holds %d events, want an identical event kept after the window", got)
+	}
+}
+
+func TestEvictionKeepsOfflineStateUntilMachineReturns(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.OfflineTimeout = time.Minute
+	cfg.WindowTTL = 10 * time.Minute
+	detector, clock, recorder := newTestDetector(cfg)
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+	clock.Advance(2 * time.Minute)
+	detector.checkOfflineMachines(clock.Now())
+	clock.Advance(20 * time.Minute)
+	detector.evictStaleWindows(clock.Now())
+
+	if _, exists := detector.slidingWindow["conveyor_001"]; exists {
+		t.Error("stale sliding window kept")
+	}
+	if !detector.IsOffline("conveyor_001") {
+		t.Fatal("eviction cleared the open machine_offline state")
+	}
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+	if got := recorder.types(); len(got) != 2 || got[0] != "machine_offline" || got[1] != "machine_online" {
+		t.Errorf("alerts = %v, want machine_offline then machine_online", got)
+	}
+}
+
+func TestEvictionKeepsActiveConditionsUntilResolved(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.ResolveAfter = 30 * time.Second
+	cfg.WindowTTL = 10 * time.Minute
+	detector, clock, recorder := newTestDetector(cfg)
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(95), Timestamp: clock.Now()})
+	clock.Advance(20 * time.Minute)
+	detector.evictStaleWindows(clock.Now())
+	if _, exists := detector.slidingWindow["conveyor_001"]; exists {
+		t.Error("stale sliding window kept")
+	}
+
+	for i := 0; i < 2; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(50), Timestamp: clock.Now()})
+		clock.Advance(time.Minute)
+	}
+	if len(recorder.resolutions) != 1 || recorder.resolutions[0].AlertType != "temperature_high" {
+		t.Errorf("resolutions = %+v, want temperature_high resolved after the machine returned", recorder.resolutions)
+	}
+}
+
+func TestEvictionDropsIdleMachinesWithoutOpenAlerts(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WindowTTL = 10 * time.Minute
+	detector, clock, _ := newTestDetector(cfg)
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+	clock.Advance(5 * time.Minute)
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_002", EventType: "conveyor", Status: "ok", Timestamp: clock.Now()})
+	clock.Advance(6 * time.Minute)
+	detector.evictStaleWindows(clock.Now())
+
+	if _, exists := detector.slidingWindow["conveyor_001"]; exists {
+		t.Error("stale sliding window kept")
+	}
+	if stats := detector.GetMachineStats("conveyor_001"); stats != nil {
+		t.Errorf("stats of an evicted machine = %v, want it treated as unknown", stats)
+	}
+	if _, exists := detector.lastSeen["conveyor_001"]; exists {
+		t.Error("liveness of an evicted machine without open alerts kept")
+	}
+	if _, exists := detector.slidingWindow["conveyor_002"]; !exists {
+		t.Error("window of a machine seen within the TTL evicted")
+	}
+}
+
+// analyzeUnstableSpeeds feeds a machine count events alternating between 1.0 and 2.2,
+// a second apart
+func analyzeUnstableSpeeds(detector *AnomalyDetector, clock *FakeClock, count int) {
+	for i := 0; i < count; i++ {
+		speed := 1.0
+		if i%2 == 1 {
+			speed = 2.2
+		}
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", ConveyorSpeed: float(speed), Timestamp: clock.Now()})
+		clock.Advance(time.Second)
+	}
+}
+
+func TestTrendDetectionWaitsForMinimumEvents(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.TrendMinEvents = 8
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyzeUnstableSpeeds(detector, clock, 7)
+	if count := countType(recorder, "speed_instability"); count != 0 {
+		t.Fatalf("speed_instability raised %d times below the minimum of %d events", count, cfg.TrendMinEvents)
+	}
+
+	analyzeUnstableSpeeds(detector, clock, 1)
+	if count := countType(recorder, "speed_instability"); count != 1 {
+		t.Errorf("speed_instability raised %d times at the minimum, want 1", count)
+	}
+}
+
+// analyzeRisingTemperatures analyzes events one second apart with the temperature rising
+// 3°C per second, pausing for gap after the events in pauseAfter
+func analyzeRisingTemperatures(detector *AnomalyDetector, clock *FakeClock, count, pauseAfter int, gap time.Duration) {
+	for i := 0; i < count; i++ {
+		if i == pauseAfter {
+			clock.Advance(gap)
+		}
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(40 + 3*float64(i)), Timestamp: clock.Now()})
+		clock.Advance(time.Second)
+	}
+}
+
+func TestTrendDetectionSkipsSeriesSpanningMaxGap(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		maxGap    time.Duration
+		gap       time.Duration
+		wantAlert bool
+	}{
+		{"continuous series", 5 * time.Second, 0, true},
+		{"gap without a maximum", 0, time.Minute, true},
+		{"gap beyond the maximum", 5 * time.Second, time.Minute, false},
+		{"gap within the maximum", 5 * time.Second, 3 * time.Second, true},
+	} {
+		cfg := testAnomalyConfig()
+		cfg.TrendMaxGap = tc.maxGap
+		detector, clock, recorder := newTestDetector(cfg)
+
+		analyzeRisingTemperatures(detector, clock, 11, 4, tc.gap)
+		if raised := countType(recorder, "rapid_temperature_change") > 0; raised != tc.wantAlert {
+			t.Errorf("%s: rapid_temperature_change raised = %t, want %t", tc.name, raised, tc.wantAlert)
+		}
+	}
+}
+
+func TestSpeedInstabilityUsesStandardDeviation(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+
+	// Speeds alternating 1.0 and 2.2 have a standard deviation of 0.6
+	analyzeUnstableSpeeds(detector, clock, 10)
+
+	var alert *models.Alert
+	for _, recorded := range recorder.alerts {
+		if recorded.AlertType == "speed_instability" {
+			alert = recorded
+		}
+	}
+	if alert == nil {
+		t.Fatalf("alerts = %v, want speed_instability for a standard deviation of 0.6", recorder.types())
+	}
+	if want := 0.6 / (0.6 + speedSpreadLimit); alert.Confidence == nil || math.Abs(*alert.Confidence-want) > 1e-9 {
+		t.Errorf("confidence = %v, want %.4f from the standard deviation", alert.Confidence, want)
+	}
+}
+
+func TestHigherSpeedSpreadYieldsHigherConfidence(t *testing.T) {
+	confidence := func(high float64) float64 {
+		detector, clock, recorder := newTestDetector(testAnomalyConfig())
+		for i := 0; i < 10; i++ {
+			speed := 1.0
+			if i%2 == 1 {
+				speed = high
+			}
+			detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", ConveyorSpeed: float(speed), Timestamp: clock.Now()})
+			clock.Advance(time.Second)
+		}
+		for _, alert := range recorder.alerts {
+			if alert.AlertType == "speed_instability" && alert.Confidence != nil {
+				return *alert.Confidence
+			}
+		}
+		t.Fatalf("no speed_instability alert with a confidence for speeds 1.0/%.1f", high)
+		return 0
+	}
+
+	if moderate, severe := confidence(2.2), confidence(3.0); severe <= moderate {
+		t.Errorf("confidence = %.3f for the wider spread, want above %.3f", severe, moderate)
+	}
+}
+
+func TestSteadySpeedIsStable(t *testing.T) {
+	detector, clock, _ := newTestDetector(testAnomalyConfig())
+
+	var events []*models.SensorEvent
+	for i := 0; i < 10; i++ {
+		events = append(events, &models.SensorEvent{MachineID: "conveyor_001", ConveyorSpeed: float(1.5 + 0.01*float64(i%2)), Timestamp: clock.Now()})
+	}
+	if spread, detected := detector.detectSpeedInstability(events); detected || spread > 0.01 {
+		t.Errorf("steady speeds gave spread %.4f, detected %t", spread, detected)
+	}
+}
+
+func TestAlertMessagesUseDisplayTemperatureUnit(t *testing.T) {
+	for _, tc := range []struct {
+		unit models.TemperatureUnit
+		want string
+	}{
+		{models.Celsius, "Temperature above maximum threshold: 100.0°C (max: 85.0°C)"},
+		{models.Fahrenheit, "Temperature above maximum threshold: 212.0°F (max: 185.0°F)"},
+	} {
+		cfg := testAnomalyConfig()
+		cfg.TemperatureUnit = tc.unit
+		detector, clock, recorder := newTestDetector(cfg)
+
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(100), Timestamp: clock.Now()})
+		if len(recorder.alerts) != 1 || recorder.alerts[0].Message != tc.want {
+			t.Errorf("%s: alerts = %v, want %q", tc.unit, recorder.alerts, tc.want)
+		}
+	}
+}
+
+func TestConfiguredThresholdSeverityAppliedToAlerts(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+
+	thresholds := *detector.GetThresholds()
+	thresholds.RobotAngleSeverity = "high"
+	thresholds.TemperatureHighSeverity = "" // Left empty, keeps its current severity
+	detector.UpdateThresholds(&thresholds)
+
+	detector.AnalyzeEvent(&models.SensorEvent{
+		MachineID:     "robot_001",
+		EventType:     "robot",
+		Status:        "ok",
+		Timestamp:     clock.Now(),
+		RobotArmAngle: float(200),
+		Temperature:   float(150),
+	})
+
+	severities := map[string]string{}
+	for _, alert := range recorder.alerts {
+		severities[alert.AlertType] = alert.Severity
+	}
+	if severities["robot_angle_invalid"] != "high" || severities["temperature_high"] != "high" {
+		t.Errorf("severities = %v, want robot_angle_invalid at the configured high and temperature_high kept at high", severities)
+	}
+}
+
+func TestMachineStatsReportWindowPercentiles(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WindowSize = 100
+	detector, clock, _ := newTestDetector(cfg)
+
+	for i := 0; i < 100; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok",
+			Temperature: float(float64((i*37)%100 + 1)), ConveyorSpeed: float(2), Timestamp: clock.Now()})
+		clock.Advance(time.Second)
+	}
+
+	stats := detector.GetMachineStats("conveyor_001")
+	for key, want := range map[string]float64{"p95_temperature": 95.05, "p99_temperature": 99.01, "p95_conveyor_speed": 2, "p99_conveyor_speed": 2} {
+		got := stats[key].(*float64)
+		if got == nil || math.Abs(*got-want) > 1e-9 {
+			t.Errorf("%s = %v, want %g", key, got, want)
+		}
+	}
+}
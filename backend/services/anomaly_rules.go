@@ -0,0 +1,80 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+)
+
+// Detection rules that operators can switch off at runtime
+const (
+	RuleThresholds             = "thresholds"
+	RuleStatus                 = "status"
+	RuleRapidTemperatureChange = "rapid_temperature_change"
+	RuleSpeedInstability       = "speed_instability"
+	RuleRepeatedFaults         = "repeated_faults"
+	RuleMachineOffline         = "machine_offline"
+	RuleEventRateDrop          = "event_rate_drop"
+)
+
+// anomalyRules lists the detection rules in the order they are reported
+var anomalyRules = []struct {
+	name        string
+	description string
+}{
+	{RuleThresholds, "Conveyor speed, temperature and robot arm angle outside their thresholds"},
+	{RuleStatus, "Events reporting a fault or warning status"},
+	{RuleRapidTemperatureChange, "Temperature changing faster than the rate limit"},
+	{RuleSpeedInstability, "Conveyor speed fluctuating beyond the spread limit"},
+	{RuleRepeatedFaults, "Repeated faults within the pattern lookback"},
+	{RuleMachineOffline, "Machines going silent longer than the offline timeout, and their recovery"},
+	{RuleEventRateDrop, "Machines sending events well below their recent baseline rate"},
+}
+
+// Rules returns every detection rule with whether it is enabled
+func (ad *AnomalyDetector) Rules() []models.AnomalyRule {
+	ad.mutex.RLock()
+	defer ad.mutex.RUnlock()
+
+	rules := make([]models.AnomalyRule, len(anomalyRules))
+	for i, rule := range anomalyRules {
+		rules[i] = models.AnomalyRule{
+			Name:        rule.name,
+			Description: rule.description,
+			Enabled:     !ad.disabledRules[rule.name],
+		}
+	}
+	return rules
+}
+
+// SetRuleEnabled switches a detection rule on or off. Alerts already raised by a
+// disabled rule stay open until their condition clears.
+func (ad *AnomalyDetector) SetRuleEnabled(name string, enabled bool) error {
+	if !IsAnomalyRule(name) {
+		return fmt.Errorf("unknown anomaly rule %q", name)
+	}
+
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
+
+	if enabled {
+		delete(ad.disabledRules, name)
+	} else {
+		ad.disabledRules[name] = true
+	}
+	return nil
+}
+
+// ruleEnabled reports whether a detection rule is enabled. The caller must hold ad.mutex.
+func (ad *AnomalyDetector) ruleEnabled(name string) bool {
+	return !ad.disabledRules[name]
+}
+
+// IsAnomalyRule reports whether name is a known detection rule
+func IsAnomalyRule(name string) bool {
+	for _, rule := range anomalyRules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
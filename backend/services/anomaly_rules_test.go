@@ -0,0 +1,36 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+)
+
+func TestDisabledRuleRaisesNoAlertsWhileOthersFire(t *testing.T) {
+	detector, clock, recorder := newTestDetector(testAnomalyConfig())
+	if err := detector.SetRuleEnabled(RuleSpeedInstability, false); err != nil {
+		t.Fatalf("SetRuleEnabled: %v", err)
+	}
+	hot := detector.GetThresholds().TemperatureMax + 10
+
+	analyzeUnstableSpeeds(detector, clock, 12)
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(hot), Timestamp: clock.Now()})
+
+	if count := countType(recorder, "speed_instability"); count != 0 {
+		t.Errorf("%d speed_instability alerts with the rule disabled, want none", count)
+	}
+	if count := countType(recorder, "temperature_high"); count != 1 {
+		t.Errorf("%d temperature_high alerts, want the enabled threshold rule to fire once", count)
+	}
+
+	if err := detector.SetRuleEnabled(RuleSpeedInstability, true); err != nil {
+		t.Fatalf("SetRuleEnabled: %v", err)
+	}
+	analyzeUnstableSpeeds(detector, clock, 2)
+	if count := countType(recorder, "speed_instability"); count == 0 {
+		t.Error("no speed_instability alert after re-enabling the rule")
+	}
+
+	if err := detector.SetRuleEnabled("no_such_rule", false); err == nil {
+		t.Error("unknown rule accepted")
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import "time"
+
+// Clock supplies the current time and tickers, so time-based behaviour can be driven
+// deterministically instead of waiting on the wall clock
+type Clock interface {
+	Now() time.Time
+	NewTicker(interval time.Duration) Ticker
+}
+
+// Ticker delivers ticks on C until stopped
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the wall clock
+type RealClock struct{}
+
+// Now returns the current time
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a ticker backed by time.Ticker
+func (RealClock) NewTicker(interval time.Duration) Ticker {
+	return realTicker{time.NewTicker(interval)}
+}
+
+// realTicker adapts time.Ticker to the Ticker interface
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+// replayClock reports the time of the event being replayed to an unstarted detector. Such
+// detectors run no background tasks, so its tickers never fire.
+type replayClock struct {
+	now time.Time
+}
+
+// Now returns the time of the event being replayed
+func (c *replayClock) Now() time.Time {
+	return c.now
+}
+
+// NewTicker returns a ticker that never fires
+func (c *replayClock) NewTicker(time.Duration) Ticker {
+	return idleTicker{}
+}
+
+// idleTicker is a ticker that never fires
+type idleTicker struct{}
+
+func (idleTicker) C() <-chan time.Time { return nil }
+func (idleTicker) Stop()               {}
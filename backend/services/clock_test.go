@@ -0,0 +1,96 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a manually advanced clock. A ticker fires once, with the new time, when
+// Advance moves time past its next tick; like time.Ticker, ticks a slow receiver misses
+// are dropped.
+type FakeClock struct {
+	now     time.Time
+	tickers []*fakeTicker
+	mutex   sync.Mutex
+}
+
+// NewFakeClock creates a fake clock starting at the given time
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time
+func (fc *FakeClock) Now() time.Time {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return fc.now
+}
+
+// NewTicker returns a ticker that fires as the clock is advanced
+func (fc *FakeClock) NewTicker(interval time.Duration) Ticker {
+	if interval <= 0 {
+		panic("services: non-positive interval for FakeClock.NewTicker")
+	}
+
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	ticker := &fakeTicker{
+		clock:    fc,
+		interval: interval,
+		next:     fc.now.Add(interval),
+		c:        make(chan time.Time, 1),
+	}
+	fc.tickers = append(fc.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward, firing every ticker whose next tick has been reached
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	fc.now = fc.now.Add(d)
+	for _, ticker := range fc.tickers {
+		if ticker.next.After(fc.now) {
+			continue
+		}
+		select {
+		case ticker.c <- fc.now:
+		default:
+		}
+		for !ticker.next.After(fc.now) {
+			ticker.next = ticker.next.Add(ticker.interval)
+		}
+	}
+}
+
+// Tickers returns how many tickers are active, so callers can wait for background
+// tasks to start before advancing the clock
+func (fc *FakeClock) Tickers() int {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	return len(fc.tickers)
+}
+
+// fakeTicker is a ticker driven by a FakeClock
+type fakeTicker struct {
+	clock    *FakeClock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"backend/config"
+	"backend/models"
+	"strings"
+	"time"
+)
+
+// snapshotRecentEvents is how many of each machine's latest events a snapshot includes
+const snapshotRecentEvents = 10
+
+// DetectorSnapshot is a point-in-time copy of the anomaly detector's internal state,
+// for diagnosing why an alert did or did not fire. Values are as stored, in Celsius.
+type DetectorSnapshot struct {
+	TakenAt    time.Time                   `json:"taken_at"`
+	Thresholds models.AnomalyThresholds    `json:"thresholds"` // Applied to every machine
+	Settings   DetectorSettings            `json:"settings"`
+	Machines   map[string]*MachineSnapshot `json:"machines"`
+
+	SuppressedDuplicates uint64 `json:"suppressed_duplicates"`    // Alerts dropped as repeats within the dedup window
+	SkippedEvents        uint64 `json:"skipped_duplicate_events"` // Events not analyzed as repeats within the event dedup window
+}
+
+// DetectorSettings are the detector's configured limits
+type DetectorSettings struct {
+	WindowSize       int                `json:"window_size"`
+	TrendMinEvents   int                `json:"trend_min_events"`
+	PatternMinEvents int                `json:"pattern_min_events"`
+	Pattern          config.PatternRule `json:"pattern"` // Default repeated fault rule
+	TrendMaxGap      string             `json:"trend_max_gap"`
+	ResolveAfter     string             `json:"resolve_after"`
+	OfflineTimeout   string             `json:"offline_timeout"`
+	WindowTTL        string             `json:"window_ttl"`
+	DedupWindow      string             `json:"dedup_window"`
+	EventDedupWindow string             `json:"event_dedup_window"`
+	RateInterval     string             `json:"rate_interval"`
+	RateBaseline     int                `json:"rate_baseline_intervals"`
+	RateDropFraction float64            `json:"rate_drop_fraction"`
+	WarmupEvents     int                `json:"warmup_events"`
+	WarmupDuration   string             `json:"warmup_duration"`
+	StatusDemotions  map[string]string  `json:"status_demotions"` // Status analyzed in place of the reported one, by event type
+}
+
+// MachineSnapshot is the detector state held for one machine
+type MachineSnapshot struct {
+	WindowSize   int                   `json:"window_size"`
+	Pattern      config.PatternRule    `json:"pattern"` // Repeated fault rule in effect
+	WindowFill   int                   `json:"window_fill"`
+	RecentEvents []SnapshotEvent       `json:"recent_events"` // Oldest first
+	LastSeen     *time.Time            `json:"last_seen"`
+	Offline      bool                  `json:"offline"`
+	Conditions   map[string]*time.Time `json:"conditions"` // Active alert types, with when each cleared (null while violated)
+	Snoozes      map[string]time.Time  `json:"snoozes"`    // Alert types suppressed until the given time
+	EventRate    *RateSnapshot         `json:"event_rate,omitempty"`
+	WarmupEvents *int                  `json:"warmup_events,omitempty"` // Events seen while warming up; absent once warmed up
+}
+
+// RateSnapshot is a machine's event rate tracking state
+type RateSnapshot struct {
+	IntervalStart time.Time `json:"interval_start"`
+	Count         int       `json:"count"`    // Events so far in the current interval
+	History       []int     `json:"history"`  // Counts of recently completed intervals, oldest first
+	Baseline      *float64  `json:"baseline"` // Mean of history; null until the baseline is full
+	Dropped       bool      `json:"dropped"`
+}
+
+// SnapshotEvent holds the values of a windowed event that detection looks at
+type SnapshotEvent struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Status        string    `json:"status"`
+	ConveyorSpeed *float64  `json:"conveyor_speed"`
+	Temperature   *float64  `json:"temperature"`
+	RobotArmAngle *float64  `json:"robot_arm_angle"`
+}
+
+// Snapshot copies the detector's state under its locks, so the result is consistent and
+// safe to inspect while events keep being analyzed
+func (ad *AnomalyDetector) Snapshot() *DetectorSnapshot {
+	ad.mutex.RLock()
+	defer ad.mutex.RUnlock()
+
+	snapshot := &DetectorSnapshot{
+		TakenAt:    ad.clock.Now(),
+		Thresholds: *ad.thresholds,
+		Settings: DetectorSettings{
+			WindowSize:       ad.windowSize,
+			TrendMinEvents:   ad.trendMinEvents,
+			PatternMinEvents: ad.patternMinEvents,
+			Pattern:          ad.pattern,
+			TrendMaxGap:      ad.trendMaxGap.String(),
+			ResolveAfter:     ad.resolveAfter.String(),
+			OfflineTimeout:   ad.offlineTimeout.String(),
+			WindowTTL:        ad.windowTTL.String(),
+			DedupWindow:      "0s",
+			EventDedupWindow: "0s",
+			RateInterval:     ad.rateInterval.String(),
+			RateBaseline:     ad.rateBaseline,
+			RateDropFraction: ad.rateDropFraction,
+			WarmupEvents:     ad.warmupEvents,
+			WarmupDuration:   ad.warmupDuration.String(),
+			StatusDemotions:  ad.statusDemotions,
+		},
+		Machines: make(map[string]*MachineSnapshot),
+	}
+	if ad.dedup != nil {
+		snapshot.Settings.DedupWindow = ad.dedup.window.String()
+		snapshot.SuppressedDuplicates = ad.dedup.suppressedCount()
+	}
+	if ad.eventDedup != nil {
+		snapshot.Settings.EventDedupWindow = ad.eventDedup.window.String()
+		snapshot.SkippedEvents = ad.eventDedup.skipped
+	}
+
+	machine := func(machineID string) *MachineSnapshot {
+		state, exists := snapshot.Machines[machineID]
+		if !exists {
+			state = &MachineSnapshot{
+				Conditions: make(map[string]*time.Time),
+				Snoozes:    make(map[string]time.Time),
+			}
+			snapshot.Machines[machineID] = state
+		}
+		return state
+	}
+
+	for machineID, window := range ad.slidingWindow {
+		state := machine(machineID)
+		events := window.GetEvents()
+		state.Pattern = ad.patternRule(machineID)
+		state.WindowSize = window.maxSize
+		state.WindowFill = len(events)
+
+		recent := window.GetRecentEvents(snapshotRecentEvents)
+		state.RecentEvents = make([]SnapshotEvent, 0, len(recent))
+		for _, event := range recent {
+			state.RecentEvents = append(state.RecentEvents, SnapshotEvent{
+				Timestamp:     event.Timestamp,
+				Status:        event.Status,
+				ConveyorSpeed: copyMetric(event.ConveyorSpeed),
+				Temperature:   copyMetric(event.Temperature),
+				RobotArmAngle: copyMetric(event.RobotArmAngle),
+			})
+		}
+	}
+
+	for machineID, lastSeen := range ad.lastSeen {
+		seen := lastSeen
+		state := machine(machineID)
+		state.LastSeen = &seen
+		state.Offline = ad.offline[machineID]
+	}
+
+	for machineID, conditions := range ad.conditions {
+		state := machine(machineID)
+		for alertType, clearSince := range conditions {
+			if clearSince.IsZero() {
+				state.Conditions[alertType] = nil
+				continue
+			}
+			cleared := clearSince
+			state.Conditions[alertType] = &cleared
+		}
+	}
+
+	for machineID, rate := range ad.rates {
+		state := &RateSnapshot{
+			IntervalStart: rate.intervalStart,
+			Count:         rate.count,
+			History:       append([]int(nil), rate.history...),
+			Dropped:       rate.dropped,
+		}
+		if len(rate.history) >= ad.rateBaseline {
+			baseline := rate.baseline()
+			state.Baseline = &baseline
+		}
+		machine(machineID).EventRate = state
+	}
+
+	if ad.warmupEvents > 0 || ad.warmupDuration > 0 {
+		for machineID, warmup := range ad.warmups {
+			events := warmup.events
+			machine(machineID).WarmupEvents = &events
+		}
+	}
+
+	ad.snoozeMutex.Lock()
+	defer ad.snoozeMutex.Unlock()
+	for key, until := range ad.snoozed {
+		if !snapshot.TakenAt.Before(until) {
+			continue // Expired; discarded on the next alert of its type
+		}
+		separator := strings.LastIndex(key, "/") // Alert types never contain a slash
+		machineID, alertType := key[:separator], key[separator+1:]
+		machine(machineID).Snoozes[alertType] = until
+	}
+
+	return snapshot
+}
+
+// copyMetric copies a metric value so the snapshot does not share the windowed event
+func copyMetric(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestSnapshotReflectsInjectedState(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.ResolveAfter = time.Minute
+	detector, clock, _ := newTestDetector(cfg)
+	hot := detector.GetThresholds().TemperatureMax + 10
+
+	for i := 0; i < 12; i++ {
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(40), Timestamp: clock.Now()})
+		clock.Advance(time.Second)
+	}
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(hot), Timestamp: clock.Now()})
+	lastSeen := clock.Now()
+	snoozedUntil := clock.Now().Add(time.Hour)
+	detector.Snooze("conveyor_002", "speed_low", snoozedUntil)
+
+	snapshot := detector.Snapshot()
+	if !snapshot.TakenAt.Equal(clock.Now()) || snapshot.Settings.WindowSize != 50 || snapshot.Thresholds.TemperatureMax != hot-10 {
+		t.Errorf("snapshot = %+v, want the detector's clock, settings and thresholds", snapshot)
+	}
+
+	machine := snapshot.Machines["conveyor_001"]
+	if machine == nil {
+		t.Fatalf("machines = %v, want conveyor_001", snapshot.Machines)
+	}
+	if machine.WindowSize != 50 || machine.WindowFill != 13 {
+		t.Errorf("window = %d of %d, want 13 of 50", machine.WindowFill, machine.WindowSize)
+	}
+	if len(machine.RecentEvents) != snapshotRecentEvents {
+		t.Fatalf("recent events = %d, want %d", len(machine.RecentEvents), snapshotRecentEvents)
+	}
+	if newest := machine.RecentEvents[len(machine.RecentEvents)-1]; *newest.Temperature != hot {
+		t.Errorf("newest recent event = %+v, want the %.0f reading last", newest, hot)
+	}
+	if machine.LastSeen == nil || !machine.LastSeen.Equal(lastSeen) || machine.Offline {
+		t.Errorf("last seen = %v, offline = %v, want online and seen at %s", machine.LastSeen, machine.Offline, lastSeen)
+	}
+	if cleared, active := machine.Conditions["temperature_high"]; !active || cleared != nil {
+		t.Errorf("conditions = %v, want temperature_high still violated", machine.Conditions)
+	}
+
+	snoozed := snapshot.Machines["conveyor_002"]
+	if snoozed == nil || !snoozed.Snoozes["speed_low"].Equal(snoozedUntil) {
+		t.Errorf("conveyor_002 = %+v, want speed_low snoozed until %s", snoozed, snoozedUntil)
+	}
+
+	// The snapshot is a copy, unaffected by later events
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(40), Timestamp: clock.Now()})
+	if machine.WindowFill != 13 || *machine.RecentEvents[len(machine.RecentEvents)-1].Temperature != hot {
+		t.Error("snapshot changed after a later event")
+	}
+}
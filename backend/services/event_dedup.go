@@ -0,0 +1,78 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// eventDeduper recognizes events analyzed within the window, such as Kafka messages
+// redelivered after a rebalance, so they are not added to sliding windows twice. Events
+// carry no ID until stored, and a redelivered event is stored again under a new one, so
+// events are identified by a hash of their machine, timestamp, type, status, readings and
+// fault code. It is guarded by the detector mutex.
+type eventDeduper struct {
+	window    time.Duration
+	seen      map[[sha256.Size]byte]time.Time
+	lastPrune time.Time
+	skipped   uint64
+}
+
+// newEventDeduper creates a deduper, or returns nil when window disables deduplication
+func newEventDeduper(window time.Duration) *eventDeduper {
+	if window <= 0 {
+		return nil
+	}
+	return &eventDeduper{
+		window: window,
+		seen:   make(map[[sha256.Size]byte]time.Time),
+	}
+}
+
+// duplicate reports whether an identical event was seen within the window before now,
+// counting it as skipped if so, and otherwise records the event as seen
+func (d *eventDeduper) duplicate(event *models.SensorEvent, now time.Time) bool {
+	d.prune(now)
+
+	key := eventFingerprint(event)
+	if seen, ok := d.seen[key]; ok && now.Sub(seen) < d.window {
+		d.skipped++
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
+
+// prune drops expired fingerprints, at most once per window
+func (d *eventDeduper) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.window {
+		return
+	}
+	for key, seen := range d.seen {
+		if now.Sub(seen) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+	d.lastPrune = now
+}
+
+// eventFingerprint hashes the fields that identify an event as reported by its machine
+func eventFingerprint(event *models.SensorEvent) [sha256.Size]byte {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%d\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s", event.MachineID, event.Timestamp.UnixNano(),
+		event.EventType, event.Status, metricKey(event.ConveyorSpeed), metricKey(event.Temperature),
+		metricKey(event.RobotArmAngle), event.FaultCode)
+
+	var key [sha256.Size]byte
+	hash.Sum(key[:0])
+	return key
+}
+
+// metricKey formats an optional metric for fingerprinting, distinguishing missing from zero
+func metricKey(value *float64) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%g", *value)
+}
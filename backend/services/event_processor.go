@@ -0,0 +1,72 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"backend/websocket"
+	"log"
+	"sync"
+)
+
+// StatusObserver is told about every processed event, e.g. to publish machine status changes
+type StatusObserver interface {
+	Observe(event *models.SensorEvent)
+}
+
+// EventProcessor runs validated sensor events through the storage, anomaly
+// detection and broadcast pipeline, independent of how they were ingested
+type EventProcessor struct {
+	db       database.Store
+	machines *MachineCache
+	detector *AnomalyDetector
+	hub      *websocket.Hub
+	observer StatusObserver
+	mutex    sync.RWMutex // Guards observer, which is registered once Kafka is reachable
+}
+
+// NewEventProcessor creates a new event processor
+func NewEventProcessor(db database.Store, machines *MachineCache, detector *AnomalyDetector, hub *websocket.Hub) *EventProcessor {
+	return &EventProcessor{
+		db:       db,
+		machines: machines,
+		detector: detector,
+		hub:      hub,
+	}
+}
+
+// SetStatusObserver registers an observer for processed events; nil removes it
+func (p *EventProcessor) SetStatusObserver(observer StatusObserver) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.observer = observer
+}
+
+// Process stores, analyzes and broadcasts a single event
+func (p *EventProcessor) Process(event *models.SensorEvent) (*models.Event, error) {
+	// Attach machine type and location from the registry
+	p.machines.Enrich(event)
+
+	// Store event in database
+	dbEvent, err := p.db.InsertEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	event.ID = dbEvent.ID
+
+	// Analyze for anomalies
+	p.detector.AnalyzeEvent(event)
+
+	// Broadcast to WebSocket clients
+	p.hub.BroadcastEvent(event)
+
+	p.mutex.RLock()
+	if p.observer != nil {
+		p.observer.Observe(event)
+	}
+	p.mutex.RUnlock()
+
+	log.Printf("Event processed: ID=%d, Machine=%s, Status=%s",
+		dbEvent.ID, event.MachineID, event.Status)
+
+	return dbEvent, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/models"
+	"backend/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// newTestProcessor creates an event processor over store whose broadcasts go to a
+// running hub, returning a client connection to that hub
+func newTestProcessor(t *testing.T, store database.Store) (*EventProcessor, *gorilla.Conn) {
+	t.Helper()
+	hub := websocket.NewHub(config.WebSocketConfig{}, store)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	readMessage(t, conn, "connection")
+
+	machines := NewMachineCache(store, 0)
+	if err := machines.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	detector := NewAnomalyDetector(testAnomalyConfig(), nil, func(*models.Alert) {}, nil)
+	return NewEventProcessor(store, machines, detector, hub), conn
+}
+
+// readMessage returns the data of the next message of msgType sent on conn, skipping
+// others
+func readMessage(t *testing.T, conn *gorilla.Conn, msgType string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var message struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("reading %s message: %v", msgType, err)
+		}
+		if message.Type == msgType {
+			return message.Data
+		}
+	}
+}
+
+func TestProcessBroadcastsEnrichedEvent(t *testing.T) {
+	store := database.NewMemoryStore()
+	store.AddMachine(models.Machine{MachineID: "conveyor_001", MachineType: "conveyor", Location: "Hall A"})
+	processor, conn := newTestProcessor(t, store)
+
+	if _, err := processor.Process(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	event := readMessage(t, conn, "sensor_event")
+	if event["machine_type"] != "conveyor" || event["location"] != "Hall A" {
+		t.Errorf("broadcast machine_type = %v, location = %v, want conveyor in Hall A", event["machine_type"], event["location"])
+	}
+}
+
+func TestProcessUnknownMachineLeftUnenriched(t *testing.T) {
+	store := database.NewMemoryStore()
+	processor, conn := newTestProcessor(t, store)
+
+	if _, err := processor.Process(&models.SensorEvent{MachineID: "robot_404", EventType: "robot", Status: "ok", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	event := readMessage(t, conn, "sensor_event")
+	if event["machine_id"] != "robot_404" {
+		t.Fatalf("broadcast machine_id = %v, want robot_404", event["machine_id"])
+	}
+	if _, ok := event["machine_type"]; ok {
+		t.Errorf("broadcast machine_type = %v for an unregistered machine, want none", event["machine_type"])
+	}
+}
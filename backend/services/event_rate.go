@@ -0,0 +1,125 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"time"
+)
+
+// eventRate counts a machine's events over consecutive fixed intervals, keeping the counts
+// of its most recent completed intervals as a rolling baseline
+type eventRate struct {
+	intervalStart time.Time
+	count         int   // Events in the current interval
+	history       []int // Counts of recently completed intervals, oldest first
+	dropped       bool  // A rate drop was reported and the rate has not yet recovered
+}
+
+// baseline returns the mean count of the completed intervals
+func (r *eventRate) baseline() float64 {
+	total := 0
+	for _, count := range r.history {
+		total += count
+	}
+	return float64(total) / float64(len(r.history))
+}
+
+// trackEventRate counts an event arriving at now towards its machine's rate, first
+// closing any intervals that have ended and checking each against the baseline. The
+// caller must hold the mutex.
+func (ad *AnomalyDetector) trackEventRate(event *models.SensorEvent, now time.Time) {
+	if ad.rateInterval <= 0 {
+		return
+	}
+
+	rate, exists := ad.rates[event.MachineID]
+	// After a silence longer than the baseline the history no longer describes the machine
+	if !exists || now.Sub(rate.intervalStart) >= time.Duration(ad.rateBaseline+1)*ad.rateInterval {
+		rate = &eventRate{intervalStart: now}
+		ad.rates[event.MachineID] = rate
+	}
+
+	alert := ad.closeRateIntervals(event.MachineID, rate, now, event)
+	rate.count++
+	if alert != nil {
+		ad.emitAlert(event.MachineID, alert)
+	}
+}
+
+// checkEventRates closes the rate intervals that have ended for every machine, so a
+// machine whose events stop altogether is caught without waiting for its next event.
+// Machines flagged offline are skipped; machine_offline already reports them.
+func (ad *AnomalyDetector) checkEventRates(now time.Time) {
+	var alerts []*models.Alert
+
+	ad.mutex.Lock()
+	for machineID, rate := range ad.rates {
+		if ad.offline[machineID] {
+			continue
+		}
+		if alert := ad.closeRateIntervals(machineID, rate, now, nil); alert != nil {
+			alert.MachineID = machineID
+			alerts = append(alerts, alert)
+		}
+	}
+	ad.mutex.Unlock()
+
+	// Invoke callbacks outside the lock so they can safely query the detector
+	for _, alert := range alerts {
+		ad.emitAlert(alert.MachineID, alert)
+	}
+}
+
+// closeRateIntervals closes a machine's intervals that ended by now, checking each
+// against the baseline, and returns the event_rate_drop alert to raise, if any. event is
+// the event being counted, nil when intervals are closed by the background check. The
+// caller must hold the mutex.
+func (ad *AnomalyDetector) closeRateIntervals(machineID string, rate *eventRate, now time.Time, event *models.SensorEvent) *models.Alert {
+	var alert *models.Alert
+	for now.Sub(rate.intervalStart) >= ad.rateInterval {
+		if drop := ad.checkEventRate(machineID, event, rate); drop != nil {
+			alert = drop
+		}
+
+		rate.history = append(rate.history, rate.count)
+		if len(rate.history) > ad.rateBaseline {
+			rate.history = rate.history[1:]
+		}
+		rate.intervalStart = rate.intervalStart.Add(ad.rateInterval)
+		rate.count = 0
+	}
+	return alert
+}
+
+// checkEventRate returns an event_rate_drop alert when the interval just completed fell
+// below the drop fraction of a full baseline. One alert is raised per drop; the next is
+// possible once an interval reaches the fraction again.
+func (ad *AnomalyDetector) checkEventRate(machineID string, event *models.SensorEvent, rate *eventRate) *models.Alert {
+	if len(rate.history) < ad.rateBaseline {
+		return nil // Baseline still forming
+	}
+
+	baseline := rate.baseline()
+	limit := baseline * ad.rateDropFraction
+	if float64(rate.count) >= limit {
+		rate.dropped = false
+		return nil
+	}
+	if rate.dropped || !ad.ruleEnabled(RuleEventRateDrop) {
+		return nil
+	}
+	rate.dropped = true
+
+	return &models.Alert{
+		AlertType: "event_rate_drop",
+		Severity:  "medium",
+		Message: ad.messages.Render(AlertMessageData{
+			MachineID: machineID,
+			Event:     event,
+			Value:     fmt.Sprintf("%d", rate.count),
+			Limit:     fmt.Sprintf("%.1f", baseline),
+			Duration:  ad.rateInterval,
+		}, "event_rate_drop"),
+		Confidence: confidenceScore(limit, float64(rate.count)),
+	}
+}
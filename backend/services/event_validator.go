@@ -0,0 +1,122 @@
+package services
+
+import (
+	"backend/config"
+	"backend/models"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// EventValidator validates incoming sensor events regardless of their transport
+type EventValidator struct {
+	maxClockSkew      time.Duration
+	temperatureUnit   models.TemperatureUnit
+	machineIDCase     string
+	machineIDPattern  *regexp.Regexp
+	speedBounds       config.MetricBounds
+	temperatureBounds config.MetricBounds // In Celsius
+	angleBounds       config.MetricBounds
+	statuses          map[string]bool
+}
+
+// NewEventValidator creates a validator from the validation configuration. Incoming
+// temperatures are converted from ingestUnit to Celsius.
+func NewEventValidator(cfg config.ValidationConfig, ingestUnit models.TemperatureUnit) *EventValidator {
+	return &EventValidator{
+		maxClockSkew:      cfg.MaxClockSkew,
+		temperatureUnit:   ingestUnit,
+		machineIDCase:     cfg.MachineIDCase,
+		machineIDPattern:  cfg.MachineIDPattern,
+		speedBounds:       cfg.ConveyorSpeedBounds,
+		temperatureBounds: cfg.TemperatureBounds,
+		angleBounds:       cfg.RobotArmAngleBounds,
+		statuses:          stringSet(cfg.Statuses),
+	}
+}
+
+// Normalize converts an incoming event's readings into the canonical storage units.
+// It must be called once per event, before Validate.
+func (v *EventValidator) Normalize(event *models.SensorEvent) {
+	event.MachineID = v.NormalizeMachineID(event.MachineID)
+	event.FaultCode = extractFaultCode(event)
+	if event.Temperature != nil {
+		celsius := v.temperatureUnit.ToCelsius(*event.Temperature)
+		event.Temperature = &celsius
+	}
+}
+
+// extractFaultCode returns the event's canonical fault code, taken from the fault_code
+// field or, failing that, the fault_code key of its additional data
+func extractFaultCode(event *models.SensorEvent) string {
+	code := event.FaultCode
+	if code == "" {
+		code = event.AdditionalData.String("fault_code", "")
+	}
+	if faultCode := models.ParseFaultCode(code); faultCode != nil {
+		return faultCode.Code
+	}
+	return ""
+}
+
+// NormalizeMachineID returns the canonical form of a machine ID, so that variants such
+// as " Sensor_Hub_001" and "sensor_hub_001" share one sliding window and set of rows.
+// Machine IDs used in queries must go through the same normalization.
+func (v *EventValidator) NormalizeMachineID(machineID string) string {
+	return config.NormalizeMachineID(machineID, v.machineIDCase)
+}
+
+// Validate checks a sensor event before it enters the processing pipeline. Events
+// timestamped more than the allowed clock skew ahead of server time are rejected,
+// as they would corrupt time-range queries.
+func (v *EventValidator) Validate(event *models.SensorEvent) error {
+	if event.MachineID == "" {
+		return fmt.Errorf("machine_id is required")
+	}
+
+	if v.machineIDPattern != nil && !v.machineIDPattern.MatchString(event.MachineID) {
+		return fmt.Errorf("malformed machine_id: %q", event.MachineID)
+	}
+
+	if event.Status == "" {
+		return fmt.Errorf("status is required")
+	}
+
+	if event.EventType == "" {
+		return fmt.Errorf("event_type is required")
+	}
+
+	if skew := time.Until(event.Timestamp); skew > v.maxClockSkew {
+		return fmt.Errorf("timestamp %s is %v in the future (max skew: %v)",
+			event.Timestamp.Format(time.RFC3339), skew.Round(time.Second), v.maxClockSkew)
+	}
+
+	// Validate status values
+	if !v.statuses[event.Status] {
+		return fmt.Errorf("invalid status: %s", event.Status)
+	}
+
+	// Validate reported sensor values are physically possible; missing ones are allowed
+	if speed := event.ConveyorSpeed; speed != nil && !v.speedBounds.Contains(*speed) {
+		return fmt.Errorf("conveyor speed out of range: %f (allowed %g to %g)", *speed, v.speedBounds.Min, v.speedBounds.Max)
+	}
+
+	if temperature := event.Temperature; temperature != nil && !v.temperatureBounds.Contains(*temperature) {
+		return fmt.Errorf("temperature out of range: %f°C (allowed %g to %g)", *temperature, v.temperatureBounds.Min, v.temperatureBounds.Max)
+	}
+
+	if angle := event.RobotArmAngle; angle != nil && !v.angleBounds.Contains(*angle) {
+		return fmt.Errorf("robot arm angle out of range: %f (allowed %g to %g)", *angle, v.angleBounds.Min, v.angleBounds.Max)
+	}
+
+	return nil
+}
+
+// stringSet returns the set of the given values
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
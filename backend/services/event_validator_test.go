@@ -0,0 +1,59 @@
+package services
+
+import (
+	"backend/config"
+	"backend/models"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// testValidationConfig returns validation settings accepting the usual statuses and
+// readings, folding machine IDs to lower case
+func testValidationConfig() config.ValidationConfig {
+	return config.ValidationConfig{
+		MaxClockSkew:        5 * time.Second,
+		MachineIDCase:       "lower",
+		MachineIDPattern:    regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,49}$`),
+		Statuses:            []string{"ok", "warning", "fault"},
+		ConveyorSpeedBounds: config.MetricBounds{Min: 0, Max: 10},
+		TemperatureBounds:   config.MetricBounds{Min: -50, Max: 300},
+		RobotArmAngleBounds: config.MetricBounds{Min: -360, Max: 360},
+	}
+}
+
+func TestMachineIDVariantsNormalizeToOneKey(t *testing.T) {
+	validator := NewEventValidator(testValidationConfig(), models.Celsius)
+	detector, clock, _ := newTestDetector(testAnomalyConfig())
+
+	for _, machineID := range []string{"Sensor_Hub_001", " sensor_hub_001", "SENSOR_HUB_001\t"} {
+		event := &models.SensorEvent{MachineID: machineID, EventType: "sensor_hub", Status: "ok", Temperature: float(60), Timestamp: clock.Now()}
+		validator.Normalize(event)
+		if err := validator.Validate(event); err != nil {
+			t.Fatalf("%q: Validate: %v", machineID, err)
+		}
+		if event.MachineID != "sensor_hub_001" {
+			t.Errorf("%q normalized to %q, want sensor_hub_001", machineID, event.MachineID)
+		}
+		detector.AnalyzeEvent(event)
+	}
+
+	if stats := detector.GetMachineStats("sensor_hub_001"); stats == nil || stats["event_count"] != 3 {
+		t.Errorf("stats = %v, want the three variants in one window", stats)
+	}
+	if got := validator.NormalizeMachineID(" Sensor_Hub_001 "); got != "sensor_hub_001" {
+		t.Errorf("query machine ID normalized to %q, want sensor_hub_001", got)
+	}
+}
+
+func TestMalformedMachineIDsRejected(t *testing.T) {
+	validator := NewEventValidator(testValidationConfig(), models.Celsius)
+
+	for _, machineID := range []string{"conveyor 001", "-conveyor", "conveyor/001", "   "} {
+		event := &models.SensorEvent{MachineID: machineID, EventType: "conveyor", Status: "ok", Timestamp: time.Now()}
+		validator.Normalize(event)
+		if err := validator.Validate(event); err == nil {
+			t.Errorf("machine ID %q accepted", machineID)
+		}
+	}
+}
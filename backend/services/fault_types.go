@@ -0,0 +1,73 @@
+package services
+
+import "backend/config"
+
+// defaultFaultTypes is the built-in fault taxonomy for the event types machines report
+// with a fault or warning status. Entries for "fault" and "warning" apply to event types
+// that are not listed.
+var defaultFaultTypes = map[string]config.FaultType{
+	"fault": {
+		Severity: "high",
+	},
+	"warning": {
+		Severity: "medium",
+	},
+	"conveyor_jam": {
+		Severity:    "high",
+		Description: "Conveyor belt jammed",
+		Action:      "Stop the line, clear the obstruction and check the belt tension before restarting",
+	},
+	"overheat": {
+		Severity:    "critical",
+		Description: "Temperature exceeds safe operating limits",
+		Action:      "Reduce load or stop the machine and inspect cooling before it resumes",
+	},
+	"robot_fault": {
+		Severity:    "high",
+		Description: "Robot arm movement restricted",
+		Action:      "Check the robot arm for obstructions and recalibrate its joints",
+	},
+	"maintenance_due": {
+		Severity:    "low",
+		Description: "Scheduled maintenance approaching",
+		Action:      "Schedule maintenance for the machine",
+	},
+}
+
+// FaultTaxonomy maps event types to how their status alerts are raised
+type FaultTaxonomy struct {
+	types map[string]config.FaultType
+}
+
+// NewFaultTaxonomy builds the taxonomy, applying overrides on top of the built-in
+// defaults. Fields an override leaves empty keep the built-in value for its event type.
+func NewFaultTaxonomy(overrides map[string]config.FaultType) *FaultTaxonomy {
+	types := make(map[string]config.FaultType, len(defaultFaultTypes)+len(overrides))
+	for eventType, faultType := range defaultFaultTypes {
+		types[eventType] = faultType
+	}
+	for eventType, override := range overrides {
+		types[eventType] = mergeFaultType(override, types[eventType])
+	}
+	return &FaultTaxonomy{types: types}
+}
+
+// Lookup returns the fault type for an event type reported with the given status. Unknown
+// event types, and fields an entry leaves empty, fall back to the entry for the status.
+func (t *FaultTaxonomy) Lookup(eventType, status string) config.FaultType {
+	return mergeFaultType(t.types[eventType], t.types[status])
+}
+
+// mergeFaultType fills the empty fields of faultType from fallback
+func mergeFaultType(faultType, fallback config.FaultType) config.FaultType {
+	if faultType.Severity == "" {
+		faultType.Severity = fallback.Severity
+	}
+	if faultType.Description == "" {
+		faultType.Description = fallback.Description
+	}
+	if faultType.Action == "" {
+		faultType.Action = fallback.Action
+	}
+	return faultType
+}
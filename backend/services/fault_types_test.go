@@ -0,0 +1,43 @@
+package services
+
+import (
+	"backend/config"
+	"backend/models"
+	"strings"
+	"testing"
+)
+
+func TestConfiguredFaultTypeSetsSeverityAndDescription(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.FaultTypes = map[string]config.FaultType{
+		"vision_reject": {Severity: "critical", Description: "Camera rejected the part", Action: "Inspect the last station"},
+		"conveyor_jam":  {Severity: "medium"},
+	}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	for _, tc := range []struct {
+		eventType, status, severity, description, action string
+	}{
+		{"vision_reject", "fault", "critical", "Camera rejected the part", "Inspect the last station"},
+		{"conveyor_jam", "fault", "medium", "Conveyor belt jammed", "Stop the line, clear the obstruction and check the belt tension before restarting"},
+		{"mystery", "fault", "high", "", ""},
+		{"mystery", "warning", "medium", "", ""},
+	} {
+		recorder.alerts = nil
+		detector.AnalyzeEvent(&models.SensorEvent{MachineID: "machine_" + tc.eventType + "_" + tc.status, EventType: tc.eventType, Status: tc.status, Timestamp: clock.Now()})
+
+		if len(recorder.alerts) != 1 {
+			t.Fatalf("%s %s: alerts = %v, want one status alert", tc.eventType, tc.status, recorder.types())
+		}
+		alert := recorder.alerts[0]
+		if alert.AlertType != tc.eventType || alert.Severity != tc.severity {
+			t.Errorf("%s %s: alert %s with severity %s, want severity %s", tc.eventType, tc.status, alert.AlertType, alert.Severity, tc.severity)
+		}
+		if tc.description != "" && !strings.Contains(alert.Message, tc.description) {
+			t.Errorf("%s %s: message %q, want the description %q", tc.eventType, tc.status, alert.Message, tc.description)
+		}
+		if action := alert.RecommendedAction; (action == nil) != (tc.action == "") || (action != nil && *action != tc.action) {
+			t.Errorf("%s %s: recommended action %v, want %q", tc.eventType, tc.status, action, tc.action)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// MachineCache keeps machine metadata in memory for enriching incoming events
+type MachineCache struct {
+	db          database.Store
+	machines    map[string]models.Machine
+	interval    time.Duration
+	stopChannel chan struct{}
+	stopOnce    sync.Once
+	mutex       sync.RWMutex
+}
+
+// NewMachineCache creates a machine cache refreshed from the database every interval
+func NewMachineCache(db database.Store, interval time.Duration) *MachineCache {
+	return &MachineCache{
+		db:          db,
+		machines:    make(map[string]models.Machine),
+		interval:    interval,
+		stopChannel: make(chan struct{}),
+	}
+}
+
+// Start loads the cache and launches the periodic refresh
+func (mc *MachineCache) Start() {
+	if err := mc.Refresh(); err != nil {
+		log.Printf("Failed to load machine cache: %v", err)
+	}
+
+	if mc.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(mc.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := mc.Refresh(); err != nil {
+					log.Printf("Failed to refresh machine cache: %v", err)
+				}
+			case <-mc.stopChannel:
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates the periodic refresh
+func (mc *MachineCache) Stop() {
+	mc.stopOnce.Do(func() {
+		close(mc.stopChannel)
+	})
+}
+
+// Refresh reloads machine metadata from the database
+func (mc *MachineCache) Refresh() error {
+	machines, err := mc.db.GetMachines()
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]models.Machine, len(machines))
+	for _, machine := range machines {
+		byID[machine.MachineID] = machine
+	}
+
+	mc.mutex.Lock()
+	mc.machines = byID
+	mc.mutex.Unlock()
+
+	return nil
+}
+
+// Get returns cached metadata for a machine
+func (mc *MachineCache) Get(machineID string) (models.Machine, bool) {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+	machine, ok := mc.machines[machineID]
+	return machine, ok
+}
+
+// Enrich copies the machine's type and location onto the event.
+// Events from unregistered machines are left unchanged.
+func (mc *MachineCache) Enrich(event *models.SensorEvent) {
+	if machine, ok := mc.Get(event.MachineID); ok {
+		event.MachineType = machine.MachineType
+		event.Location = machine.Location
+	}
+}
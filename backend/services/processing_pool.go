@@ -0,0 +1,82 @@
+package services
+
+import (
+	"backend/models"
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+// ProcessingPool runs events through the processor on a fixed set of workers. Events are
+// sharded by machine ID, so each machine's events are processed in arrival order by a
+// single worker (as the stateful anomaly detector requires) while different machines
+// are stored in parallel. Queues are bounded; Submit blocks when a shard is full, which
+// applies backpressure to the source instead of growing memory or dropping events.
+type ProcessingPool struct {
+	processor *EventProcessor
+	queues    []chan poolJob
+	wg        sync.WaitGroup
+}
+
+// poolJob is a queued event and the callback told the outcome of processing it
+type poolJob struct {
+	event *models.SensorEvent
+	done  func(error)
+}
+
+// NewProcessingPool creates a pool with the given number of workers and per-worker queue size
+func NewProcessingPool(processor *EventProcessor, workers, queueSize int) *ProcessingPool {
+	pool := &ProcessingPool{
+		processor: processor,
+		queues:    make([]chan poolJob, workers),
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan poolJob, queueSize)
+	}
+	return pool
+}
+
+// Start launches the workers
+func (p *ProcessingPool) Start() {
+	for i, queue := range p.queues {
+		p.wg.Add(1)
+		go p.work(i, queue)
+	}
+}
+
+// Submit queues an event on its machine's shard, blocking while that shard is full.
+// done, if not nil, is called with the processing result once the event is handled.
+func (p *ProcessingPool) Submit(event *models.SensorEvent, done func(error)) {
+	p.queues[p.shard(event.MachineID)] <- poolJob{event: event, done: done}
+}
+
+// Stop closes the queues and waits for the workers to finish the events already queued.
+// Submit must not be called after Stop.
+func (p *ProcessingPool) Stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// work processes one shard's events in order
+func (p *ProcessingPool) work(id int, queue <-chan poolJob) {
+	defer p.wg.Done()
+
+	for job := range queue {
+		_, err := p.processor.Process(job.event)
+		if err != nil {
+			log.Printf("Worker %d failed to store event: %v", id, err)
+		}
+		if job.done != nil {
+			job.done(err)
+		}
+	}
+}
+
+// shard maps a machine ID onto a worker
+func (p *ProcessingPool) shard(machineID string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(machineID))
+	return int(hash.Sum32() % uint32(len(p.queues)))
+}
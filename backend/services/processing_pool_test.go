@@ -0,0 +1,100 @@
+package services
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/models"
+	"backend/websocket"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// orderRecordingStore records the sequence numbers, carried as conveyor speeds, of the
+// events it stores per machine, taking latency to insert each like a remote database
+type orderRecordingStore struct {
+	*database.MemoryStore
+	latency time.Duration
+	mutex   sync.Mutex
+	stored  map[string][]float64
+}
+
+func (s *orderRecordingStore) InsertEvent(event *models.SensorEvent) (*models.Event, error) {
+	time.Sleep(s.latency)
+	s.mutex.Lock()
+	s.stored[event.MachineID] = append(s.stored[event.MachineID], *event.ConveyorSpeed)
+	s.mutex.Unlock()
+	return s.MemoryStore.InsertEvent(event)
+}
+
+// newTestPool starts a pool of workers over a recording store without broadcast clients
+func newTestPool(workers int, latency time.Duration) (*ProcessingPool, *orderRecordingStore) {
+	store := &orderRecordingStore{MemoryStore: database.NewMemoryStore(), latency: latency, stored: make(map[string][]float64)}
+	detector := NewAnomalyDetector(testAnomalyConfig(), nil, func(*models.Alert) {}, nil)
+	processor := NewEventProcessor(store, NewMachineCache(store, 0), detector, websocket.NewHub(config.WebSocketConfig{}, nil))
+
+	pool := NewProcessingPool(processor, workers, 16)
+	pool.Start()
+	return pool, store
+}
+
+// submitEvents submits count events round-robin across machines, numbering each
+// machine's events in order
+func submitEvents(pool *ProcessingPool, machines, count int) {
+	for i := 0; i < count; i++ {
+		event := &models.SensorEvent{
+			MachineID:     fmt.Sprintf("conveyor_%03d", i%machines),
+			EventType:     "conveyor",
+			Status:        "ok",
+			ConveyorSpeed: float(float64(i / machines)),
+			Timestamp:     time.Now(),
+		}
+		pool.Submit(event, nil)
+	}
+}
+
+func TestProcessingPoolPreservesPerMachineOrder(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	pool, store := newTestPool(4, 0)
+	submitEvents(pool, 10, 2000)
+	pool.Stop()
+
+	if len(store.stored) != 10 {
+		t.Fatalf("events stored for %d machines, want 10", len(store.stored))
+	}
+	for machineID, sequence := range store.stored {
+		if len(sequence) != 200 {
+			t.Errorf("%s: %d events stored, want 200", machineID, len(sequence))
+		}
+		for i, seq := range sequence {
+			if seq != float64(i) {
+				t.Errorf("%s: event %v stored at position %d, want arrival order", machineID, seq, i)
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkProcessingPool measures sustained throughput for 16 machines with a
+// simulated 200µs insert latency, for a single worker (sequential processing) and for
+// larger pools
+func BenchmarkProcessingPool(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool, _ := newTestPool(workers, 200*time.Microsecond)
+			b.ResetTimer()
+			submitEvents(pool, 16, b.N)
+			pool.Stop()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"time"
+)
+
+// ComputeReliability derives a machine's reliability metrics from its stored events and
+// alerts since the given time, see models.MachineReliability
+func ComputeReliability(db database.Store, machineID string, since time.Time) (*models.MachineReliability, error) {
+	onsets, err := db.GetFaultOnsets(machineID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	handling, err := db.GetAlertHandlingTimes(machineID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MachineReliability{
+		MachineID:                    machineID,
+		Since:                        since,
+		Until:                        time.Now(),
+		FaultOnsets:                  len(onsets),
+		MeanTimeBetweenFaultsSeconds: meanInterval(onsets),
+		AlertsAcknowledged:           handling.Acknowledged,
+		MeanTimeToAcknowledgeSeconds: handling.MeanToAcknowledge,
+		AlertsResolved:               handling.Resolved,
+		MeanTimeToResolveSeconds:     handling.MeanToResolve,
+	}, nil
+}
+
+// meanInterval returns the mean gap in seconds between consecutive ordered times, or nil
+// with fewer than two times
+func meanInterval(times []time.Time) *float64 {
+	if len(times) < 2 {
+		return nil
+	}
+	// The gaps telescope, so their mean is the overall span over their count
+	mean := times[len(times)-1].Sub(times[0]).Seconds() / float64(len(times)-1)
+	return &mean
+}
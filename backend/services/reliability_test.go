@@ -0,0 +1,71 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestComputeReliability(t *testing.T) {
+	store := database.NewMemoryStore()
+	now := time.Now()
+
+	// Fault runs start 4h and 1h ago; the consecutive fault 3h ago continues the first run
+	for _, event := range []struct {
+		ago    time.Duration
+		status string
+	}{{5 * time.Hour, "ok"}, {4 * time.Hour, "fault"}, {3 * time.Hour, "fault"}, {2 * time.Hour, "ok"}, {time.Hour, "fault"}} {
+		if _, err := store.InsertEvent(&models.SensorEvent{
+			MachineID: "conveyor_001", EventType: "conveyor", Status: event.status, Timestamp: now.Add(-event.ago),
+		}); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+
+	for _, alert := range []models.Alert{
+		{MachineID: "conveyor_001", AlertType: "temperature_high", CreatedAt: now.Add(-30 * time.Minute)},
+		{MachineID: "conveyor_002", AlertType: "temperature_high", CreatedAt: now.Add(-30 * time.Minute)}, // Another machine
+	} {
+		if _, err := store.InsertAlertUnlessDuplicate(&alert, 0); err != nil {
+			t.Fatalf("InsertAlertUnlessDuplicate: %v", err)
+		}
+	}
+	for _, machineID := range []string{"conveyor_001", "conveyor_002"} {
+		if err := store.ResolveAlerts(&models.AlertResolution{
+			MachineID: machineID, AlertType: "temperature_high", ResolvedAt: now.Add(-20 * time.Minute),
+		}); err != nil {
+			t.Fatalf("ResolveAlerts: %v", err)
+		}
+	}
+
+	reliability, err := ComputeReliability(store, "conveyor_001", now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("ComputeReliability: %v", err)
+	}
+	if reliability.FaultOnsets != 2 {
+		t.Errorf("fault onsets = %d, want 2", reliability.FaultOnsets)
+	}
+	if mtbf := reliability.MeanTimeBetweenFaultsSeconds; mtbf == nil || *mtbf != (3*time.Hour).Seconds() {
+		t.Errorf("MTBF = %v, want %v", mtbf, (3 * time.Hour).Seconds())
+	}
+	if reliability.AlertsResolved != 1 {
+		t.Errorf("alerts resolved = %d, want 1", reliability.AlertsResolved)
+	}
+	if mttr := reliability.MeanTimeToResolveSeconds; mttr == nil || *mttr != (10*time.Minute).Seconds() {
+		t.Errorf("MTTR = %v, want %v", mttr, (10 * time.Minute).Seconds())
+	}
+	if reliability.AlertsAcknowledged != 0 || reliability.MeanTimeToAcknowledgeSeconds != nil {
+		t.Errorf("acknowledged = %d (mean %v), want none", reliability.AlertsAcknowledged, reliability.MeanTimeToAcknowledgeSeconds)
+	}
+}
+
+func TestComputeReliabilityWithoutHistory(t *testing.T) {
+	reliability, err := ComputeReliability(database.NewMemoryStore(), "conveyor_001", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("ComputeReliability: %v", err)
+	}
+	if reliability.FaultOnsets != 0 || reliability.MeanTimeBetweenFaultsSeconds != nil || reliability.MeanTimeToResolveSeconds != nil {
+		t.Errorf("reliability = %+v, want no onsets and null means", reliability)
+	}
+}
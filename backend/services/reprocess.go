@@ -0,0 +1,137 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxReprocessEvents caps how many events one reprocessing request replays
+const MaxReprocessEvents = 50000
+
+// ErrReprocessTooLarge is returned when a reprocessing window holds more events than
+// MaxReprocessEvents
+var ErrReprocessTooLarge = errors.New("too many events in the reprocessing window")
+
+// Reprocess replays a machine's stored events in [since, until] through the detector's
+// current thresholds and rules, and stores the alerts they raise. An alert is skipped
+// when the machine already has one of the same type for the same event or created within
+// tolerance of the event, so reprocessing a window twice adds nothing.
+func Reprocess(db database.Store, detector *AnomalyDetector, machineID string, since, until time.Time, tolerance time.Duration) (*models.ReprocessResult, error) {
+	events, err := db.GetMachineEventsBetween(machineID, since, until, MaxReprocessEvents+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > MaxReprocessEvents {
+		return nil, fmt.Errorf("%w (limit %d); narrow the time range", ErrReprocessTooLarge, MaxReprocessEvents)
+	}
+
+	alerts := detector.Replay(events)
+	result := &models.ReprocessResult{
+		MachineID:      machineID,
+		Since:          since,
+		Until:          until,
+		EventsReplayed: len(events),
+		AlertsDetected: len(alerts),
+	}
+
+	for _, alert := range alerts {
+		inserted, err := db.InsertAlertUnlessDuplicate(alert, tolerance)
+		if err != nil {
+			return nil, err
+		}
+		if inserted {
+			result.AlertsInserted++
+		} else {
+			result.DuplicatesSkipped++
+		}
+	}
+
+	return result, nil
+}
+
+// Replay runs stored events, oldest first, through a fresh detector with this detector's
+// current thresholds, rules and settings, and returns the alerts they raise, each carrying
+// its triggering event's ID and timestamp. The live detector's state is untouched; snoozes
+// are not applied and offline detection does not run.
+func (ad *AnomalyDetector) Replay(events []models.Event) []*models.Alert {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var alerts []*models.Alert
+	var current *models.SensorEvent
+	clock := &replayClock{now: events[0].Timestamp}
+	replica := ad.replica(clock, func(alert *models.Alert) {
+		id := current.ID
+		alert.EventID = &id
+		alert.CreatedAt = current.Timestamp
+		alerts = append(alerts, alert)
+	})
+
+	for _, stored := range events {
+		current = stored.SensorEvent()
+		if current.Timestamp.After(clock.now) {
+			clock.now = current.Timestamp
+		}
+		replica.AnalyzeEvent(current)
+	}
+
+	return alerts
+}
+
+// replica returns an unstarted detector with the same thresholds, rules and settings as
+// ad but none of its per-machine state, reporting alerts to alertCallback
+func (ad *AnomalyDetector) replica(clock Clock, alertCallback func(*models.Alert)) *AnomalyDetector {
+	ad.mutex.RLock()
+	defer ad.mutex.RUnlock()
+
+	thresholds := *ad.thresholds
+	disabledRules := make(map[string]bool, len(ad.disabledRules))
+	for rule, disabled := range ad.disabledRules {
+		disabledRules[rule] = disabled
+	}
+	var dedup *alertDeduper
+	if ad.dedup != nil {
+		dedup = newAlertDeduper(ad.dedup.window)
+	}
+	var eventDedupWindow time.Duration
+	if ad.eventDedup != nil {
+		eventDedupWindow = ad.eventDedup.window
+	}
+
+	return &AnomalyDetector{
+		thresholds:       &thresholds,
+		slidingWindow:    make(map[string]*SlidingWindow),
+		lastSeen:         make(map[string]time.Time),
+		offline:          make(map[string]bool),
+		snoozed:          make(map[string]time.Time),
+		windowSize:       ad.windowSize,
+		trendMinEvents:   ad.trendMinEvents,
+		patternMinEvents: ad.patternMinEvents,
+		pattern:          ad.pattern,
+		patternOverrides: ad.patternOverrides,
+		trendMaxGap:      ad.trendMaxGap,
+		conditions:       make(map[string]map[string]time.Time),
+		disabledRules:    disabledRules,
+		dedup:            dedup,
+		eventDedup:       newEventDeduper(eventDedupWindow),
+		rates:            make(map[string]*eventRate),
+		rateInterval:     ad.rateInterval,
+		rateBaseline:     ad.rateBaseline,
+		rateDropFraction: ad.rateDropFraction,
+		warmups:          make(map[string]*machineWarmup),
+		warmupEvents:     ad.warmupEvents,
+		warmupDuration:   ad.warmupDuration,
+		temperatureUnit:  ad.temperatureUnit,
+		messages:         ad.messages,
+		faultTypes:       ad.faultTypes,
+		statusDemotions:  ad.statusDemotions,
+		idleStatuses:     ad.idleStatuses,
+		clock:            clock,
+		stopChannel:      make(chan struct{}),
+		alertCallback:    alertCallback,
+	}
+}
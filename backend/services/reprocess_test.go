@@ -0,0 +1,55 @@
+package services
+
+import (
+	"backend/database"
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestReprocessStoresMissingAlertsOnce(t *testing.T) {
+	store := database.NewMemoryStore()
+	detector, _, recorder := newTestDetector(testAnomalyConfig())
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	for i, temperature := range []float64{50, 150, 50} {
+		if _, err := store.InsertEvent(&models.SensorEvent{
+			MachineID:   "conveyor_001",
+			EventType:   "conveyor",
+			Status:      "ok",
+			Timestamp:   start.Add(time.Duration(i) * time.Minute),
+			Temperature: float(temperature),
+		}); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+
+	result, err := Reprocess(store, detector, "conveyor_001", start, start.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatalf("Reprocess: %v", err)
+	}
+	if result.EventsReplayed != 3 || result.AlertsDetected != 1 || result.AlertsInserted != 1 {
+		t.Fatalf("result = %+v, want 3 events replayed and one alert inserted", result)
+	}
+
+	alerts, err := store.GetUnacknowledgedAlerts(nil, "")
+	if err != nil {
+		t.Fatalf("GetUnacknowledgedAlerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].AlertType != "temperature_high" || !alerts[0].CreatedAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("alerts = %+v, want temperature_high at the violating event's time", alerts)
+	}
+
+	again, err := Reprocess(store, detector, "conveyor_001", start, start.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatalf("Reprocess: %v", err)
+	}
+	if again.AlertsInserted != 0 || again.DuplicatesSkipped != 1 {
+		t.Errorf("second result = %+v, want the alert skipped as a duplicate", again)
+	}
+
+	// Replays run on a copy of the detector and leave the live one untouched
+	if len(recorder.alerts) != 0 || detector.GetMachineStats("conveyor_001") != nil {
+		t.Errorf("live detector raised %v and holds state after replay", recorder.types())
+	}
+}
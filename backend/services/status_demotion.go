@@ -0,0 +1,31 @@
+package services
+
+import "backend/models"
+
+// statusRanks orders the statuses a demotion moves between, most severe last
+var statusRanks = map[string]int{
+	"ok":      0,
+	"warning": 1,
+	"fault":   2,
+}
+
+// demote returns the event to analyze in place of one whose type is configured to be
+// reported with a less severe status, e.g. maintenance_due faults analyzed as warnings.
+// The copy carries the demoted status, so it raises the matching status alert, or none
+// for ok, and does not count towards repeated faults. Other events, and demotions that
+// would raise the reported status, leave the event as is; the stored event keeps the
+// status it was reported with.
+func (ad *AnomalyDetector) demote(event *models.SensorEvent) *models.SensorEvent {
+	status, ok := ad.statusDemotions[event.EventType]
+	if !ok {
+		return event
+	}
+	reported, known := statusRanks[event.Status]
+	if !known || statusRanks[status] >= reported {
+		return event
+	}
+
+	demoted := *event
+	demoted.Status = status
+	return &demoted
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestDemoteAppliesOnlyLessSevereConfiguredStatus(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.StatusDemotions = map[string]string{"maintenance_due": "warning", "calibration": "ok"}
+	detector, _, _ := newTestDetector(cfg)
+
+	for _, tc := range []struct {
+		eventType, status, want string
+	}{
+		{"maintenance_due", "fault", "warning"},
+		{"maintenance_due", "warning", "warning"},
+		{"maintenance_due", "ok", "ok"}, // Never raised
+		{"calibration", "fault", "ok"},
+		{"overheat", "fault", "fault"}, // Unmapped
+	} {
+		event := &models.SensorEvent{MachineID: "conveyor_001", EventType: tc.eventType, Status: tc.status}
+		if got := detector.demote(event); got.Status != tc.want {
+			t.Errorf("%s %s analyzed as %s, want %s", tc.eventType, tc.status, got.Status, tc.want)
+		}
+		if event.Status != tc.status {
+			t.Errorf("%s %s: reported event changed to %s, want it kept", tc.eventType, tc.status, event.Status)
+		}
+	}
+}
+
+func TestDemotedFaultsRaiseNoRepeatedFaults(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.StatusDemotions = map[string]string{"maintenance_due": "warning", "calibration": "ok"}
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyze := func(eventType string, count int) {
+		for i := 0; i < count; i++ {
+			detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: eventType, Status: "fault", Timestamp: clock.Now()})
+			clock.Advance(time.Second)
+		}
+	}
+
+	analyze("calibration", 5)
+	if len(recorder.alerts) != 0 {
+		t.Fatalf("alerts = %v for faults demoted to ok, want none", recorder.types())
+	}
+
+	analyze("maintenance_due", 10)
+	if count := countType(recorder, "maintenance_due"); count != 10 {
+		t.Errorf("maintenance_due raised %d times, want a warning alert for each of 10 events", count)
+	}
+	if count := countType(recorder, "repeated_faults"); count != 0 {
+		t.Errorf("repeated_faults raised %d times for demoted faults, want none", count)
+	}
+
+	analyze("overheat", 3)
+	if count := countType(recorder, "repeated_faults"); count != 1 {
+		t.Errorf("repeated_faults raised %d times for unmapped faults, want 1", count)
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"time"
+)
+
+// machineWarmup tracks how much baseline a machine has built since its window was
+// created, at startup, after a reset or after eviction
+type machineWarmup struct {
+	started time.Time
+	events  int
+}
+
+// warmingUp counts an event arriving at now towards its machine's warmup and reports
+// whether the machine is still warming up: it has seen fewer than the warmup events or
+// started less than the warmup duration ago. Trend and pattern detection are held off
+// meanwhile, since a short history trips them spuriously; threshold and status alerts
+// fire as usual. The caller must hold the mutex.
+func (ad *AnomalyDetector) warmingUp(machineID string, now time.Time) bool {
+	if ad.warmupEvents <= 0 && ad.warmupDuration <= 0 {
+		return false
+	}
+
+	warmup, exists := ad.warmups[machineID]
+	if !exists {
+		return false // Warmed up already
+	}
+	warmup.events++
+	if warmup.events < ad.warmupEvents || now.Sub(warmup.started) < ad.warmupDuration {
+		return true
+	}
+	delete(ad.warmups, machineID)
+	return false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestWarmupSuppressesTrendAlertsUntilEnoughEvents(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WarmupEvents = 12
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyzeUnstableSpeeds(detector, clock, 11)
+	if count := countType(recorder, "speed_instability"); count != 0 {
+		t.Fatalf("speed_instability raised %d times during the %d event warmup", count, cfg.WarmupEvents)
+	}
+
+	analyzeUnstableSpeeds(detector, clock, 1)
+	if count := countType(recorder, "speed_instability"); count != 1 {
+		t.Errorf("speed_instability raised %d times once warmed up, want 1", count)
+	}
+}
+
+func TestWarmupSuppressesTrendAlertsUntilDurationPasses(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WarmupDuration = 30 * time.Second
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyzeUnstableSpeeds(detector, clock, 30)
+	if count := countType(recorder, "speed_instability"); count != 0 {
+		t.Fatalf("speed_instability raised %d times during the %s warmup", count, cfg.WarmupDuration)
+	}
+
+	analyzeUnstableSpeeds(detector, clock, 1)
+	if count := countType(recorder, "speed_instability"); count != 1 {
+		t.Errorf("speed_instability raised %d times once warmed up, want 1", count)
+	}
+}
+
+func TestThresholdAlertsFireDuringWarmup(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WarmupEvents = 100
+	detector, clock, recorder := newTestDetector(cfg)
+
+	detector.AnalyzeEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Temperature: float(150), Timestamp: clock.Now()})
+	if count := countType(recorder, "temperature_high"); count != 1 {
+		t.Errorf("temperature_high raised %d times during warmup, want 1", count)
+	}
+}
+
+func TestResetMachineRestartsWarmup(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.WarmupEvents = 12
+	detector, clock, recorder := newTestDetector(cfg)
+
+	analyzeUnstableSpeeds(detector, clock, 12)
+	detector.ResetMachine("conveyor_001")
+	raised := countType(recorder, "speed_instability")
+
+	analyzeUnstableSpeeds(detector, clock, 11)
+	if count := countType(recorder, "speed_instability"); count != raised {
+		t.Errorf("speed_instability raised %d more times after a reset, want none until warmed up again", count-raised)
+	}
+}
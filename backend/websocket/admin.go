@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"backend/models"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminActions are the privileged server actions available to admin clients
+type AdminActions interface {
+	ResetMachine(machineID string) bool
+	UpdateThresholds(thresholds *models.AnomalyThresholds)
+}
+
+// SetAdminActions registers the actions admin clients may invoke. Until it is called,
+// admin commands are rejected.
+func (h *Hub) SetAdminActions(actions AdminActions) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.admin = actions
+}
+
+// isAdminRequest reports whether a connection request carries the admin token as a bearer
+// token. The token is never read from the URL, which access logs and proxies record;
+// browsers, which cannot set headers on WebSocket requests, send an authenticate message.
+func (h *Hub) isAdminRequest(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.isAdminToken(token)
+}
+
+// isAdminToken reports whether token is the configured admin token
+func (h *Hub) isAdminToken(token string) bool {
+	if h.adminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
+
+// handleAuthenticate grants the admin role to a client presenting the admin token. It is
+// only accepted as the first message on a connection, so a token cannot be guessed
+// repeatedly over one connection and the role is settled before any command runs.
+func (c *Client) handleAuthenticate(data json.RawMessage, first bool) {
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(data, &auth); err != nil || auth.Token == "" {
+		c.reply("error", map[string]string{"error": "authenticate requires a token"})
+		return
+	}
+	if !first {
+		c.reply("error", map[string]string{"error": "authenticate must be the first message on the connection"})
+		return
+	}
+	if !c.hub.isAdminToken(auth.Token) {
+		log.Printf("Rejected admin token from client %s", c.id)
+		c.reply("error", map[string]string{"error": "invalid token"})
+		return
+	}
+
+	c.isAdmin = true
+	log.Printf("Client %s authenticated as admin", c.id)
+	c.reply("authenticated", map[string]interface{}{"admin": true})
+}
+
+// handleAdminCommand runs a privileged command and replies with an admin_result message.
+// Clients that have not presented the admin token receive an error instead.
+func (c *Client) handleAdminCommand(data json.RawMessage) {
+	var command struct {
+		Command    string                    `json:"command"`
+		MachineID  string                    `json:"machine_id"`
+		Thresholds *models.AnomalyThresholds `json:"thresholds"` // In Celsius, as stored
+	}
+	if err := json.Unmarshal(data, &command); err != nil {
+		c.reply("error", map[string]string{"error": "invalid admin command"})
+		return
+	}
+
+	if !c.isAdmin {
+		log.Printf("Rejected admin command %q from non-admin client %s", command.Command, c.id)
+		c.reply("error", map[string]string{"error": "admin role required", "command": command.Command})
+		return
+	}
+
+	c.hub.mutex.RLock()
+	actions := c.hub.admin
+	c.hub.mutex.RUnlock()
+
+	result := map[string]interface{}{"command": command.Command}
+	err := runAdminCommand(actions, command.Command, command.MachineID, command.Thresholds, result)
+	result["success"] = err == nil
+	if err != nil {
+		result["error"] = err.Error()
+	}
+
+	log.Printf("Admin command %q from client %s: success=%t", command.Command, c.id, err == nil)
+	c.reply("admin_result", result)
+}
+
+// runAdminCommand dispatches a command to the registered actions, adding any output to result
+func runAdminCommand(actions AdminActions, command, machineID string, thresholds *models.AnomalyThresholds, result map[string]interface{}) error {
+	if actions == nil {
+		return fmt.Errorf("admin actions are not available")
+	}
+
+	switch command {
+	case "reset_window":
+		if machineID == "" {
+			return fmt.Errorf("machine_id is required")
+		}
+		result["machine_id"] = machineID
+		result["reset"] = actions.ResetMachine(machineID)
+		return nil
+
+	case "update_thresholds":
+		if thresholds == nil {
+			return fmt.Errorf("thresholds are required")
+		}
+		if err := thresholds.Validate(); err != nil {
+			return err
+		}
+		actions.UpdateThresholds(thresholds)
+		result["thresholds"] = thresholds
+		return nil
+
+	default:
+		return fmt.Errorf("unknown admin command %q", command)
+	}
+}
+
+// reply sends a direct message to the client, dropping it if the send buffer is full
+func (c *Client) reply(msgType string, data interface{}) {
+	message := models.WebSocketMessage{
+		Type:      msgType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	if msgBytes, err := json.Marshal(message); err == nil {
+		if !c.deliver(msgBytes, 0) {
+			log.Printf("Failed to send %s to client %s", msgType, c.id)
+		}
+	}
+}
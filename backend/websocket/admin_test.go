@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/models"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeAdminActions records the admin actions invoked
+type fakeAdminActions struct {
+	reset      []string
+	thresholds *models.AnomalyThresholds
+}
+
+func (a *fakeAdminActions) ResetMachine(machineID string) bool {
+	a.reset = append(a.reset, machineID)
+	return true
+}
+
+func (a *fakeAdminActions) UpdateThresholds(thresholds *models.AnomalyThresholds) {
+	a.thresholds = thresholds
+}
+
+// newAdminTestClient returns an unregistered client of a hub with admin token "secret"
+func newAdminTestClient() (*Client, *fakeAdminActions) {
+	hub := NewHub(config.WebSocketConfig{AdminToken: "secret"}, nil)
+	actions := &fakeAdminActions{}
+	hub.SetAdminActions(actions)
+	return &Client{hub: hub, send: make(chan []byte, 10), id: "test-client"}, actions
+}
+
+func TestIsAdminRequestIgnoresQueryToken(t *testing.T) {
+	hub := NewHub(config.WebSocketConfig{AdminToken: "secret"}, nil)
+
+	bearer := httptest.NewRequest("GET", "/ws", nil)
+	bearer.Header.Set("Authorization", "Bearer secret")
+	if !hub.isAdminRequest(bearer) {
+		t.Error("bearer token not accepted")
+	}
+	if hub.isAdminRequest(httptest.NewRequest("GET", "/ws?token=secret", nil)) {
+		t.Error("token accepted from the URL")
+	}
+
+	wrong := httptest.NewRequest("GET", "/ws", nil)
+	wrong.Header.Set("Authorization", "Bearer guess")
+	if hub.isAdminRequest(wrong) {
+		t.Error("wrong bearer token accepted")
+	}
+}
+
+func TestIsAdminRequestDisabledWithoutToken(t *testing.T) {
+	hub := NewHub(config.WebSocketConfig{}, nil)
+	request := httptest.NewRequest("GET", "/ws", nil)
+	request.Header.Set("Authorization", "Bearer ")
+	if hub.isAdminRequest(request) {
+		t.Error("empty bearer token accepted with admin commands disabled")
+	}
+}
+
+func TestAuthenticateFirstMessageGrantsAdmin(t *testing.T) {
+	client, actions := newAdminTestClient()
+
+	client.handleMessage([]byte(`{"type":"authenticate","data":{"token":"secret"}}`))
+	if reply := receive(t, client); reply["type"] != "authenticated" {
+		t.Fatalf("reply = %v, want authenticated", reply)
+	}
+
+	client.handleMessage([]byte(`{"type":"admin_command","data":{"command":"reset_window","machine_id":"conveyor_001"}}`))
+	reply := receive(t, client)
+	if reply["type"] != "admin_result" || reply["data"].(map[string]interface{})["success"] != true {
+		t.Fatalf("reply = %v, want a successful admin_result", reply)
+	}
+	if len(actions.reset) != 1 || actions.reset[0] != "conveyor_001" {
+		t.Errorf("reset machines %v, want conveyor_001", actions.reset)
+	}
+}
+
+func TestAuthenticateRejectedAfterFirstMessage(t *testing.T) {
+	client, actions := newAdminTestClient()
+
+	client.handleMessage([]byte(`{"type":"get_subscriptions"}`))
+	receive(t, client)
+	client.handleMessage([]byte(`{"type":"authenticate","data":{"token":"secret"}}`))
+	if reply := receive(t, client); reply["type"] != "error" {
+		t.Fatalf("reply = %v, want an error", reply)
+	}
+
+	client.handleMessage([]byte(`{"type":"admin_command","data":{"command":"reset_window","machine_id":"conveyor_001"}}`))
+	if reply := receive(t, client); reply["type"] != "error" {
+		t.Errorf("reply = %v, want admin role required", reply)
+	}
+	if len(actions.reset) != 0 {
+		t.Errorf("non-admin client reset machines %v", actions.reset)
+	}
+}
+
+func TestAuthenticateRejectsWrongToken(t *testing.T) {
+	client, _ := newAdminTestClient()
+
+	client.handleMessage([]byte(`{"type":"authenticate","data":{"token":"guess"}}`))
+	if reply := receive(t, client); reply["type"] != "error" {
+		t.Fatalf("reply = %v, want an error", reply)
+	}
+	if client.isAdmin {
+		t.Error("wrong token granted the admin role")
+	}
+}
+
+func TestUpdateThresholdsValidates(t *testing.T) {
+	actions := &fakeAdminActions{}
+	result := map[string]interface{}{}
+	if err := runAdminCommand(actions, "update_thresholds", "", nil, result); err == nil {
+		t.Error("update_thresholds without thresholds succeeded")
+	}
+	if err := runAdminCommand(actions, "drop_tables", "", nil, result); err == nil {
+		t.Error("unknown command succeeded")
+	}
+	if err := runAdminCommand(nil, "reset_window", "conveyor_001", nil, result); err == nil {
+		t.Error("command ran without admin actions registered")
+	}
+}
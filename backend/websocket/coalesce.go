@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"backend/models"
+	"sync"
+	"time"
+)
+
+// eventCoalescer limits sensor event broadcasts to one per machine per interval. Events
+// arriving within the interval replace any pending one (latest wins) and the pending
+// event is sent when the interval ends. Status changes are always sent immediately.
+type eventCoalescer struct {
+	interval time.Duration
+	send     func(*models.SensorEvent)
+	machines map[string]*coalesceState
+	mutex    sync.Mutex
+}
+
+// coalesceState tracks broadcasts for one machine
+type coalesceState struct {
+	lastSent   time.Time
+	lastStatus string
+	pending    *models.SensorEvent
+	timer      *time.Timer
+}
+
+// newEventCoalescer creates a coalescer that passes events through send
+func newEventCoalescer(interval time.Duration, send func(*models.SensorEvent)) *eventCoalescer {
+	return &eventCoalescer{
+		interval: interval,
+		send:     send,
+		machines: make(map[string]*coalesceState),
+	}
+}
+
+// offer sends the event now or holds it until the machine's interval ends
+func (ec *eventCoalescer) offer(event *models.SensorEvent) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	now := time.Now()
+	state, exists := ec.machines[event.MachineID]
+	if !exists {
+		state = &coalesceState{}
+		ec.machines[event.MachineID] = state
+	}
+
+	statusChanged := exists && event.Status != state.lastStatus
+	if !exists || statusChanged || (state.pending == nil && now.Sub(state.lastSent) >= ec.interval) {
+		// A newer event supersedes whatever was pending
+		if state.timer != nil {
+			state.timer.Stop()
+			state.timer = nil
+		}
+		state.pending = nil
+		ec.sendLocked(state, event, now)
+		return
+	}
+
+	state.pending = event
+	if state.timer == nil {
+		machineID := event.MachineID
+		state.timer = time.AfterFunc(state.lastSent.Add(ec.interval).Sub(now), func() {
+			ec.flush(machineID)
+		})
+	}
+}
+
+// flush sends a machine's pending event once its interval has ended
+func (ec *eventCoalescer) flush(machineID string) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	state, exists := ec.machines[machineID]
+	if !exists {
+		return
+	}
+
+	state.timer = nil
+	if state.pending != nil {
+		event := state.pending
+		state.pending = nil
+		ec.sendLocked(state, event, time.Now())
+	}
+}
+
+// sendLocked sends an event and records it as the machine's latest broadcast
+func (ec *eventCoalescer) sendLocked(state *coalesceState, event *models.SensorEvent, now time.Time) {
+	state.lastSent = now
+	state.lastStatus = event.Status
+	ec.send(event)
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+)
+
+func TestCoalescerThrottlesEventsButPassesStatusChanges(t *testing.T) {
+	sent := make(chan int, 20)
+	coalescer := newEventCoalescer(100*time.Millisecond, func(event *models.SensorEvent) { sent <- event.ID })
+	offer := func(id int, machineID, status string) {
+		coalescer.offer(&models.SensorEvent{ID: id, MachineID: machineID, Status: status})
+	}
+	expectSent := func(want ...int) {
+		t.Helper()
+		for _, id := range want {
+			select {
+			case got := <-sent:
+				if got != id {
+					t.Fatalf("sent event %d, want %d", got, id)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("event %d not sent", id)
+			}
+		}
+		select {
+		case got := <-sent:
+			t.Fatalf("event %d sent, want it throttled", got)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	offer(1, "conveyor_001", "ok")
+	offer(2, "conveyor_001", "ok")
+	offer(3, "conveyor_001", "ok")
+	offer(4, "conveyor_002", "ok") // Other machines are throttled separately
+	expectSent(1, 4)
+
+	// A status change goes out at once, superseding the pending event
+	offer(5, "conveyor_001", "fault")
+	expectSent(5)
+
+	offer(6, "conveyor_001", "fault")
+	offer(7, "conveyor_001", "fault")
+	expectSent()
+
+	// The latest pending event is sent when the interval ends
+	time.Sleep(100 * time.Millisecond)
+	expectSent(7)
+}
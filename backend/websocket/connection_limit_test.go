@@ -0,0 +1,45 @@
+package websocket
+
+import (
+	"backend/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestConnectionsBeyondLimitRejected(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{MaxClients: 2})
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	first := dial(t, server, "")
+	dial(t, server, "")
+	waitFor(t, "both clients to register", func() bool { return hub.GetClientCount() == 2 })
+
+	_, resp, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err == nil {
+		t.Fatal("third connection accepted with a limit of 2")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("third connection rejected with %v, want 503", resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "too many WebSocket clients (limit 2)") {
+		t.Errorf("rejection body = %q, want the client limit explained", body)
+	}
+
+	active, peak, maxClients := hub.GetConnectionCounts()
+	if active != 2 || peak != 2 || maxClients != 2 {
+		t.Errorf("connection counts = %d active, %d peak, %d max, want 2, 2 and 2", active, peak, maxClients)
+	}
+
+	// Closing a connection frees its slot for the next one
+	first.Close()
+	waitFor(t, "the closed client to unregister", func() bool { return hub.GetClientCount() == 1 })
+	dial(t, server, "")
+	waitFor(t, "the new client to register", func() bool { return hub.GetClientCount() == 2 })
+}
@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+// WebSocket subprotocols a client may request at connect to choose the message encoding.
+// Clients that request neither get JSON text frames.
+const (
+	subprotocolMsgpack = "msgpack" // MessagePack binary frames
+	subprotocolJSON    = "json"    // JSON text frames
+)
+
+// Messages are encoded as JSON once per broadcast and stamped per client (see
+// withSequence), so MessagePack clients receive a transcoding of the same JSON. Field
+// names and structure are identical; timestamps stay RFC3339 strings.
+var (
+	jsonHandle    = &codec.JsonHandle{}
+	msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+)
+
+func init() {
+	mapType := reflect.TypeOf(map[string]interface{}(nil))
+	jsonHandle.MapType = mapType
+	msgpackHandle.MapType = mapType
+	msgpackHandle.RawToString = true
+}
+
+// jsonToMsgpack re-encodes a JSON message as MessagePack
+func jsonToMsgpack(payload []byte) ([]byte, error) {
+	return transcode(payload, jsonHandle, msgpackHandle)
+}
+
+// msgpackToJSON re-encodes a MessagePack message as JSON
+func msgpackToJSON(payload []byte) ([]byte, error) {
+	return transcode(payload, msgpackHandle, jsonHandle)
+}
+
+// transcode decodes payload with one codec and encodes the value with another
+func transcode(payload []byte, from, to codec.Handle) ([]byte, error) {
+	var value interface{}
+	if err := codec.NewDecoderBytes(payload, from).Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var encoded []byte
+	if err := codec.NewEncoderBytes(&encoded, to).Encode(value); err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
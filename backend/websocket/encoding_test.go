@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+func TestMessageRoundTripsThroughMessagePack(t *testing.T) {
+	payload, err := json.Marshal(models.WebSocketMessage{
+		Type:      "alert",
+		Data:      &models.Alert{ID: 7, MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high", Message: "hot"},
+		Timestamp: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	packed, err := jsonToMsgpack(payload)
+	if err != nil {
+		t.Fatalf("jsonToMsgpack: %v", err)
+	}
+	unpacked, err := msgpackToJSON(packed)
+	if err != nil {
+		t.Fatalf("msgpackToJSON: %v", err)
+	}
+
+	var before, after map[string]interface{}
+	json.Unmarshal(payload, &before)
+	if err := json.Unmarshal(unpacked, &after); err != nil {
+		t.Fatalf("decoding %s: %v", unpacked, err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("round trip = %v, want %v", after, before)
+	}
+	if len(packed) >= len(payload) {
+		t.Errorf("MessagePack encoding is %d bytes, want smaller than the %d byte JSON", len(packed), len(payload))
+	}
+}
+
+// readMessagePack reads the next frame on conn, which must be binary, and decodes it
+func readMessagePack(t *testing.T, conn *gorilla.Conn) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frameType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	if frameType != gorilla.BinaryMessage {
+		t.Fatalf("frame type = %d, want binary", frameType)
+	}
+	var message map[string]interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&message); err != nil {
+		t.Fatalf("decoding MessagePack: %v", err)
+	}
+	return message
+}
+
+func TestMessagePackSubprotocolNegotiatedAtConnect(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	text := dial(t, server, "")
+	dialer := gorilla.Dialer{Subprotocols: []string{subprotocolMsgpack}}
+	binary, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { binary.Close() })
+	if binary.Subprotocol() != subprotocolMsgpack {
+		t.Fatalf("subprotocol = %q, want %s", binary.Subprotocol(), subprotocolMsgpack)
+	}
+
+	if msgType, _ := nextMessage(t, text); msgType != "connection" {
+		t.Errorf("JSON client greeted with %s, want connection", msgType)
+	}
+	if welcome := readMessagePack(t, binary); welcome["type"] != "connection" {
+		t.Errorf("MessagePack client greeted with %v, want connection", welcome)
+	}
+
+	// Messages from the client may be MessagePack too
+	var ping []byte
+	codec.NewEncoderBytes(&ping, msgpackHandle).Encode(map[string]interface{}{"type": "ping", "data": map[string]interface{}{"timestamp": 1718000000123}})
+	if err := binary.WriteMessage(gorilla.BinaryMessage, ping); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	if pong := readMessagePack(t, binary); pong["type"] != "pong" {
+		t.Errorf("reply = %v, want pong", pong)
+	}
+
+	waitFor(t, "both clients to register", func() bool { return hub.GetClientCount() == 2 })
+	hub.BroadcastStats(map[string]int{"events": 3})
+
+	msgType, data := nextMessage(t, text)
+	message := readMessagePack(t, binary)
+	if msgType != "stats" || message["type"] != "stats" {
+		t.Fatalf("received %s and %v, want stats on both", msgType, message["type"])
+	}
+	packed, _ := message["data"].(map[string]interface{})
+	if data["events"] != float64(3) || fmt.Sprint(packed["events"]) != "3" {
+		t.Errorf("stats = %v as JSON and %v as MessagePack, want 3 events in both", data, packed)
+	}
+}
@@ -1,54 +1,111 @@
 package websocket
 
 import (
+	"backend/config"
 	"backend/models"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-    CheckOrigin: func(r *http.Request) bool {
-        origin := r.Header.Get("Origin")
-        allowed := "https://8jmxm2bjvs.us-east-1.awsapprunner.com/"
-        return origin == allowed
-    },
-    ReadBufferSize:  1024,
-    WriteBufferSize: 1024,
-}
-
-
 // Hub maintains the set of active clients and broadcasts messages to the clients
 type Hub struct {
-	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-	mutex      sync.RWMutex
+	upgrader    websocket.Upgrader
+	proxies     *proxyResolver
+	events      EventSource
+	backfill    int // Most stored events replayed to a resuming client
+	admin       AdminActions
+	adminToken  string          // Token granting the admin role; empty disables admin commands
+	coalescer   *eventCoalescer // Throttles per-machine sensor events; nil sends every event
+	minSeverity int             // Default alert severity floor (models.SeverityLevels rank) for new clients
+	block       bool            // Broadcasts wait for a busy hub instead of being dropped
+	limiter     *connectionLimiter
+	clients     map[*Client]bool
+	broadcast   chan broadcastMessage
+	register    chan *Client
+	unregister  chan *Client
+	mutex       sync.RWMutex
+
+	// done is closed when shutdown begins; broadcasts after that are discarded
+	done         chan struct{}
+	shutdownOnce sync.Once
+}
+
+// broadcastMessage is an encoded message queued for delivery to all clients
+type broadcastMessage struct {
+	msgType  string // WebSocketMessage type, used to honour per-client opt-outs
+	eventID  int    // Stored event ID for sensor events, used to de-duplicate resumed streams
+	severity int    // Severity rank of alerts, compared against client severity floors
+	payload  []byte
 }
 
 // Client represents a websocket client connection
 type Client struct {
-	hub        *Hub
-	conn       *websocket.Conn
-	send       chan []byte
-	id         string
-	subscribed map[string]bool // Topics the client is subscribed to
-	mutex      sync.RWMutex
+	hub         *Hub
+	conn        *websocket.Conn
+	send        chan []byte
+	id          string
+	remoteIP    string          // Client address, resolved through trusted proxies
+	isAdmin     bool            // Presented the admin token; may run admin commands. Read pump only
+	received    int             // Messages received from the client. Read pump only
+	binary      bool            // Negotiated the msgpack subprotocol; messages are sent as MessagePack
+	subscribed  map[string]bool // Topics the client is subscribed to
+	optedOut    map[string]bool // Broadcast message types the client does not want
+	minSeverity int             // Alerts ranked below this severity are not sent
+	statsOnly   bool            // Connected to the stats stream; receives stats broadcasts and nothing else
+	pingSentAt  time.Time       // When the last protocol-level ping was written
+	rtt         time.Duration   // Most recent measured ping round-trip time
+	mutex       sync.RWMutex
+
+	// Messages are stamped with a sequence number and queued on send under sendMutex, so
+	// numbers reach the client in order
+	lastSeq    uint64 // Sequence number of the last message sent or dropped
+	sendClosed bool
+	sendMutex  sync.Mutex
+
+	// While a resuming client is replayed stored events, live broadcasts are held back
+	// and released once the replay completes
+	holding   bool
+	held      []broadcastMessage
+	holdMutex sync.Mutex
 }
 
-// NewHub creates a new WebSocket hub
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+// NewHub creates a new WebSocket hub. events supplies stored events replayed to clients
+// that reconnect with a cursor.
+func NewHub(cfg config.WebSocketConfig, events EventSource) *Hub {
+	proxies := newProxyResolver(cfg.AllowedOrigins, cfg.TrustedProxies)
+	hub := &Hub{
+		upgrader: websocket.Upgrader{
+			CheckOrigin:     proxies.checkOrigin,
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{subprotocolMsgpack, subprotocolJSON},
+		},
+		proxies:     proxies,
+		events:      events,
+		backfill:    cfg.BackfillLimit,
+		adminToken:  cfg.AdminToken,
+		minSeverity: models.SeverityLevels[cfg.AlertMinSeverity],
+		block:       cfg.BroadcastPolicy == config.BroadcastBlock,
+		limiter:     &connectionLimiter{max: cfg.MaxClients},
+		broadcast:   make(chan broadcastMessage),
+		register:    make(chan *Client),
+		unregister:  make(chan *Client),
+		clients:     make(map[*Client]bool),
+		done:        make(chan struct{}),
 	}
+	if cfg.CoalesceInterval > 0 {
+		hub.coalescer = newEventCoalescer(cfg.CoalesceInterval, hub.broadcastEvent)
+	}
+	return hub
 }
 
 // Run starts the hub
@@ -58,50 +115,66 @@ func (h *Hub) Run() {
 		case client := <-h.register:
 			h.mutex.Lock()
 			h.clients[client] = true
+			total := len(h.clients)
 			h.mutex.Unlock()
-			log.Printf("Client %s registered, total clients: %d", client.id, len(h.clients))
-
-			// Send welcome message
-			welcome := models.WebSocketMessage{
-				Type:      "connection",
-				Data:      map[string]string{"status": "connected", "client_id": client.id},
-				Timestamp: time.Now(),
-			}
-			if msg, err := json.Marshal(welcome); err == nil {
-				select {
-				case client.send <- msg:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
+			log.Printf("Client %s (%s) registered, total clients: %d", client.id, client.remoteIP, total)
 
 		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("Client %s unregistered, total clients: %d", client.id, len(h.clients))
+			if total, ok := h.removeClient(client); ok {
+				log.Printf("Client %s unregistered, total clients: %d", client.id, total)
 			}
-			h.mutex.Unlock()
 
 		case message := <-h.broadcast:
+			// Clients that cannot keep up are evicted after the loop, under the write lock
+			var dead []*Client
 			h.mutex.RLock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if !client.wants(message) || client.hold(message) {
+					continue
+				}
+				if !client.deliver(message.payload, 0) {
+					dead = append(dead, client)
 				}
 			}
 			h.mutex.RUnlock()
+
+			for _, client := range dead {
+				if total, ok := h.removeClient(client); ok {
+					log.Printf("Client %s evicted with a full send buffer, total clients: %d", client.id, total)
+				}
+			}
 		}
 	}
 }
 
-// BroadcastEvent broadcasts a sensor event to all connected clients
+// removeClient drops a registered client, closing its send channel and freeing its
+// connection slot. It returns the clients remaining, and false if the client was
+// already removed.
+func (h *Hub) removeClient(client *Client) (int, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return len(h.clients), false
+	}
+	delete(h.clients, client)
+	h.limiter.release()
+	client.closeSend()
+	return len(h.clients), true
+}
+
+// BroadcastEvent broadcasts a sensor event to all connected clients. When coalescing is
+// enabled, each machine's events are throttled to one per interval, except status changes.
 func (h *Hub) BroadcastEvent(event *models.SensorEvent) {
+	if h.coalescer != nil {
+		h.coalescer.offer(event)
+		return
+	}
+	h.broadcastEvent(event)
+}
+
+// broadcastEvent queues a sensor event for all connected clients
+func (h *Hub) broadcastEvent(event *models.SensorEvent) {
 	message := models.WebSocketMessage{
 		Type:      "sensor_event",
 		Data:      event,
@@ -109,11 +182,7 @@ func (h *Hub) BroadcastEvent(event *models.SensorEvent) {
 	}
 
 	if msgBytes, err := json.Marshal(message); err == nil {
-		select {
-		case h.broadcast <- msgBytes:
-		default:
-			log.Println("Broadcast channel full, dropping message")
-		}
+		h.enqueue(broadcastMessage{msgType: message.Type, eventID: event.ID, payload: msgBytes}, "message")
 	}
 }
 
@@ -126,11 +195,20 @@ func (h *Hub) BroadcastAlert(alert *models.Alert) {
 	}
 
 	if msgBytes, err := json.Marshal(message); err == nil {
-		select {
-		case h.broadcast <- msgBytes:
-		default:
-			log.Println("Broadcast channel full, dropping alert")
-		}
+		h.enqueue(broadcastMessage{msgType: message.Type, severity: models.SeverityLevels[alert.Severity], payload: msgBytes}, "alert")
+	}
+}
+
+// BroadcastResolved notifies all connected clients that an alert condition has cleared
+func (h *Hub) BroadcastResolved(resolution *models.AlertResolution) {
+	message := models.WebSocketMessage{
+		Type:      "resolved",
+		Data:      resolution,
+		Timestamp: time.Now(),
+	}
+
+	if msgBytes, err := json.Marshal(message); err == nil {
+		h.enqueue(broadcastMessage{msgType: message.Type, payload: msgBytes}, "resolution")
 	}
 }
 
@@ -143,12 +221,59 @@ func (h *Hub) BroadcastStats(stats interface{}) {
 	}
 
 	if msgBytes, err := json.Marshal(message); err == nil {
+		h.enqueue(broadcastMessage{msgType: message.Type, payload: msgBytes}, "stats")
+	}
+}
+
+// enqueue hands a broadcast to the Run loop. If the loop is busy, the message is dropped
+// or, under the block policy, waited on. Once shutdown has begun it is discarded.
+func (h *Hub) enqueue(message broadcastMessage, kind string) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	if h.block {
 		select {
-		case h.broadcast <- msgBytes:
-		default:
-			log.Println("Broadcast channel full, dropping stats")
+		case h.broadcast <- message:
+		case <-h.done:
 		}
+		return
 	}
+
+	select {
+	case h.broadcast <- message:
+	default:
+		log.Printf("Broadcast channel full, dropping %s", kind)
+	}
+}
+
+// Shutdown stops accepting broadcasts, then sends a close frame to every connected client
+// and closes its connection, giving up on clients that cannot be reached before ctx is done
+func (h *Hub) Shutdown(ctx context.Context) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+	// Stop accepting broadcasts, releasing any waiting under the block policy
+	h.shutdownOnce.Do(func() { close(h.done) })
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		client.conn.WriteControl(websocket.CloseMessage, closeMessage, deadline)
+		client.conn.Close()
+	}
+
+	log.Printf("Closed %d WebSocket connections", len(h.clients))
+	return nil
 }
 
 // GetClientCount returns the number of connected clients
@@ -158,27 +283,110 @@ func (h *Hub) GetClientCount() int {
 	return len(h.clients)
 }
 
-// HandleWebSocket handles WebSocket connections
+// GetConnectionCounts returns the connections currently held, including those still
+// registering, the most held at once since startup, and the limit (0 is unlimited)
+func (h *Hub) GetConnectionCounts() (active, peak, limit int) {
+	active, peak = h.limiter.counts()
+	return active, peak, h.limiter.max
+}
+
+// GetAverageLatency returns the mean ping round-trip time across clients that have
+// completed at least one ping/pong exchange
+func (h *Hub) GetAverageLatency() time.Duration {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var total time.Duration
+	measured := 0
+	for client := range h.clients {
+		if rtt := client.latency(); rtt > 0 {
+			total += rtt
+			measured++
+		}
+	}
+
+	if measured == 0 {
+		return 0
+	}
+	return total / time.Duration(measured)
+}
+
+// HandleWebSocket handles WebSocket connections. A client reconnecting with a since_id
+// (last event ID seen) or since (RFC3339 timestamp) query parameter is first replayed
+// the stored events it missed, then switched to the live stream. Clients requesting the
+// msgpack subprotocol exchange MessagePack binary frames instead of JSON text.
 func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	cursor, err := parseResumeCursor(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	minSeverity := h.minSeverity
+	if severity := r.URL.Query().Get("min_severity"); severity != "" {
+		rank, ok := models.SeverityLevels[strings.ToLower(severity)]
+		if !ok {
+			http.Error(w, fmt.Sprintf("invalid min_severity %q", severity), http.StatusBadRequest)
+			return
+		}
+		minSeverity = rank
+	}
+
+	h.serve(w, r, cursor, minSeverity, false)
+}
+
+// HandleStatsWebSocket handles connections to the stats stream, for dashboards that only
+// show the periodic statistics: clients receive stats broadcasts, never sensor events,
+// alerts or resolutions, and cannot opt back in to them. Nothing is replayed on connect.
+func (h *Hub) HandleStatsWebSocket(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, nil, h.minSeverity, true)
+}
+
+// serve upgrades the connection, registers its client and starts the client's pumps,
+// replaying stored events from cursor first when it is set. Connections beyond the
+// client limit are refused with 503 before upgrading.
+func (h *Hub) serve(w http.ResponseWriter, r *http.Request, cursor *resumeCursor, minSeverity int, statsOnly bool) {
+	remoteIP := h.proxies.clientIP(r)
+
+	if !h.limiter.acquire() {
+		log.Printf("Rejected WebSocket connection from %s: client limit of %d reached", remoteIP, h.limiter.max)
+		http.Error(w, fmt.Sprintf("too many WebSocket clients (limit %d), retry later", h.limiter.max), http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		h.limiter.release()
+		log.Printf("WebSocket upgrade error from %s (origin %q): %v", remoteIP, r.Header.Get("Origin"), err)
 		return
 	}
 
 	clientID := generateClientID()
 	client := &Client{
-		hub:        h,
-		conn:       conn,
-		send:       make(chan []byte, 256),
-		id:         clientID,
-		subscribed: make(map[string]bool),
+		hub:         h,
+		conn:        conn,
+		send:        make(chan []byte, 256),
+		id:          clientID,
+		remoteIP:    remoteIP,
+		isAdmin:     h.isAdminRequest(r),
+		binary:      conn.Subprotocol() == subprotocolMsgpack,
+		subscribed:  make(map[string]bool),
+		optedOut:    make(map[string]bool),
+		minSeverity: minSeverity,
+		statsOnly:   statsOnly,
+		holding:     cursor != nil,
 	}
 
+	// Queue the welcome message first so it precedes any replayed or live messages
+	client.queueMessage("connection", map[string]string{"status": "connected", "client_id": client.id})
+
 	client.hub.register <- client
 
 	// Start goroutines for this client
 	go client.writePump()
+	if cursor != nil {
+		h.resume(client, cursor)
+	}
 	go client.readPump()
 }
 
@@ -193,11 +401,12 @@ func (c *Client) readPump() {
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.recordPong()
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -205,6 +414,13 @@ func (c *Client) readPump() {
 			break
 		}
 
+		if messageType == websocket.BinaryMessage {
+			if message, err = msgpackToJSON(message); err != nil {
+				log.Printf("Failed to decode MessagePack message from client %s: %v", c.id, err)
+				continue
+			}
+		}
+
 		// Handle client messages (subscriptions, etc.)
 		c.handleMessage(message)
 	}
@@ -227,13 +443,18 @@ func (c *Client) writePump() {
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			frameType := websocket.TextMessage
+			if c.binary {
+				frameType = websocket.BinaryMessage
+			}
+			if err := c.conn.WriteMessage(frameType, message); err != nil {
 				log.Printf("WebSocket write error: %v", err)
 				return
 			}
 
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.recordPing()
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -252,53 +473,122 @@ func (c *Client) handleMessage(message []byte) {
 		log.Printf("Failed to unmarshal client message: %v", err)
 		return
 	}
+	first := c.received == 0
+	c.received++
 
 	switch msg.Type {
 	case "subscribe":
+		// "types" opts back in to broadcast message types previously unsubscribed from
+		// "min_severity" sets the lowest alert severity the client receives
 		var subscribeData struct {
-			Topics []string `json:"topics"`
+			Topics      []string `json:"topics"`
+			Types       []string `json:"types"`
+			MinSeverity string   `json:"min_severity"`
 		}
 		if err := json.Unmarshal(msg.Data, &subscribeData); err == nil {
-			c.subscribe(subscribeData.Topics)
+			added, existing := c.subscribe(subscribeData.Topics)
+			c.setOptOut(subscribeData.Types, false)
+			if subscribeData.MinSeverity != "" {
+				c.setMinSeverity(subscribeData.MinSeverity)
+			}
+
+			// Subscribing is idempotent; the confirmation tells new topics from repeats
+			c.reply("subscribed", map[string]interface{}{
+				"added":              added,
+				"already_subscribed": existing,
+				"topics":             c.subscriptions(),
+			})
 		}
 
 	case "unsubscribe":
+		// "types" opts out of broadcast message types: sensor_event, stats or alert
 		var unsubscribeData struct {
 			Topics []string `json:"topics"`
+			Types  []string `json:"types"`
 		}
 		if err := json.Unmarshal(msg.Data, &unsubscribeData); err == nil {
 			c.unsubscribe(unsubscribeData.Topics)
+			c.setOptOut(unsubscribeData.Types, true)
 		}
 
+	case "get_subscriptions":
+		c.mutex.RLock()
+		optedOut := mapKeys(c.optedOut)
+		minSeverity := severityName(c.minSeverity)
+		c.mutex.RUnlock()
+
+		c.reply("subscriptions", map[string]interface{}{
+			"topics":       c.subscriptions(),
+			"opted_out":    optedOut,
+			"min_severity": minSeverity,
+		})
+
 	case "ping":
+		// Echo the client-supplied timestamp so the client can compute round-trip latency
+		var pingData struct {
+			Timestamp json.RawMessage `json:"timestamp"`
+		}
+		if len(msg.Data) > 0 {
+			json.Unmarshal(msg.Data, &pingData)
+		}
+
+		pongData := map[string]interface{}{"client_id": c.id}
+		if len(pingData.Timestamp) > 0 {
+			pongData["client_timestamp"] = pingData.Timestamp
+		}
+
 		pong := models.WebSocketMessage{
 			Type:      "pong",
-			Data:      map[string]string{"client_id": c.id},
+			Data:      pongData,
 			Timestamp: time.Now(),
 		}
 		if pongBytes, err := json.Marshal(pong); err == nil {
-			select {
-			case c.send <- pongBytes:
-			default:
+			if !c.deliver(pongBytes, 0) {
 				log.Printf("Failed to send pong to client %s", c.id)
 			}
 		}
 
+	case "authenticate":
+		c.handleAuthenticate(msg.Data, first)
+
+	case "admin_command":
+		c.handleAdminCommand(msg.Data)
+
 	default:
 		log.Printf("Unknown message type from client %s: %s", c.id, msg.Type)
 	}
 }
 
-// subscribe adds topics to client subscription
-func (c *Client) subscribe(topics []string) {
+// subscribe adds topics to client subscription, returning the topics that were newly
+// added and those the client was already subscribed to
+func (c *Client) subscribe(topics []string) (added, existing []string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	added, existing = []string{}, []string{}
 	for _, topic := range topics {
+		if topic == "" || slices.Contains(added, topic) || slices.Contains(existing, topic) {
+			continue
+		}
+		if c.subscribed[topic] {
+			existing = append(existing, topic)
+			continue
+		}
 		c.subscribed[topic] = true
+		added = append(added, topic)
 	}
 
-	log.Printf("Client %s subscribed to topics: %v", c.id, topics)
+	if len(added) > 0 {
+		log.Printf("Client %s subscribed to topics: %v", c.id, added)
+	}
+	return added, existing
+}
+
+// subscriptions returns the client's subscribed topics, sorted
+func (c *Client) subscriptions() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return mapKeys(c.subscribed)
 }
 
 // unsubscribe removes topics from client subscription
@@ -313,6 +603,53 @@ func (c *Client) unsubscribe(topics []string) {
 	log.Printf("Client %s unsubscribed from topics: %v", c.id, topics)
 }
 
+// setOptOut opts the client out of, or back in to, the given broadcast message types
+func (c *Client) setOptOut(msgTypes []string, optOut bool) {
+	if len(msgTypes) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, msgType := range msgTypes {
+		if optOut {
+			c.optedOut[msgType] = true
+		} else {
+			delete(c.optedOut, msgType)
+		}
+	}
+
+	log.Printf("Client %s opted out of message types: %v", c.id, mapKeys(c.optedOut))
+}
+
+// setMinSeverity sets the client's alert severity floor, rejecting unknown severities
+func (c *Client) setMinSeverity(severity string) {
+	rank, ok := models.SeverityLevels[strings.ToLower(severity)]
+	if !ok {
+		c.reply("error", map[string]string{"error": fmt.Sprintf("invalid min_severity %q", severity)})
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.minSeverity = rank
+
+	log.Printf("Client %s alert severity floor set to %s", c.id, severity)
+}
+
+// wants reports whether the client accepts a broadcast. Clients receive every message
+// type unless they have opted out of it, and only alerts at or above their severity floor.
+// Stats stream clients receive stats only.
+func (c *Client) wants(message broadcastMessage) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.optedOut[message.msgType] || (c.statsOnly && message.msgType != "stats") {
+		return false
+	}
+	return message.msgType != "alert" || message.severity >= c.minSeverity
+}
+
 // isSubscribed checks if client is subscribed to a topic
 func (c *Client) isSubscribed(topic string) bool {
 	c.mutex.RLock()
@@ -320,7 +657,52 @@ func (c *Client) isSubscribed(topic string) bool {
 	return c.subscribed[topic]
 }
 
+// recordPing notes when a protocol-level ping was sent
+func (c *Client) recordPing() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pingSentAt = time.Now()
+}
+
+// recordPong updates the round-trip time from the outstanding ping
+func (c *Client) recordPong() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.pingSentAt.IsZero() {
+		c.rtt = time.Since(c.pingSentAt)
+		c.pingSentAt = time.Time{}
+	}
+}
+
+// latency returns the most recent ping round-trip time
+func (c *Client) latency() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.rtt
+}
+
+// mapKeys returns the keys of a set, sorted
+func mapKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// severityName returns the severity with the given models.SeverityLevels rank, or ""
+// for rank 0 (no floor)
+func severityName(rank int) string {
+	for name, level := range models.SeverityLevels {
+		if level == rank {
+			return name
+		}
+	}
+	return ""
+}
+
 // generateClientID generates a unique client ID
 func generateClientID() string {
 	return time.Now().Format("20060102150405") + "-" + string(rune(time.Now().UnixNano()%1000))
-}
\ No newline at end of file
+}
@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestHub returns a running hub whose broadcasts wait for the Run loop, so tests do
+// not race the drop-on-busy policy
+func newTestHub(t *testing.T, cfg config.WebSocketConfig) *Hub {
+	t.Helper()
+	cfg.BroadcastPolicy = config.BroadcastBlock
+	hub := NewHub(cfg, nil)
+	go hub.Run()
+	t.Cleanup(func() { hub.shutdownOnce.Do(func() { close(hub.done) }) })
+	return hub
+}
+
+// newTestClient registers a client without a connection, taking a connection slot the
+// way serve does. Its send buffer holds buffer messages.
+func newTestClient(hub *Hub, buffer int) *Client {
+	hub.limiter.acquire()
+	client := &Client{
+		hub:        hub,
+		send:       make(chan []byte, buffer),
+		id:         "test-client",
+		subscribed: make(map[string]bool),
+		optedOut:   make(map[string]bool),
+	}
+	hub.register <- client
+	return client
+}
+
+// receive decodes the next message queued for a client
+func receive(t *testing.T, client *Client) map[string]interface{} {
+	t.Helper()
+	select {
+	case payload, ok := <-client.send:
+		if !ok {
+			t.Fatal("send channel closed")
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal(payload, &message); err != nil {
+			t.Fatalf("decoding %s: %v", payload, err)
+		}
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message queued")
+		return nil
+	}
+}
+
+// waitFor polls condition until it holds or a deadline passes
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBroadcastEvictsClientWithFullBuffer(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{MaxClients: 1})
+	slow := newTestClient(hub, 1)
+
+	hub.BroadcastStats(map[string]int{"events": 1})
+	hub.BroadcastStats(map[string]int{"events": 2})
+
+	waitFor(t, "the slow client to be evicted", func() bool { return hub.GetClientCount() == 0 })
+	if active, _, _ := hub.GetConnectionCounts(); active != 0 {
+		t.Errorf("%d connection slots held after eviction, want 0", active)
+	}
+	if !hub.limiter.acquire() {
+		t.Error("evicted client's slot was not freed for a new connection")
+	}
+
+	receive(t, slow)
+	if _, ok := <-slow.send; ok {
+		t.Error("evicted client's send channel left open")
+	}
+}
+
+func TestUnregisterFreesConnectionSlot(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{MaxClients: 1})
+	client := newTestClient(hub, 1)
+	waitFor(t, "the client to register", func() bool { return hub.GetClientCount() == 1 })
+
+	hub.unregister <- client
+	hub.unregister <- client // Unregistering twice must not free the slot twice
+
+	waitFor(t, "the client to unregister", func() bool { return hub.GetClientCount() == 0 })
+	if active, _, _ := hub.GetConnectionCounts(); active != 0 {
+		t.Errorf("%d connection slots held after unregistering, want 0", active)
+	}
+}
+
+func TestPingEchoesClientTimestamp(t *testing.T) {
+	client := &Client{hub: NewHub(config.WebSocketConfig{}, nil), send: make(chan []byte, 1), id: "test-client"}
+
+	client.handleMessage([]byte(`{"type": "ping", "data": {"timestamp": 1718000000123}}`))
+
+	pong := receive(t, client)
+	data, _ := pong["data"].(map[string]interface{})
+	if pong["type"] != "pong" || data["client_timestamp"] != 1718000000123.0 {
+		t.Errorf("reply = %v, want a pong echoing the client timestamp", pong)
+	}
+}
+
+func TestAverageLatencyCoversMeasuredClients(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	for _, rtt := range []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 0} {
+		client := newTestClient(hub, 1)
+		client.rtt = rtt
+	}
+	waitFor(t, "the clients to register", func() bool { return hub.GetClientCount() == 3 })
+
+	if latency := hub.GetAverageLatency(); latency != 20*time.Millisecond {
+		t.Errorf("average latency = %v, want 20ms over the two measured clients", latency)
+	}
+}
+
+func TestClientOptedOutOfSensorEventsReceivesAlertsAndStats(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	client := newTestClient(hub, 4)
+	waitFor(t, "the client to register", func() bool { return hub.GetClientCount() == 1 })
+	client.handleMessage([]byte(`{"type": "unsubscribe", "data": {"types": ["sensor_event"]}}`))
+
+	hub.BroadcastEvent(&models.SensorEvent{MachineID: "conveyor_001", Status: "ok"})
+	hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high"})
+	hub.BroadcastEvent(&models.SensorEvent{MachineID: "conveyor_001", Status: "ok"})
+	hub.BroadcastStats(map[string]int{"events": 2})
+
+	for _, want := range []string{"alert", "stats"} {
+		if message := receive(t, client); message["type"] != want {
+			t.Errorf("message type = %v, want %s", message["type"], want)
+		}
+	}
+	if queued := len(client.send); queued != 0 {
+		t.Errorf("%d further messages queued, want sensor events withheld", queued)
+	}
+
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"types": ["sensor_event"]}}`))
+	if message := receive(t, client); message["type"] != "subscribed" {
+		t.Fatalf("reply = %v, want subscribed", message)
+	}
+	hub.BroadcastEvent(&models.SensorEvent{MachineID: "conveyor_001", Status: "ok"})
+	if message := receive(t, client); message["type"] != "sensor_event" {
+		t.Errorf("message type = %v after opting back in, want sensor_event", message["type"])
+	}
+}
+
+func TestClientSeverityFloorFiltersAlerts(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	client := newTestClient(hub, 4)
+	waitFor(t, "the client to register", func() bool { return hub.GetClientCount() == 1 })
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"min_severity": "high"}}`))
+	if message := receive(t, client); message["type"] != "subscribed" {
+		t.Fatalf("reply = %v, want subscribed", message)
+	}
+
+	hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "speed_high", Severity: "medium"})
+	hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "temperature_critical", Severity: "critical"})
+
+	message := receive(t, client)
+	data, _ := message["data"].(map[string]interface{})
+	if message["type"] != "alert" || data["severity"] != "critical" {
+		t.Errorf("message = %v, want the critical alert", message)
+	}
+	if queued := len(client.send); queued != 0 {
+		t.Errorf("%d further messages queued, want the medium alert withheld", queued)
+	}
+
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"min_severity": "severe"}}`))
+	if message := receive(t, client); message["type"] != "error" {
+		t.Errorf("reply = %v, want an error for an unknown severity", message)
+	}
+}
+
+func TestBroadcastAfterShutdownIsNoOp(t *testing.T) {
+	for _, policy := range []string{config.BroadcastDrop, config.BroadcastBlock} {
+		// No Run loop: under the block policy a broadcast that reached the channel would hang
+		hub := NewHub(config.WebSocketConfig{BroadcastPolicy: policy}, nil)
+		if err := hub.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			hub.BroadcastEvent(&models.SensorEvent{MachineID: "conveyor_001", Status: "ok"})
+			hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high"})
+			hub.BroadcastResolved(&models.AlertResolution{MachineID: "conveyor_001", AlertType: "temperature_high"})
+			hub.BroadcastStats(map[string]int{"events": 1})
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s policy: broadcast after shutdown blocked", policy)
+		}
+		if queued := len(hub.broadcast); queued != 0 {
+			t.Errorf("%s policy: %d broadcasts queued after shutdown, want none", policy, queued)
+		}
+	}
+}
+
+func TestSubscriptionListReflectsClientState(t *testing.T) {
+	client := &Client{
+		hub:        NewHub(config.WebSocketConfig{}, nil),
+		send:       make(chan []byte, 8),
+		id:         "test-client",
+		subscribed: make(map[string]bool),
+		optedOut:   make(map[string]bool),
+	}
+	data := func(message map[string]interface{}) map[string]interface{} {
+		data, _ := message["data"].(map[string]interface{})
+		return data
+	}
+
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"topics": ["line1", "line2"]}}`))
+	reply := data(receive(t, client))
+	if fmt.Sprint(reply["added"]) != "[line1 line2]" || fmt.Sprint(reply["already_subscribed"]) != "[]" {
+		t.Errorf("first subscribe reply = %v, want line1 and line2 added", reply)
+	}
+
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"topics": ["line2", "line3", "line3"]}}`))
+	reply = data(receive(t, client))
+	if fmt.Sprint(reply["added"]) != "[line3]" || fmt.Sprint(reply["already_subscribed"]) != "[line2]" {
+		t.Errorf("repeated subscribe reply = %v, want line3 added and line2 already subscribed", reply)
+	}
+
+	client.handleMessage([]byte(`{"type": "unsubscribe", "data": {"topics": ["line1"], "types": ["stats"]}}`))
+	client.handleMessage([]byte(`{"type": "subscribe", "data": {"min_severity": "high"}}`))
+	receive(t, client)
+	client.handleMessage([]byte(`{"type": "get_subscriptions"}`))
+
+	message := receive(t, client)
+	reply = data(message)
+	if message["type"] != "subscriptions" {
+		t.Fatalf("reply = %v, want subscriptions", message)
+	}
+	if fmt.Sprint(reply["topics"]) != "[line2 line3]" || fmt.Sprint(reply["opted_out"]) != "[stats]" || reply["min_severity"] != "high" {
+		t.Errorf("subscriptions = %v, want topics line2 and line3, stats opted out and a high severity floor", reply)
+	}
+}
@@ -0,0 +1,40 @@
+package websocket
+
+import "sync"
+
+// connectionLimiter caps concurrent WebSocket connections, since each one holds a send
+// buffer and two goroutines. A slot is taken before the upgrade, so connections still
+// registering with the hub count towards the limit.
+type connectionLimiter struct {
+	max    int // Most concurrent connections; 0 is unlimited
+	mutex  sync.Mutex
+	active int
+	peak   int // Most connections held at once since startup
+}
+
+// acquire takes a connection slot, reporting false when the limit is reached
+func (l *connectionLimiter) acquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.max > 0 && l.active >= l.max {
+		return false
+	}
+	l.active++
+	l.peak = max(l.peak, l.active)
+	return true
+}
+
+// release frees the slot of a connection that closed or failed to upgrade
+func (l *connectionLimiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.active--
+}
+
+// counts returns the connections currently held and the peak since startup
+func (l *connectionLimiter) counts() (active, peak int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.active, l.peak
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyResolver validates origins and resolves client addresses for connections
+// that may arrive through a load balancer such as App Runner or an ALB
+type proxyResolver struct {
+	allowedOrigins map[string]bool
+	allowAll       bool
+	trusted        []*net.IPNet
+}
+
+// newProxyResolver creates a resolver for the given allowed origins and trusted proxy ranges
+func newProxyResolver(allowedOrigins []string, trusted []*net.IPNet) *proxyResolver {
+	p := &proxyResolver{
+		allowedOrigins: make(map[string]bool),
+		trusted:        trusted,
+	}
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			p.allowAll = true
+		}
+		p.allowedOrigins[normalizeOrigin(origin)] = true
+	}
+	return p
+}
+
+// checkOrigin accepts connections from configured origins. Requests relayed by a trusted
+// proxy are also accepted when the origin matches the host the client originally requested.
+func (p *proxyResolver) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.allowAll {
+		// Non-browser clients do not send an Origin header
+		return true
+	}
+
+	if p.allowedOrigins[normalizeOrigin(origin)] {
+		return true
+	}
+
+	if p.isTrusted(peerIP(r)) {
+		if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+			if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, forwardedHost) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the originating client address. X-Forwarded-For is only consulted when
+// the direct peer is a trusted proxy; the right-most untrusted hop is taken as the client
+// so that spoofed entries prepended by the client are ignored.
+func (p *proxyResolver) clientIP(r *http.Request) string {
+	peer := peerIP(r)
+	if !p.isTrusted(peer) {
+		return peer
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !p.isTrusted(hop) || i == 0 {
+			return hop
+		}
+	}
+
+	return peer
+}
+
+// isTrusted reports whether ip belongs to a configured trusted proxy range
+func (p *proxyResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range p.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP returns the address of the directly connected peer
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// normalizeOrigin lowercases an origin and strips any trailing slash
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
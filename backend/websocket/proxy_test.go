@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestResolver returns a resolver allowing https://dashboard.example.com and trusting
+// proxies in 10.0.0.0/8
+func newTestResolver(t *testing.T) *proxyResolver {
+	t.Helper()
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR: %v", err)
+	}
+	return newProxyResolver([]string{"https://dashboard.example.com/"}, []*net.IPNet{trusted})
+}
+
+func TestCheckOriginHonoursForwardedHostOnlyFromTrustedProxies(t *testing.T) {
+	resolver := newTestResolver(t)
+
+	for _, tc := range []struct {
+		name, peer, origin, forwardedHost string
+		accepted                          bool
+	}{
+		{"configured origin", "203.0.113.7", "https://Dashboard.example.com", "", true},
+		{"no origin", "203.0.113.7", "", "", true},
+		{"forwarded host from trusted proxy", "10.1.2.3", "https://fleet.internal", "fleet.internal", true},
+		{"forwarded host from untrusted peer", "203.0.113.7", "https://fleet.internal", "fleet.internal", false},
+		{"mismatched forwarded host", "10.1.2.3", "https://evil.example", "fleet.internal", false},
+	} {
+		request := httptest.NewRequest("GET", "/ws", nil)
+		request.RemoteAddr = tc.peer + ":51234"
+		if tc.origin != "" {
+			request.Header.Set("Origin", tc.origin)
+		}
+		if tc.forwardedHost != "" {
+			request.Header.Set("X-Forwarded-Host", tc.forwardedHost)
+		}
+
+		if accepted := resolver.checkOrigin(request); accepted != tc.accepted {
+			t.Errorf("%s: accepted = %v, want %v", tc.name, accepted, tc.accepted)
+		}
+	}
+}
+
+func TestClientIPTrustsForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	resolver := newTestResolver(t)
+
+	for _, tc := range []struct {
+		name, peer, forwardedFor, want string
+	}{
+		{"direct client", "203.0.113.7", "", "203.0.113.7"},
+		{"spoofed header from untrusted peer", "203.0.113.7", "198.51.100.1", "203.0.113.7"},
+		{"client behind trusted proxy", "10.1.2.3", "198.51.100.1", "198.51.100.1"},
+		{"spoofed hop prepended by the client", "10.1.2.3", "1.2.3.4, 198.51.100.1, 10.9.9.9", "198.51.100.1"},
+	} {
+		request := httptest.NewRequest("GET", "/ws", nil)
+		request.RemoteAddr = tc.peer + ":51234"
+		if tc.forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+
+		if got := resolver.clientIP(request); got != tc.want {
+			t.Errorf("%s: client IP = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxHeldMessages bounds the live messages held back while a client is replayed
+const maxHeldMessages = 1000
+
+// queueTimeout is how long a replay waits for a slow client before giving up
+const queueTimeout = 10 * time.Second
+
+// EventSource supplies stored events to clients resuming from a cursor
+type EventSource interface {
+	GetEventsAfter(afterID int, since *time.Time, limit int) ([]models.Event, error)
+}
+
+// resumeCursor is the last position in the event stream a reconnecting client has seen
+type resumeCursor struct {
+	afterID int
+	since   *time.Time
+}
+
+// parseResumeCursor reads the since_id and since query parameters. It returns nil
+// when neither is present, meaning the client only wants the live stream.
+func parseResumeCursor(query url.Values) (*resumeCursor, error) {
+	sinceID, since := query.Get("since_id"), query.Get("since")
+	if sinceID == "" && since == "" {
+		return nil, nil
+	}
+
+	cursor := &resumeCursor{}
+	if sinceID != "" {
+		id, err := strconv.Atoi(sinceID)
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("invalid since_id %q", sinceID)
+		}
+		cursor.afterID = id
+	}
+	if since != "" {
+		timestamp, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since %q (expected RFC3339)", since)
+		}
+		cursor.since = &timestamp
+	}
+
+	return cursor, nil
+}
+
+// resume replays the stored events after cursor to client, then releases the live
+// messages held back meanwhile. Held sensor events the replay already delivered are
+// skipped, so the client sees every event exactly once.
+func (h *Hub) resume(client *Client, cursor *resumeCursor) {
+	lastID := cursor.afterID
+	complete := map[string]interface{}{}
+
+	events, err := h.events.GetEventsAfter(cursor.afterID, cursor.since, h.backfill)
+	if err != nil {
+		log.Printf("Failed to replay events to client %s: %v", client.id, err)
+		complete["error"] = "failed to load stored events"
+	}
+
+	for _, stored := range events {
+		if !client.queueMessage("sensor_event", stored.SensorEvent()) {
+			client.release(lastID)
+			return
+		}
+		lastID = stored.ID
+	}
+
+	// A full page means older events may remain; the client can page them via the REST API
+	complete["count"] = len(events)
+	complete["last_event_id"] = lastID
+	complete["truncated"] = h.backfill > 0 && len(events) == h.backfill
+	if client.queueMessage("backfill_complete", complete) {
+		log.Printf("Replayed %d events to client %s", len(events), client.id)
+	}
+
+	client.release(lastID)
+}
+
+// queueMessage encodes and queues a message for the client, waiting briefly if its
+// buffer is full. It reports false if the client could not keep up.
+func (c *Client) queueMessage(msgType string, data interface{}) bool {
+	message := models.WebSocketMessage{
+		Type:      msgType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	msgBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to encode %s for client %s: %v", msgType, c.id, err)
+		return true
+	}
+
+	return c.queue(msgBytes)
+}
+
+// queue sends an encoded message to the client, waiting up to queueTimeout
+func (c *Client) queue(msgBytes []byte) bool {
+	if !c.deliver(msgBytes, queueTimeout) {
+		log.Printf("Client %s is not reading, abandoning replay", c.id)
+		return false
+	}
+	return true
+}
+
+// hold buffers a live broadcast while the client is being replayed. It reports
+// whether the message was held.
+func (c *Client) hold(message broadcastMessage) bool {
+	c.holdMutex.Lock()
+	defer c.holdMutex.Unlock()
+
+	if !c.holding {
+		return false
+	}
+
+	if len(c.held) >= maxHeldMessages {
+		log.Printf("Client %s replay backlog full, dropping oldest live message", c.id)
+		c.held = c.held[1:]
+		c.skipSequence()
+	}
+	c.held = append(c.held, message)
+	return true
+}
+
+// release sends the held live messages, skipping sensor events up to lastID that the
+// replay already delivered, and switches the client to the live stream. Messages are
+// delivered outside holdMutex, so the hub's broadcast loop is never stalled behind a
+// slow client; those held meanwhile are sent in the next pass, and the client goes live
+// once a pass finds none left.
+func (c *Client) release(lastID int) {
+	for {
+		c.holdMutex.Lock()
+		held := c.held
+		c.held = nil
+		if len(held) == 0 {
+			c.holding = false
+		}
+		c.holdMutex.Unlock()
+
+		if len(held) == 0 {
+			return
+		}
+
+		for _, message := range held {
+			if message.eventID != 0 && message.eventID <= lastID {
+				continue
+			}
+			if !c.wants(message) {
+				continue
+			}
+			if !c.queue(message.payload) {
+				c.holdMutex.Lock()
+				c.holding = false
+				c.held = nil
+				c.holdMutex.Unlock()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/database"
+	"backend/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// dial connects a WebSocket client to server with the given query string
+func dial(t *testing.T, server *httptest.Server, query string) *gorilla.Conn {
+	t.Helper()
+	conn, _, err := gorilla.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+query, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nextMessage reads the next message sent on conn, returning its type and data
+func nextMessage(t *testing.T, conn *gorilla.Conn) (string, map[string]interface{}) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message struct {
+		Type string                 `json:"type"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return message.Type, message.Data
+}
+
+func TestParseResumeCursor(t *testing.T) {
+	cursor, err := parseResumeCursor(url.Values{"since_id": {"42"}, "since": {"2026-01-02T03:04:05Z"}})
+	if err != nil {
+		t.Fatalf("parseResumeCursor: %v", err)
+	}
+	if cursor.afterID != 42 || cursor.since == nil || cursor.since.Hour() != 3 {
+		t.Errorf("cursor = %+v, want after 42 since 03:04:05", cursor)
+	}
+
+	if cursor, err := parseResumeCursor(url.Values{}); cursor != nil || err != nil {
+		t.Errorf("no parameters gave %+v, %v; want no cursor", cursor, err)
+	}
+	for _, query := range []url.Values{{"since_id": {"-1"}}, {"since_id": {"abc"}}, {"since": {"yesterday"}}} {
+		if _, err := parseResumeCursor(query); err == nil {
+			t.Errorf("%v accepted", query)
+		}
+	}
+}
+
+func TestReleaseSkipsReplayedEvents(t *testing.T) {
+	hub := NewHub(config.WebSocketConfig{}, nil)
+	client := &Client{hub: hub, send: make(chan []byte, 10), holding: true, optedOut: map[string]bool{}}
+
+	client.hold(broadcastMessage{msgType: "sensor_event", eventID: 5, payload: []byte(`{"type":"sensor_event","id":5}`)})
+	client.hold(broadcastMessage{msgType: "sensor_event", eventID: 6, payload: []byte(`{"type":"sensor_event","id":6}`)})
+	client.release(5)
+
+	if got := receive(t, client)["id"]; got != float64(6) {
+		t.Errorf("released event %v, want 6 after skipping the replayed 5", got)
+	}
+	if client.hold(broadcastMessage{msgType: "stats"}) {
+		t.Error("client still holding after release")
+	}
+}
+
+// TestReleaseDoesNotStallBroadcasts checks that while a resuming client is slow to take
+// its held messages, the hub keeps holding new ones for it and delivering to others
+func TestReleaseDoesNotStallBroadcasts(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	resuming := newTestClient(hub, 0) // Unbuffered: each held message waits for a read
+	resuming.holdMutex.Lock()
+	resuming.holding = true
+	resuming.holdMutex.Unlock()
+	other := newTestClient(hub, 10)
+	waitFor(t, "both clients to register", func() bool { return hub.GetClientCount() == 2 })
+
+	hub.BroadcastStats(map[string]int{"events": 1})
+	receive(t, other)
+
+	released := make(chan struct{})
+	go func() {
+		resuming.release(0)
+		close(released)
+	}()
+
+	// Let the release block delivering the first held message
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		hub.BroadcastStats(map[string]int{"events": 2})
+		hub.BroadcastStats(map[string]int{"events": 3})
+	}()
+	for want := 2; want <= 3; want++ {
+		if got := receive(t, other)["data"].(map[string]interface{})["events"]; got != float64(want) {
+			t.Errorf("other client received events=%v, want %d", got, want)
+		}
+	}
+
+	for want := 1; want <= 3; want++ {
+		message := receive(t, resuming)
+		if got := message["data"].(map[string]interface{})["events"]; got != float64(want) {
+			t.Errorf("resuming client received events=%v, want %d in order", got, want)
+		}
+	}
+	<-released
+	if resuming.hold(broadcastMessage{msgType: "stats"}) {
+		t.Error("client still holding after release")
+	}
+}
+
+// TestResumeFromCursorContinuesWithoutGapsOrDuplicates disconnects a client after three
+// events and reconnects it with the last event ID it saw, while events keep arriving
+func TestResumeFromCursorContinuesWithoutGapsOrDuplicates(t *testing.T) {
+	store := database.NewMemoryStore()
+	hub := NewHub(config.WebSocketConfig{BackfillLimit: 100, BroadcastPolicy: config.BroadcastBlock}, store)
+	go hub.Run()
+	t.Cleanup(func() { hub.shutdownOnce.Do(func() { close(hub.done) }) })
+	server := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	publish := func() {
+		stored, err := store.InsertEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok", Timestamp: time.Now()})
+		if err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+		hub.BroadcastEvent(stored.SensorEvent())
+	}
+
+	var seen []int
+	first := dial(t, server, "")
+	if msgType, _ := nextMessage(t, first); msgType != "connection" {
+		t.Fatalf("first message %s, want connection", msgType)
+	}
+	for i := 0; i < 3; i++ {
+		publish()
+		if msgType, data := nextMessage(t, first); msgType == "sensor_event" {
+			seen = append(seen, int(data["id"].(float64)))
+		}
+	}
+	first.Close()
+
+	// Events stored while the client is away, and one arriving as it reconnects
+	publish()
+	publish()
+	second := dial(t, server, "?since_id=3")
+	publish()
+
+	backfilled := false
+	for len(seen) < 6 {
+		msgType, data := nextMessage(t, second)
+		switch msgType {
+		case "sensor_event":
+			seen = append(seen, int(data["id"].(float64)))
+		case "backfill_complete":
+			backfilled = true
+		}
+	}
+	if !backfilled {
+		t.Error("no backfill_complete sent on resuming")
+	}
+	for i, id := range seen {
+		if id != i+1 {
+			t.Fatalf("event IDs received = %v, want 1-6 in order, each once", seen)
+		}
+	}
+
+	publish()
+	for {
+		if msgType, data := nextMessage(t, second); msgType == "sensor_event" {
+			if id := int(data["id"].(float64)); id != 7 {
+				t.Errorf("live event %d after resuming, want 7", id)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Every message sent to a client carries a per-connection sequence number, "seq", starting
+// at 1. Messages the client filtered out (subscriptions, opt-outs, severity floor) or that
+// were coalesced never consume a number, so a gap means a message meant for the client was
+// dropped, e.g. because it could not keep up; the client can then reconnect with since_id
+// to backfill the events it missed.
+
+// deliver stamps payload with the client's next sequence number, encodes it for the
+// client and queues it, waiting up to timeout for buffer space (not at all for 0). It
+// reports whether the message was queued; a dropped message still consumes its number
+// so the client sees the gap.
+func (c *Client) deliver(payload []byte, timeout time.Duration) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.lastSeq++
+	if c.sendClosed {
+		return false
+	}
+	payload = withSequence(payload, c.lastSeq)
+	if c.binary {
+		encoded, err := jsonToMsgpack(payload)
+		if err != nil {
+			log.Printf("Failed to encode MessagePack message for client %s: %v", c.id, err)
+			return false
+		}
+		payload = encoded
+	}
+
+	if timeout <= 0 {
+		select {
+		case c.send <- payload:
+			return true
+		default:
+			return false
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.send <- payload:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// skipSequence consumes a sequence number for a message dropped before it was delivered
+func (c *Client) skipSequence() {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	c.lastSeq++
+}
+
+// closeSend closes the send channel, ending the write pump. Later deliveries are dropped.
+func (c *Client) closeSend() {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.send)
+	}
+}
+
+// withSequence inserts a "seq" field at the start of an encoded JSON object. Broadcasts are
+// encoded once for all clients, so the per-client number is spliced in rather than encoded.
+func withSequence(payload []byte, seq uint64) []byte {
+	if len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+
+	stamped := make([]byte, 0, len(payload)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendUint(stamped, seq, 10)
+	if payload[1] != '}' {
+		stamped = append(stamped, ',')
+	}
+	return append(stamped, payload[1:]...)
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/models"
+	"testing"
+)
+
+// sequenceOf returns the sequence number of a decoded message
+func sequenceOf(t *testing.T, message map[string]interface{}) uint64 {
+	t.Helper()
+	seq, ok := message["seq"].(float64)
+	if !ok {
+		t.Fatalf("message %v has no sequence number", message)
+	}
+	return uint64(seq)
+}
+
+func TestBroadcastSequenceNumbersIncrement(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	client := newTestClient(hub, 8)
+	waitFor(t, "the client to register", func() bool { return hub.GetClientCount() == 1 })
+
+	hub.BroadcastStats(map[string]int{"events": 1})
+	hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "high"})
+	hub.BroadcastStats(map[string]int{"events": 2})
+
+	for want := uint64(1); want <= 3; want++ {
+		if seq := sequenceOf(t, receive(t, client)); seq != want {
+			t.Errorf("sequence = %d, want %d", seq, want)
+		}
+	}
+}
+
+func TestDroppedMessageLeavesSequenceGap(t *testing.T) {
+	client := &Client{send: make(chan []byte, 2), id: "test-client"}
+
+	for i := 0; i < 3; i++ {
+		if queued := client.deliver([]byte(`{"type":"stats"}`), 0); queued != (i < 2) {
+			t.Errorf("message %d queued = %v with a buffer of 2", i+1, queued)
+		}
+	}
+	receive(t, client)
+	receive(t, client)
+	client.deliver([]byte(`{"type":"stats"}`), 0)
+
+	if seq := sequenceOf(t, receive(t, client)); seq != 4 {
+		t.Errorf("sequence after a dropped message = %d, want 4, leaving a gap at 3", seq)
+	}
+}
+
+func TestWithSequenceSplicesIntoEmptyAndNonEmptyObjects(t *testing.T) {
+	for _, tc := range []struct {
+		payload, want string
+	}{
+		{`{"type":"stats"}`, `{"seq":7,"type":"stats"}`},
+		{`{}`, `{"seq":7}`},
+		{`[1]`, `[1]`},
+	} {
+		if got := string(withSequence([]byte(tc.payload), 7)); got != tc.want {
+			t.Errorf("withSequence(%s) = %s, want %s", tc.payload, got, tc.want)
+		}
+	}
+}
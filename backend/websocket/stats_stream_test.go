@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"backend/config"
+	"backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestStatsStreamReceivesOnlyStats(t *testing.T) {
+	hub := newTestHub(t, config.WebSocketConfig{})
+	full := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(full.Close)
+	statsStream := httptest.NewServer(http.HandlerFunc(hub.HandleStatsWebSocket))
+	t.Cleanup(statsStream.Close)
+
+	client := dial(t, full, "")
+	statsClient := dial(t, statsStream, "")
+	for _, conn := range []*gorilla.Conn{client, statsClient} {
+		if msgType, _ := nextMessage(t, conn); msgType != "connection" {
+			t.Fatalf("greeted with %s, want connection", msgType)
+		}
+	}
+
+	// Opting back in to other message types has no effect on the stats stream
+	statsClient.WriteJSON(map[string]interface{}{"type": "subscribe", "data": map[string]interface{}{"types": []string{"sensor_event", "alert"}}})
+	if msgType, _ := nextMessage(t, statsClient); msgType != "subscribed" {
+		t.Fatalf("reply = %s, want subscribed", msgType)
+	}
+
+	waitFor(t, "both clients to register", func() bool { return hub.GetClientCount() == 2 })
+	hub.BroadcastEvent(&models.SensorEvent{MachineID: "conveyor_001", EventType: "conveyor", Status: "ok"})
+	hub.BroadcastAlert(&models.Alert{MachineID: "conveyor_001", AlertType: "temperature_high", Severity: "critical"})
+	hub.BroadcastStats(map[string]int{"events": 3})
+
+	for _, want := range []string{"sensor_event", "alert", "stats"} {
+		if msgType, _ := nextMessage(t, client); msgType != want {
+			t.Errorf("full client received %s, want %s", msgType, want)
+		}
+	}
+	msgType, data := nextMessage(t, statsClient)
+	if msgType != "stats" || data["events"] != float64(3) {
+		t.Errorf("stats client received %s %v first, want the stats", msgType, data)
+	}
+}
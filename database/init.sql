@@ -10,6 +10,8 @@ CREATE TABLE IF NOT EXISTS events (
     temperature DECIMAL(5,2),
     robot_arm_angle DECIMAL(5,2),
     status VARCHAR(20) NOT NULL DEFAULT 'ok',
+    line VARCHAR(50) NOT NULL DEFAULT '',
+    fault_code VARCHAR(50),
     raw_data JSONB,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -18,12 +20,35 @@ CREATE TABLE IF NOT EXISTS events (
 CREATE TABLE IF NOT EXISTS alerts (
     id SERIAL PRIMARY KEY,
     event_id INTEGER REFERENCES events(id),
+    machine_id VARCHAR(50) NOT NULL DEFAULT '',
     alert_type VARCHAR(50) NOT NULL,
     severity VARCHAR(20) NOT NULL DEFAULT 'medium',
     message TEXT NOT NULL,
+    confidence DOUBLE PRECISION CHECK (confidence BETWEEN 0 AND 1),
+    recommended_action TEXT, -- What operators should do, from the fault taxonomy
     acknowledged BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    acknowledged_at TIMESTAMPTZ
+    acknowledged_at TIMESTAMPTZ,
+    acknowledged_by VARCHAR(100),
+    acknowledgement_note TEXT,
+    resolved_at TIMESTAMPTZ,
+    test BOOLEAN NOT NULL DEFAULT FALSE -- Synthetic alerts fired to verify notification delivery
+);
+
+-- Alert snoozes silence new alerts of one type for a machine until they expire
+CREATE TABLE IF NOT EXISTS alert_snoozes (
+    machine_id VARCHAR(50) NOT NULL,
+    alert_type VARCHAR(50) NOT NULL,
+    snoozed_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (machine_id, alert_type)
+);
+
+-- Anomaly detection rules switched on or off by operators; rules without a row are enabled
+CREATE TABLE IF NOT EXISTS anomaly_rules (
+    rule_name VARCHAR(50) PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
 -- Process parameters table for dynamic control
@@ -42,18 +67,55 @@ CREATE TABLE IF NOT EXISTS machines (
     machine_id VARCHAR(50) NOT NULL UNIQUE,
     machine_type VARCHAR(50) NOT NULL,
     location VARCHAR(100),
+    area VARCHAR(50) NOT NULL DEFAULT '',
+    line VARCHAR(50) NOT NULL DEFAULT '',
     status VARCHAR(20) NOT NULL DEFAULT 'active',
     config JSONB,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Audit trail of configuration changes and operator actions
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    client_ip VARCHAR(45) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    target VARCHAR(100) NOT NULL DEFAULT '',
+    old_value JSONB,
+    new_value JSONB
+);
+
+-- Upgrade databases created from an older version of this file, which Postgres does not
+-- re-run; the backend applies the same statements at startup (database/migrations.go)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS line VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS fault_code VARCHAR(50);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS machine_id VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION CHECK (confidence BETWEEN 0 AND 1);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS recommended_action TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_by VARCHAR(100);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledgement_note TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS test BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE alerts SET machine_id = events.machine_id FROM events
+    WHERE alerts.machine_id = '' AND alerts.event_id = events.id;
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS area VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE machines ADD COLUMN IF NOT EXISTS line VARCHAR(50) NOT NULL DEFAULT '';
+
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
-CREATE INDEX IF NOT EXISTS idx_events_machine_id ON events(machine_id);
+-- Serves per-machine event listing and stats, including the (timestamp, id) keyset cursor
+CREATE INDEX IF NOT EXISTS idx_events_machine_timestamp ON events(machine_id, timestamp DESC, id DESC);
 CREATE INDEX IF NOT EXISTS idx_events_status ON events(status);
+CREATE INDEX IF NOT EXISTS idx_events_line ON events(line);
+CREATE INDEX IF NOT EXISTS idx_events_fault_code ON events(fault_code) WHERE fault_code IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_alerts_created_at ON alerts(created_at);
-CREATE INDEX IF NOT EXISTS idx_alerts_acknowledged ON alerts(acknowledged);
+-- Serves the unacknowledged alert listing ordered by recency
+CREATE INDEX IF NOT EXISTS idx_alerts_acknowledged_created_at ON alerts(acknowledged, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_machines_area ON machines(area);
+CREATE INDEX IF NOT EXISTS idx_alerts_machine_type ON alerts(machine_id, alert_type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at DESC);
 
 -- Insert default process parameters
 INSERT INTO process_parameters (parameter_name, parameter_value, parameter_type, description) VALUES
@@ -66,8 +128,8 @@ INSERT INTO process_parameters (parameter_name, parameter_value, parameter_type,
 ON CONFLICT (parameter_name) DO NOTHING;
 
 -- Insert default machines
-INSERT INTO machines (machine_id, machine_type, location, config) VALUES
-('conveyor_001', 'conveyor', 'Line 1 - Station A', '{"max_speed": 3.0, "length": 10.0}'),
-('robot_arm_001', 'robot_arm', 'Line 1 - Station B', '{"max_angle": 180, "payload": 50}'),
-('sensor_hub_001', 'sensor_hub', 'Line 1 - Central', '{"sensors": ["temperature", "speed", "position"]}')
+INSERT INTO machines (machine_id, machine_type, location, area, line, config) VALUES
+('conveyor_001', 'conveyor', 'Line 1 - Station A', 'assembly', 'line1', '{"max_speed": 3.0, "rated_speed": 2.0, "length": 10.0}'),
+('robot_arm_001', 'robot_arm', 'Line 1 - Station B', 'assembly', 'line1', '{"max_angle": 180, "payload": 50}'),
+('sensor_hub_001', 'sensor_hub', 'Line 1 - Central', 'assembly', 'line1', '{"sensors": ["temperature", "speed", "position"]}')
 ON CONFLICT (machine_id) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync/atomic"
+
+	"github.com/IBM/sarama"
+)
+
+// DeliveryStats counts publish outcomes over the simulator's lifetime
+type DeliveryStats struct {
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	retried   atomic.Uint64
+}
+
+// Log writes a one-line summary of delivery outcomes
+func (d *DeliveryStats) Log() {
+	delivered, failed := d.delivered.Load(), d.failed.Load()
+
+	var successRate float64
+	if total := delivered + failed; total > 0 {
+		successRate = float64(delivered) / float64(total) * 100
+	}
+
+	log.Printf("Delivery summary: delivered=%d, failed=%d, retries=%d, success_rate=%.1f%%",
+		delivered, failed, d.retried.Load(), successRate)
+}
+
+// isRetriable reports whether a failed delivery may succeed if sent again. Transient
+// broker and network conditions are retried; anything else, such as an oversized or
+// invalid message or a producer that is shutting down, is treated as fatal.
+func isRetriable(err error) bool {
+	var kerr sarama.KError
+	if errors.As(err, &kerr) {
+		switch kerr {
+		case sarama.ErrNotLeaderForPartition,
+			sarama.ErrLeaderNotAvailable,
+			sarama.ErrUnknownTopicOrPartition,
+			sarama.ErrRequestTimedOut,
+			sarama.ErrNotEnoughReplicas,
+			sarama.ErrNotEnoughReplicasAfterAppend,
+			sarama.ErrNetworkException:
+			return true
+		default:
+			return false
+		}
+	}
+
+	if errors.Is(err, sarama.ErrOutOfBrokers) || errors.Is(err, sarama.ErrNotConnected) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+// newPublishingSimulator returns a test simulator publishing through a mock producer,
+// resending retriable failures up to retries times
+func newPublishingSimulator(t *testing.T, retries int) (*SensorSimulator, *mocks.SyncProducer) {
+	t.Helper()
+	producer := mocks.NewSyncProducer(t, nil)
+	t.Cleanup(func() { producer.Close() })
+
+	simulator := newTestSimulator(defaultProfile())
+	simulator.producer = producer
+	simulator.topic = "sensor-events"
+	simulator.partition = -1
+	simulator.publishRetries = retries
+	return simulator, producer
+}
+
+func TestPublishRetriesRetriableFailureThenSucceeds(t *testing.T) {
+	simulator, producer := newPublishingSimulator(t, 3)
+	producer.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+	producer.ExpectSendMessageAndFail(sarama.ErrRequestTimedOut)
+	producer.ExpectSendMessageAndSucceed()
+
+	if err := simulator.publishEvent(simulator.generateSensorEvent()); err != nil {
+		t.Fatalf("publishEvent: %v", err)
+	}
+	if delivered, failed, retried := simulator.delivery.delivered.Load(), simulator.delivery.failed.Load(), simulator.delivery.retried.Load(); delivered != 1 || failed != 0 || retried != 2 {
+		t.Errorf("delivered=%d failed=%d retried=%d, want 1, 0 and 2", delivered, failed, retried)
+	}
+}
+
+func TestPublishGivesUpAfterRetries(t *testing.T) {
+	simulator, producer := newPublishingSimulator(t, 1)
+	producer.ExpectSendMessageAndFail(sarama.ErrLeaderNotAvailable)
+	producer.ExpectSendMessageAndFail(sarama.ErrLeaderNotAvailable)
+
+	if err := simulator.publishEvent(simulator.generateSensorEvent()); err == nil {
+		t.Fatal("publishEvent succeeded with every attempt failing")
+	}
+	if failed, retried := simulator.delivery.failed.Load(), simulator.delivery.retried.Load(); failed != 1 || retried != 1 {
+		t.Errorf("failed=%d retried=%d, want 1 and 1", failed, retried)
+	}
+}
+
+func TestPublishDoesNotRetryFatalFailure(t *testing.T) {
+	simulator, producer := newPublishingSimulator(t, 3)
+	producer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+
+	if err := simulator.publishEvent(simulator.generateSensorEvent()); err == nil {
+		t.Fatal("publishEvent succeeded after a fatal failure")
+	}
+	if failed, retried := simulator.delivery.failed.Load(), simulator.delivery.retried.Load(); failed != 1 || retried != 0 {
+		t.Errorf("failed=%d retried=%d, want a single failure without retries", failed, retried)
+	}
+}
+
+// closingClient is a client that only closes
+type closingClient struct {
+	sarama.Client
+	closed bool
+}
+
+func (c *closingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestStartPublishesExactlyLimitEventsThenStops(t *testing.T) {
+	simulator, producer := newPublishingSimulator(t, 0)
+	client := &closingClient{}
+	simulator.client = client
+	simulator.frequency = time.Millisecond
+	simulator.flushTimeout = time.Second
+	for i := 0; i < 5; i++ {
+		producer.ExpectSendMessageAndSucceed()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		simulator.Start(5)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("simulator still running after its event limit")
+	}
+
+	if delivered := simulator.delivery.delivered.Load(); delivered != 5 {
+		t.Errorf("delivered %d events, want 5", delivered)
+	}
+	if !client.closed {
+		t.Error("client not closed on exit")
+	}
+}
@@ -17,51 +17,154 @@ import (
 
 // SensorEvent represents a sensor reading event
 type SensorEvent struct {
-	Timestamp       time.Time `json:"timestamp"`
-	MachineID       string    `json:"machine_id"`
-	ConveyorSpeed   float64   `json:"conveyor_speed"`
-	Temperature     float64   `json:"temperature"`
-	RobotArmAngle   float64   `json:"robot_arm_angle"`
-	Status          string    `json:"status"`
-	EventType       string    `json:"event_type"`
-	AdditionalData  map[string]interface{} `json:"additional_data,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+	MachineID      string                 `json:"machine_id"`
+	ConveyorSpeed  float64                `json:"conveyor_speed"`
+	Temperature    float64                `json:"temperature"`
+	RobotArmAngle  float64                `json:"robot_arm_angle"`
+	Status         string                 `json:"status"`
+	EventType      string                 `json:"event_type"`
+	AdditionalData map[string]interface{} `json:"additional_data,omitempty"`
 }
 
 // SensorSimulator handles sensor data generation and publishing
 type SensorSimulator struct {
-	producer        sarama.SyncProducer
-	topic           string
-	frequency       time.Duration
-	machineID       string
-	faultRate       float64
-	conveyorSpeed   float64
-	temperature     float64
-	robotArmAngle   float64
+	client        sarama.Client // Owned by the simulator; the producer does not close it
+	producer      sarama.SyncProducer
+	topic         string
+	frequency     time.Duration
+	machineID     string
+	faultRate     float64
+	profile       SensorProfile
+	partition     int32 // Partition every event is sent to under fixed partitioning; -1 when keyed
+	conveyorSpeed float64
+	temperature   float64
+	robotArmAngle float64
+
+	publishRetries int
+	retryBackoff   time.Duration
+	reportInterval time.Duration
+	flushTimeout   time.Duration
+	delivery       DeliveryStats
 }
 
-// NewSensorSimulator creates a new sensor simulator instance
-func NewSensorSimulator(brokers, topic, machineID string, frequency time.Duration) (*SensorSimulator, error) {
+// ProducerSettings controls Kafka delivery guarantees for the simulator
+type ProducerSettings struct {
+	Acks       string // "all", "leader" or "none"
+	Retries    int
+	Idempotent bool
+
+	PublishRetries int           // Resends of a message after a retriable delivery failure
+	RetryBackoff   time.Duration // Wait between resends, doubled after each attempt
+	ReportInterval time.Duration // How often the delivery summary is logged; 0 disables
+	FlushTimeout   time.Duration // How long Close waits for in-flight messages to flush
+
+	PartitionStrategy string           // PartitionKeyed or PartitionFixed
+	PartitionMap      map[string]int32 // Partition of each machine under fixed partitioning
+}
+
+// newProducerConfig builds the sarama producer configuration for the given settings,
+// along with the machine's fixed partition (-1 when partitioned by key).
+// Idempotent production requires acks=all and at least one retry; with it enabled the
+// broker deduplicates retried sends, so retries no longer risk duplicate events.
+func newProducerConfig(machineID string, settings ProducerSettings) (*sarama.Config, int32, error) {
 	config := sarama.NewConfig()
-	config.Producer.RequiredAcks = sarama.WaitForAll
-	config.Producer.Retry.Max = 3
+
+	switch settings.Acks {
+	case "all":
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	case "leader":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "none":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		return nil, 0, fmt.Errorf("invalid producer acks %q (expected all, leader or none)", settings.Acks)
+	}
+
+	if settings.Retries < 0 {
+		return nil, 0, fmt.Errorf("producer retries must not be negative")
+	}
+	if settings.PublishRetries < 0 {
+		return nil, 0, fmt.Errorf("publish retries must not be negative")
+	}
+	config.Producer.Retry.Max = settings.Retries
+
+	if settings.Idempotent {
+		if settings.Acks != "all" {
+			return nil, 0, fmt.Errorf("idempotent producer requires acks=all, got %q", settings.Acks)
+		}
+		if settings.Retries < 1 {
+			return nil, 0, fmt.Errorf("idempotent producer requires at least one retry")
+		}
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+		config.Version = sarama.V2_6_0_0
+	}
+
+	partition, err := configurePartitioner(config, machineID, settings)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	config.Producer.Return.Successes = true
 	config.ClientID = fmt.Sprintf("sensor-simulator-%s", machineID)
 
+	if err := config.Validate(); err != nil {
+		return nil, 0, fmt.Errorf("invalid producer config: %v", err)
+	}
+
+	return config, partition, nil
+}
+
+// NewSensorSimulator creates a new sensor simulator instance
+func NewSensorSimulator(brokers, topic, machineID string, frequency time.Duration, settings ProducerSettings, profile SensorProfile) (*SensorSimulator, error) {
+	config, partition, err := newProducerConfig(machineID, settings)
+	if err != nil {
+		return nil, err
+	}
+
 	brokerList := []string{brokers}
-	producer, err := sarama.NewSyncProducer(brokerList, config)
+	client, err := sarama.NewClient(brokerList, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %v", err)
+	}
+
+	// Every event of the machine goes to one partition; report which, or fail fast when
+	// the fixed mapping points past the topic's partitions
+	if assigned, count, err := resolvePartition(client, topic, machineID, partition); err != nil {
+		if count > 0 {
+			client.Close()
+			return nil, err
+		}
+		log.Printf("Warning: could not check partition assignment: %v", err)
+	} else {
+		log.Printf("Machine %s publishes to %s partition %d of %d (%s partitioning)",
+			machineID, topic, assigned, count, settings.PartitionStrategy)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create producer: %v", err)
 	}
 
 	return &SensorSimulator{
+		client:        client,
 		producer:      producer,
 		topic:         topic,
 		frequency:     frequency,
 		machineID:     machineID,
 		faultRate:     0.02, // 2% fault probability
-		conveyorSpeed: 1.5,  // Initial speed
-		temperature:   72.0, // Initial temperature
-		robotArmAngle: 90.0, // Initial angle
+		profile:       profile,
+		partition:     partition,
+		conveyorSpeed: profile.ConveyorSpeed.Initial,
+		temperature:   profile.Temperature.Initial,
+		robotArmAngle: profile.RobotArmAngle.Initial,
+
+		publishRetries: settings.PublishRetries,
+		retryBackoff:   settings.RetryBackoff,
+		reportInterval: settings.ReportInterval,
+		flushTimeout:   settings.FlushTimeout,
 	}, nil
 }
 
@@ -69,15 +172,13 @@ func NewSensorSimulator(brokers, topic, machineID string, frequency time.Duratio
 func (s *SensorSimulator) generateSensorEvent() *SensorEvent {
 	now := time.Now()
 
-	// Add some realistic variation to sensor readings
-	s.conveyorSpeed += (rand.Float64() - 0.5) * 0.2 // ±0.1 variation
-	s.temperature += (rand.Float64() - 0.5) * 2.0   // ±1.0 variation
-	s.robotArmAngle += (rand.Float64() - 0.5) * 10.0 // ±5.0 variation
-
-	// Keep values within realistic bounds
-	s.conveyorSpeed = clamp(s.conveyorSpeed, 0.5, 3.0)
-	s.temperature = clamp(s.temperature, 20.0, 80.0)
-	s.robotArmAngle = clamp(s.robotArmAngle, 0.0, 180.0)
+	// Add realistic variation to sensor readings, kept within the profile's bounds. The
+	// other metrics' changes are correlated with the conveyor speed's as configured.
+	speedNoise := unitNoise()
+	correlation := s.profile.Correlation
+	s.conveyorSpeed = s.profile.ConveyorSpeed.Step(s.conveyorSpeed, speedNoise)
+	s.temperature = s.profile.Temperature.Step(s.temperature, correlatedNoise(speedNoise, correlation.SpeedTemperature))
+	s.robotArmAngle = s.profile.RobotArmAngle.Step(s.robotArmAngle, correlatedNoise(speedNoise, correlation.SpeedRobotArm))
 
 	status := "ok"
 	eventType := "normal"
@@ -129,7 +230,8 @@ func (s *SensorSimulator) generateSensorEvent() *SensorEvent {
 	}
 }
 
-// publishEvent sends sensor event to Kafka
+// publishEvent sends sensor event to Kafka. Retriable delivery failures are resent up to
+// the configured number of times with exponential backoff; fatal failures are not.
 func (s *SensorSimulator) publishEvent(event *SensorEvent) error {
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
@@ -142,35 +244,67 @@ func (s *SensorSimulator) publishEvent(event *SensorEvent) error {
 		{Key: []byte("status"), Value: []byte(event.Status)},
 	}
 
+	// Partition is only honoured under fixed partitioning; otherwise the key decides it
 	message := &sarama.ProducerMessage{
-		Topic:   s.topic,
-		Key:     sarama.StringEncoder(event.MachineID),
-		Value:   sarama.ByteEncoder(eventJSON),
-		Headers: headers,
+		Topic:     s.topic,
+		Key:       sarama.StringEncoder(event.MachineID),
+		Value:     sarama.ByteEncoder(eventJSON),
+		Headers:   headers,
+		Partition: s.partition,
 	}
 
-	partition, offset, err := s.producer.SendMessage(message)
-	if err != nil {
-		return fmt.Errorf("failed to produce message: %v", err)
-	}
+	backoff := s.retryBackoff
+	for attempt := 0; ; attempt++ {
+		partition, offset, err := s.producer.SendMessage(message)
+		if err == nil {
+			s.delivery.delivered.Add(1)
+			log.Printf("Event delivered to topic %s [%d] at offset %v: %s",
+				s.topic, partition, offset, event.EventType)
+			return nil
+		}
 
-	log.Printf("Event delivered to topic %s [%d] at offset %v: %s",
-		s.topic, partition, offset, event.EventType)
+		if !isRetriable(err) {
+			s.delivery.failed.Add(1)
+			return fmt.Errorf("failed to produce message (not retriable): %v", err)
+		}
+		if attempt >= s.publishRetries {
+			s.delivery.failed.Add(1)
+			return fmt.Errorf("failed to produce message after %d attempts: %v", attempt+1, err)
+		}
 
-	return nil
+		s.delivery.retried.Add(1)
+		log.Printf("Retriable delivery failure (attempt %d/%d), retrying in %v: %v",
+			attempt+1, s.publishRetries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
 }
 
-// Start begins the sensor simulation loop
-func (s *SensorSimulator) Start() {
+// Start begins the sensor simulation loop. With a positive limit the simulator stops
+// after publishing that many events, delivered or not, which gives integration tests a
+// deterministic workload; otherwise it runs until interrupted.
+func (s *SensorSimulator) Start(limit int) {
 	log.Printf("Starting sensor simulator for machine %s, frequency: %v", s.machineID, s.frequency)
+	if limit > 0 {
+		log.Printf("Publishing %d events, then exiting", limit)
+	}
 
 	ticker := time.NewTicker(s.frequency)
 	defer ticker.Stop()
 
+	// A nil channel never fires, so reporting is off when no interval is set
+	var report <-chan time.Time
+	if s.reportInterval > 0 {
+		reportTicker := time.NewTicker(s.reportInterval)
+		defer reportTicker.Stop()
+		report = reportTicker.C
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	published := 0
 	for {
 		select {
 		case <-ticker.C:
@@ -178,6 +312,15 @@ func (s *SensorSimulator) Start() {
 			if err := s.publishEvent(event); err != nil {
 				log.Printf("Error publishing event: %v", err)
 			}
+
+			published++
+			if limit > 0 && published >= limit {
+				log.Printf("Published %d events, shutting down...", published)
+				s.Close()
+				return
+			}
+		case <-report:
+			s.delivery.Log()
 		case sig := <-sigChan:
 			log.Printf("Received signal %v, shutting down...", sig)
 			s.Close()
@@ -186,10 +329,29 @@ func (s *SensorSimulator) Start() {
 	}
 }
 
-// Close gracefully shuts down the simulator
+// Close gracefully shuts down the simulator, waiting up to the flush timeout for the
+// producer to deliver in-flight messages
 func (s *SensorSimulator) Close() {
 	log.Println("Closing sensor simulator...")
-	s.producer.Close()
+	s.delivery.Log()
+
+	done := make(chan error, 1)
+	go func() {
+		err := s.producer.Close()
+		if closeErr := s.client.Close(); err == nil {
+			err = closeErr
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("Error closing producer: %v", err)
+		}
+	case <-time.After(s.flushTimeout):
+		log.Printf("Producer did not flush within %v, exiting", s.flushTimeout)
+	}
 }
 
 // clamp constrains a value between min and max
@@ -228,15 +390,79 @@ func main() {
 		log.Fatalf("Invalid sensor frequency: %v", err)
 	}
 
-	log.Printf("Configuration: brokers=%s, topic=%s, machine=%s, frequency=%dms",
-		brokers, topic, machineID, frequency)
+	eventCount, err := strconv.Atoi(getEnvOrDefault("EVENT_COUNT", "0"))
+	if err != nil || eventCount < 0 {
+		log.Fatalf("Invalid event count: %q", os.Getenv("EVENT_COUNT"))
+	}
+
+	retries, err := strconv.Atoi(getEnvOrDefault("PRODUCER_RETRIES", "3"))
+	if err != nil {
+		log.Fatalf("Invalid producer retries: %v", err)
+	}
+
+	idempotent, err := strconv.ParseBool(getEnvOrDefault("PRODUCER_IDEMPOTENT", "false"))
+	if err != nil {
+		log.Fatalf("Invalid producer idempotence flag: %v", err)
+	}
+
+	publishRetries, err := strconv.Atoi(getEnvOrDefault("PUBLISH_RETRIES", "3"))
+	if err != nil {
+		log.Fatalf("Invalid publish retries: %v", err)
+	}
+
+	retryBackoff, err := time.ParseDuration(getEnvOrDefault("PUBLISH_RETRY_BACKOFF", "200ms"))
+	if err != nil {
+		log.Fatalf("Invalid publish retry backoff: %v", err)
+	}
+
+	reportInterval, err := time.ParseDuration(getEnvOrDefault("DELIVERY_REPORT_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatalf("Invalid delivery report interval: %v", err)
+	}
+
+	flushTimeout, err := time.ParseDuration(getEnvOrDefault("SHUTDOWN_FLUSH_TIMEOUT", "15s"))
+	if err != nil {
+		log.Fatalf("Invalid shutdown flush timeout: %v", err)
+	}
+
+	partitionMap, err := parsePartitionMap(os.Getenv("PARTITION_MAP"))
+	if err != nil {
+		log.Fatalf("Invalid partition map: %v", err)
+	}
+
+	settings := ProducerSettings{
+		Acks:       getEnvOrDefault("PRODUCER_ACKS", "all"),
+		Retries:    retries,
+		Idempotent: idempotent,
+
+		PublishRetries: publishRetries,
+		RetryBackoff:   retryBackoff,
+		ReportInterval: reportInterval,
+		FlushTimeout:   flushTimeout,
+
+		PartitionStrategy: getEnvOrDefault("PARTITION_STRATEGY", PartitionKeyed),
+		PartitionMap:      partitionMap,
+	}
+
+	profile, err := loadProfile(os.Getenv("SENSOR_PROFILE"))
+	if err != nil {
+		log.Fatalf("Invalid sensor profile: %v", err)
+	}
+
+	log.Printf("Configuration: brokers=%s, topic=%s, machine=%s, frequency=%dms, acks=%s, retries=%d, idempotent=%t, publish_retries=%d, partitioning=%s",
+		brokers, topic, machineID, frequency, settings.Acks, settings.Retries, settings.Idempotent, settings.PublishRetries, settings.PartitionStrategy)
 
 	// Create and start simulator
-	simulator, err := NewSensorSimulator(brokers, topic, machineID, time.Duration(frequency)*time.Millisecond)
+	simulator, err := NewSensorSimulator(brokers, topic, machineID, time.Duration(frequency)*time.Millisecond, settings, profile)
 	if err != nil {
 		log.Fatalf("Failed to create sensor simulator: %v", err)
 	}
 
 	// Start simulation
-	simulator.Start()
-}
\ No newline at end of file
+	simulator.Start(eventCount)
+
+	// A bounded run is usually a test fixture; fail it if any event was lost
+	if eventCount > 0 && simulator.delivery.failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func TestProducerConfigReflectsSettings(t *testing.T) {
+	for _, tc := range []struct {
+		settings ProducerSettings
+		acks     sarama.RequiredAcks
+	}{
+		{ProducerSettings{Acks: "all", Retries: 5, Idempotent: true}, sarama.WaitForAll},
+		{ProducerSettings{Acks: "leader", Retries: 2}, sarama.WaitForLocal},
+		{ProducerSettings{Acks: "none"}, sarama.NoResponse},
+	} {
+		config, _, err := newProducerConfig("conveyor_001", tc.settings)
+		if err != nil {
+			t.Fatalf("acks=%s: newProducerConfig: %v", tc.settings.Acks, err)
+		}
+
+		if config.Producer.RequiredAcks != tc.acks {
+			t.Errorf("acks=%s: RequiredAcks = %v, want %v", tc.settings.Acks, config.Producer.RequiredAcks, tc.acks)
+		}
+		if config.Producer.Retry.Max != tc.settings.Retries {
+			t.Errorf("acks=%s: Retry.Max = %d, want %d", tc.settings.Acks, config.Producer.Retry.Max, tc.settings.Retries)
+		}
+		if config.Producer.Idempotent != tc.settings.Idempotent {
+			t.Errorf("acks=%s: Idempotent = %v, want %v", tc.settings.Acks, config.Producer.Idempotent, tc.settings.Idempotent)
+		}
+	}
+}
+
+func TestProducerConfigRejectsIncompatibleIdempotence(t *testing.T) {
+	for _, settings := range []ProducerSettings{
+		{Acks: "leader", Retries: 3, Idempotent: true},
+		{Acks: "all", Retries: 0, Idempotent: true},
+		{Acks: "some", Retries: 3},
+	} {
+		if _, _, err := newProducerConfig("conveyor_001", settings); err == nil {
+			t.Errorf("settings %+v accepted", settings)
+		}
+	}
+}
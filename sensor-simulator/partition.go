@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// Partitioning strategies for produced messages. Both place all of a machine's events
+// on one partition, preserving their order.
+const (
+	PartitionKeyed = "keyed" // Hash of the machine ID message key
+	PartitionFixed = "fixed" // Explicit machine to partition mapping
+)
+
+// configurePartitioner sets the producer partitioner for the strategy and returns the
+// partition fixed for the machine, or -1 when partitions are chosen by key
+func configurePartitioner(config *sarama.Config, machineID string, settings ProducerSettings) (int32, error) {
+	switch settings.PartitionStrategy {
+	case "", PartitionKeyed:
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+		return -1, nil
+	case PartitionFixed:
+		partition, ok := settings.PartitionMap[machineID]
+		if !ok {
+			return 0, fmt.Errorf("fixed partitioning requires a partition for machine %s in PARTITION_MAP", machineID)
+		}
+		config.Producer.Partitioner = sarama.NewManualPartitioner
+		return partition, nil
+	default:
+		return 0, fmt.Errorf("invalid partition strategy %q (expected %s or %s)", settings.PartitionStrategy, PartitionKeyed, PartitionFixed)
+	}
+}
+
+// parsePartitionMap parses a comma-separated list of machine_id=partition pairs
+func parsePartitionMap(value string) (map[string]int32, error) {
+	partitions := make(map[string]int32)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		machineID, partition, ok := strings.Cut(pair, "=")
+		machineID = strings.TrimSpace(machineID)
+		if !ok || machineID == "" {
+			return nil, fmt.Errorf("expected machine_id=partition, got %q", pair)
+		}
+
+		number, err := strconv.ParseInt(strings.TrimSpace(partition), 10, 32)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid partition for machine %s: %q", machineID, partition)
+		}
+		partitions[machineID] = int32(number)
+	}
+	return partitions, nil
+}
+
+// resolvePartition looks up the topic's partitions and returns the one the machine's
+// events go to. Fixed partitions beyond the topic's partition count are rejected, so a
+// bad PARTITION_MAP fails at startup rather than on every send.
+func resolvePartition(client sarama.Client, topic, machineID string, fixed int32) (int32, int, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list partitions of topic %s: %v", topic, err)
+	}
+	count := len(partitions)
+	if count == 0 {
+		return 0, 0, fmt.Errorf("topic %s has no partitions", topic)
+	}
+
+	if fixed >= 0 {
+		if int(fixed) >= count {
+			return 0, count, fmt.Errorf("machine %s is mapped to partition %d but topic %s has %d partitions", machineID, fixed, topic, count)
+		}
+		return fixed, count, nil
+	}
+
+	// The same hash the producer applies to the machine ID key
+	message := &sarama.ProducerMessage{Topic: topic, Key: sarama.StringEncoder(machineID)}
+	partition, err := sarama.NewHashPartitioner(topic).Partition(message, int32(count))
+	if err != nil {
+		return 0, count, fmt.Errorf("failed to hash machine %s to a partition: %v", machineID, err)
+	}
+	return partition, count, nil
+}
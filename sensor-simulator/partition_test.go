@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+// partitionedClient reports a topic with a fixed number of partitions
+type partitionedClient struct {
+	sarama.Client
+	partitions int
+}
+
+func (c partitionedClient) Partitions(string) ([]int32, error) {
+	partitions := make([]int32, c.partitions)
+	for i := range partitions {
+		partitions[i] = int32(i)
+	}
+	return partitions, nil
+}
+
+func TestKeyedPartitioningMapsMachineToOnePartition(t *testing.T) {
+	config := sarama.NewConfig()
+	fixed, err := configurePartitioner(config, "conveyor_001", ProducerSettings{PartitionStrategy: PartitionKeyed})
+	if err != nil || fixed != -1 {
+		t.Fatalf("configurePartitioner = %d, %v, want partitioning by key", fixed, err)
+	}
+
+	for i := 0; i < 20; i++ {
+		machineID := fmt.Sprintf("conveyor_%03d", i)
+		want, _, err := resolvePartition(partitionedClient{partitions: 12}, "sensor-events", machineID, fixed)
+		if err != nil {
+			t.Fatalf("resolvePartition: %v", err)
+		}
+
+		// A fresh partitioner per message, as a restarted producer would have
+		for j := 0; j < 5; j++ {
+			message := &sarama.ProducerMessage{Topic: "sensor-events", Key: sarama.StringEncoder(machineID)}
+			partition, err := config.Producer.Partitioner("sensor-events").Partition(message, 12)
+			if err != nil {
+				t.Fatalf("Partition: %v", err)
+			}
+			if partition != want {
+				t.Errorf("%s sent to partition %d, want %d every time", machineID, partition, want)
+			}
+		}
+	}
+}
+
+func TestFixedPartitioningUsesPartitionMap(t *testing.T) {
+	settings := ProducerSettings{PartitionStrategy: PartitionFixed, PartitionMap: map[string]int32{"conveyor_001": 3}}
+
+	partition, err := configurePartitioner(sarama.NewConfig(), "conveyor_001", settings)
+	if err != nil || partition != 3 {
+		t.Errorf("configurePartitioner = %d, %v, want partition 3", partition, err)
+	}
+	if _, err := configurePartitioner(sarama.NewConfig(), "conveyor_002", settings); err == nil {
+		t.Error("unmapped machine accepted under fixed partitioning")
+	}
+	if _, _, err := resolvePartition(partitionedClient{partitions: 2}, "sensor-events", "conveyor_001", partition); err == nil {
+		t.Error("partition 3 accepted for a topic with 2 partitions")
+	}
+	if _, err := configurePartitioner(sarama.NewConfig(), "conveyor_001", ProducerSettings{PartitionStrategy: "random"}); err == nil {
+		t.Error("unknown partition strategy accepted")
+	}
+}
+
+func TestParsePartitionMap(t *testing.T) {
+	partitions, err := parsePartitionMap(" conveyor_001=0, robot_002 = 4 ,")
+	if err != nil {
+		t.Fatalf("parsePartitionMap: %v", err)
+	}
+	if want := map[string]int32{"conveyor_001": 0, "robot_002": 4}; !reflect.DeepEqual(partitions, want) {
+		t.Errorf("partitions = %v, want %v", partitions, want)
+	}
+
+	for _, value := range []string{"conveyor_001", "=1", "conveyor_001=-1", "conveyor_001=two"} {
+		if _, err := parsePartitionMap(value); err == nil {
+			t.Errorf("partition map %q accepted", value)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// MetricProfile describes how a single simulated metric behaves
+type MetricProfile struct {
+	Initial float64 `json:"initial"` // Starting value
+	Drift   float64 `json:"drift"`   // Maximum change per reading in either direction
+	Min     float64 `json:"min"`     // Lower clamp bound
+	Max     float64 `json:"max"`     // Upper clamp bound
+}
+
+// Correlation couples the other metrics' drift to conveyor speed. Each coefficient,
+// between -1 and 1, is the correlation of per-reading changes: -0.8 for temperature
+// means it usually rises as the conveyor slows under load. Zero drifts independently.
+type Correlation struct {
+	SpeedTemperature float64 `json:"speed_temperature"`
+	SpeedRobotArm    float64 `json:"speed_robot_arm"`
+}
+
+// SensorProfile groups the metric profiles for a simulated machine
+type SensorProfile struct {
+	ConveyorSpeed MetricProfile `json:"conveyor_speed"`
+	Temperature   MetricProfile `json:"temperature"`
+	RobotArmAngle MetricProfile `json:"robot_arm_angle"`
+	Correlation   Correlation   `json:"correlation"`
+}
+
+// defaultProfile returns the behaviour of a standard conveyor line
+func defaultProfile() SensorProfile {
+	return SensorProfile{
+		ConveyorSpeed: MetricProfile{Initial: 1.5, Drift: 0.1, Min: 0.5, Max: 3.0},
+		Temperature:   MetricProfile{Initial: 72.0, Drift: 1.0, Min: 20.0, Max: 80.0},
+		RobotArmAngle: MetricProfile{Initial: 90.0, Drift: 5.0, Min: 0.0, Max: 180.0},
+	}
+}
+
+// loadProfile builds the sensor profile from an optional JSON file, falling back to the
+// default profile for anything the file omits. INITIAL_* environment variables override
+// the starting values, and *_CORRELATION variables the coupling coefficients.
+func loadProfile(path string) (SensorProfile, error) {
+	profile := defaultProfile()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return profile, fmt.Errorf("failed to read sensor profile: %v", err)
+		}
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return profile, fmt.Errorf("failed to parse sensor profile: %v", err)
+		}
+	}
+
+	overrides := []struct {
+		key    string
+		metric *MetricProfile
+	}{
+		{"INITIAL_CONVEYOR_SPEED", &profile.ConveyorSpeed},
+		{"INITIAL_TEMPERATURE", &profile.Temperature},
+		{"INITIAL_ROBOT_ARM_ANGLE", &profile.RobotArmAngle},
+	}
+	for _, override := range overrides {
+		if value := os.Getenv(override.key); value != "" {
+			initial, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return profile, fmt.Errorf("invalid %s: %v", override.key, err)
+			}
+			override.metric.Initial = initial
+		}
+	}
+
+	couplings := []struct {
+		key         string
+		coefficient *float64
+	}{
+		{"SPEED_TEMPERATURE_CORRELATION", &profile.Correlation.SpeedTemperature},
+		{"SPEED_ROBOT_ARM_CORRELATION", &profile.Correlation.SpeedRobotArm},
+	}
+	for _, coupling := range couplings {
+		if value := os.Getenv(coupling.key); value != "" {
+			coefficient, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return profile, fmt.Errorf("invalid %s: %v", coupling.key, err)
+			}
+			*coupling.coefficient = coefficient
+		}
+	}
+
+	return profile, profile.Validate()
+}
+
+// Validate checks that every metric has sane bounds and a starting value within them
+func (p SensorProfile) Validate() error {
+	metrics := map[string]MetricProfile{
+		"conveyor_speed":  p.ConveyorSpeed,
+		"temperature":     p.Temperature,
+		"robot_arm_angle": p.RobotArmAngle,
+	}
+	for name, metric := range metrics {
+		if metric.Min >= metric.Max {
+			return fmt.Errorf("%s: min (%.2f) must be below max (%.2f)", name, metric.Min, metric.Max)
+		}
+		if metric.Drift < 0 {
+			return fmt.Errorf("%s: drift must not be negative", name)
+		}
+		if metric.Initial < metric.Min || metric.Initial > metric.Max {
+			return fmt.Errorf("%s: initial value %.2f outside bounds %.2f-%.2f", name, metric.Initial, metric.Min, metric.Max)
+		}
+	}
+
+	for name, coefficient := range map[string]float64{
+		"speed_temperature": p.Correlation.SpeedTemperature,
+		"speed_robot_arm":   p.Correlation.SpeedRobotArm,
+	} {
+		if coefficient < -1 || coefficient > 1 {
+			return fmt.Errorf("correlation %s: %.2f outside -1 to 1", name, coefficient)
+		}
+	}
+	return nil
+}
+
+// Step applies noise (between -1 and 1) times the metric's drift to value and clamps the
+// result to the metric's bounds
+func (m MetricProfile) Step(value, noise float64) float64 {
+	value += noise * m.Drift
+	return clamp(value, m.Min, m.Max)
+}
+
+// unitNoise returns uniform noise between -1 and 1
+func unitNoise() float64 {
+	return rand.Float64()*2 - 1
+}
+
+// correlatedNoise returns noise between -1 and 1 whose correlation with base is
+// coefficient. It mixes base with independent noise, then rescales so the result never
+// exceeds the range, which preserves the correlation.
+func correlatedNoise(base, coefficient float64) float64 {
+	own := math.Sqrt(1 - coefficient*coefficient)
+	return (coefficient*base + own*unitNoise()) / (math.Abs(coefficient) + own)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestSimulator returns a simulator generating fault-free readings for profile,
+// without a producer
+func newTestSimulator(profile SensorProfile) *SensorSimulator {
+	return &SensorSimulator{
+		machineID:     "conveyor_001",
+		profile:       profile,
+		conveyorSpeed: profile.ConveyorSpeed.Initial,
+		temperature:   profile.Temperature.Initial,
+		robotArmAngle: profile.RobotArmAngle.Initial,
+	}
+}
+
+// writeProfile writes a sensor profile file and returns its path
+func writeProfile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing profile: %v", err)
+	}
+	return path
+}
+
+func TestGeneratedValuesStayWithinProfileBounds(t *testing.T) {
+	path := writeProfile(t, `{
+		"conveyor_speed": {"initial": 2.0, "drift": 0.5, "min": 1.8, "max": 2.2},
+		"temperature": {"initial": 40, "drift": 3, "min": 38, "max": 42}
+	}`)
+	profile, err := loadProfile(path)
+	if err != nil {
+		t.Fatalf("loadProfile: %v", err)
+	}
+	if profile.RobotArmAngle != defaultProfile().RobotArmAngle {
+		t.Errorf("robot arm angle profile = %+v, want the default for an omitted metric", profile.RobotArmAngle)
+	}
+
+	simulator := newTestSimulator(profile)
+	for i := 0; i < 1000; i++ {
+		event := simulator.generateSensorEvent()
+		if event.ConveyorSpeed < 1.8 || event.ConveyorSpeed > 2.2 {
+			t.Fatalf("conveyor speed %.3f outside 1.8-2.2", event.ConveyorSpeed)
+		}
+		if event.Temperature < 38 || event.Temperature > 42 {
+			t.Fatalf("temperature %.3f outside 38-42", event.Temperature)
+		}
+		if event.RobotArmAngle < 0 || event.RobotArmAngle > 180 {
+			t.Fatalf("robot arm angle %.3f outside 0-180", event.RobotArmAngle)
+		}
+	}
+}
+
+func TestLoadProfileRejectsInvalidBounds(t *testing.T) {
+	for _, contents := range []string{
+		`{"temperature": {"initial": 40, "drift": 1, "min": 50, "max": 45}}`,
+		`{"temperature": {"initial": 60, "drift": 1, "min": 20, "max": 50}}`,
+		`{"conveyor_speed": {"initial": 1, "drift": -0.1, "min": 0, "max": 2}}`,
+		`{"correlation": {"speed_temperature": -1.5}}`,
+	} {
+		if _, err := loadProfile(writeProfile(t, contents)); err == nil {
+			t.Errorf("profile %s accepted", contents)
+		}
+	}
+}
+
+// changeCorrelation returns the Pearson correlation of the per-reading changes of two series
+func changeCorrelation(a, b []float64) float64 {
+	n := float64(len(a) - 1)
+	var sumA, sumB, sumAA, sumBB, sumAB float64
+	for i := 1; i < len(a); i++ {
+		da, db := a[i]-a[i-1], b[i]-b[i-1]
+		sumA, sumB = sumA+da, sumB+db
+		sumAA, sumBB, sumAB = sumAA+da*da, sumBB+db*db, sumAB+da*db
+	}
+	covariance := sumAB/n - sumA/n*sumB/n
+	return covariance / math.Sqrt((sumAA/n-sumA/n*sumA/n)*(sumBB/n-sumB/n*sumB/n))
+}
+
+func TestGeneratedChangesFollowConfiguredCorrelation(t *testing.T) {
+	// Bounds wide enough that clamping never distorts the changes
+	wide := MetricProfile{Initial: 0, Drift: 10, Min: -1e9, Max: 1e9}
+	for _, tc := range []struct {
+		speedTemperature, speedRobotArm float64
+	}{
+		{-0.8, 0},
+		{0.5, -0.3},
+	} {
+		simulator := newTestSimulator(SensorProfile{
+			ConveyorSpeed: wide,
+			Temperature:   wide,
+			RobotArmAngle: wide,
+			Correlation:   Correlation{SpeedTemperature: tc.speedTemperature, SpeedRobotArm: tc.speedRobotArm},
+		})
+
+		var speeds, temperatures, angles []float64
+		for i := 0; i < 20000; i++ {
+			event := simulator.generateSensorEvent()
+			speeds = append(speeds, event.ConveyorSpeed)
+			temperatures = append(temperatures, event.Temperature)
+			angles = append(angles, event.RobotArmAngle)
+		}
+
+		if got := changeCorrelation(speeds, temperatures); math.Abs(got-tc.speedTemperature) > 0.05 {
+			t.Errorf("speed/temperature correlation = %.3f, want %.2f", got, tc.speedTemperature)
+		}
+		if got := changeCorrelation(speeds, angles); math.Abs(got-tc.speedRobotArm) > 0.05 {
+			t.Errorf("speed/robot arm correlation = %.3f, want %.2f", got, tc.speedRobotArm)
+		}
+	}
+}